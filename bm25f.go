@@ -0,0 +1,296 @@
+package gcse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/daviddengcn/gcse/configs"
+)
+
+// The fields of a hit scored by BM25F.
+const (
+	BM25FName = iota
+	BM25FPackage
+	BM25FSynopsis
+	BM25FSentences
+	BM25FExported
+	BM25FReadme
+
+	BM25FFieldCount
+)
+
+// BM25FFieldNames are the names of the fields in the configuration.
+var BM25FFieldNames = [BM25FFieldCount]string{
+	"name", "package", "synopsis", "sentences", "exported", "readme",
+}
+
+// matchScoreFloor is the match score of a hit per query token, added to the
+// BM25F score, so that the hits matched only by the text not scored, e.g.
+// the description, are still ordered by their static scores.
+const matchScoreFloor = 0.02
+
+// maxReadmeTerms is the maximum number of the most frequent words of the
+// readme kept in a HitInfo, which is in memory when served. The readme is
+// scored by these words only.
+const maxReadmeTerms = 64
+
+// fieldTexts returns the texts of the fields of a hit, except the readme
+// which is not kept in the hit.
+func fieldTexts(hit *HitInfo) [BM25FFieldCount]string {
+	var texts [BM25FFieldCount]string
+	texts[BM25FName] = hit.Name
+	texts[BM25FPackage] = removeHost(hit.Package)
+	texts[BM25FSynopsis] = hit.Synopsis
+	texts[BM25FSentences] = strings.Join(hit.ImportantSentences, " ")
+	texts[BM25FExported] = strings.Join(hit.Exported, " ")
+	return texts
+}
+
+// isWordPair returns whether token is a pair of adjacent words, e.g.
+// "web-framework", which never contain separators otherwise.
+func isWordPair(token string) bool {
+	return strings.Contains(token, "-")
+}
+
+// setBM25FFields sets FieldLens and ReadmeTerms of hit, whose other fields
+// are all set, with the text of the readme. All the tokens of the readme are
+// counted in its length, but, to keep the hits served in memory small, only
+// the maxReadmeTerms most frequent words are kept in ReadmeTerms. The pairs of
+// words are never kept; the words of a pair are scored separately.
+func setBM25FFields(hit *HitInfo, readme string) {
+	hit.FieldLens = make([]int, BM25FFieldCount)
+	for f, text := range fieldTexts(hit) {
+		_, hit.FieldLens[f] = CountTokens(nil, []byte(text))
+	}
+	hit.ReadmeTerms = nil
+	counts := make(map[string]int)
+	forEachToken([]byte(readme), func(token string) {
+		hit.FieldLens[BM25FReadme]++
+		if !isWordPair(token) {
+			counts[token]++
+		}
+	})
+	if len(counts) == 0 {
+		return
+	}
+	terms := make([]string, 0, len(counts))
+	for t := range counts {
+		terms = append(terms, t)
+	}
+	if len(terms) > maxReadmeTerms {
+		sort.Slice(terms, func(i, j int) bool {
+			if counts[terms[i]] != counts[terms[j]] {
+				return counts[terms[i]] > counts[terms[j]]
+			}
+			return terms[i] < terms[j]
+		})
+		terms = terms[:maxReadmeTerms]
+	}
+	hit.ReadmeTerms = make(map[string]int, len(terms))
+	for _, t := range terms {
+		hit.ReadmeTerms[t] = counts[t]
+	}
+}
+
+// BM25FField is the parameters of a field.
+type BM25FField struct {
+	Weight float64
+	// The degree of the normalization by the field length, in [0, 1].
+	B float64
+	// The average length of the field over all the hits.
+	AvgLen float64
+}
+
+// BM25F scores the matches of the hits to a query.
+type BM25F struct {
+	K1     float64
+	Fields [BM25FFieldCount]BM25FField
+}
+
+// Scoring is the parameters of the scores of the search results, see
+// configs.RankingBM25FK1 and the following ones for the meanings.
+type Scoring struct {
+	K1 float64
+	// By BM25FFieldNames.
+	Weights map[string]float64
+	B       map[string]float64
+
+	Blend        string
+	StaticExp    float64
+	StaticWeight float64
+}
+
+// ConfiguredScoring returns the Scoring in configs.
+func ConfiguredScoring() *Scoring {
+	sc := &Scoring{
+		K1:           configs.RankingBM25FK1,
+		Weights:      make(map[string]float64),
+		B:            make(map[string]float64),
+		Blend:        configs.RankingBlend,
+		StaticExp:    configs.RankingStaticExp,
+		StaticWeight: configs.RankingStaticWeight,
+	}
+	for k, v := range configs.RankingBM25FWeights {
+		sc.Weights[k] = v
+	}
+	for k, v := range configs.RankingBM25FB {
+		sc.B[k] = v
+	}
+	return sc
+}
+
+// NewBM25F returns a BM25F with the parameters of sc and the average lengths
+// of the fields, indexed by the BM25F* constants.
+func (sc *Scoring) NewBM25F(avgLens []float64) *BM25F {
+	s := &BM25F{K1: sc.K1}
+	for f, name := range BM25FFieldNames {
+		s.Fields[f] = BM25FField{
+			Weight: sc.Weights[name],
+			B:      sc.B[name],
+		}
+		if f < len(avgLens) {
+			s.Fields[f].AvgLen = avgLens[f]
+		}
+	}
+	return s
+}
+
+// AvgFieldLens returns the average lengths of the fields of the hits visited
+// by forEach.
+func AvgFieldLens(forEach func(func(hit *HitInfo))) []float64 {
+	sums := make([]float64, BM25FFieldCount)
+	n := 0
+	forEach(func(hit *HitInfo) {
+		n++
+		for f := 0; f < BM25FFieldCount && f < len(hit.FieldLens); f++ {
+			sums[f] += float64(hit.FieldLens[f])
+		}
+	})
+	if n > 0 {
+		for f := range sums {
+			sums[f] /= float64(n)
+		}
+	}
+	return sums
+}
+
+// BM25FIdf returns the inverse document frequency of a token in df of N
+// docs.
+func BM25FIdf(df, N int) float64 {
+	return math.Log(1 + (float64(N)-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// FieldMatch is the contribution of a field to the frequency of a token.
+type FieldMatch struct {
+	Field string
+	Count int
+	// The normalization by the field length, Count*Weight/Norm is
+	// contributed.
+	Weight float64
+	Norm   float64
+}
+
+// TokenMatch is the contribution of a query token to a match score.
+type TokenMatch struct {
+	Token  string
+	IDF    float64
+	Fields []FieldMatch `json:",omitempty"`
+	// The weighted frequency over the fields, and the contribution to the
+	// score, IDF*TF/(K1+TF).
+	TF    float64
+	Score float64
+}
+
+// MatchExplanation is the breakdown of a BM25F score by the query tokens.
+type MatchExplanation struct {
+	K1 float64
+	// matchScoreFloor times the number of tokens.
+	Floor  float64
+	Tokens []TokenMatch
+	Score  float64
+}
+
+// Score returns the BM25F score of hit for the query tokens, with idfs their
+// inverse document frequencies, plus matchScoreFloor per token. The score is
+// 1 for an empty query.
+func (s *BM25F) Score(hit *HitInfo, tokens []string, idfs []float64) float64 {
+	return s.score(hit, tokens, idfs, nil)
+}
+
+// Explain returns the breakdown of the Score of hit.
+func (s *BM25F) Explain(hit *HitInfo, tokens []string, idfs []float64) *MatchExplanation {
+	e := &MatchExplanation{K1: s.K1}
+	e.Score = s.score(hit, tokens, idfs, e)
+	return e
+}
+
+// score returns the BM25F score of hit, and appends the contributions of the
+// tokens to e if not nil.
+func (s *BM25F) score(hit *HitInfo, tokens []string, idfs []float64, e *MatchExplanation) float64 {
+	if len(tokens) == 0 {
+		return 1
+	}
+	var counts [BM25FFieldCount]map[string]int
+	for f, text := range fieldTexts(hit) {
+		if text != "" && s.Fields[f].Weight != 0 {
+			counts[f], _ = CountTokens(nil, []byte(text))
+		}
+	}
+	counts[BM25FReadme] = hit.ReadmeTerms
+
+	score := matchScoreFloor * float64(len(tokens))
+	if e != nil {
+		e.Floor = score
+	}
+	for i, token := range tokens {
+		var m *TokenMatch
+		if e != nil {
+			e.Tokens = append(e.Tokens, TokenMatch{Token: token, IDF: idfs[i]})
+			m = &e.Tokens[len(e.Tokens)-1]
+		}
+		tf := 0.
+		for f := range s.Fields {
+			cnt := counts[f][token]
+			if cnt == 0 {
+				continue
+			}
+			fld := &s.Fields[f]
+			norm := 1.
+			if fld.AvgLen > 0 && f < len(hit.FieldLens) {
+				norm = 1 - fld.B + fld.B*float64(hit.FieldLens[f])/fld.AvgLen
+			}
+			tf += fld.Weight * float64(cnt) / norm
+			if m != nil {
+				m.Fields = append(m.Fields, FieldMatch{Field: BM25FFieldNames[f], Count: cnt, Weight: fld.Weight, Norm: norm})
+			}
+		}
+		if tf > 0 {
+			ts := idfs[i] * tf / (s.K1 + tf)
+			score += ts
+			if m != nil {
+				m.TF, m.Score = tf, ts
+			}
+		}
+	}
+	return score
+}
+
+// BlendFormula returns the formula of BlendScores.
+func (sc *Scoring) BlendFormula() string {
+	if sc.Blend == "linear" {
+		return fmt.Sprintf("%g*log(1+static) + %g*match", sc.StaticWeight, 1-sc.StaticWeight)
+	}
+	return fmt.Sprintf("static^%g * match", sc.StaticExp)
+}
+
+// BlendScores returns the score of a search result of the static score and
+// the match score.
+func (sc *Scoring) BlendScores(static, match float64) float64 {
+	if sc.Blend == "linear" {
+		w := sc.StaticWeight
+		return w*math.Log1p(math.Max(static, 0)) + (1-w)*match
+	}
+	return math.Pow(math.Max(static, 0), sc.StaticExp) * match
+}
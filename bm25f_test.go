@@ -0,0 +1,132 @@
+package gcse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func TestCountTokens(t *testing.T) {
+	counts, total := CountTokens(nil, []byte("go web web-framework"))
+	assert.Equal(t, "total", total, 5)
+	assert.Equal(t, "counts", counts, map[string]int{
+		"go": 1, "web": 2, "framework": 1, "web-framework": 1,
+	})
+}
+
+func TestSetBM25FFields(t *testing.T) {
+	hit := &HitInfo{DocInfo: DocInfo{
+		Name:     "mux",
+		Package:  "github.com/gorilla/mux",
+		Synopsis: "A powerful URL router",
+		Exported: []string{"Router", "Route"},
+	}}
+	setBM25FFields(hit, "router router mux")
+	// A camel-case word, e.g. "Router", is counted as a part and as a whole.
+	assert.Equal(t, "FieldLens", hit.FieldLens, []int{1, 3, 4, 0, 4, 3})
+	assert.Equal(t, "ReadmeTerms", hit.ReadmeTerms, map[string]int{"router": 2, "mux": 1})
+
+	// The words of a short readme are all kept, but not the pairs of words.
+	readme := strings.Repeat("a and of to is with for this that it ", 10) + "use gorilla mux as the router"
+	setBM25FFields(hit, readme)
+	assert.Equal(t, "ReadmeTerms[mux]", hit.ReadmeTerms["mux"], 1)
+	assert.Equal(t, "ReadmeTerms[gorilla]", hit.ReadmeTerms["gorilla"], 1)
+	assert.Equal(t, "ReadmeTerms[and]", hit.ReadmeTerms["and"], 10)
+	assert.Equal(t, "ReadmeTerms[gorilla-mux]", hit.ReadmeTerms["gorilla-mux"], 0)
+	_, total := CountTokens(nil, []byte(readme))
+	assert.Equal(t, "FieldLens[readme]", hit.FieldLens[BM25FReadme], total)
+
+	// Only the most frequent words of a long readme are kept, but all of them
+	// are counted in its length.
+	var words []string
+	for i := 0; i < 2*maxReadmeTerms; i++ {
+		words = append(words, fmt.Sprintf("zq%c%c", 'a'+i/16, 'a'+i%16))
+	}
+	readme = strings.Repeat("router ", 3) + strings.Join(words, " ")
+	setBM25FFields(hit, readme)
+	assert.Equal(t, "len(ReadmeTerms)", len(hit.ReadmeTerms), maxReadmeTerms)
+	assert.Equal(t, "ReadmeTerms[router]", hit.ReadmeTerms["router"], 3)
+	_, total = CountTokens(nil, []byte(readme))
+	assert.Equal(t, "FieldLens[readme]", hit.FieldLens[BM25FReadme], total)
+}
+
+func TestBM25F_Score(t *testing.T) {
+	newHit := func(name, synopsis, readme string) *HitInfo {
+		hit := &HitInfo{DocInfo: DocInfo{
+			Name:     name,
+			Package:  "github.com/x/" + name,
+			Synopsis: synopsis,
+		}}
+		setBM25FFields(hit, readme)
+		return hit
+	}
+	hits := []*HitInfo{
+		newHit("router", "A router.", ""),
+		newHit("web", "A web framework.", "Contains a router."),
+		newHit("web2", "A web framework with a router, of routers.", ""),
+		newHit("other", "Other.", ""),
+	}
+	s := ConfiguredScoring().NewBM25F(AvgFieldLens(func(f func(hit *HitInfo)) {
+		for _, hit := range hits {
+			f(hit)
+		}
+	}))
+	tokens := []string{"router"}
+	idfs := []float64{BM25FIdf(3, 4)}
+
+	scores := make([]float64, len(hits))
+	for i, hit := range hits {
+		scores[i] = s.Score(hit, tokens, idfs)
+	}
+	assert.True(t, "name match first", scores[0] > scores[1] && scores[0] > scores[2])
+	assert.True(t, "readme match scored", scores[1] > 0)
+	assert.Equal(t, "no match", scores[3], matchScoreFloor)
+	assert.Equal(t, "empty query", s.Score(hits[3], nil, nil), 1.)
+
+	// The term frequency saturates.
+	assert.True(t, "bounded", scores[0] < idfs[0])
+}
+
+func TestScoring_BlendScores(t *testing.T) {
+	sc := ConfiguredScoring()
+	sc.Blend = "product"
+	assert.True(t, "product", sc.BlendScores(2, 3) > sc.BlendScores(1, 3))
+	assert.Equal(t, "product of 0", sc.BlendScores(0, 3), 0.)
+
+	sc.Blend = "linear"
+	assert.True(t, "linear of 0", sc.BlendScores(0, 3) > 0)
+	assert.True(t, "linear", sc.BlendScores(2, 3) > sc.BlendScores(1, 3))
+}
+
+func TestBM25F_Explain(t *testing.T) {
+	hit := &HitInfo{DocInfo: DocInfo{
+		Name:     "router",
+		Package:  "github.com/x/router",
+		Synopsis: "A fast router.",
+	}}
+	setBM25FFields(hit, "")
+	s := ConfiguredScoring().NewBM25F(nil)
+	tokens, idfs := []string{"router", "fast", "slow"}, []float64{1, 2, 3}
+
+	e := s.Explain(hit, tokens, idfs)
+	assert.Equal(t, "Score", e.Score, s.Score(hit, tokens, idfs))
+	assert.Equal(t, "len(Tokens)", len(e.Tokens), 3)
+	assert.Equal(t, "Floor", e.Floor, 3*matchScoreFloor)
+	sum := e.Floor
+	for i, m := range e.Tokens {
+		assert.Equal(t, "Token", m.Token, tokens[i])
+		assert.Equal(t, "IDF", m.IDF, idfs[i])
+		sum += m.Score
+	}
+	assert.Equal(t, "sum", sum, e.Score)
+
+	var fields []string
+	for _, f := range e.Tokens[0].Fields {
+		fields = append(fields, f.Field)
+	}
+	assert.StringEqual(t, "router fields", fields, []string{"name", "package", "synopsis"})
+	assert.Equal(t, "fast fields", len(e.Tokens[1].Fields), 1)
+	assert.Equal(t, "slow", e.Tokens[2], TokenMatch{Token: "slow", IDF: 3})
+}
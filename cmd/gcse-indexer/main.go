@@ -1,23 +1,20 @@
 package main
 
 import (
+	"flag"
 	"log"
 
-	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/pipeline/indexer"
 )
 
 func main() {
-	log.Println("indexer started...")
+	var opts indexer.Options
+	flag.BoolVar(&opts.Full, "full", false, "Build a full index even if a delta of the last one is enough")
+	flag.BoolVar(&opts.SkipValidation, "skip-validation", false, "Serve the new segment even if it fails the validation against the last good one")
+	flag.Parse()
 
-	if err := configs.IndexSegments().ClearUndones(); err != nil {
-		log.Printf("Indexer: ClearUndones failed: %v", err)
-	}
-
-	if err := clearOutdatedIndex(); err != nil {
-		log.Printf("Indexer: clearOutdatedIndex failed: %v", err)
-	}
-	if !doIndex() {
-		log.Fatal("Indexer encountered one or more problems")
+	if _, err := indexer.Run(opts); err != nil {
+		log.Fatalf("Indexer encountered one or more problems: %v", err)
 	}
 
 	log.Println("Indexer finished OK, exiting.")
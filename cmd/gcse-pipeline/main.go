@@ -0,0 +1,139 @@
+/*
+GCSE pipeline daemon, running tocrawl, crawler, mergedocs and indexer in a
+loop.
+
+Each stage is run in a subprocess of this program with -stage, so that a
+timed out stage can be killed and a crashing one does not bring down the
+daemon.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/pipeline"
+	"github.com/daviddengcn/go-villa"
+)
+
+// stageResult is written by the subprocess running a stage to the -result
+// file.
+type stageResult struct {
+	Counts map[string]int64
+	Error  string `json:",omitempty"`
+}
+
+// runStage runs a stage in this process and writes the result to resultFn.
+func runStage(name string, resultFn villa.Path) error {
+	st := pipeline.FindStage(pipeline.Stages(), name)
+	if st == nil {
+		return errorsp.NewWithStacks("unknown stage %q", name)
+	}
+	counts, err := st.Run(context.Background())
+	res := stageResult{Counts: counts}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if resultFn != "" {
+		bs, jerr := json.Marshal(res)
+		if jerr != nil {
+			return errorsp.WithStacks(jerr)
+		}
+		if werr := resultFn.WriteFile(bs, 0644); werr != nil {
+			return errorsp.WithStacks(werr)
+		}
+	}
+	return err
+}
+
+// execStage runs a stage in a subprocess, which is killed when ctx is done.
+func execStage(ctx context.Context, st pipeline.Stage) (map[string]int64, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	resultFn := configs.PipelinePath().Join(st.Name + ".result")
+	resultFn.Remove()
+	defer resultFn.Remove()
+
+	cmd := exec.CommandContext(ctx, self, "-stage", st.Name, "-result", resultFn.S())
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, errorsp.WithStacks(ctx.Err())
+	}
+
+	var res stageResult
+	bs, err := resultFn.ReadFile()
+	if err != nil {
+		if runErr != nil {
+			return nil, errorsp.WithStacksAndMessage(runErr, "stage %s exited without a result", st.Name)
+		}
+		return nil, errorsp.WithStacks(err)
+	}
+	if err := json.Unmarshal(bs, &res); err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	if res.Error != "" {
+		return res.Counts, errorsp.NewWithStacks("%s", res.Error)
+	}
+	return res.Counts, errorsp.WithStacks(runErr)
+}
+
+func main() {
+	var (
+		stage    = flag.String("stage", "", "Run a single stage in this process and exit, one of tocrawl, crawler, mergedocs and indexer")
+		resultFn = flag.String("result", "", "The file to write the result of -stage to")
+		once     = flag.Bool("once", false, "Run the pipeline once and exit")
+		addr     = flag.String("addr", configs.PipelineAddr, "addr to serve the status, none if empty")
+	)
+	flag.Parse()
+
+	if *stage != "" {
+		if err := runStage(*stage, villa.Path(*resultFn)); err != nil {
+			log.Fatalf("Stage %s failed: %v", *stage, err)
+		}
+		return
+	}
+
+	r := &pipeline.Runner{
+		Dir:        configs.PipelinePath(),
+		Stages:     pipeline.Stages(),
+		Timeouts:   configs.PipelineStageTimeouts,
+		Retries:    configs.PipelineRetries,
+		RetryDelay: configs.PipelineRetryDelay,
+		KeepRuns:   configs.PipelineKeepRuns,
+		Exec:       execStage,
+	}
+
+	if *addr != "" && !*once {
+		go func() {
+			log.Printf("Serving the status on %s", *addr)
+			if err := http.ListenAndServe(*addr, r); err != nil {
+				log.Fatalf("ListenAndServe failed: %v", err)
+			}
+		}()
+	}
+
+	for {
+		if rec, err := r.RunOnce(context.Background()); err != nil {
+			log.Printf("Run failed: %v", err)
+		} else {
+			log.Printf("Run %s finished in %v", rec.ID, rec.End.Sub(rec.Start))
+		}
+		if *once {
+			return
+		}
+		log.Printf("Sleeping %v before the next run", configs.PipelineInterval)
+		time.Sleep(configs.PipelineInterval)
+	}
+}
@@ -1,45 +1,48 @@
 package main
 
 import (
-	"context"
 	"flag"
 	"net"
+	"time"
 
 	"github.com/daviddengcn/gcse/configs"
 	"github.com/daviddengcn/gcse/store"
-	"github.com/daviddengcn/gcse/utils"
 	"github.com/golang/glog"
 	"google.golang.org/grpc"
 
 	gpb "github.com/daviddengcn/gcse/shared/proto"
 )
 
-type server struct {
-}
-
-var _ gpb.StoreServiceServer = (*server)(nil)
-
-func (s *server) PackageCrawlHistory(_ context.Context, req *gpb.PackageCrawlHistoryReq) (*gpb.PackageCrawlHistoryResp, error) {
-	site, path := utils.SplitPackage(req.Package)
-	info, err := store.ReadPackageHistory(site, path)
-	if err != nil {
-		glog.Errorf("ReadPackageHistoryOf %q %q failed: %v", site, path, err)
-		return nil, err
+// compactChanges deletes the changes older than configs.StoreChangeRetention
+// every interval.
+func compactChanges(interval time.Duration) {
+	for ; ; time.Sleep(interval) {
+		n, err := store.CompactChanges(time.Now().Add(-configs.StoreChangeRetention))
+		if err != nil {
+			glog.Errorf("CompactChanges failed: %v", err)
+			continue
+		}
+		glog.Infof("%d changes compacted", n)
 	}
-	return &gpb.PackageCrawlHistoryResp{Info: info}, nil
 }
 
 func main() {
 	addr := flag.String("addr", configs.StoreDAddr, "addr to listen")
+	compactInterval := flag.Duration("compact_interval", time.Hour, "interval of compacting the change log")
 
 	flag.Parse()
 
+	if err := store.Open(); err != nil {
+		glog.Fatalf("Opening the store failed: %v", err)
+	}
+	go compactChanges(*compactInterval)
+
 	glog.Infof("Starting listener on %s", *addr)
 	lis, err := net.Listen("tcp", *addr)
 	if err != nil {
 		glog.Fatalf("Failed to start listener: %v", err)
 	}
 	grpcServer := grpc.NewServer()
-	gpb.RegisterStoreServiceServer(grpcServer, &server{})
+	gpb.RegisterStoreServiceServer(grpcServer, store.NewServer())
 	grpcServer.Serve(lis)
 }
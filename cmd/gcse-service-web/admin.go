@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/utils"
+)
+
+// indexStatus is the response of pageAdminIndex.
+type indexStatus struct {
+	Segments []string
+	Pinned   string `json:",omitempty"`
+	Served   string
+	Loaded   string
+}
+
+func segmentName(segm utils.Segment) string {
+	if segm == "" {
+		return ""
+	}
+	return segm.Name()
+}
+
+func currentIndexStatus() (*indexStatus, error) {
+	segms := configs.IndexSegments()
+	dones, err := gcse.DoneIndexSegments(segms)
+	if err != nil {
+		return nil, err
+	}
+	pinned, err := gcse.PinnedIndexSegment(segms)
+	if err != nil {
+		return nil, err
+	}
+	served, err := gcse.ServedIndexSegment(segms)
+	if err != nil {
+		return nil, err
+	}
+	st := &indexStatus{
+		Pinned: segmentName(pinned),
+		Served: segmentName(served),
+	}
+	for _, s := range dones {
+		st.Segments = append(st.Segments, s.Name())
+	}
+	indexMu.Lock()
+	st.Loaded = segmentName(indexSegment)
+	indexMu.Unlock()
+	return st, nil
+}
+
+// pageAdminIndex pins, unpins or rolls back the served index segment, or
+// shows the status of the segments:
+//
+//	/admin/index?pass=<pass>&action=status
+//	/admin/index?pass=<pass>&action=pin&segment=<name>
+//	/admin/index?pass=<pass>&action=unpin
+//	/admin/index?pass=<pass>&action=rollback
+//
+// rollback pins the latest good segment older than the served one. The actions
+// are disabled if configs.AdminPass is empty.
+func pageAdminIndex(w http.ResponseWriter, r *http.Request) {
+	if configs.AdminPass == "" || r.FormValue("pass") != configs.AdminPass {
+		http.Error(w, "Incorrect password!", http.StatusForbidden)
+		return
+	}
+	segms := configs.IndexSegments()
+	var err error
+	switch action := strings.ToLower(r.FormValue("action")); action {
+	case "", "status":
+	case "pin":
+		err = gcse.PinIndexSegment(segms, r.FormValue("segment"))
+	case "unpin":
+		err = gcse.UnpinIndexSegment(segms)
+	case "rollback":
+		var served, prev utils.Segment
+		if served, err = gcse.ServedIndexSegment(segms); err != nil {
+			break
+		}
+		if prev, err = gcse.PreviousIndexSegment(segms, served); err != nil {
+			break
+		}
+		if prev == "" {
+			http.Error(w, "No segment older than "+segmentName(served)+" to roll back to", http.StatusConflict)
+			return
+		}
+		err = gcse.PinIndexSegment(segms, prev.Name())
+	default:
+		http.Error(w, "Unknown action: "+action, http.StatusBadRequest)
+		return
+	}
+	if err == nil {
+		err = loadIndex()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	st, err := currentIndexStatus()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
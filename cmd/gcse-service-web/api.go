@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/golangplus/bytes"
@@ -34,12 +35,14 @@ func filterFunc(s string, f func(r rune) bool) string {
 }
 
 type SearchApiHit struct {
-	Name        string `json:"name"`
-	Package     string `json:"package"`
-	Author      string `json:"author"`
-	Synopsis    string `json:"synopsis"`
-	Description string `json:"description"`
-	ProjectURL  string `json:"projecturl"`
+	Name        string  `json:"name"`
+	Package     string  `json:"package"`
+	Author      string  `json:"author"`
+	Synopsis    string  `json:"synopsis"`
+	Description string  `json:"description"`
+	ProjectURL  string  `json:"projecturl"`
+	License     string  `json:"license,omitempty"`
+	PageRank    float64 `json:"pagerank"`
 }
 
 type SearchApiStruct struct {
@@ -64,6 +67,8 @@ func SearchResultToApi(q string, res *SearchResult) *SearchApiStruct {
 			Synopsis:    hit.Synopsis,
 			Description: hit.Description,
 			ProjectURL:  hit.ProjectURL,
+			License:     hit.License,
+			PageRank:    hit.PageRank,
 		}
 		apiRes.Hits = append(apiRes.Hits, apiHit)
 	}
@@ -140,6 +145,16 @@ func pageApi(w http.ResponseWriter, r *http.Request) {
 			TestImports  []string
 			ProjectURL   string
 			StaticRank   int
+			PageRank     float64
+			License      string
+			ForkedFrom   string
+			ForkOf       string
+			Archived     bool
+			Deprecated   string
+			Retracted    []string
+			Quality      gcse.QualityInfo
+			Activity     time.Time
+			Release      time.Time
 		}{
 			doc.Package,
 			doc.Name,
@@ -152,8 +167,33 @@ func pageApi(w http.ResponseWriter, r *http.Request) {
 			doc.TestImports,
 			doc.ProjectURL,
 			doc.StaticRank + 1,
+			doc.PageRank,
+			doc.License,
+			doc.ForkedFrom,
+			doc.ForkOf,
+			doc.Archived,
+			doc.Deprecated,
+			doc.Retracted,
+			doc.Quality,
+			doc.LatestActivity(),
+			doc.LatestRelease,
 		}, callback)
 
+	case "explain":
+		bi.Inc("api.explain")
+		id := r.FormValue("id")
+
+		e, found, err := explainHit(getDatabase(), r.FormValue("q"), id)
+		if err != nil {
+			apiContent(w, http.StatusInternalServerError, err.Error(), callback)
+			return
+		}
+		if !found {
+			apiContent(w, http.StatusNotFound, fmt.Sprintf("Package %s not found!", id), callback)
+			return
+		}
+		apiContent(w, http.StatusOK, e, callback)
+
 	case "tops":
 		bi.Inc("api.tops")
 		N, _ := strconv.Atoi(r.FormValue("len"))
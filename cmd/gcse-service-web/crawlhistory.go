@@ -11,6 +11,9 @@ import (
 	gpb "github.com/daviddengcn/gcse/shared/proto"
 )
 
+// The number of the events in a page of the crawl history.
+const crawlHistoryPageSize = 50
+
 func (s *server) pageCrawlHistory(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -18,7 +21,9 @@ func (s *server) pageCrawlHistory(w http.ResponseWriter, r *http.Request) {
 
 	pkg := strings.ToLower(r.FormValue("id"))
 	resp, err := s.storeClient.PackageCrawlHistory(ctx, &gpb.PackageCrawlHistoryReq{
-		Package: pkg,
+		Package:   pkg,
+		PageSize:  crawlHistoryPageSize,
+		PageToken: r.FormValue("p"),
 	})
 	if err != nil {
 		glog.Errorf("PackageCrawlHistory %q failed: %v", pkg, err)
@@ -27,15 +32,37 @@ func (s *server) pageCrawlHistory(w http.ResponseWriter, r *http.Request) {
 	}
 	hi := resp.Info
 	type Event struct {
-		Time   time.Time
-		Action string
+		Time           time.Time
+		Action         string
+		FailureReason  string
+		Etag           string
+		CrawlerVersion int32
 	}
-	events := make([]Event, 0, len(hi.Events))
-	for _, e := range hi.Events {
+	events := make([]Event, 0, len(resp.Events))
+	for _, e := range resp.Events {
 		t, _ := ptypes.Timestamp(e.Timestamp)
 		events = append(events, Event{
-			Time:   t,
-			Action: e.Action.String(),
+			Time:           t,
+			Action:         e.Action.String(),
+			FailureReason:  e.FailureReason,
+			Etag:           e.Etag,
+			CrawlerVersion: e.CrawlerVersion,
+		})
+	}
+	type Rollup struct {
+		Day                      time.Time
+		Success, Failed, Invalid int32
+	}
+	// The rollups are shown, newest first, after the last page of the events.
+	var rollups []Rollup
+	for i := len(hi.Rollups) - 1; i >= 0 && resp.NextPageToken == ""; i-- {
+		ru := hi.Rollups[i]
+		day, _ := ptypes.Timestamp(ru.Day)
+		rollups = append(rollups, Rollup{
+			Day:     day,
+			Success: ru.Success,
+			Failed:  ru.Failed,
+			Invalid: ru.Invalid,
 		})
 	}
 	var foundTm, succTm, failedTm *time.Time
@@ -44,26 +71,32 @@ func (s *server) pageCrawlHistory(w http.ResponseWriter, r *http.Request) {
 		*foundTm, _ = ptypes.Timestamp(hi.FoundTime)
 	}
 	if hi.LatestSuccess != nil {
-		succTm := &time.Time{}
+		succTm = &time.Time{}
 		*succTm, _ = ptypes.Timestamp(hi.LatestSuccess)
 	}
 	if hi.LatestFailed != nil {
-		failedTm := &time.Time{}
+		failedTm = &time.Time{}
 		*failedTm, _ = ptypes.Timestamp(hi.LatestFailed)
 	}
 	if err := templates.ExecuteTemplate(w, "crawlhistory.html", struct {
 		UIUtils
+		ID            string
 		FoundTime     *time.Time
 		FoundWay      string
 		LatestSuccess *time.Time
 		LatestFailed  *time.Time
 		Events        []Event
+		NextPage      string
+		Rollups       []Rollup
 	}{
+		ID:            pkg,
 		FoundTime:     foundTm,
 		FoundWay:      hi.FoundWay,
 		LatestSuccess: succTm,
 		LatestFailed:  failedTm,
 		Events:        events,
+		NextPage:      resp.NextPageToken,
+		Rollups:       rollups,
 	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
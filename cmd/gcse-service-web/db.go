@@ -1,10 +1,10 @@
 package main
 
 import (
-	"errors"
 	"log"
 	"os"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -15,18 +15,21 @@ import (
 	"github.com/daviddengcn/gcse/configs"
 	"github.com/daviddengcn/gcse/utils"
 	"github.com/daviddengcn/go-easybi"
-	"github.com/daviddengcn/go-index"
 )
 
 var (
 	databaseValue atomic.Value
-	indexSegment  utils.Segment
+	// Guards indexSegment and the exchanges of the database.
+	indexMu      sync.Mutex
+	indexSegment utils.Segment
 )
 
 type database interface {
 	PackageCount() int
 	ProjectCount() int
 	IndexUpdated() time.Time
+	// The average lengths of the fields of the packages for BM25F.
+	AvgFieldLens() []float64
 	Close()
 
 	FindFullPackage(id string) (hit gcse.HitInfo, found bool)
@@ -35,9 +38,10 @@ type database interface {
 	Search(q map[string]stringsp.Set, out func(docID int32, data interface{}) error) error
 }
 
+// searcherDB is the index served, with the project count and the store of
+// its segment.
 type searcherDB struct {
-	ts   index.TokenSetSearcher
-	hits *index.ConstArrayReader
+	*gcse.IndexReader
 
 	projectCount int
 	indexUpdated time.Time
@@ -45,144 +49,95 @@ type searcherDB struct {
 	storeDB *bh.RefCountBox
 }
 
-func (db *searcherDB) PackageCount() int {
-	if db == nil {
-		return 0
-	}
-	return db.ts.DocCount()
-}
-
 func (db *searcherDB) ProjectCount() int {
-	if db == nil {
-		return 0
-	}
 	return db.projectCount
 }
 
 func (db *searcherDB) IndexUpdated() time.Time {
-	if db == nil {
+	if db.IndexReader == nil {
 		return time.Now()
 	}
 	return db.indexUpdated
 }
 
-func (db *searcherDB) Close() {
-	if db == nil {
-		return
-	}
-	db.hits.Close()
-}
-
-var notFoundInHits = errors.New("Not found in hits")
-
-func (db *searcherDB) FindFullPackage(id string) (gcse.HitInfo, bool) {
-	if db == nil {
-		log.Print("Database not loaded!")
-		return gcse.HitInfo{}, false
-	}
-	var hit gcse.HitInfo
-	found := false
-	if err := db.ts.Search(index.SingleFieldQuery(gcse.IndexPkgField, id), func(docID int32, _ interface{}) error {
-		h, err := db.hits.GetGob(int(docID))
-		if err != nil {
-			return err
-		}
-		hit = h.(gcse.HitInfo)
-		found = true
-		return nil
-	}); err != nil {
-		return gcse.HitInfo{}, false
-	}
-	if !found {
-		return gcse.HitInfo{}, false
-	}
-	return hit, true
-}
-
-func (db *searcherDB) ForEachFullPackage(out func(gcse.HitInfo) error) error {
-	if db == nil {
-		return nil
-	}
-	return db.hits.ForEachGob(func(_ int, hit interface{}) error {
-		return out(hit.(gcse.HitInfo))
-	})
-}
-
-func (db *searcherDB) PackageCountOfToken(field, token string) int {
-	if db == nil {
-		return 0
-	}
-	return len(db.ts.TokenDocList(field, token))
-}
-
-func (db *searcherDB) Search(q map[string]stringsp.Set, out func(docID int32, data interface{}) error) error {
-	if db == nil {
-		return nil
-	}
-	return db.ts.Search(q, out)
-}
-
 func getDatabase() database {
 	db, ok := databaseValue.Load().(database)
 	if !ok {
-		return (*searcherDB)(nil)
+		return &searcherDB{}
 	}
 	return db
 }
 
-func loadIndex() error {
-	segm, err := configs.IndexSegments().FindMaxDone()
-	if segm == "" || err != nil {
-		return err
-	}
-	if indexSegment != "" && !utils.SegmentLess(indexSegment, segm) {
-		// no new index
-		return nil
+// openSearcherDB opens the index in segm, with its full index if it is a
+// delta. The full index of cur is reused if it is the same one.
+func openSearcherDB(segm utils.Segment, cur *searcherDB) (*searcherDB, error) {
+	var curReader *gcse.IndexReader
+	if cur != nil {
+		curReader = cur.IndexReader
 	}
-	db := &searcherDB{}
-	if err := func() error {
-		f, err := os.Open(segm.Join(gcse.IndexFn))
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		return db.ts.Load(f)
-	}(); err != nil {
-		return err
+	reader, err := gcse.OpenIndexReader(segm, curReader)
+	if err != nil {
+		return nil, err
 	}
+	db := &searcherDB{IndexReader: reader}
 	db.storeDB = &bh.RefCountBox{
 		DataPath: func() string {
 			return segm.Join(configs.FnStore)
 		},
 	}
-	hitsPath := segm.Join(gcse.HitsArrFn)
-	if db.hits, err = index.OpenConstArray(hitsPath); err != nil {
-		log.Printf("OpenConstArray %v failed: %v", hitsPath, err)
-		return err
-	}
 	// Calculate db.projectCount
 	var projects stringsp.Set
-	db.ts.Search(nil, func(docID int32, data interface{}) error {
-		hit := data.(gcse.HitInfo)
-		projects.Add(hit.ProjectURL)
+	db.Search(nil, func(_ int32, data interface{}) error {
+		projects.Add(data.(gcse.HitInfo).ProjectURL)
 		return nil
 	})
 	db.projectCount = len(projects)
-	gcse.AddBiValueAndProcess(bi.Max, "index.proj-count", db.projectCount)
 
 	// Update db.indexUpdated
 	db.indexUpdated = time.Now()
 	if st, err := os.Stat(segm.Join(gcse.IndexFn)); err == nil {
 		db.indexUpdated = st.ModTime()
 	}
-	indexSegment = segm
-	log.Printf("Load index from %v (%d packages)", segm, db.PackageCount())
+	return db, nil
+}
+
+// loadIndex loads the segment to serve, the pinned one or the latest done
+// one, if it is not the one loaded.
+func loadIndex() error {
+	indexMu.Lock()
+	defer indexMu.Unlock()
 
-	// Exchange new/old database and close the old one.
+	segm, err := gcse.ServedIndexSegment(configs.IndexSegments())
+	if segm == "" || err != nil {
+		return err
+	}
+	if segm == indexSegment {
+		// no new index
+		return nil
+	}
 	oldDB := getDatabase()
+	cur, _ := oldDB.(*searcherDB)
+	db, err := openSearcherDB(segm, cur)
+	if err != nil {
+		return err
+	}
+	gcse.AddBiValueAndProcess(bi.Max, "index.proj-count", db.projectCount)
+
+	indexSegment = segm
+	if base := db.BaseSegment(); base != segm {
+		log.Printf("Load index from %v with delta %v (%d packages)", base, segm, db.PackageCount())
+	} else {
+		log.Printf("Load index from %v (%d packages)", segm, db.PackageCount())
+	}
+
+	// Exchange new/old database and close the old one, except the full index
+	// shared with the new one.
 	databaseValue.Store(db)
-	oldDB.Close()
+	if cur != nil {
+		cur.CloseExcept(db.IndexReader)
+	} else {
+		oldDB.Close()
+	}
 	oldDB = nil
 	utils.DumpMemStats()
 
@@ -1,9 +1,19 @@
 package main
 
 import (
+	"io"
+	"os"
+	"sort"
 	"testing"
 
+	"github.com/daviddengcn/go-villa"
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/mr"
+	"github.com/golangplus/strings"
 	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/utils"
 )
 
 func TestFindFullPackage_NotFound(t *testing.T) {
@@ -11,3 +21,129 @@ func TestFindFullPackage_NotFound(t *testing.T) {
 	_, found := db.FindFullPackage("abc")
 	assert.False(t, "found", found)
 }
+
+func docsInput(docs []gcse.DocInfo) mr.Input {
+	return &mr.InputStruct{
+		PartCountF: func() (int, error) {
+			return 1, nil
+		},
+		IteratorF: func(int) (sophie.IterateCloser, error) {
+			index := 0
+			return &sophie.IterateCloserStruct{
+				NextF: func(key, val sophie.SophieReader) error {
+					if index >= len(docs) {
+						return io.EOF
+					}
+					*key.(*sophie.RawString) = sophie.RawString(docs[index].Package)
+					*val.(*gcse.DocInfo) = docs[index]
+					val.(*gcse.DocInfo).Imports = append([]string{}, docs[index].Imports...)
+					index++
+					return nil
+				},
+			}, nil
+		},
+	}
+}
+
+// buildIndex indexes docs into segm, a delta of base if base is not empty.
+func buildIndex(t *testing.T, docs []gcse.DocInfo, segm, base utils.Segment) {
+	assert.NoErrorOrDie(t, segm.Make())
+	var ts interface{ Save(io.Writer) error }
+	if base == "" {
+		idx, err := gcse.Index(docsInput(docs), string(segm))
+		assert.NoErrorOrDie(t, err)
+		ts = idx
+	} else {
+		idx, _, err := gcse.IndexDelta(docsInput(docs), string(base), string(segm))
+		assert.NoErrorOrDie(t, err)
+		ts = idx
+	}
+	f, err := os.Create(segm.Join(gcse.IndexFn))
+	assert.NoErrorOrDie(t, err)
+	defer f.Close()
+	assert.NoErrorOrDie(t, ts.Save(f))
+}
+
+func TestSearcherDB_Delta(t *testing.T) {
+	const (
+		pkgJSON  = "github.com/a/json"
+		pkgYAML  = "github.com/b/yaml"
+		pkgOld   = "github.com/c/old"
+		pkgApp   = "github.com/d/app"
+		pkgJSON2 = "github.com/e/json2"
+	)
+	tmpPath := villa.Path(os.TempDir()).Join("gcse_searcherdb_testing")
+	assert.NoError(t, tmpPath.RemoveAll())
+	defer tmpPath.RemoveAll()
+	segms := utils.Segments(tmpPath.S())
+
+	docs := []gcse.DocInfo{
+		{Package: pkgJSON, Name: "json", Description: "Package json parses JSON."},
+		{Package: pkgYAML, Name: "yaml", Description: "Package yaml parses YAML."},
+		{Package: pkgOld, Name: "old", Description: "Package old parses JSON, too."},
+		{Package: pkgApp, Name: "main", Imports: []string{pkgJSON, pkgYAML}},
+	}
+	buildIndex(t, docs, segms.Join("0"), "")
+
+	// old is deleted, json2 is added, and app imports json2 instead of yaml.
+	docs = []gcse.DocInfo{
+		docs[0], docs[1],
+		{Package: pkgApp, Name: "main", Imports: []string{pkgJSON, pkgJSON2}},
+		{Package: pkgJSON2, Name: "json2", Description: "Package json2 parses JSON faster."},
+	}
+	buildIndex(t, docs, segms.Join("1"), segms.Join("0"))
+
+	db, err := openSearcherDB(segms.Join("1"), nil)
+	assert.NoErrorOrDie(t, err)
+	defer db.Close()
+	assert.Equal(t, "PackageCount", db.PackageCount(), 4)
+
+	var pkgs []string
+	lastScore := -1.
+	assert.NoError(t, db.Search(nil, func(_ int32, data interface{}) error {
+		hit := data.(gcse.HitInfo)
+		if lastScore >= 0 {
+			assert.True(t, "descending scores", hit.StaticScore <= lastScore)
+		}
+		lastScore = hit.StaticScore
+		pkgs = append(pkgs, hit.Package)
+		return nil
+	}))
+	sort.Strings(pkgs)
+	assert.Equal(t, "pkgs", pkgs, []string{pkgJSON, pkgYAML, pkgApp, pkgJSON2})
+
+	var jsonPkgs []string
+	assert.NoError(t, db.Search(map[string]stringsp.Set{gcse.IndexTextField: stringsp.NewSet("json")}, func(_ int32, data interface{}) error {
+		jsonPkgs = append(jsonPkgs, data.(gcse.HitInfo).Package)
+		return nil
+	}))
+	sort.Strings(jsonPkgs)
+	assert.Equal(t, "jsonPkgs", jsonPkgs, []string{pkgJSON, pkgJSON2})
+	assert.Equal(t, "PackageCountOfToken", db.PackageCountOfToken(gcse.IndexTextField, "json"), 2)
+
+	_, found := db.FindFullPackage(pkgOld)
+	assert.False(t, "found old", found)
+	hit, found := db.FindFullPackage(pkgYAML)
+	assert.True(t, "found yaml", found)
+	assert.Equal(t, "yaml.ImportedLen", hit.ImportedLen, 0)
+	hit, found = db.FindFullPackage(pkgJSON)
+	assert.True(t, "found json", found)
+	assert.Equal(t, "json.ImportedLen", hit.ImportedLen, 1)
+
+	var full []string
+	assert.NoError(t, db.ForEachFullPackage(func(hit gcse.HitInfo) error {
+		full = append(full, hit.Package)
+		return nil
+	}))
+	sort.Strings(full)
+	assert.Equal(t, "full", full, []string{pkgJSON, pkgYAML, pkgApp, pkgJSON2})
+
+	// A newer delta shares the loaded full index.
+	buildIndex(t, docs[:3], segms.Join("2"), segms.Join("0"))
+	db2, err := openSearcherDB(segms.Join("2"), db)
+	assert.NoErrorOrDie(t, err)
+	assert.True(t, "base shared", db2.SharesBase(db.IndexReader))
+	assert.Equal(t, "PackageCount", db2.PackageCount(), 3)
+	db.CloseExcept(db2.IndexReader)
+	db = db2
+}
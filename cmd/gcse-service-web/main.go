@@ -72,6 +72,7 @@ func init() {
 	http.HandleFunc("/loadtemplates", pageLoadTemplate)
 	http.HandleFunc("/badge", pageBadge)
 	http.HandleFunc("/badgepage", pageBadgePage)
+	http.HandleFunc("/admin/index", pageAdminIndex)
 	bi.HandleRequest(configs.BiWebPath)
 
 	http.HandleFunc("/", pageRoot)
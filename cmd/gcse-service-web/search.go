@@ -3,7 +3,6 @@ package main
 import (
 	"html/template"
 	"log"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -15,15 +14,12 @@ import (
 	"golang.org/x/net/trace"
 
 	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/spider/license"
 	"github.com/daviddengcn/go-easybi"
 	"github.com/daviddengcn/go-index"
 )
 
-type Hit struct {
-	gcse.HitInfo
-	MatchScore float64
-	Score      float64
-}
+type Hit = gcse.ScoredHit
 
 type SearchResult struct {
 	TotalResults int
@@ -34,102 +30,99 @@ var stopWords = stringsp.NewSet(
 	"the", "on", "in", "as",
 )
 
-func idf(df, N int) float64 {
-	if df < 1 {
-		df = 1
+// queryFilters are the filters given as "<name>:<value>" words in a query.
+// A "-" prefix excludes the matched packages instead, e.g. "-license:gpl".
+type queryFilters struct {
+	// Normalized license tokens, see license.Tokens.
+	licenses         stringsp.Set
+	excludedLicenses stringsp.Set
+	// "is:deprecated" keeps only the deprecated packages, "-is:deprecated"
+	// excludes them.
+	onlyDeprecated     bool
+	excludedDeprecated bool
+}
+
+// parseQuery extracts the filters from q and returns the remaining text.
+func parseQuery(q string) (string, queryFilters) {
+	var filters queryFilters
+	var words []string
+	for _, word := range strings.Fields(q) {
+		lower := strings.ToLower(word)
+		if v, ok := stringsp.MatchPrefix(lower, "license:"); ok && v != "" {
+			filters.licenses.Add(license.NormalizeID(v))
+			continue
+		}
+		if v, ok := stringsp.MatchPrefix(lower, "-license:"); ok && v != "" {
+			filters.excludedLicenses.Add(license.NormalizeID(v))
+			continue
+		}
+		switch lower {
+		case "is:deprecated":
+			filters.onlyDeprecated = true
+			continue
+		case "-is:deprecated":
+			filters.excludedDeprecated = true
+			continue
+		}
+		words = append(words, word)
 	}
-	idf := math.Log(float64(N) / float64(df))
-	if idf > 1 {
-		idf = math.Sqrt(idf)
+	return strings.Join(words, " "), filters
+}
+
+// match returns true if the hit passes all filters.
+func (f *queryFilters) match(hit *gcse.HitInfo) bool {
+	deprecated := hit.Deprecated != ""
+	if f.onlyDeprecated && !deprecated || f.excludedDeprecated && deprecated {
+		return false
+	}
+	if len(f.licenses) == 0 && len(f.excludedLicenses) == 0 {
+		return true
+	}
+	tokens := license.Tokens(hit.License)
+	if len(f.licenses) > 0 && !containAny(f.licenses, tokens) {
+		return false
+	}
+	return !containAny(f.excludedLicenses, tokens)
+}
+
+func containAny(set stringsp.Set, elements []string) bool {
+	for _, e := range elements {
+		if set.Contain(e) {
+			return true
+		}
 	}
-	return idf
+	return false
 }
 
 func search(tr trace.Trace, db database, q string) (*SearchResult, stringsp.Set, error) {
+	q, filters := parseQuery(q)
 	tokens := gcse.AppendTokens(nil, []byte(q))
-	tokenList := tokens.Elements()
 	log.Printf("tokens for query %s: %v", q, tokens)
 
-	var hits []*Hit
-
-	N := db.PackageCount()
-	textIdfs := make([]float64, len(tokenList))
-	nameIdfs := make([]float64, len(tokenList))
-	for i := range textIdfs {
-		textIdfs[i] = idf(db.PackageCountOfToken(gcse.IndexTextField, tokenList[i]), N)
-		nameIdfs[i] = idf(db.PackageCountOfToken(gcse.IndexNameField, tokenList[i]), N)
-	}
-
-	db.Search(map[string]stringsp.Set{gcse.IndexTextField: tokens},
-		func(docID int32, data interface{}) error {
-			hit := &Hit{}
-			var ok bool
-			hit.HitInfo, ok = data.(gcse.HitInfo)
-			if !ok {
-				log.Print("ok = false")
-			}
-
-			hit.MatchScore = gcse.CalcMatchScore(&hit.HitInfo, tokenList, textIdfs, nameIdfs)
-			hit.Score = math.Max(hit.StaticScore, hit.TestStaticScore) * hit.MatchScore
-
-			hits = append(hits, hit)
-			return nil
-		})
-	tr.LazyPrintf("Got %d hits for query %q", len(hits), q)
-
-	swapHits := func(i, j int) {
-		hits[i], hits[j] = hits[j], hits[i]
-	}
-	sortp.SortF(len(hits), func(i, j int) bool {
-		// true if doc i is before doc j
-		ssi, ssj := hits[i].Score, hits[j].Score
-		if ssi > ssj {
-			return true
-		}
-		if ssi < ssj {
-			return false
-		}
-		sci, scj := hits[i].StarCount, hits[j].StarCount
-		if sci > scj {
-			return true
-		}
-		if sci < scj {
-			return false
-		}
-		pi, pj := hits[i].Package, hits[j].Package
-		if len(pi) < len(pj) {
-			return true
-		}
-		if len(pi) > len(pj) {
-			return false
-		}
-		return pi < pj
-	}, swapHits)
-
-	tr.LazyPrintf("Results sorted")
-
-	if len(hits) < 5000 {
-		// Adjust Score by down ranking duplicated packages
-		pkgCount := make(map[string]int)
-		for _, hit := range hits {
-			cnt := pkgCount[hit.Name] + 1
-			pkgCount[hit.Name] = cnt
-			if cnt > 1 && hit.ImportedLen == 0 && hit.TestImportedLen == 0 {
-				hit.Score /= float64(cnt)
-			}
-		}
-		// Re-sort
-		sortp.BubbleF(len(hits), func(i, j int) bool {
-			return hits[i].Score > hits[j].Score
-		}, swapHits)
-		tr.LazyPrintf("Results reranked")
+	hits, err := gcse.SearchAndRank(db, tokens, filters.match, gcse.ConfiguredScoring())
+	if err != nil {
+		return nil, nil, err
 	}
+	tr.LazyPrintf("Got %d hits for query %q, ranked", len(hits), q)
 	return &SearchResult{
 		TotalResults: len(hits),
 		Hits:         hits,
 	}, tokens, nil
 }
 
+// explainHit returns the breakdown of the score of the package id for the
+// query q, found is false if the package is not in db.
+func explainHit(db database, q, id string) (e *gcse.HitExplanation, found bool, err error) {
+	hit, found := db.FindFullPackage(id)
+	if !found {
+		return nil, false, nil
+	}
+	q, filters := parseQuery(q)
+	tokens := gcse.AppendTokens(nil, []byte(q))
+	e, err = gcse.ExplainHit(db, tokens, filters.match, gcse.ConfiguredScoring(), &hit)
+	return e, true, err
+}
+
 func splitToLines(text string) []string {
 	lines := strings.Split(text, "\n")
 	newLines := make([]string, 0, len(lines))
@@ -232,6 +225,14 @@ type ShowDocInfo struct {
 	MarkedName    template.HTML
 	MarkedPackage template.HTML
 	Subs          []SubProjectInfo
+	Forks         []ForkInfo
+}
+
+// ForkInfo is a copy of a package in a fork or a mirror, folded into the
+// canonical package in the search results.
+type ForkInfo struct {
+	Package  string
+	Archived bool
 }
 
 type ShowResults struct {
@@ -288,38 +289,111 @@ func packageShowName(name, pkg string) string {
 	return "(" + prj + ")"
 }
 
+// forkRoots returns the ends of the chains of ForkOf of the forks in hits, by
+// the packages of the forks. Only the chains within hits count, and those
+// ending in a loop are ignored.
+func forkRoots(hits []*Hit) map[string]string {
+	forkOf := make(map[string]string)
+	for _, d := range hits {
+		forkOf[d.Package] = d.ForkOf
+	}
+	roots := make(map[string]string)
+	for _, d := range hits {
+		pkg, steps := d.Package, 0
+		for ; steps <= len(hits); steps++ {
+			next, ok := forkOf[pkg]
+			if !ok || next == "" {
+				break
+			}
+			if _, ok := forkOf[next]; !ok {
+				break
+			}
+			pkg = next
+		}
+		if pkg != d.Package && steps <= len(hits) {
+			roots[d.Package] = pkg
+		}
+	}
+	return roots
+}
+
+// parentEntry returns the nearest ancestor of pkg in its project for which
+// isEntry returns true, and the path of pkg relative to it. An empty parent
+// is returned if none.
+func parentEntry(pkg string, isEntry func(string) bool) (parent, subPath string) {
+	parts := strings.Split(pkg, "/")
+	for i := len(parts) - 1; i >= 2; i-- {
+		if p := strings.Join(parts[:i], "/"); isEntry(p) {
+			return p, "/" + strings.Join(parts[i:], "/")
+		}
+	}
+	return "", ""
+}
+
 func showSearchResults(db database, results *SearchResult, tokens stringsp.Set, r Range) *ShowResults {
 	docs := make([]ShowDocInfo, 0, len(results.Hits))
 
 	projToIdx := make(map[string]int)
 	folded := 0
 
+	// Copies of packages in forks and mirrors are folded into the entry of the
+	// canonical package, i.e. the end of their chain of ForkOf, if it is also
+	// in the results. The entry is the canonical package itself, or its parent
+	// if it is folded as a subpackage, which is found beforehand since the
+	// forks fold nothing. The forks appearing before the entry are kept in
+	// pendingForks until it shows up.
+	roots := forkRoots(results.Hits)
+	entryOf := make(map[string]string)
+	for _, d := range results.Hits {
+		if _, ok := roots[d.Package]; ok {
+			continue
+		}
+		parent, _ := parentEntry(d.Package, func(pkg string) bool {
+			return entryOf[pkg] == pkg
+		})
+		if parent == "" {
+			parent = d.Package
+		}
+		entryOf[d.Package] = parent
+	}
+	pendingForks := make(map[string][]ForkInfo)
+
 	cnt := 0
-mainLoop:
 	for _, d := range results.Hits {
 		d.Name = packageShowName(d.Name, d.Package)
 
-		parts := strings.Split(d.Package, "/")
-		if len(parts) > 2 {
-			// try fold it (if its parent has been in the list)
-			for i := len(parts) - 1; i >= 2; i-- {
-				pkg := strings.Join(parts[:i], "/")
-				if idx, ok := projToIdx[pkg]; ok {
-					markedName := markText(d.Name, tokens, markWord)
-					if r.In(idx) {
-						docsIdx := idx - r.start
-						docs[docsIdx].Subs = append(docs[docsIdx].Subs,
-							SubProjectInfo{
-								MarkedName: markedName,
-								Package:    d.Package,
-								SubPath:    "/" + strings.Join(parts[i:], "/"),
-								Info:       d.Synopsis,
-							})
-					}
-					folded++
-					continue mainLoop
+		if root, ok := roots[d.Package]; ok {
+			entry := entryOf[root]
+			fork := ForkInfo{Package: d.Package, Archived: d.Archived}
+			if idx, ok := projToIdx[entry]; ok {
+				if r.In(idx) {
+					docsIdx := idx - r.start
+					docs[docsIdx].Forks = append(docs[docsIdx].Forks, fork)
 				}
+			} else {
+				pendingForks[entry] = append(pendingForks[entry], fork)
 			}
+			folded++
+			continue
+		}
+
+		if parent, subPath := parentEntry(d.Package, func(pkg string) bool {
+			_, ok := projToIdx[pkg]
+			return ok
+		}); parent != "" {
+			// Fold it into its parent in the list.
+			if idx := projToIdx[parent]; r.In(idx) {
+				docsIdx := idx - r.start
+				docs[docsIdx].Subs = append(docs[docsIdx].Subs,
+					SubProjectInfo{
+						MarkedName: markText(d.Name, tokens, markWord),
+						Package:    d.Package,
+						SubPath:    subPath,
+						Info:       d.Synopsis,
+					})
+			}
+			folded++
+			continue
 		}
 		projToIdx[d.Package] = cnt
 		if r.In(cnt) {
@@ -348,6 +422,7 @@ mainLoop:
 				MarkedName:    markedName,
 				Summary:       markText(raw, tokens, markWord),
 				MarkedPackage: markText(d.Package, tokens, markWord),
+				Forks:         pendingForks[d.Package],
 			})
 		}
 		cnt++
@@ -386,6 +461,17 @@ func pageSearch(w http.ResponseWriter, r *http.Request) {
 	tr.LazyPrintf("Search success with %d hits and %d tokens", len(results.Hits), len(tokens))
 	showResults := showSearchResults(db, results, tokens, Range{(p - 1) * itemsPerPage, itemsPerPage})
 	tr.LazyPrintf("showSearchResults with %d results", len(showResults.Docs))
+	// The debug panel of the ranking of a package.
+	var explain *gcse.HitExplanation
+	if id := strings.TrimSpace(r.FormValue("explain")); id != "" {
+		e, found, err := explainHit(db, q, id)
+		if err != nil {
+			tr.LazyPrintf("explainHit failed: %v", err)
+		} else if found {
+			explain = e
+		}
+		tr.LazyPrintf("explainHit %v: found %v", id, found)
+	}
 	totalPages := (showResults.TotalEntries + itemsPerPage - 1) / itemsPerPage
 	log.Printf("totalPages: %d", totalPages)
 	var beforePages, afterPages []int
@@ -427,6 +513,7 @@ func pageSearch(w http.ResponseWriter, r *http.Request) {
 		AfterPages  []int
 		BottomQ     bool
 		TotalPages  int
+		Explain     *gcse.HitExplanation
 	}{
 		Q:           q,
 		Results:     showResults,
@@ -438,6 +525,7 @@ func pageSearch(w http.ResponseWriter, r *http.Request) {
 		AfterPages:  afterPages,
 		BottomQ:     len(results.Hits) >= 5,
 		TotalPages:  totalPages,
+		Explain:     explain,
 	}
 	log.Printf("Search results ready")
 	err = templates.ExecuteTemplate(w, "search.html", data)
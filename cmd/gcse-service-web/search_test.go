@@ -0,0 +1,187 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/daviddengcn/go-villa"
+	"github.com/golangplus/strings"
+	"github.com/golangplus/testing/assert"
+	"golang.org/x/net/trace"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/utils"
+)
+
+func TestParseQuery(t *testing.T) {
+	text, filters := parseQuery("json  License:MIT parser -license:GPL")
+	assert.Equal(t, "text", text, "json parser")
+	assert.Equal(t, "licenses", filters.licenses, stringsp.NewSet("mit"))
+	assert.Equal(t, "excludedLicenses", filters.excludedLicenses, stringsp.NewSet("gpl"))
+}
+
+func TestQueryFilters_License(t *testing.T) {
+	hit := func(license string) *gcse.HitInfo {
+		return &gcse.HitInfo{DocInfo: gcse.DocInfo{License: license}}
+	}
+	_, noFilter := parseQuery("json")
+	assert.True(t, "no filter", noFilter.match(hit("")))
+
+	_, filters := parseQuery("json license:gpl")
+	assert.True(t, "GPL-3.0", filters.match(hit("GPL-3.0")))
+	assert.False(t, "LGPL-3.0", filters.match(hit("LGPL-3.0")))
+	assert.False(t, "unknown", filters.match(hit("")))
+
+	_, filters = parseQuery("json -license:gpl")
+	assert.False(t, "GPL-2.0", filters.match(hit("GPL-2.0")))
+	assert.True(t, "MIT", filters.match(hit("MIT")))
+	assert.True(t, "unknown", filters.match(hit("")))
+}
+
+func TestQueryFilters_Deprecated(t *testing.T) {
+	hit := func(deprecated string) *gcse.HitInfo {
+		return &gcse.HitInfo{DocInfo: gcse.DocInfo{Deprecated: deprecated}}
+	}
+	text, filters := parseQuery("json -is:deprecated")
+	assert.Equal(t, "text", text, "json")
+	assert.False(t, "deprecated", filters.match(hit("Use encoding/json instead.")))
+	assert.True(t, "not deprecated", filters.match(hit("")))
+
+	_, filters = parseQuery("json is:deprecated")
+	assert.True(t, "deprecated", filters.match(hit("Use encoding/json instead.")))
+	assert.False(t, "not deprecated", filters.match(hit("")))
+}
+
+func TestShowSearchResults_Forks(t *testing.T) {
+	hit := func(pkg, forkOf string) *Hit {
+		return &Hit{HitInfo: gcse.HitInfo{
+			DocInfo: gcse.DocInfo{Package: pkg, Name: "lib"},
+			ForkOf:  forkOf,
+		}}
+	}
+	results := &SearchResult{
+		TotalResults: 4,
+		Hits: []*Hit{
+			hit("github.com/b/lib", "github.com/a/lib"),
+			hit("github.com/a/lib", ""),
+			hit("github.com/c/lib", "github.com/a/lib"),
+			hit("github.com/d/lib", "github.com/x/lib"),
+		},
+	}
+	shown := showSearchResults(&searcherDB{}, results, nil, Range{0, 10})
+	assert.Equal(t, "TotalEntries", shown.TotalEntries, 2)
+	assert.Equal(t, "Folded", shown.Folded, 2)
+	assert.Equal(t, "Docs[0].Package", shown.Docs[0].Package, "github.com/a/lib")
+	assert.Equal(t, "Docs[0].Forks", shown.Docs[0].Forks, []ForkInfo{
+		{Package: "github.com/b/lib"}, {Package: "github.com/c/lib"},
+	})
+	assert.Equal(t, "Docs[1].Package", shown.Docs[1].Package, "github.com/d/lib")
+}
+
+func TestShowSearchResults_ForkChain(t *testing.T) {
+	hit := func(pkg, forkOf string) *Hit {
+		return &Hit{HitInfo: gcse.HitInfo{
+			DocInfo: gcse.DocInfo{Package: pkg, Name: "lib"},
+			ForkOf:  forkOf,
+		}}
+	}
+	results := &SearchResult{
+		TotalResults: 8,
+		Hits: []*Hit{
+			// c is a fork of b, which is a fork of a.
+			hit("github.com/c/lib", "github.com/b/lib"),
+			hit("github.com/b/lib", "github.com/a/lib"),
+			hit("github.com/a/lib", ""),
+			// The canonical package is folded into its parent.
+			hit("github.com/e/x/sub", "github.com/d/x/sub"),
+			hit("github.com/d/x", ""),
+			hit("github.com/d/x/sub", ""),
+			// A loop of forks is shown as is.
+			hit("github.com/f/lib", "github.com/g/lib"),
+			hit("github.com/g/lib", "github.com/f/lib"),
+		},
+	}
+	shown := showSearchResults(&searcherDB{}, results, nil, Range{0, 10})
+	var pkgs []string
+	for _, doc := range shown.Docs {
+		pkgs = append(pkgs, doc.Package)
+	}
+	assert.Equal(t, "pkgs", pkgs, []string{"github.com/a/lib", "github.com/d/x", "github.com/f/lib", "github.com/g/lib"})
+	assert.Equal(t, "Folded", shown.Folded, 4)
+	assert.Equal(t, "Docs[0].Forks", shown.Docs[0].Forks, []ForkInfo{
+		{Package: "github.com/c/lib"}, {Package: "github.com/b/lib"},
+	})
+	assert.Equal(t, "Docs[1].Forks", shown.Docs[1].Forks, []ForkInfo{
+		{Package: "github.com/e/x/sub"},
+	})
+	assert.Equal(t, "len(Docs[1].Subs)", len(shown.Docs[1].Subs), 1)
+}
+
+func TestExplainHit(t *testing.T) {
+	tmpPath := villa.Path(os.TempDir()).Join("gcse_explainhit_testing")
+	assert.NoError(t, tmpPath.RemoveAll())
+	defer tmpPath.RemoveAll()
+	segm := utils.Segments(tmpPath.S()).Join("0")
+
+	buildIndex(t, []gcse.DocInfo{
+		{Package: "github.com/a/json", Name: "json", Description: "Package json parses JSON."},
+		{Package: "github.com/b/yaml", Name: "yaml", Description: "Package yaml parses YAML."},
+		{Package: "github.com/c/app", Name: "main", Description: "Command app reads JSON.", Imports: []string{"github.com/a/json"}},
+	}, segm, "")
+	db, err := openSearcherDB(segm, nil)
+	assert.NoErrorOrDie(t, err)
+	defer db.Close()
+
+	results, _, err := search(trace.New("test", "test"), db, "json")
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "TotalResults", results.TotalResults, 2)
+
+	for i, hit := range results.Hits {
+		e, found, err := explainHit(db, "json", hit.Package)
+		assert.NoErrorOrDie(t, err)
+		assert.True(t, "found", found)
+		assert.Equal(t, "Rank", e.Rank, i+1)
+		assert.Equal(t, "TotalResults", e.TotalResults, 2)
+		assert.Equal(t, "RankScore", e.RankScore, hit.Score)
+		assert.Equal(t, "Match.Score", e.Match.Score, hit.MatchScore)
+		assert.Equal(t, "Static.Score", e.Static.Score, hit.StaticScore)
+	}
+
+	e, found, err := explainHit(db, "json", "github.com/b/yaml")
+	assert.NoErrorOrDie(t, err)
+	assert.True(t, "found", found)
+	assert.Equal(t, "Rank", e.Rank, 0)
+	assert.Equal(t, "Match.Score", e.Match.Score, e.Match.Floor)
+
+	_, found, err = explainHit(db, "json", "github.com/x/none")
+	assert.NoError(t, err)
+	assert.False(t, "found", found)
+}
+
+func TestSearch_FiltersOnly(t *testing.T) {
+	tmpPath := villa.Path(os.TempDir()).Join("gcse_search_filters_testing")
+	assert.NoError(t, tmpPath.RemoveAll())
+	defer tmpPath.RemoveAll()
+	segm := utils.Segments(tmpPath.S()).Join("0")
+
+	buildIndex(t, []gcse.DocInfo{
+		{Package: "github.com/a/json", Name: "json", Description: "Package json parses JSON.", License: "MIT"},
+		{Package: "github.com/b/yaml", Name: "yaml", Description: "Package yaml parses YAML.", License: "MIT"},
+		{Package: "github.com/c/xml", Name: "xml", Description: "Package xml parses XML.", License: "GPL-3.0"},
+	}, segm, "")
+	db, err := openSearcherDB(segm, nil)
+	assert.NoErrorOrDie(t, err)
+	defer db.Close()
+
+	// A query of filters only matches every package passing them.
+	results, _, err := search(trace.New("test", "test"), db, "license:mit")
+	assert.NoErrorOrDie(t, err)
+	var pkgs []string
+	for _, hit := range results.Hits {
+		pkgs = append(pkgs, hit.Package)
+	}
+	sort.Strings(pkgs)
+	assert.Equal(t, "pkgs", pkgs, []string{"github.com/a/json", "github.com/b/yaml"})
+	assert.Equal(t, "TotalResults", results.TotalResults, 2)
+}
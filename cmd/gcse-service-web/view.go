@@ -11,6 +11,7 @@ import (
 
 	"github.com/ajstarks/svgo"
 	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/spider"
 )
 
 func pageView(w http.ResponseWriter, r *http.Request) {
@@ -37,12 +38,16 @@ func pageView(w http.ResponseWriter, r *http.Request) {
 			TotalDocCount int
 			StaticRank    int
 			ShowReadme    bool
+			Replacement   string
+			DocCoverage   int // in percentage
 		}{
 			HitInfo:       d,
 			DescHTML:      template.HTML(descHTML),
 			TotalDocCount: db.PackageCount(),
 			StaticRank:    d.StaticRank + 1,
 			ShowReadme:    len(d.Description) < 10 && len(d.ReadmeData) > 0,
+			Replacement:   spider.SuggestedReplacement(d.Deprecated),
+			DocCoverage:   int(d.Quality.DocCoverage()*100 + 0.5),
 		}); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -165,7 +165,7 @@ func exec(maxCrawl int, dur time.Duration) error {
 }
 
 func main() {
-	log.Printf("Using Github personal token: %v", configs.CrawlerGithubPersonal)
+	log.Printf("Using Github personal token: %v", configs.CrawlerGithubPersonal != "")
 
 	httpClient := gcse.NewHTTPClient("")
 
@@ -7,14 +7,13 @@ import (
 	"github.com/golangplus/fmt"
 
 	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
 	"github.com/daviddengcn/sophie"
 	"github.com/daviddengcn/sophie/kv"
 )
 
 func main() {
-	//	path := "data/docs"
-	path := "data/docs-updated"
-	kvDir := kv.DirInput(sophie.LocalFsPath(path))
+	kvDir := kv.DirInput(configs.DocsDBFsPath())
 
 	cnt, err := kvDir.PartCount()
 	if err != nil {
@@ -5,23 +5,27 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
 
+	"github.com/boltdb/bolt"
 	"github.com/golangplus/fmt"
 
+	"github.com/daviddengcn/bolthelper"
 	"github.com/daviddengcn/gcse"
 	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/spider"
 	"github.com/daviddengcn/go-index"
 	"github.com/daviddengcn/sophie"
 	"github.com/daviddengcn/sophie/kv"
 )
 
 func help() {
-	fmt.Fprintf(os.Stderr, "Usage: %v docs|index|crawler [keys...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %v docs|index|crawler|filecache [keys...]\n", os.Args[0])
 }
 
 func dumpDocs(keys []string) {
 	var (
-		path  = configs.DataRoot.Join(configs.FnDocs).S()
+		path  = configs.DocsDBPath()
 		kvDir = kv.DirInput(sophie.LocalFsPath(path))
 	)
 
@@ -32,7 +36,7 @@ func dumpDocs(keys []string) {
 
 	parts := map[int]map[string]bool{}
 	for _, key := range keys {
-		part := gcse.CalcPackagePartition(key, gcse.DOCS_PARTS)
+		part := gcse.CalcPackagePartition(key, cnt)
 		if parts[part] == nil {
 			parts[part] = make(map[string]bool)
 		}
@@ -116,10 +120,11 @@ func dumpIndex(keys []string) {
 
 func dumpCrawler(keys []string) {
 	cDB := gcse.LoadCrawlerDB()
+	defer cDB.Close()
 	if len(keys) == 0 {
 		// Full dump
 		log.Printf("Dumping PackageDB...")
-		cDB.PackageDB.Iterate(func(k string, v interface{}) error {
+		cDB.PackageDB.Iterate(func(k string, v gcse.CrawlingEntry) error {
 			fmtp.Printfln("Package %v: %+v", k, v)
 			return nil
 		})
@@ -127,13 +132,40 @@ func dumpCrawler(keys []string) {
 	}
 
 	for _, key := range keys {
-		var ent gcse.CrawlingEntry
-		if cDB.PackageDB.Get(key, &ent) {
+		if ent, ok := cDB.PackageDB.Get(key); ok {
 			fmtp.Printfln("Package %v: %+v", key, ent)
 		}
 	}
 }
 
+// dumpFileCache prints the statistics of the file cache, and the signatures
+// of the files in keys with the files referencing them.
+func dumpFileCache(keys []string) {
+	path := configs.FileCacheBoltPath()
+	db, err := bh.Open(path, 0644, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		log.Fatalf("bh.Open(%q) failed: %v", path, err)
+	}
+	defer db.Close()
+
+	fc := &spider.BoltFileCache{DB: db}
+	st, err := fc.Stats()
+	if err != nil {
+		log.Fatalf("fc.Stats() failed: %v", err)
+	}
+	fmtp.Printfln("Entries: %d", st.Entries)
+	fmtp.Printfln("Bytes: %d", st.Bytes)
+	fmtp.Printfln("Hits: %d, Misses: %d, Hit ratio: %.3f", st.Hits, st.Misses, st.HitRatio())
+
+	for _, key := range keys {
+		sign, paths, err := fc.Lookup(key)
+		if err != nil {
+			log.Fatalf("fc.Lookup(%q) failed: %v", key, err)
+		}
+		fmtp.Printfln("%v -> %q referenced by %v", key, sign, paths)
+	}
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		help()
@@ -147,6 +179,8 @@ func main() {
 		dumpIndex(os.Args[2:])
 	case "crawler":
 		dumpCrawler(os.Args[2:])
+	case "filecache":
+		dumpFileCache(os.Args[2:])
 	default:
 		help()
 	}
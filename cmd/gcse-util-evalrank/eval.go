@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/gcse"
+)
+
+// Judgments are the graded relevant packages of the queries. A package not
+// judged for a query is irrelevant, i.e. of grade 0.
+type Judgments struct {
+	// In the order of the first appearances in the file.
+	Queries []string
+	Grades  map[string]map[string]int
+}
+
+// ReadJudgments reads judgments of lines of tab separated query, package and
+// grade. Empty lines and lines starting with '#' are ignored.
+func ReadJudgments(r io.Reader) (*Judgments, error) {
+	j := &Judgments{Grades: make(map[string]map[string]int)}
+	s := bufio.NewScanner(r)
+	for ln := 1; s.Scan(); ln++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 {
+			return nil, errorsp.NewWithStacks("line %d: expecting 3 tab separated columns, got %d", ln, len(parts))
+		}
+		q, pkg := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		grade, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || grade < 0 {
+			return nil, errorsp.NewWithStacks("line %d: invalid grade %q", ln, parts[2])
+		}
+		if j.Grades[q] == nil {
+			j.Queries = append(j.Queries, q)
+			j.Grades[q] = make(map[string]int)
+		}
+		j.Grades[q][pkg] = grade
+	}
+	if err := s.Err(); err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	return j, nil
+}
+
+// ReadJudgmentsFile reads the judgments in the file at fn, see ReadJudgments.
+func ReadJudgmentsFile(fn string) (*Judgments, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	defer f.Close()
+	return ReadJudgments(f)
+}
+
+func dcg(grades []int) float64 {
+	s := 0.
+	for i, g := range grades {
+		s += (math.Pow(2, float64(g)) - 1) / math.Log2(float64(i+2))
+	}
+	return s
+}
+
+// NDCG returns the normalized discounted cumulative gain of the top k of the
+// ranked packages, with gains of 2^grade-1. 0 if no package is relevant.
+func NDCG(ranked []string, grades map[string]int, k int) float64 {
+	var gs, ideal []int
+	for i := 0; i < k && i < len(ranked); i++ {
+		gs = append(gs, grades[ranked[i]])
+	}
+	for _, g := range grades {
+		ideal = append(ideal, g)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ideal)))
+	if len(ideal) > k {
+		ideal = ideal[:k]
+	}
+	idcg := dcg(ideal)
+	if idcg == 0 {
+		return 0
+	}
+	return dcg(gs) / idcg
+}
+
+// ReciprocalRank returns 1/rank of the first relevant one of the ranked
+// packages, 0 if none.
+func ReciprocalRank(ranked []string, grades map[string]int) float64 {
+	for i, pkg := range ranked {
+		if grades[pkg] > 0 {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// Precision returns the fraction of the relevant ones in the top k of the
+// ranked packages.
+func Precision(ranked []string, grades map[string]int, k int) float64 {
+	if k <= 0 {
+		return 0
+	}
+	cnt := 0
+	for i := 0; i < k && i < len(ranked); i++ {
+		if grades[ranked[i]] > 0 {
+			cnt++
+		}
+	}
+	return float64(cnt) / float64(k)
+}
+
+// QueryResult is the evaluation of a query.
+type QueryResult struct {
+	Query string
+	// The top k packages.
+	Top       []string
+	NDCG      float64
+	MRR       float64
+	Precision float64
+}
+
+// Evaluation is the results of the queries of some judgments.
+type Evaluation struct {
+	Queries []QueryResult
+
+	MeanNDCG      float64
+	MeanMRR       float64
+	MeanPrecision float64
+}
+
+// Evaluate searches the queries of j in idx, ranked by sc, and evaluates the
+// top k results of each query.
+func Evaluate(idx gcse.SearchIndex, sc *gcse.Scoring, j *Judgments, k int) (*Evaluation, error) {
+	ev := &Evaluation{}
+	for _, q := range j.Queries {
+		tokens := gcse.AppendTokens(nil, []byte(q))
+		hits, err := gcse.SearchAndRank(idx, tokens, nil, sc)
+		if err != nil {
+			return nil, errorsp.WithStacksAndMessage(err, "query %q", q)
+		}
+		var ranked []string
+		for _, hit := range hits {
+			ranked = append(ranked, hit.Package)
+		}
+		grades := j.Grades[q]
+		r := QueryResult{
+			Query:     q,
+			NDCG:      NDCG(ranked, grades, k),
+			MRR:       ReciprocalRank(ranked, grades),
+			Precision: Precision(ranked, grades, k),
+		}
+		if len(ranked) > k {
+			ranked = ranked[:k]
+		}
+		r.Top = ranked
+		ev.Queries = append(ev.Queries, r)
+
+		ev.MeanNDCG += r.NDCG
+		ev.MeanMRR += r.MRR
+		ev.MeanPrecision += r.Precision
+	}
+	if n := float64(len(ev.Queries)); n > 0 {
+		ev.MeanNDCG /= n
+		ev.MeanMRR /= n
+		ev.MeanPrecision /= n
+	}
+	return ev, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/utils"
+)
+
+func assertClose(t *testing.T, name string, act, exp float64) {
+	assert.True(t, fmt.Sprintf("%s: %v ~ %v", name, act, exp), math.Abs(act-exp) < 1e-9)
+}
+
+func TestReadJudgments(t *testing.T) {
+	j, err := ReadJudgments(strings.NewReader("# comment\nb\tpkg1\t3\n\na\tpkg2\t1\nb\tpkg3\t0\n"))
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "Queries", j.Queries, []string{"b", "a"})
+	assert.Equal(t, "Grades", j.Grades, map[string]map[string]int{
+		"b": {"pkg1": 3, "pkg3": 0},
+		"a": {"pkg2": 1},
+	})
+
+	_, err = ReadJudgments(strings.NewReader("a\tpkg\n"))
+	assert.Error(t, err)
+	_, err = ReadJudgments(strings.NewReader("a\tpkg\tgood\n"))
+	assert.Error(t, err)
+}
+
+func TestMetrics(t *testing.T) {
+	grades := map[string]int{"a": 3, "b": 1, "c": 0}
+
+	assertClose(t, "NDCG ideal", NDCG([]string{"a", "b", "x"}, grades, 10), 1)
+	assertClose(t, "NDCG none", NDCG([]string{"x", "y"}, grades, 10), 0)
+	// dcg = 1/log2(2) + 7/log2(4), idcg = 7/log2(2) + 1/log2(3)
+	exp := 4.5 / (7 + 1/math.Log2(3))
+	assertClose(t, "NDCG swapped", NDCG([]string{"b", "x", "a"}, grades, 10), exp)
+	// Only the top 1 counted.
+	assertClose(t, "NDCG@1", NDCG([]string{"b", "a"}, grades, 1), 1./7)
+	assertClose(t, "NDCG no relevant", NDCG([]string{"a"}, map[string]int{"a": 0}, 10), 0)
+
+	assertClose(t, "MRR first", ReciprocalRank([]string{"a", "b"}, grades), 1)
+	assertClose(t, "MRR third", ReciprocalRank([]string{"c", "x", "b"}, grades), 1./3)
+	assertClose(t, "MRR none", ReciprocalRank([]string{"c", "x"}, grades), 0)
+
+	assertClose(t, "P@2", Precision([]string{"a", "c", "b"}, grades, 2), 0.5)
+	assertClose(t, "P@4", Precision([]string{"a", "c", "b"}, grades, 4), 0.5)
+}
+
+func TestEvaluate_Fixture(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gcse-evalrank-testing")
+	assert.NoErrorOrDie(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	segm := utils.Segment(filepath.Join(tmpDir, "0"))
+	assert.NoErrorOrDie(t, BuildFixtureIndex("testdata/docs.json", segm))
+	idx, err := gcse.OpenIndexReader(segm, nil)
+	assert.NoErrorOrDie(t, err)
+	defer idx.Close()
+	assert.Equal(t, "PackageCount", idx.PackageCount(), 10)
+
+	j, err := ReadJudgmentsFile("testdata/judgments.tsv")
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "len(Queries)", len(j.Queries), 5)
+
+	ev, err := Evaluate(idx, gcse.ConfiguredScoring(), j, 10)
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "len(ev.Queries)", len(ev.Queries), 5)
+	for _, r := range ev.Queries {
+		assert.True(t, r.Query+" has results", len(r.Top) > 0)
+		assert.True(t, r.Query+" relevant first", j.Grades[r.Query][r.Top[0]] > 0)
+	}
+	assert.True(t, "MeanNDCG", ev.MeanNDCG > 0.9 && ev.MeanNDCG <= 1)
+	assertClose(t, "MeanMRR", ev.MeanMRR, 1)
+
+	// An irrelevant query scores 0.
+	j.Queries = append(j.Queries, "yaml json")
+	ev, err = Evaluate(idx, gcse.ConfiguredScoring(), j, 1)
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "len(ev.Queries)", len(ev.Queries), 6)
+	assertClose(t, "NDCG of unjudged", ev.Queries[5].NDCG, 0)
+
+	sc, err := ReadScoringFile("testdata/scoring_b.json")
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "Blend", sc.Blend, "linear")
+	assert.Equal(t, "StaticWeight", sc.StaticWeight, 0.9)
+	assert.Equal(t, "K1", sc.K1, gcse.ConfiguredScoring().K1)
+}
+
+func evaluateFixture(t *testing.T, segm utils.Segment, docsFn string, j *Judgments) *Evaluation {
+	assert.NoErrorOrDie(t, BuildFixtureIndex(docsFn, segm))
+	idx, err := gcse.OpenIndexReader(segm, nil)
+	assert.NoErrorOrDie(t, err)
+	defer idx.Close()
+	ev, err := Evaluate(idx, gcse.ConfiguredScoring(), j, 10)
+	assert.NoErrorOrDie(t, err)
+	return ev
+}
+
+func TestEvaluate_Freshness(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gcse-evalrank-testing")
+	assert.NoErrorOrDie(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	j, err := ReadJudgmentsFile("testdata/freshness_judgments.tsv")
+	assert.NoErrorOrDie(t, err)
+
+	// Without the freshness and the host factors.
+	halfLife, bonus, hostFactors := configs.RankingFreshnessHalfLife, configs.RankingReleaseBonus, configs.RankingHostFactors
+	configs.RankingFreshnessHalfLife, configs.RankingReleaseBonus, configs.RankingHostFactors = 0, 0, nil
+	off := evaluateFixture(t, utils.Segment(filepath.Join(tmpDir, "0")), "testdata/freshness_docs.json", j)
+	configs.RankingFreshnessHalfLife, configs.RankingReleaseBonus, configs.RankingHostFactors = halfLife, bonus, hostFactors
+
+	on := evaluateFixture(t, utils.Segment(filepath.Join(tmpDir, "1")), "testdata/freshness_docs.json", j)
+	assert.True(t, fmt.Sprintf("MeanNDCG %v > %v", on.MeanNDCG, off.MeanNDCG), on.MeanNDCG > off.MeanNDCG)
+	assertClose(t, "MeanNDCG", on.MeanNDCG, 1)
+	for i, r := range on.Queries {
+		assert.Equal(t, r.Query+" top", r.Top[0], best(j.Grades[r.Query]))
+		if r.Query == "cron" {
+			// Popular and only a few years old, not decayed below a fresh
+			// toy package.
+			assert.Equal(t, "cron top without freshness", off.Queries[i].Top[0], r.Top[0])
+		} else {
+			assert.NotEqual(t, r.Query+" top without freshness", off.Queries[i].Top[0], r.Top[0])
+		}
+	}
+}
+
+// best returns the package of the highest grade.
+func best(grades map[string]int) string {
+	pkg := ""
+	for p, g := range grades {
+		if pkg == "" || g > grades[pkg] {
+			pkg = p
+		}
+	}
+	return pkg
+}
@@ -0,0 +1,241 @@
+// Command gcse-util-evalrank evaluates the ranking of the search results of
+// an index segment against relevance judgments, with NDCG, MRR and
+// precision, and compares two scoring configurations or two segments.
+//
+// The judgments file has lines of tab separated query, package and grade,
+// 0 (irrelevant) to 3 (perfect). Lines starting with '#' are comments.
+//
+// Examples:
+//
+//	gcse-util-evalrank -judgments judgments.tsv
+//	gcse-util-evalrank -judgments judgments.tsv -scoring_b scoring.json
+//	gcse-util-evalrank -judgments judgments.tsv -segment data/index/1 -segment_b data/index/2
+//	gcse-util-evalrank -judgments testdata/judgments.tsv -docs testdata/docs.json
+//
+// scoring.json overrides fields of the configured gcse.Scoring, e.g.
+// {"Blend": "linear", "Weights": {"name": 5}}.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/fmt"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/utils"
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/mr"
+)
+
+// ReadScoringFile returns the configured Scoring with the fields in the JSON
+// file at fn overridden.
+func ReadScoringFile(fn string) (*gcse.Scoring, error) {
+	sc := gcse.ConfiguredScoring()
+	bs, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	if err := json.Unmarshal(bs, sc); err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "parsing %v", fn)
+	}
+	return sc, nil
+}
+
+func docsInput(docs []gcse.DocInfo) mr.Input {
+	return &mr.InputStruct{
+		PartCountF: func() (int, error) {
+			return 1, nil
+		},
+		IteratorF: func(int) (sophie.IterateCloser, error) {
+			index := 0
+			return &sophie.IterateCloserStruct{
+				NextF: func(key, val sophie.SophieReader) error {
+					if index >= len(docs) {
+						return io.EOF
+					}
+					*key.(*sophie.RawString) = sophie.RawString(docs[index].Package)
+					*val.(*gcse.DocInfo) = docs[index]
+					index++
+					return nil
+				},
+			}, nil
+		},
+	}
+}
+
+// BuildFixtureIndex indexes the docs in the JSON file at docsFn, an array of
+// gcse.DocInfo, into segm.
+func BuildFixtureIndex(docsFn string, segm utils.Segment) error {
+	bs, err := ioutil.ReadFile(docsFn)
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	var docs []gcse.DocInfo
+	if err := json.Unmarshal(bs, &docs); err != nil {
+		return errorsp.WithStacksAndMessage(err, "parsing %v", docsFn)
+	}
+	if err := segm.Make(); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	ts, err := gcse.Index(docsInput(docs), string(segm))
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(segm.Join(gcse.IndexFn))
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	defer f.Close()
+	return errorsp.WithStacks(ts.Save(f))
+}
+
+func printEvaluation(ev *Evaluation, k int) {
+	fmtp.Printfln("%-30s %8s %8s %8s", "query", "NDCG@"+strconv.Itoa(k), "MRR", "P@"+strconv.Itoa(k))
+	for _, r := range ev.Queries {
+		fmtp.Printfln("%-30s %8.4f %8.4f %8.4f", r.Query, r.NDCG, r.MRR, r.Precision)
+	}
+	fmtp.Printfln("%-30s %8.4f %8.4f %8.4f", "(mean)", ev.MeanNDCG, ev.MeanMRR, ev.MeanPrecision)
+}
+
+// printComparison prints the metrics of a and b, of the same judgments, with
+// the deltas, and the top results side by side of the queries ranked
+// differently.
+func printComparison(a, b *Evaluation, k int) {
+	fmtp.Printfln("%-30s %8s %8s %8s   %8s %8s %8s   %8s %8s %8s", "query",
+		"NDCG A", "NDCG B", "delta", "MRR A", "MRR B", "delta", "P A", "P B", "delta")
+	row := func(q string, na, nb, ma, mb, pa, pb float64) {
+		fmtp.Printfln("%-30s %8.4f %8.4f %+8.4f   %8.4f %8.4f %+8.4f   %8.4f %8.4f %+8.4f", q,
+			na, nb, nb-na, ma, mb, mb-ma, pa, pb, pb-pa)
+	}
+	for i, ra := range a.Queries {
+		rb := b.Queries[i]
+		row(ra.Query, ra.NDCG, rb.NDCG, ra.MRR, rb.MRR, ra.Precision, rb.Precision)
+	}
+	row("(mean)", a.MeanNDCG, b.MeanNDCG, a.MeanMRR, b.MeanMRR, a.MeanPrecision, b.MeanPrecision)
+
+	for i, ra := range a.Queries {
+		rb := b.Queries[i]
+		if strings.Join(ra.Top, "\n") == strings.Join(rb.Top, "\n") {
+			continue
+		}
+		fmtp.Printfln("")
+		fmtp.Printfln("Query %q, top %d:", ra.Query, k)
+		for j := 0; j < len(ra.Top) || j < len(rb.Top); j++ {
+			var pa, pb string
+			if j < len(ra.Top) {
+				pa = ra.Top[j]
+			}
+			if j < len(rb.Top) {
+				pb = rb.Top[j]
+			}
+			mark := " "
+			if pa != pb {
+				mark = "*"
+			}
+			fmtp.Printfln("%s %2d  %-45s %s", mark, j+1, pa, pb)
+		}
+	}
+}
+
+func main() {
+	segmA := flag.String("segment", "", "The index segment to evaluate, the served one if empty")
+	segmB := flag.String("segment_b", "", "The index segment to compare with, the same one as -segment if empty")
+	scoringB := flag.String("scoring_b", "", "A JSON file of the fields of the scoring to compare with, overriding the configured ones")
+	judgmentsFn := flag.String("judgments", "", "The file of the relevance judgments")
+	docsFn := flag.String("docs", "", "A JSON file of the docs to build a fixture index of, instead of -segment")
+	k := flag.Int("k", 10, "The number of the top results to evaluate")
+	flag.Parse()
+
+	if err := run(*segmA, *segmB, *scoringB, *judgmentsFn, *docsFn, *k); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run evaluates the judgments in judgmentsFn and prints the results. It
+// returns, instead of exiting, on errors so that the deferred cleanups run.
+func run(segmA, segmB, scoringB, judgmentsFn, docsFn string, k int) error {
+	if judgmentsFn == "" {
+		return errorsp.NewWithStacks("-judgments is required")
+	}
+	j, err := ReadJudgmentsFile(judgmentsFn)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "ReadJudgmentsFile %v failed", judgmentsFn)
+	}
+
+	if docsFn != "" {
+		tmpDir, err := ioutil.TempDir("", "gcse-evalrank")
+		if err != nil {
+			return errorsp.WithStacksAndMessage(err, "TempDir failed")
+		}
+		defer os.RemoveAll(tmpDir)
+		segm := utils.Segment(filepath.Join(tmpDir, "0"))
+		if err := BuildFixtureIndex(docsFn, segm); err != nil {
+			return errorsp.WithStacksAndMessage(err, "BuildFixtureIndex %v failed", docsFn)
+		}
+		segmA = string(segm)
+	}
+	if segmA == "" {
+		segm, err := gcse.ServedIndexSegment(configs.IndexSegments())
+		if err != nil {
+			return errorsp.WithStacksAndMessage(err, "ServedIndexSegment failed")
+		}
+		if segm == "" {
+			return errorsp.NewWithStacks("no index segment to evaluate")
+		}
+		segmA = string(segm)
+	}
+
+	idxA, err := gcse.OpenIndexReader(utils.Segment(segmA), nil)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "OpenIndexReader %v failed", segmA)
+	}
+	defer idxA.Close()
+	scA := gcse.ConfiguredScoring()
+	evA, err := Evaluate(idxA, scA, j, k)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "Evaluate failed")
+	}
+	if segmB == "" && scoringB == "" {
+		fmtp.Printfln("Segment %v, %d queries:", segmA, len(j.Queries))
+		printEvaluation(evA, k)
+		return nil
+	}
+
+	idxB, scB := idxA, scA
+	if segmB != "" {
+		if idxB, err = gcse.OpenIndexReader(utils.Segment(segmB), nil); err != nil {
+			return errorsp.WithStacksAndMessage(err, "OpenIndexReader %v failed", segmB)
+		}
+		defer idxB.Close()
+	} else {
+		segmB = segmA
+	}
+	if scoringB != "" {
+		if scB, err = ReadScoringFile(scoringB); err != nil {
+			return errorsp.WithStacksAndMessage(err, "ReadScoringFile %v failed", scoringB)
+		}
+	}
+	evB, err := Evaluate(idxB, scB, j, k)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "Evaluate failed")
+	}
+	fmtp.Printfln("A: segment %v, configured scoring", segmA)
+	if scoringB != "" {
+		fmtp.Printfln("B: segment %v, scoring %v", segmB, scoringB)
+	} else {
+		fmtp.Printfln("B: segment %v, configured scoring", segmB)
+	}
+	fmtp.Printfln("%d queries:", len(j.Queries))
+	printComparison(evA, evB, k)
+	return nil
+}
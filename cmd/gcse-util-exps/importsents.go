@@ -26,6 +26,7 @@ func init() {
 
 func main() {
 	docDB := gcse.NewMemDB(DocDBPath, gcse.KindDocDB)
+	defer docDB.Close()
 	countAll, countReadme, countHasSents := 0, 0, 0
 	countSents := 0
 
@@ -14,12 +14,8 @@ import (
 
 func doFill() error {
 	cDB := gcse.LoadCrawlerDB()
-	return cDB.PackageDB.Iterate(func(pkg string, val interface{}) error {
-		ent, ok := val.(gcse.CrawlingEntry)
-		if !ok {
-			log.Printf("Wrong entry, ignored: %+v", ent)
-			return nil
-		}
+	defer cDB.Close()
+	return cDB.PackageDB.Iterate(func(pkg string, ent gcse.CrawlingEntry) error {
 		site, path := utils.SplitPackage(pkg)
 		return store.AppendPackageEvent(site, path, "unknown", ent.ScheduleTime.Add(-10*timep.Day), gcsepb.HistoryEvent_Action_None)
 	})
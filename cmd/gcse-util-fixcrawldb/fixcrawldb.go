@@ -47,17 +47,14 @@ func main() {
 	dryRun := false
 	// Load CrawlerDB
 	cDB := gcse.LoadCrawlerDB()
-	fpDataRoot := sophie.FsPath{
-		Fs:   sophie.LocalFS,
-		Path: configs.DataRoot.S(),
-	}
-	pkgs, err := loadDocsPkgs(kv.DirInput(fpDataRoot.Join(configs.FnDocs)))
+	defer cDB.Close()
+	pkgs, err := loadDocsPkgs(kv.DirInput(configs.DocsDBFsPath()))
 	if err != nil {
 		log.Fatalf("loadDocsPkgs failed: %v", err)
 	}
 	db := cDB.PackageDB
 	var toDelete []string
-	if err := db.Iterate(func(id string, val interface{}) error {
+	if err := db.Iterate(func(id string, _ gcse.CrawlingEntry) error {
 		if pkgs.Contain(id) {
 			// If the pacakge is already in docs, do not touch it.
 			return nil
@@ -0,0 +1,63 @@
+// gcse-util-migrate migrates the store to the latest schema version and
+// reports the quarantined records.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/store"
+)
+
+func reportQuarantined() error {
+	cnt := 0
+	if err := store.ForEachQuarantined(func(key string, v []byte) error {
+		cnt++
+		fmt.Printf("quarantined: %s (%d bytes)\n", key, len(v))
+		return nil
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("%d records quarantined\n", cnt)
+	return nil
+}
+
+func doMigrate(opts store.MigrateOptions) error {
+	version, err := store.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema version: %d, latest: %d\n", version, store.LatestSchemaVersion())
+	applied, err := store.Migrate(opts)
+	if err != nil {
+		return err
+	}
+	verb := "Applied"
+	if opts.DryRun {
+		verb = "Would apply"
+	}
+	for _, m := range applied {
+		fmt.Printf("%s migration %d: %s\n", verb, m.Version, m.Name)
+	}
+	return reportQuarantined()
+}
+
+func main() {
+	dryRun := flag.Bool("dry_run", false, "run the migrations without committing them")
+	backup := flag.String("backup", configs.StoreBackupPath(), "path to save a snapshot before migrating, empty to skip")
+	flag.Parse()
+
+	// Opens the backend without the automatic migration.
+	b, err := store.OpenBackend(configs.StoreBackend)
+	if err != nil {
+		log.Fatalf("OpenBackend %q failed: %v", configs.StoreBackend, err)
+	}
+	store.UseBackend(b)
+	defer b.Close()
+
+	if err := doMigrate(store.MigrateOptions{DryRun: *dryRun, BackupPath: *backup}); err != nil {
+		log.Fatalf("doMigrate failed: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+// Command gcse-util-repartition rewrites the docs into the number of the
+// partitions configured by docdb.parts, e.g. after it is changed.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/pipeline/mergedocs"
+)
+
+func main() {
+	flag.Parse()
+
+	st, err := mergedocs.Repartition()
+	if err != nil {
+		log.Fatalf("Repartitioning failed: %v", err)
+	}
+	log.Printf("Docs repartitioned into %d partitions, %d docs.", configs.DocsParts, st.Unchanged)
+}
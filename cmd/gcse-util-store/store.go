@@ -0,0 +1,242 @@
+// gcse-util-store backs up and restores the store, manages the backups, and
+// garbage collects the store.
+//
+// Usage:
+//
+//	gcse-util-store [flags] backup [<dir>]
+//	gcse-util-store [flags] restore <backup>
+//	gcse-util-store list [<dir>]
+//	gcse-util-store [flags] prune [<dir>]
+//	gcse-util-store verify <backup>
+//	gcse-util-store [-delete] gc
+//
+// <dir> is configs.StoreBackupDir() by default.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/kv"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/strings"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/store"
+)
+
+var (
+	name       = flag.String("name", "", "name of the backup, the creation time if empty")
+	tarball    = flag.Bool("tar", false, "save the backup as a gzipped tarball")
+	force      = flag.Bool("force", false, "restore even if the store is not empty")
+	keepLast   = flag.Int("keep_last", 7, "number of the newest backups to keep when pruning")
+	keepDaily  = flag.Int("keep_daily", 7, "number of the latest days to keep a backup of when pruning")
+	keepWeekly = flag.Int("keep_weekly", 4, "number of the latest weeks to keep a backup of when pruning")
+	dryRun     = flag.Bool("dry_run", false, "list the backups to prune without deleting them")
+	del        = flag.Bool("delete", false, "delete the garbage found by gc instead of reporting it only")
+)
+
+func printManifest(path string, m *store.BackupManifest) {
+	fmt.Printf("%s\t%s\tschema version %d\tlast change %d\n", path, m.Created.Format("2006-01-02 15:04:05"), m.SchemaVersion, m.LastChangeSeq)
+}
+
+// openStore opens the backend without the automatic migration, which is done
+// after restoring.
+func openStore() {
+	b, err := store.OpenBackend(configs.StoreBackend)
+	if err != nil {
+		log.Fatalf("OpenBackend %q failed: %v", configs.StoreBackend, err)
+	}
+	store.UseBackend(b)
+}
+
+func doBackup(dir string) error {
+	m, err := store.Backup(dir, store.BackupOptions{Name: *name, Tarball: *tarball})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up %s\n", m.Name)
+	return nil
+}
+
+func doRestore(path string) error {
+	m, err := store.RestoreBackup(path, store.RestoreOptions{Force: *force})
+	if errorsp.Cause(err) == store.ErrStoreNotEmpty {
+		return errorsp.NewWithStacks("the store is not empty, use -force to overwrite it")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s\n", m.Name)
+	return nil
+}
+
+func doList(dir string) error {
+	backups, err := store.ListBackups(dir)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups {
+		printManifest(b.Path, b.Manifest)
+	}
+	return nil
+}
+
+func doPrune(dir string) error {
+	pruned, err := store.PruneBackups(dir, store.RetentionPolicy{
+		KeepLast:   *keepLast,
+		KeepDaily:  *keepDaily,
+		KeepWeekly: *keepWeekly,
+	}, *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "Pruned"
+	if *dryRun {
+		verb = "Would prune"
+	}
+	for _, b := range pruned {
+		fmt.Printf("%s %s\n", verb, b.Path)
+	}
+	return nil
+}
+
+func doVerify(path string) error {
+	m, err := store.VerifyBackup(path)
+	if err != nil {
+		return err
+	}
+	printManifest(path, m)
+	fmt.Println("OK")
+	return nil
+}
+
+// loadLivePackages returns the packages in the docs and the CrawlerDB. Since
+// everything else is collected by gc, it fails if either of them can not be
+// loaded, or no package is found.
+func loadLivePackages() (stringsp.Set, error) {
+	var live stringsp.Set
+	in := kv.DirInput(configs.DocsDBFsPath())
+	cnt, err := in.PartCount()
+	if err != nil {
+		return nil, err
+	}
+	if cnt == 0 {
+		return nil, errorsp.NewWithStacks("no docs found in %v", configs.DocsDBFsPath())
+	}
+	for part := 0; part < cnt; part++ {
+		it, err := in.Iterator(part)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			var key sophie.RawString
+			var val gcse.DocInfo
+			if err := it.Next(&key, &val); err != nil {
+				if errorsp.Cause(err) == io.EOF {
+					break
+				}
+				it.Close()
+				return nil, err
+			}
+			live.Add(string(key))
+		}
+		it.Close()
+	}
+	log.Printf("%d packages in the docs", len(live))
+	cDB, err := gcse.OpenCrawlerDB()
+	if err != nil {
+		return nil, err
+	}
+	defer cDB.Close()
+	if err := cDB.PackageDB.Iterate(func(pkg string, _ gcse.CrawlingEntry) error {
+		live.Add(pkg)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	log.Printf("%d packages in the docs or the CrawlerDB", len(live))
+	if len(live) == 0 {
+		return nil, errorsp.NewWithStacks("no live packages found")
+	}
+	return live, nil
+}
+
+func doGC() error {
+	live, err := loadLivePackages()
+	if err != nil {
+		return err
+	}
+	stats, err := store.GC(store.GCOptions{
+		IsLivePackage: func(site, path string) bool {
+			return live.Contain(site + "/" + path)
+		},
+		RepoMaxAge: configs.StoreRepoMaxAge,
+		Delete:     *del,
+		Found: func(category, key string) {
+			fmt.Printf("%s\t%s\n", category, key)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	categories := make([]string, 0, len(stats))
+	for c := range stats {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+	verb := "Found"
+	if *del {
+		verb = "Collected"
+	}
+	for _, c := range categories {
+		fmt.Printf("%s %d %s\n", verb, stats[c], c)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: gcse-util-store [flags] backup|restore|list|prune|verify|gc [<path>]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	path := flag.Arg(1)
+	if path == "" {
+		path = configs.StoreBackupDir()
+	}
+	var err error
+	switch cmd := flag.Arg(0); cmd {
+	case "backup":
+		err = doBackup(path)
+	case "restore":
+		if flag.NArg() < 2 {
+			log.Fatal("The backup to restore is missing")
+		}
+		openStore()
+		err = doRestore(path)
+	case "list":
+		err = doList(path)
+	case "prune":
+		err = doPrune(path)
+	case "gc":
+		err = doGC()
+	case "verify":
+		if flag.NArg() < 2 {
+			log.Fatal("The backup to verify is missing")
+		}
+		err = doVerify(path)
+	default:
+		log.Fatalf("Unknown command %q", cmd)
+	}
+	if err != nil {
+		log.Fatalf("%s failed: %v", flag.Arg(0), err)
+	}
+}
@@ -3,8 +3,12 @@ package configs
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/golangplus/strings"
@@ -27,6 +31,11 @@ const (
 	FnDocs    = "docs"
 	FnNewDocs = "newdocs"
 
+	// The folder of the generations of the docs, each a segment containing
+	// FnDocs, and the file in it naming the current generation.
+	fnDocsGens       = "docs-gens"
+	FnDocsGenCurrent = "current"
+
 	FnStore = "store"
 )
 
@@ -36,6 +45,9 @@ var (
 
 	LoadTemplatePass = ""
 	AutoLoadTemplate = false
+	// The password of the admin actions of the web server, which are
+	// disabled if empty.
+	AdminPass = ""
 
 	DataRoot = villa.Path("./data/")
 
@@ -52,13 +64,131 @@ var (
 	CrawlerGithubClientID     = os.Getenv("GITHUB_CLIENT_ID")
 	CrawlerGithubClientSecret = os.Getenv("GITHUB_CLIENT_SECRET")
 	CrawlerGithubPersonal     = os.Getenv("GITHUB_PERSONAL_ACCESS_TOKEN")
+	// Hosts running Gitea whose API reports the stars.
+	CrawlerGiteaHosts = []string{"codeberg.org"}
+	// Names of the popularity providers to remove, e.g. "gitlab-stars".
+	CrawlerDisabledPopularity []string
+	// Limits of the file cache of the crawler, no limit if 0. The least
+	// recently used files are evicted when exceeded.
+	CrawlerFileCacheMaxEntries = 2000000
+	CrawlerFileCacheMaxBytes   = 2 << 30
+	// The maximum number of the entries in a partition of the entries to
+	// crawl. Each partition is crawled by a worker.
+	CrawlerPartitionSize = 375000
+	// The maximum number of the partitions crawled concurrently, no limit if
+	// 0.
+	CrawlerWorkers = 0
 
 	BiWebPath = "/bi"
 
 	NonCrawlHosts          = stringsp.Set{}
 	NonStorePackageRegexps = []string{}
+	// The number of the partitions of the docs. The docs are repartitioned by
+	// the next merge after it is changed, or by gcse-util-repartition.
+	DocsParts = 128
+	// The maximum number of the concurrent workers of a phase of the MR jobs
+	// of the docs, e.g. mergedocs, no limit if 0.
+	MRWorkers = runtime.NumCPU()
 
 	StoreDAddr = ":8081"
+	// The storage backend of the store package: "bolt", "memory" or "sqlite".
+	StoreBackend = "bolt"
+	// Whether to save a snapshot of the store before migrating its schema.
+	StoreBackupBeforeMigration = true
+	// The address of the gcse-service-stored instance to access the store
+	// through, e.g. "localhost:8081". The store is accessed locally if empty.
+	StoreRemoteAddr = ""
+	// How long the change log of the store is kept.
+	StoreChangeRetention = 7 * 24 * time.Hour
+	// Crawl history events older than this are rolled up into daily counts.
+	StoreHistoryDetailAge = 30 * 24 * time.Hour
+	// How long the daily counts of the crawl history are kept, 0 for ever.
+	StoreHistoryRetention = 2 * 365 * 24 * time.Hour
+	// Repositories and RepoInfo crawled longer ago are garbage collected.
+	StoreRepoMaxAge = 365 * 24 * time.Hour
+
+	// The indexer builds a full index if the last one is older than this, or
+	// the changed packages since it exceed the ratio of all. Otherwise only
+	// the changed packages are indexed into a delta.
+	IndexerFullInterval  = 24 * time.Hour
+	IndexerDeltaMaxRatio = 0.1
+	// The parameters of the BM25F match score of the search results. The
+	// weights and b of the fields, name, package, synopsis, sentences,
+	// exported and readme, are by the names of the fields.
+	RankingBM25FK1      = 1.2
+	RankingBM25FWeights = map[string]float64{
+		"name":      3,
+		"package":   1.5,
+		"synopsis":  2,
+		"sentences": 1,
+		"exported":  0.5,
+		"readme":    0.5,
+	}
+	RankingBM25FB = map[string]float64{
+		"name":      0.3,
+		"package":   0.3,
+		"synopsis":  0.75,
+		"sentences": 0.75,
+		"exported":  0.75,
+		"readme":    0.75,
+	}
+	// How the static score and the match score are combined into the score
+	// of a search result: "product", static^RankingStaticExp * match, or
+	// "linear", RankingStaticWeight * log(1 + static) +
+	// (1 - RankingStaticWeight) * match.
+	RankingBlend        = "product"
+	RankingStaticExp    = 1.0
+	RankingStaticWeight = 0.5
+	// The freshness of a package is by the latest activity of it, i.e. the
+	// latest commit, push, release or change found by the crawler, relative
+	// to the latest crawl of all. The static score of a package inactive for
+	// longer than the grace is multiplied by 0.5^((age - grace) / half-life),
+	// at least the min factor. 0 half-life disables the decay.
+	RankingFreshnessGrace     = 365 * 24 * time.Hour
+	RankingFreshnessHalfLife  = 3 * 365 * 24 * time.Hour
+	RankingFreshnessMinFactor = 0.5
+	// A release within the window adds up to the bonus, decreasing linearly
+	// with its age, to the static score.
+	RankingReleaseBonus  = 0.5
+	RankingReleaseWindow = 180 * 24 * time.Hour
+	// The static scores of the packages hosted on the sites are multiplied
+	// by the factors, e.g. for closed sites.
+	RankingHostFactors = map[string]float64{
+		"code.google.com": 0.01,
+	}
+
+	// The number of the latest good index segments kept for rolling back.
+	IndexerKeepSegments = 3
+	// A new index segment fails the validation if the number of the docs
+	// drops by more than this ratio of the last good one.
+	IndexerMaxDocDrop = 0.2
+	// A new index segment fails the validation if more than this ratio of
+	// the hits have zero or invalid static scores.
+	IndexerMaxZeroScoreRatio = 0.01
+	// Queries and the packages expected in their results. A new index
+	// segment fails the validation if an expected package is not found.
+	IndexerSampleQueries = map[string][]string{
+		"gin":      {"github.com/gin-gonic/gin"},
+		"mux":      {"github.com/gorilla/mux"},
+		"protobuf": {"github.com/golang/protobuf/proto"},
+		"logrus":   {"github.com/sirupsen/logrus"},
+	}
+
+	// The address of the status endpoint of gcse-pipeline.
+	PipelineAddr = ":8082"
+	// The time to wait between two runs of the pipeline.
+	PipelineInterval = 10 * time.Minute
+	// The number of retries of a failed stage, and the delay before each.
+	PipelineRetries    = 2
+	PipelineRetryDelay = time.Minute
+	// The maximum running time of each stage, no limit if absent.
+	PipelineStageTimeouts = map[string]time.Duration{
+		"tocrawl":   2 * time.Hour,
+		"mergedocs": 2 * time.Hour,
+		"indexer":   4 * time.Hour,
+	}
+	// The number of run records kept.
+	PipelineKeepRuns = 100
 
 	LogDir = "/tmp"
 )
@@ -75,6 +205,7 @@ func init() {
 	ServerRoot = conf.Path("web.root", ServerRoot)
 	LoadTemplatePass = conf.String("web.loadtemplatepass", LoadTemplatePass)
 	AutoLoadTemplate = conf.Bool("web.autoloadtemplate", AutoLoadTemplate)
+	AdminPass = conf.String("web.adminpass", AdminPass)
 
 	DataRoot = conf.Path("back.dbroot", DataRoot)
 
@@ -102,17 +233,85 @@ func init() {
 	CrawlerGithubClientID = conf.String("crawler.github.clientid", CrawlerGithubClientID)
 	CrawlerGithubClientSecret = conf.String("crawler.github.clientsecret", CrawlerGithubClientSecret)
 	CrawlerGithubPersonal = conf.String("crawler.github.personal", CrawlerGithubPersonal)
+	CrawlerGiteaHosts = conf.StringList("crawler.gitea_hosts", CrawlerGiteaHosts)
+	CrawlerDisabledPopularity = conf.StringList("crawler.disabled_popularity", nil)
+	CrawlerFileCacheMaxEntries = conf.Int("crawler.filecache.max_entries", CrawlerFileCacheMaxEntries)
+	CrawlerFileCacheMaxBytes = conf.Int("crawler.filecache.max_bytes", CrawlerFileCacheMaxBytes)
+	CrawlerPartitionSize = conf.Int("crawler.partition_size", CrawlerPartitionSize)
+	CrawlerWorkers = conf.Int("crawler.workers", CrawlerWorkers)
 
 	NonStorePackageRegexps = conf.StringList("docdb.nonstore_regexps", nil)
+	DocsParts = conf.Int("docdb.parts", DocsParts)
+	if DocsParts <= 0 {
+		log.Fatalf("docdb.parts must be positive: %d", DocsParts)
+	}
+	MRWorkers = conf.Int("docdb.mr_workers", MRWorkers)
 
 	bi.DataPath = conf.String("bi.data_path", "/tmp/gcse.bolt")
 	BiWebPath = conf.String("bi.web_path", BiWebPath)
 
 	StoreDAddr = conf.String("stored.addr", StoreDAddr)
+	StoreBackend = conf.String("store.backend", StoreBackend)
+	StoreBackupBeforeMigration = conf.Bool("store.backup_before_migration", StoreBackupBeforeMigration)
+	StoreRemoteAddr = conf.String("store.remote_addr", StoreRemoteAddr)
+	StoreChangeRetention = conf.Duration("store.change_retention", StoreChangeRetention)
+	StoreHistoryDetailAge = conf.Duration("store.history_detail_age", StoreHistoryDetailAge)
+	StoreHistoryRetention = conf.Duration("store.history_retention", StoreHistoryRetention)
+	StoreRepoMaxAge = conf.Duration("store.repo_max_age", StoreRepoMaxAge)
+
+	IndexerFullInterval = conf.Duration("indexer.full_interval", IndexerFullInterval)
+	IndexerDeltaMaxRatio = conf.Float("indexer.delta_max_ratio", IndexerDeltaMaxRatio)
+	RankingBM25FK1 = conf.Float("ranking.bm25f.k1", RankingBM25FK1)
+	floatsOf(conf.Object("ranking.bm25f.weights", nil), RankingBM25FWeights)
+	floatsOf(conf.Object("ranking.bm25f.b", nil), RankingBM25FB)
+	RankingBlend = conf.String("ranking.blend.func", RankingBlend)
+	RankingStaticExp = conf.Float("ranking.blend.static_exp", RankingStaticExp)
+	RankingStaticWeight = conf.Float("ranking.blend.static_weight", RankingStaticWeight)
+	RankingFreshnessGrace = conf.Duration("ranking.freshness.grace", RankingFreshnessGrace)
+	RankingFreshnessHalfLife = conf.Duration("ranking.freshness.half_life", RankingFreshnessHalfLife)
+	RankingFreshnessMinFactor = conf.Float("ranking.freshness.min_factor", RankingFreshnessMinFactor)
+	RankingReleaseBonus = conf.Float("ranking.release.bonus", RankingReleaseBonus)
+	RankingReleaseWindow = conf.Duration("ranking.release.window", RankingReleaseWindow)
+	floatsOf(conf.Object("ranking.host_factors", nil), RankingHostFactors)
+
+	IndexerKeepSegments = conf.Int("indexer.keep_segments", IndexerKeepSegments)
+	IndexerMaxDocDrop = conf.Float("indexer.max_doc_drop", IndexerMaxDocDrop)
+	IndexerMaxZeroScoreRatio = conf.Float("indexer.max_zero_score_ratio", IndexerMaxZeroScoreRatio)
+	if queries := conf.Object("indexer.sample_queries", nil); queries != nil {
+		IndexerSampleQueries = make(map[string][]string)
+		for q, pkgs := range queries {
+			list, _ := pkgs.([]interface{})
+			for _, pkg := range list {
+				if s, ok := pkg.(string); ok {
+					IndexerSampleQueries[q] = append(IndexerSampleQueries[q], s)
+				}
+			}
+		}
+	}
+
+	PipelineAddr = conf.String("pipeline.addr", PipelineAddr)
+	PipelineInterval = conf.Duration("pipeline.interval", PipelineInterval)
+	PipelineRetries = conf.Int("pipeline.retries", PipelineRetries)
+	PipelineRetryDelay = conf.Duration("pipeline.retry_delay", PipelineRetryDelay)
+	// The crawler stops itself after CrawlerDuePerRun.
+	PipelineStageTimeouts["crawler"] = CrawlerDuePerRun + 30*time.Minute
+	for stage, d := range PipelineStageTimeouts {
+		PipelineStageTimeouts[stage] = conf.Duration("pipeline.timeout."+stage, d)
+	}
+	PipelineKeepRuns = conf.Int("pipeline.keep_runs", PipelineKeepRuns)
 
 	LogDir = conf.String("log.dir", LogDir)
 }
 
+// floatsOf sets the numbers in obj to m.
+func floatsOf(obj map[string]interface{}, m map[string]float64) {
+	for k, v := range obj {
+		if f, ok := v.(float64); ok {
+			m[k] = f
+		}
+	}
+}
+
 func DataRootFsPath() sophie.FsPath {
 	return sophie.LocalFsPath(DataRoot.S())
 }
@@ -125,12 +324,37 @@ func CrawlerDBFsPath() sophie.FsPath {
 	return DataRootFsPath().Join(fnCrawlerDB)
 }
 
+// DocsGenerations returns the segments of the generations of the docs.
+func DocsGenerations() utils.Segments {
+	return utils.Segments(DataRoot.Join(fnDocsGens))
+}
+
+// CurrentDocsGeneration returns the generation of the docs named by
+// FnDocsGenCurrent, empty if none, i.e. the docs are in the legacy folder
+// DataRoot/FnDocs.
+func CurrentDocsGeneration() utils.Segment {
+	gens := DocsGenerations()
+	bs, err := ioutil.ReadFile(filepath.Join(string(gens), FnDocsGenCurrent))
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimSpace(string(bs))
+	if name == "" {
+		return ""
+	}
+	return gens.Join(name)
+}
+
+// DocsDBPath returns the folder of the docs of the current generation.
 func DocsDBPath() string {
+	if gen := CurrentDocsGeneration(); gen != "" {
+		return gen.Join(FnDocs)
+	}
 	return DataRoot.Join(FnDocs).S()
 }
 
 func DocsDBFsPath() sophie.FsPath {
-	return DataRootFsPath().Join(FnDocs)
+	return sophie.LocalFsPath(DocsDBPath())
 }
 
 func ToCrawlPath() string {
@@ -149,6 +373,33 @@ func StoreBoltPath() string {
 	return DataRoot.Join("store.bolt").S()
 }
 
+func StoreSQLitePath() string {
+	return DataRoot.Join("store.sqlite").S()
+}
+
+// StoreBackupPath returns the path of the snapshot saved before migrating the
+// store, named by the current time so that earlier ones are kept.
+func StoreBackupPath() string {
+	return DataRoot.Join("store.backup." + time.Now().Format("20060102-150405") + ".bolt").S()
+}
+
+// StoreMigrationLockPath returns the path of the lock file held while
+// migrating the store.
+func StoreMigrationLockPath() string {
+	return DataRoot.Join("store.migrate.lock").S()
+}
+
+// StoreBackupDir returns the default directory of the backups of the store.
+func StoreBackupDir() string {
+	return DataRoot.Join("backups").S()
+}
+
+// PipelinePath returns the directory of the run records and the lock of
+// gcse-pipeline.
+func PipelinePath() villa.Path {
+	return DataRoot.Join("pipeline")
+}
+
 func FileCacheBoltPath() string {
 	return DataRoot.Join("filecache.bolt").S()
 }
@@ -3,11 +3,11 @@ package gcse
 import (
 	"context"
 	"errors"
-	"net/http"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/golangplus/bytes"
 	"github.com/golangplus/testing/assert"
 
@@ -15,6 +15,8 @@ import (
 	"github.com/daviddengcn/gcse/spider/github"
 	"github.com/daviddengcn/gddo/doc"
 	"github.com/daviddengcn/go-villa"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
 )
 
 func TestReadmeToText(t *testing.T) {
@@ -26,29 +28,6 @@ func TestReadmeToText_Panic(t *testing.T) {
 	ReadmeToText("a.md", "* [[t]](/t)")
 }
 
-func TestPlusone(t *testing.T) {
-	url := "http://www.google.com/"
-	cnt, err := Plusone(http.DefaultClient, url)
-	assert.NoError(t, err)
-	t.Logf("Plusone of %s: %d", url, cnt)
-	//	if cnt <= 0 {
-	//		t.Errorf("Zero Plusone count for %s", url)
-	//	}
-}
-
-func TestLikeButton(t *testing.T) {
-	url := "http://www.facebook.com/"
-	cnt, err := LikeButton(http.DefaultClient, url)
-	if err != nil {
-		t.Error(err)
-		return
-	}
-	t.Logf("LikeButton of %s: %d", url, cnt)
-	if cnt <= 0 {
-		//		t.Errorf("Zero LikeButton count for %s", url)
-	}
-}
-
 func TestCrawlPackage(t *testing.T) {
 	ctx := context.Background()
 
@@ -132,6 +111,8 @@ func TestDocDB_Export(t *testing.T) {
 		t.Errorf("db.Export failed: %v", err)
 		return
 	}
+	defer villa.Path("testexport_db.gob").RemoveAll()
+	defer villa.Path("testexport_db.gob.wal").RemoveAll()
 
 	var newDB DocDB = PackedDocDB{NewMemDB(villa.Path("."), "testexport_db")}
 	count := 0
@@ -183,3 +164,23 @@ func TestFullProjectOfPackage(t *testing.T) {
 		assert.Equal(t, "FullProjectOfPackage "+pkg, FullProjectOfPackage(pkg), prj)
 	}
 }
+
+func TestMergeListedRepoInfo(t *testing.T) {
+	ts := func(sec int64) *timestamp.Timestamp {
+		return &timestamp.Timestamp{Seconds: sec}
+	}
+	old := &gpb.RepoInfo{LastUpdated: ts(10), LatestCommit: ts(9), LatestRelease: ts(8)}
+
+	// Not pushed since, the dates are still those of the repository.
+	ri := mergeListedRepoInfo(old, &gpb.RepoInfo{Stars: 5, LastUpdated: ts(10)})
+	assert.Equal(t, "ri", ri, &gpb.RepoInfo{Stars: 5, LastUpdated: ts(10), LatestCommit: ts(9), LatestRelease: ts(8)})
+
+	// Pushed since, the old LastUpdated is kept with the dates so that they
+	// are read again.
+	ri = mergeListedRepoInfo(old, &gpb.RepoInfo{Stars: 5, LastUpdated: ts(20)})
+	assert.Equal(t, "ri", ri, &gpb.RepoInfo{Stars: 5, LastUpdated: ts(10), LatestCommit: ts(9), LatestRelease: ts(8)})
+
+	// No dates known.
+	ri = mergeListedRepoInfo(nil, &gpb.RepoInfo{Stars: 5, LastUpdated: ts(20)})
+	assert.Equal(t, "ri", ri, &gpb.RepoInfo{Stars: 5, LastUpdated: ts(20)})
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/golangplus/errors"
+
 	"github.com/daviddengcn/gcse/configs"
 	"github.com/daviddengcn/gddo/doc"
 )
@@ -22,8 +24,8 @@ const (
  * CrawlerDB including all crawler entires database.
  */
 type CrawlerDB struct {
-	PackageDB *MemDB
-	PersonDB  *MemDB
+	PackageDB *TypedDB[CrawlingEntry]
+	PersonDB  *TypedDB[CrawlingEntry]
 }
 
 // LoadCrawlerDB loads PackageDB and PersonDB and returns a new *CrawlerDB
@@ -33,11 +35,29 @@ func LoadCrawlerDB() *CrawlerDB {
 	log.Printf("Loading CrawlerDB from %s", root)
 
 	return &CrawlerDB{
-		PackageDB: NewMemDB(root, KindPackage),
-		PersonDB:  NewMemDB(root, KindPerson),
+		PackageDB: NewTypedDB[CrawlingEntry](root, KindPackage),
+		PersonDB:  NewTypedDB[CrawlingEntry](root, KindPerson),
 	}
 }
 
+// OpenCrawlerDB is the same as LoadCrawlerDB but fails if PackageDB or
+// PersonDB fails to load, e.g. when it is locked by another process.
+func OpenCrawlerDB() (*CrawlerDB, error) {
+	root := configs.CrawlerDBPath()
+	pkgDB, err := OpenTypedDB[CrawlingEntry](root, KindPackage)
+	if err != nil {
+		pkgDB.Close()
+		return nil, errorsp.WithStacksAndMessage(err, "loading %s in %v failed", KindPackage, root)
+	}
+	personDB, err := OpenTypedDB[CrawlingEntry](root, KindPerson)
+	if err != nil {
+		pkgDB.Close()
+		personDB.Close()
+		return nil, errorsp.WithStacksAndMessage(err, "loading %s in %v failed", KindPerson, root)
+	}
+	return &CrawlerDB{PackageDB: pkgDB, PersonDB: personDB}, nil
+}
+
 // Sync syncs both PackageDB and PersonDB. Returns error if any of the sync
 // failed.
 func (cdb *CrawlerDB) Sync() error {
@@ -53,6 +73,15 @@ func (cdb *CrawlerDB) Sync() error {
 	return nil
 }
 
+// Close closes both PackageDB and PersonDB.
+func (cdb *CrawlerDB) Close() error {
+	err := cdb.PackageDB.Close()
+	if err2 := cdb.PersonDB.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
 // SchedulePackage schedules a package to be crawled at a specific time.
 func (cdb *CrawlerDB) SchedulePackage(pkg string, sTime time.Time, etag string) error {
 	ent := CrawlingEntry{
@@ -71,8 +100,8 @@ func (cdb *CrawlerDB) SchedulePackage(pkg string, sTime time.Time, etag string)
 // not specified earlier.
 func (cdb *CrawlerDB) PushToCrawlPackage(pkg string) {
 	now := time.Now()
-	var ent CrawlingEntry
-	if cdb.PackageDB.Get(pkg, &ent) {
+	ent, ok := cdb.PackageDB.Get(pkg)
+	if ok {
 		if ent.ScheduleTime.Before(now) {
 			// The package has been scheduled to an earlier time.
 			return
@@ -95,8 +124,7 @@ func (cdb *CrawlerDB) AppendPackage(pkg string, inDocs func(pkg string) bool) {
 	if !doc.IsValidRemotePath(pkg) {
 		return
 	}
-	var ent CrawlingEntry
-	if cdb.PackageDB.Get(pkg, &ent) {
+	if ent, ok := cdb.PackageDB.Get(pkg); ok {
 		if ent.ScheduleTime.Before(time.Now()) || inDocs(pkg) {
 			return
 		}
@@ -126,9 +154,7 @@ func (cdb *CrawlerDB) SchedulePerson(id string, sTime time.Time) error {
 func (cdb *CrawlerDB) AppendPerson(site, username string) bool {
 	id := SitePersonID(site, username)
 
-	var ent CrawlingEntry
-	exists := cdb.PersonDB.Get(id, &ent)
-	if exists {
+	if _, exists := cdb.PersonDB.Get(id); exists {
 		// already scheduled
 		return false
 	}
@@ -2,6 +2,7 @@ package gcse
 
 import (
 	"encoding/gob"
+	"hash/fnv"
 	"regexp"
 	"strings"
 	"time"
@@ -31,6 +32,50 @@ type DocInfo struct {
 	Imports     []string
 	TestImports []string
 	Exported    []string // exported tokens(funcs/types)
+	License     string   // SPDX identifier, "" if unknown
+
+	ForkedFrom string // Full path of the repository this one is forked from
+	Archived   bool   // Whether the repository is archived by the owner
+	// Signature of the Go files of the package, identical for the copies of
+	// a package in forks and mirrors. Empty if unknown.
+	Signature string
+
+	// Deprecation message from the "Deprecated:" paragraph of the package
+	// comment or the module comment in go.mod. Empty if not deprecated.
+	Deprecated string
+	Retracted  []string // Versions retracted by the go.mod file
+
+	Quality QualityInfo
+
+	// The activity of the repository, zero if unknown: the latest push, the
+	// latest commit of the default branch and the latest release.
+	RepoUpdated   time.Time
+	LatestCommit  time.Time
+	LatestRelease time.Time
+	// When the Go files were found changed, i.e. a different Signature from
+	// the previous crawl, by the crawl history. Zero if unknown.
+	Changed time.Time
+}
+
+// QualityInfo is the quality signals of a package computed by the crawler.
+type QualityInfo struct {
+	TestFiles  int
+	TestSize   int // Total size of the test files in bytes
+	Examples   int // Number of runnable Example functions
+	Exported   int // Number of exported identifiers
+	Documented int // Number of exported identifiers with doc comments
+	UsesCgo    bool
+	UsesUnsafe bool
+	HasReadme  bool
+}
+
+// DocCoverage returns the fraction of exported identifiers with doc
+// comments. 0 is returned if nothing is exported.
+func (q *QualityInfo) DocCoverage() float64 {
+	if q.Exported == 0 {
+		return 0
+	}
+	return float64(q.Documented) / float64(q.Exported)
 }
 
 // Returns a new instance of DocInfo as a sophie.Sophier
@@ -64,6 +109,21 @@ type HitInfo struct {
 	StaticScore       float64
 	TestStaticScore   float64
 	StaticRank        int // zero-based
+	// PageRank in the import graph, scaled so that the average is 1.
+	PageRank float64
+	// The latest crawl time of all the docs, the freshness of the hit is
+	// relative to. Zero if unknown.
+	IndexTime time.Time
+
+	// The canonical package if this one is a copy of it in a fork or a
+	// mirror, empty otherwise.
+	ForkOf string
+
+	// The numbers of the tokens in the fields scored by BM25F, indexed by the
+	// BM25F* constants.
+	FieldLens []int
+	// The counts of the tokens of the readme, except the pairs of words.
+	ReadmeTerms map[string]int
 }
 
 func init() {
@@ -162,7 +222,7 @@ func CheckCamel(last, current rune) index.RuneType {
 }
 
 // a block does not contain blanks
-func appendTokensOfBlock(tokens stringsp.Set, block []byte) stringsp.Set {
+func forEachTokenOfBlock(block []byte, output func(token string)) {
 	lastToken := ""
 	index.Tokenize(CheckRuneType, (*bytesp.Slice)(&block),
 		func(token []byte) error {
@@ -174,10 +234,10 @@ func appendTokensOfBlock(tokens stringsp.Set, block []byte) stringsp.Set {
 						tokenStr := string(token)
 						tokenStr = NormWord(tokenStr)
 						if !stopWords.Contain(tokenStr) {
-							tokens.Add(tokenStr)
+							output(tokenStr)
 						}
 						if last != "" {
-							tokens.Add(last + string(tokenStr))
+							output(last + string(tokenStr))
 						}
 						last = tokenStr
 						return nil
@@ -185,49 +245,60 @@ func appendTokensOfBlock(tokens stringsp.Set, block []byte) stringsp.Set {
 			}
 			tokenStr = NormWord(tokenStr)
 			if !stopWords.Contain(tokenStr) {
-				tokens.Add(tokenStr)
+				output(tokenStr)
 			}
 			if lastToken != "" {
 				if tokenStr[0] > 128 && lastToken[0] > 128 {
 					// Chinese bigrams
-					tokens.Add(lastToken + tokenStr)
+					output(lastToken + tokenStr)
 				} else if tokenStr[0] <= 128 && lastToken[0] <= 128 {
-					tokens.Add(lastToken + "-" + tokenStr)
+					output(lastToken + "-" + tokenStr)
 				}
 			}
 			lastToken = tokenStr
 			return nil
 		})
-	return tokens
 }
 
-// Tokenizes text into the current token set.
-func AppendTokens(tokens stringsp.Set, text []byte) stringsp.Set {
+// forEachToken calls output with each occurrence of the tokens of text.
+func forEachToken(text []byte, output func(token string)) {
 	textBuf := filterURLs(text)
 	textBuf = filterEmails(textBuf)
 
 	index.Tokenize(index.SeparatorFRuneTypeFunc(unicode.IsSpace),
 		(*bytesp.Slice)(&textBuf), func(block []byte) error {
-			tokens = appendTokensOfBlock(tokens, block)
+			forEachTokenOfBlock(block, output)
 			return nil
 		})
+}
 
+// Tokenizes text into the current token set.
+func AppendTokens(tokens stringsp.Set, text []byte) stringsp.Set {
+	forEachToken(text, func(token string) {
+		tokens.Add(token)
+	})
 	return tokens
 }
 
-const (
-	DOCS_PARTS = 128
-)
-
-func CalcPackagePartition(pkg string, totalParts int) int {
-	hash := 0
-	for i, l := 0, len(pkg); i < l; i++ {
-		b := pkg[i]
-		hash = hash*33 + int(b)
-		if hash > totalParts {
-			hash = hash % totalParts
-		}
+// CountTokens adds the number of the occurrences of each token of text to
+// counts, which is allocated if nil, and returns it with the total number of
+// the occurrences.
+func CountTokens(counts map[string]int, text []byte) (map[string]int, int) {
+	if counts == nil {
+		counts = make(map[string]int)
 	}
+	total := 0
+	forEachToken(text, func(token string) {
+		counts[token]++
+		total++
+	})
+	return counts, total
+}
 
-	return hash
+// CalcPackagePartition returns the partition, in [0, totalParts), of a
+// package, by the FNV-1a hash of it.
+func CalcPackagePartition(pkg string, totalParts int) int {
+	h := fnv.New32a()
+	h.Write([]byte(pkg))
+	return int(h.Sum32() % uint32(totalParts))
 }
@@ -1,6 +1,7 @@
 package gcse
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -61,3 +62,18 @@ func TestCheckRuneType_BOM(t *testing.T) {
 	tp := CheckRuneType('A', 0xfeff)
 	assert.Equal(t, "CheckRuneType(A, 0xfeff)", tp, index.TokenSep)
 }
+
+func TestCalcPackagePartition(t *testing.T) {
+	assert.Equal(t, "part", CalcPackagePartition("github.com/daviddengcn/gcse", 128), CalcPackagePartition("github.com/daviddengcn/gcse", 128))
+
+	// Similar packages are well distributed.
+	counts := make([]int, 16)
+	for i := 0; i < 1600; i++ {
+		part := CalcPackagePartition(fmt.Sprintf("github.com/user%d/repo", i), len(counts))
+		assert.True(t, "part in range", part >= 0 && part < len(counts))
+		counts[part]++
+	}
+	for part, cnt := range counts {
+		assert.True(t, fmt.Sprintf("counts[%d] = %d", part, cnt), cnt > 50 && cnt < 150)
+	}
+}
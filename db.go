@@ -2,7 +2,6 @@ package gcse
 
 import (
 	"encoding/gob"
-	"io"
 	"log"
 	"os"
 	"reflect"
@@ -15,6 +14,8 @@ import (
 	"github.com/daviddengcn/go-villa"
 )
 
+// MemDB is an in-memory key-value DB persisted as a gob snapshot of the whole
+// map plus a write-ahead log (WAL) of the Puts and Deletes since then.
 type MemDB struct {
 	db map[string]interface{}
 	fn villa.Path
@@ -22,6 +23,11 @@ type MemDB struct {
 	syncMutex    sync.Mutex // if lock both mutexes, lock RWMutex first
 	lastModified time.Time
 	modified     bool
+
+	// Appended in Put/Delete and fsynced in Sync. Nil if fn is empty or
+	// opening it failed.
+	wal          *walLog
+	snapshotSize int64
 }
 
 func NewMemDB(root villa.Path, kind string) *MemDB {
@@ -52,6 +58,10 @@ func (mdb *MemDB) LastModified() time.Time {
 	return mdb.lastModified
 }
 
+// Load loads the snapshot and replays the WAL. A torn record at the end of
+// the WAL, e.g. written partially before a crash, is ignored, and truncated on
+// the first modification, which opens the WAL for writing under an exclusive
+// lock.
 func (mdb *MemDB) Load() error {
 	if mdb.fn == "" {
 		return nil
@@ -59,67 +69,44 @@ func (mdb *MemDB) Load() error {
 	mdb.Lock()
 	defer mdb.Unlock()
 
-	lastModified := time.Now()
-	if st, err := mdb.fn.Stat(); err == nil {
-		lastModified = st.ModTime()
+	if mdb.wal != nil {
+		mdb.wal.close()
+		mdb.wal = nil
 	}
+	mdb.db = make(map[string]interface{})
 
-	if f, err := mdb.fn.Open(); err == nil {
-		defer f.Close()
-
-		dec := gob.NewDecoder(f)
-		if err := dec.Decode(&mdb.db); err != nil {
-			return err
-		}
-	} else if os.IsNotExist(err) {
-		// try recover from fn.new
-		if f, err := (mdb.fn + ".new").Open(); err == nil {
-			defer f.Close()
-
-			dec := gob.NewDecoder(f)
-			if err := dec.Decode(&mdb.db); err != nil {
-				return err
-			}
-		} else if os.IsNotExist(err) {
-			// just an empty db
-			mdb.db = make(map[string]interface{})
-		} else {
-			return err
-		}
-	} else {
+	size, lastModified, err := loadSnapshot(mdb.fn, &mdb.db)
+	if err != nil {
 		return err
 	}
+	if lastModified.IsZero() {
+		lastModified = time.Now()
+	}
+	mdb.snapshotSize = size
 
-	mdb.lastModified = lastModified
-	mdb.modified = false
-	return nil
-}
-
-// 1) save to fn.new; 2) remove fn; 3) rename fn.new to fn.
-func safeSave(fn villa.Path, doSave func(w io.Writer) error) error {
-	tmpFn := fn + ".new"
-	if err := func() error {
-		f, err := tmpFn.Create()
-		if err != nil {
-			return err
+	wal, walModified, err := openWALLog(mdb.fn+".wal", func(r walRecord) {
+		if r.Delete {
+			delete(mdb.db, r.Key)
+		} else {
+			mdb.db[r.Key] = r.Value
 		}
-		defer f.Close()
-
-		return doSave(f)
-	}(); err != nil {
+	})
+	if err != nil {
 		return err
 	}
-
-	if err := fn.Remove(); err != nil && !os.IsNotExist(err) {
-		return err
-	}
-	if err := tmpFn.Rename(fn); err != nil {
-		return err
+	mdb.wal = wal
+	if walModified.After(lastModified) {
+		lastModified = walModified
 	}
 
+	mdb.lastModified = lastModified
+	mdb.modified = false
 	return nil
 }
 
+// Sync makes the modifications durable. Usually only the WAL is flushed and
+// fsynced. A snapshot is saved and the WAL is truncated if there is no
+// snapshot yet, the WAL has grown too large, or appending to it failed.
 func (mdb *MemDB) Sync() error {
 	if mdb.fn == "" {
 		// this db is not for syncing
@@ -136,15 +123,67 @@ func (mdb *MemDB) Sync() error {
 	mdb.syncMutex.Lock()
 	defer mdb.syncMutex.Unlock()
 
-	if err := safeSave(mdb.fn, func(w io.Writer) error {
-		enc := gob.NewEncoder(w)
-		return enc.Encode(mdb.db)
-	}); err != nil {
-		return err
+	if mdb.wal != nil && !mdb.wal.shouldCheckpoint(mdb.snapshotSize) {
+		err := mdb.wal.sync()
+		if err == nil {
+			mdb.modified = false
+			return nil
+		}
+		log.Printf("Syncing %v failed, falling back to a checkpoint: %v", mdb.wal.fn, err)
+	}
+	return mdb.checkpoint()
+}
+
+// Checkpoint saves a snapshot of the DB and truncates the WAL.
+func (mdb *MemDB) Checkpoint() error {
+	if mdb.fn == "" {
+		return nil
+	}
+
+	mdb.RLock()
+	defer mdb.RUnlock()
+
+	mdb.syncMutex.Lock()
+	defer mdb.syncMutex.Unlock()
+
+	return mdb.checkpoint()
+}
+
+// Close closes the WAL, releasing its lock if opened for writing. The
+// modifications not synced are lost, and the DB should not be used after.
+func (mdb *MemDB) Close() error {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	if mdb.wal == nil {
+		return nil
 	}
+	err := mdb.wal.close()
+	mdb.wal = nil
+	return err
+}
 
+// checkpoint is called with the RLock and syncMutex held. The WAL is
+// truncated after the snapshot is renamed, so a crash in between only
+// replays the records already in the snapshot.
+func (mdb *MemDB) checkpoint() error {
+	if mdb.wal != nil {
+		// Fails before saving the snapshot if another process writes the WAL.
+		if err := mdb.wal.openWriter(); err != nil {
+			return err
+		}
+	}
+	size, err := saveSnapshot(mdb.fn, mdb.db)
+	if err != nil {
+		return err
+	}
+	mdb.snapshotSize = size
 	mdb.modified = false
-	return nil
+
+	if mdb.wal == nil {
+		return nil
+	}
+	return mdb.wal.truncate()
 }
 
 /*
@@ -184,6 +223,9 @@ func (mdb *MemDB) Put(key string, data interface{}) {
 	defer mdb.Unlock()
 
 	mdb.db[key] = data
+	if mdb.wal != nil {
+		mdb.wal.append(walRecord{Key: key, Value: data})
+	}
 	mdb.lastModified = time.Now()
 	mdb.modified = true
 }
@@ -193,6 +235,9 @@ func (mdb *MemDB) Delete(key string) {
 	defer mdb.Unlock()
 
 	delete(mdb.db, key)
+	if mdb.wal != nil {
+		mdb.wal.append(walRecord{Key: key, Delete: true})
+	}
 	mdb.lastModified = time.Now()
 	mdb.modified = true
 }
@@ -1,6 +1,8 @@
 package gcse
 
 import (
+	"fmt"
+	"os"
 	"testing"
 
 	"github.com/daviddengcn/go-villa"
@@ -15,6 +17,7 @@ func TestMemDB_Bug_Sync(t *testing.T) {
 
 	// Cleanup after ourselves.
 	defer villa.Path(path.S() + ".new").RemoveAll()
+	defer villa.Path(path.S() + ".wal").RemoveAll()
 
 	db := NewMemDB(".", "testmemdb")
 	db.Put("s", 1)
@@ -41,6 +44,7 @@ func TestMemDB_Recover(t *testing.T) {
 
 	// Cleanup after ourselves.
 	defer villa.Path(path.S() + ".new").RemoveAll()
+	defer villa.Path(path.S() + ".wal").RemoveAll()
 
 	db := NewMemDB(".", "testmemdb")
 	db.Put("s", 1)
@@ -67,3 +71,181 @@ func TestMemDB_Recover(t *testing.T) {
 	}
 	assert.Equal(t, "vl", vl, 1)
 }
+
+func newTestMemDBRoot(t *testing.T, name string) villa.Path {
+	root := villa.Path(os.TempDir()).Join(name)
+	assert.NoErrorOrDie(t, root.RemoveAll())
+	assert.NoErrorOrDie(t, root.MkdirAll(0755))
+	return root
+}
+
+func memDBContents(db *MemDB) map[string]interface{} {
+	m := make(map[string]interface{})
+	db.Iterate(func(k string, v interface{}) error {
+		m[k] = v
+		return nil
+	})
+	return m
+}
+
+func TestMemDB_WAL(t *testing.T) {
+	root := newTestMemDBRoot(t, "TestMemDB_WAL")
+	defer root.RemoveAll()
+
+	db := NewMemDB(root, "db")
+	db.Put("a", 1)
+	db.Put("b", 2)
+	// The first Sync saves a snapshot.
+	assert.NoError(t, db.Sync())
+	st, err := root.Join("db.gob").Stat()
+	assert.NoErrorOrDie(t, err)
+	snapshotTime := st.ModTime()
+
+	db.Put("c", 3)
+	db.Delete("a")
+	db.Put("b", 4)
+	assert.NoError(t, db.Sync())
+	// Only the WAL is written.
+	st, err = root.Join("db.gob").Stat()
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "snapshot time", st.ModTime(), snapshotTime)
+	assert.True(t, "wal.size > 0", db.wal.size > 0)
+
+	expected := map[string]interface{}{"b": 4, "c": 3}
+	assert.Equal(t, "contents", memDBContents(NewMemDB(root, "db")), expected)
+
+	assert.NoError(t, db.Checkpoint())
+	assert.Equal(t, "wal.size", db.wal.size, int64(0))
+	st, err = root.Join("db.gob.wal").Stat()
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "WAL size", st.Size(), int64(0))
+	assert.Equal(t, "contents", memDBContents(NewMemDB(root, "db")), expected)
+}
+
+func TestMemDB_WALCrash(t *testing.T) {
+	root := newTestMemDBRoot(t, "TestMemDB_WALCrash")
+	defer root.RemoveAll()
+
+	db := NewMemDB(root, "db")
+	db.Put("base", 0)
+	assert.NoError(t, db.Sync())
+	snapshot, err := root.Join("db.gob").ReadFile()
+	assert.NoErrorOrDie(t, err)
+
+	// The contents after each operation.
+	states := []map[string]interface{}{memDBContents(db)}
+	for i := 0; i < 5; i++ {
+		db.Put(fmt.Sprint(i), i)
+		states = append(states, memDBContents(db))
+		if i%2 == 1 {
+			db.Delete(fmt.Sprint(i - 1))
+			states = append(states, memDBContents(db))
+		}
+	}
+	assert.NoError(t, db.Sync())
+	wal, err := root.Join("db.gob.wal").ReadFile()
+	assert.NoErrorOrDie(t, err)
+
+	crashed := villa.Path(os.TempDir()).Join("TestMemDB_WALCrash_crashed")
+	defer crashed.RemoveAll()
+	// Crashes after writing every possible prefix of the WAL.
+	for n := 0; n <= len(wal); n++ {
+		assert.NoErrorOrDie(t, crashed.RemoveAll())
+		assert.NoErrorOrDie(t, crashed.MkdirAll(0755))
+		assert.NoErrorOrDie(t, crashed.Join("db.gob").WriteFile(snapshot, 0644))
+		assert.NoErrorOrDie(t, crashed.Join("db.gob.wal").WriteFile(wal[:n], 0644))
+
+		got := memDBContents(NewMemDB(crashed, "db"))
+		found := false
+		for _, s := range states {
+			if fmt.Sprint(s) == fmt.Sprint(got) {
+				found = true
+				break
+			}
+		}
+		assert.True(t, fmt.Sprintf("%d bytes: %v is a state", n, got), found)
+		if n == len(wal) {
+			assert.Equal(t, "contents", got, states[len(states)-1])
+		}
+		// The torn record is truncated so that new records are readable.
+		db2 := NewMemDB(crashed, "db")
+		db2.Put("new", 100)
+		assert.NoError(t, db2.Sync())
+		assert.NoError(t, db2.Close())
+		var v int
+		assert.True(t, "Get new", NewMemDB(crashed, "db").Get("new", &v))
+		assert.Equal(t, "v", v, 100)
+	}
+
+	// Garbage at the end, e.g. a corrupted sector, is dropped too.
+	assert.NoErrorOrDie(t, crashed.Join("db.gob.wal").WriteFile(append(append([]byte(nil), wal...), 1, 2, 3, 4, 5, 6, 7, 8, 9), 0644))
+	assert.NoErrorOrDie(t, crashed.Join("db.gob").WriteFile(snapshot, 0644))
+	assert.Equal(t, "contents", memDBContents(NewMemDB(crashed, "db")), states[len(states)-1])
+}
+
+func TestMemDB_WALReader(t *testing.T) {
+	root := newTestMemDBRoot(t, "TestMemDB_WALReader")
+	defer root.RemoveAll()
+
+	db := NewMemDB(root, "db")
+	defer db.Close()
+	db.Put("a", 1)
+	assert.NoError(t, db.Sync())
+	db.Put("b", 2)
+	assert.NoError(t, db.Sync())
+	expected := memDBContents(db)
+
+	// A record being appended by the writer.
+	walFn := root.Join("db.gob.wal")
+	f, err := os.OpenFile(walFn.S(), os.O_WRONLY|os.O_APPEND, 0)
+	assert.NoErrorOrDie(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 100, 1, 2})
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	st, err := walFn.Stat()
+	assert.NoErrorOrDie(t, err)
+	size := st.Size()
+
+	// Loading ignores the torn record without truncating it.
+	reader := NewMemDB(root, "db")
+	assert.Equal(t, "contents", memDBContents(reader), expected)
+	assert.NoError(t, reader.Close())
+	st, err = walFn.Stat()
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "WAL size", st.Size(), size)
+
+	// Another writer is refused while db writes the WAL.
+	other := NewMemDB(root, "db")
+	defer other.Close()
+	other.Put("c", 3)
+	assert.Error(t, other.Sync())
+	st, err = walFn.Stat()
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "WAL size", st.Size(), size)
+	assert.Equal(t, "contents", memDBContents(NewMemDB(root, "db")), expected)
+}
+
+func TestMemDB_CheckpointCrash(t *testing.T) {
+	root := newTestMemDBRoot(t, "TestMemDB_CheckpointCrash")
+	defer root.RemoveAll()
+
+	db := NewMemDB(root, "db")
+	db.Put("a", 1)
+	assert.NoError(t, db.Sync())
+	db.Put("b", 2)
+	db.Delete("a")
+	assert.NoError(t, db.Sync())
+	expected := memDBContents(db)
+
+	// Crashed while writing the new snapshot: fn and the WAL are intact.
+	assert.NoErrorOrDie(t, root.Join("db.gob.new").WriteFile([]byte("partial"), 0644))
+	assert.Equal(t, "contents", memDBContents(NewMemDB(root, "db")), expected)
+
+	// Crashed after renaming the snapshot but before truncating the WAL: the
+	// records are replayed on the snapshot which has them already.
+	wal, err := root.Join("db.gob.wal").ReadFile()
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, db.Checkpoint())
+	assert.NoErrorOrDie(t, root.Join("db.gob.wal").WriteFile(wal, 0644))
+	assert.Equal(t, "contents", memDBContents(NewMemDB(root, "db")), expected)
+}
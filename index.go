@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/golangplus/errors"
@@ -61,8 +62,21 @@ func filterDocInfo(docInfo *DocInfo) {
 	}
 }
 
+// indexAndSaveHits indexes hits in the order of idxs with the StaticRank of
+// each as its position, ties sharing the same rank.
 func indexAndSaveHits(ts *index.TokenSetSearcher, hits []HitInfo, idxs []int, saveFullHit func(*HitInfo) error) error {
 	rank := 0
+	return indexAndSaveRankedHits(ts, hits, idxs, func(i int) int {
+		if i > 0 && hits[idxs[i]].StaticScore < hits[idxs[i-1]].StaticScore {
+			rank = i
+		}
+		return rank
+	}, saveFullHit)
+}
+
+// indexAndSaveRankedHits indexes hits in the order of idxs, with the
+// StaticRank of the i-th one set to rankOf(i), which is called in order.
+func indexAndSaveRankedHits(ts *index.TokenSetSearcher, hits []HitInfo, idxs []int, rankOf func(i int) int, saveFullHit func(*HitInfo) error) error {
 	var bar *pb.ProgressBar
 	if terminal.IsTerminal(int(os.Stdout.Fd())) {
 		bar = pb.New(len(idxs))
@@ -70,10 +84,7 @@ func indexAndSaveHits(ts *index.TokenSetSearcher, hits []HitInfo, idxs []int, sa
 	}
 	for i := range idxs {
 		hit := &hits[idxs[i]]
-		if i > 0 && hit.StaticScore < hits[idxs[i-1]].StaticScore {
-			rank = i
-		}
-		hit.StaticRank = rank
+		hit.StaticRank = rankOf(i)
 
 		if err := saveFullHit(hit); err != nil {
 			return err
@@ -113,31 +124,103 @@ func indexAndSaveHits(ts *index.TokenSetSearcher, hits []HitInfo, idxs []int, sa
 	return nil
 }
 
-func Index(docDB mr.Input, outDir string) (*index.TokenSetSearcher, error) {
-	utils.DumpMemStats()
+// canonicalLess returns true if a is a better choice than b as the canonical
+// one among the copies of a package: not a fork, not archived, more stars and
+// then shorter path.
+func canonicalLess(a, b *HitInfo) bool {
+	if (a.ForkedFrom == "") != (b.ForkedFrom == "") {
+		return a.ForkedFrom == ""
+	}
+	if a.Archived != b.Archived {
+		return !a.Archived
+	}
+	if a.StarCount != b.StarCount {
+		return a.StarCount > b.StarCount
+	}
+	if len(a.Package) != len(b.Package) {
+		return len(a.Package) < len(b.Package)
+	}
+	return a.Package < b.Package
+}
 
-	docPartCnt, err := docDB.PartCount()
-	if err != nil {
-		return nil, err
+// markForks sets the ForkOf field of the hits which are copies of other
+// packages. A package is a copy if its repository is a known fork of the
+// repository of an indexed package with the same relative path, or if its Go
+// files have the same signature as a package of another project.
+func markForks(hits []HitInfo) {
+	idxOfPkg := make(map[string]int, len(hits))
+	for i := range hits {
+		idxOfPkg[hits[i].Package] = i
+	}
+	for i := range hits {
+		hit := &hits[i]
+		if hit.ForkedFrom == "" {
+			continue
+		}
+		org := hit.ForkedFrom + strings.TrimPrefix(hit.Package, FullProjectOfPackage(hit.Package))
+		if _, ok := idxOfPkg[org]; ok && org != hit.Package {
+			hit.ForkOf = org
+		}
 	}
-	docCount := 0
 
-	log.Printf("Generating importsDB ...")
-	importsDB := NewTokenIndexer("", "")
-	testImportsDB := NewTokenIndexer("", "")
-	// per project imported by projects
-	prjImportsDB := NewTokenIndexer("", "")
-	type projectStart struct {
-		StarCount   int
-		LastUpdated time.Time
+	idxsOfSign := make(map[string][]int)
+	for i := range hits {
+		if sign := hits[i].Signature; sign != "" && hits[i].ForkOf == "" {
+			idxsOfSign[sign] = append(idxsOfSign[sign], i)
+		}
 	}
-	prjStars := make(map[string]projectStart)
+	for _, idxs := range idxsOfSign {
+		if len(idxs) < 2 {
+			continue
+		}
+		best := idxs[0]
+		for _, idx := range idxs[1:] {
+			if canonicalLess(&hits[idx], &hits[best]) {
+				best = idx
+			}
+		}
+		bestPrj := FullProjectOfPackage(hits[best].Package)
+		for _, idx := range idxs {
+			// Same files in different folders of a project are not copies.
+			if idx != best && FullProjectOfPackage(hits[idx].Package) != bestPrj {
+				hits[idx].ForkOf = hits[best].Package
+			}
+		}
+	}
+}
+
+type projectStart struct {
+	StarCount   int
+	LastUpdated time.Time
+}
+
+// indexContext is the information of all the docs needed to compute the
+// HitInfo of any of them.
+type indexContext struct {
+	importsDB     *TokenIndexer
+	testImportsDB *TokenIndexer
+	// per project imported by projects
+	prjImportsDB *TokenIndexer
+	prjStars     map[string]projectStart
+	// The canonical packages of the copies, see markForks.
+	forkOf map[string]string
+	// The PageRanks of the packages in the import graph.
+	pageRank map[string]float64
+	// The latest crawl time of the docs, see HitInfo.IndexTime.
+	indexTime time.Time
+	docCount  int
+}
 
-	// generate importsDB
+// forEachDoc calls f with all the docs in docDB, filtered by filterDocInfo.
+func forEachDoc(docDB mr.Input, f func(pkg string, docInfo *DocInfo) error) error {
+	docPartCnt, err := docDB.PartCount()
+	if err != nil {
+		return err
+	}
 	for i := 0; i < docPartCnt; i++ {
 		it, err := docDB.Iterator(i)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		var pkg sophie.RawString
 		var docInfo DocInfo
@@ -147,114 +230,190 @@ func Index(docDB mr.Input, outDir string) (*index.TokenSetSearcher, error) {
 					break
 				}
 				it.Close()
-				return nil, err
+				return err
 			}
 			filterDocInfo(&docInfo)
+			if err := f(string(pkg), &docInfo); err != nil {
+				it.Close()
+				return err
+			}
+		}
+		it.Close()
+	}
+	return nil
+}
 
-			importsDB.PutTokens(string(pkg), stringsp.NewSet(docInfo.Imports...))
-			testImportsDB.PutTokens(string(pkg), stringsp.NewSet(docInfo.TestImports...))
+func newIndexContext(docDB mr.Input) (*indexContext, error) {
+	c := &indexContext{
+		importsDB:     NewTokenIndexer("", ""),
+		testImportsDB: NewTokenIndexer("", ""),
+		prjImportsDB:  NewTokenIndexer("", ""),
+		prjStars:      make(map[string]projectStart),
+	}
+	// Only the fields used by markForks are kept.
+	var forkHits []HitInfo
+	var pkgs []string
+	if err := forEachDoc(docDB, func(pkg string, docInfo *DocInfo) error {
+		c.importsDB.PutTokens(pkg, stringsp.NewSet(docInfo.Imports...))
+		c.testImportsDB.PutTokens(pkg, stringsp.NewSet(docInfo.TestImports...))
+
+		var projects stringsp.Set
+		for _, imp := range docInfo.Imports {
+			projects.Add(FullProjectOfPackage(imp))
+		}
+		for _, imp := range docInfo.TestImports {
+			projects.Add(FullProjectOfPackage(imp))
+		}
+		prj := FullProjectOfPackage(pkg)
+		orgProjects := c.prjImportsDB.TokensOfId(prj)
+		projects.Add(orgProjects...)
+		c.prjImportsDB.PutTokens(prj, projects)
+
+		// update stars
+		if cur, ok := c.prjStars[prj]; !ok ||
+			docInfo.LastUpdated.After(cur.LastUpdated) {
+			c.prjStars[prj] = projectStart{
+				StarCount:   docInfo.StarCount,
+				LastUpdated: docInfo.LastUpdated,
+			}
+		}
+		forkHits = append(forkHits, HitInfo{DocInfo: DocInfo{
+			Package:    docInfo.Package,
+			StarCount:  docInfo.StarCount,
+			ForkedFrom: docInfo.ForkedFrom,
+			Archived:   docInfo.Archived,
+			Signature:  docInfo.Signature,
+		}})
+		pkgs = append(pkgs, docInfo.Package)
+		if docInfo.LastUpdated.After(c.indexTime) {
+			c.indexTime = docInfo.LastUpdated
+		}
+		c.docCount++
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Marking forks and mirrors ...")
+	markForks(forkHits)
+	c.forkOf = make(map[string]string)
+	for i := range forkHits {
+		if forkHits[i].ForkOf != "" {
+			c.forkOf[forkHits[i].Package] = forkHits[i].ForkOf
+		}
+	}
+
+	log.Printf("Computing PageRanks of %d packages ...", len(pkgs))
+	ranks := newImportGraph(pkgs, c.importsDB.IdsOfToken).pageRanks()
+	c.pageRank = make(map[string]float64, len(pkgs))
+	for i, pkg := range pkgs {
+		c.pageRank[pkg] = ranks[i]
+	}
+	return c, nil
+}
+
+// fillDeps sets the fields of hitInfo computed from the other docs.
+func (c *indexContext) fillDeps(hitInfo *HitInfo) {
+	hitInfo.Imported = c.importsDB.IdsOfToken(hitInfo.Package)
+	hitInfo.ImportedLen = len(hitInfo.Imported)
+	hitInfo.TestImported = c.testImportsDB.IdsOfToken(hitInfo.Package)
+	hitInfo.TestImportedLen = len(hitInfo.TestImported)
+
+	var (
+		prj               = FullProjectOfPackage(hitInfo.Package)
+		impPrjsCnt        = len(c.prjImportsDB.IdsOfToken(prj))
+		assignedStarCount = float64(c.prjStars[prj].StarCount)
+	)
+
+	if prj != hitInfo.Package {
+		if impPrjsCnt == 0 {
+			assignedStarCount = 0
+		} else {
+			perStarCount := float64(c.prjStars[prj].StarCount) / float64(impPrjsCnt)
 
 			var projects stringsp.Set
-			for _, imp := range docInfo.Imports {
+			for _, imp := range hitInfo.Imported {
 				projects.Add(FullProjectOfPackage(imp))
 			}
-			for _, imp := range docInfo.TestImports {
+			for _, imp := range hitInfo.TestImported {
 				projects.Add(FullProjectOfPackage(imp))
 			}
-			prj := FullProjectOfPackage(string(pkg))
-			orgProjects := prjImportsDB.TokensOfId(prj)
-			projects.Add(orgProjects...)
-			prjImportsDB.PutTokens(prj, projects)
-
-			// update stars
-			if cur, ok := prjStars[prj]; !ok ||
-				docInfo.LastUpdated.After(cur.LastUpdated) {
-				prjStars[prj] = projectStart{
-					StarCount:   docInfo.StarCount,
-					LastUpdated: docInfo.LastUpdated,
-				}
-			}
-			docCount++
+			assignedStarCount = perStarCount * float64(len(projects))
 		}
-		it.Close()
 	}
+	hitInfo.AssignedStarCount = assignedStarCount
+	hitInfo.ForkOf = c.forkOf[hitInfo.Package]
+	hitInfo.PageRank = c.pageRank[hitInfo.Package]
+	hitInfo.IndexTime = c.indexTime
+}
 
-	utils.DumpMemStats()
-	log.Printf("Making HitInfos ...")
-	hits := make([]HitInfo, 0, docCount)
-	for i := 0; i < docPartCnt; i++ {
-		it, err := docDB.Iterator(i)
-		if err != nil {
-			return nil, err
-		}
+// finishHit sets the remaining fields of hitInfo after fillDeps.
+func finishHit(hitInfo *HitInfo) {
+	realTestImported := excludeImports(hitInfo.TestImported, hitInfo.Imported)
 
-		var (
-			pkg     sophie.RawString
-			hitInfo HitInfo
-		)
+	readme := ReadmeToText(hitInfo.ReadmeFn, hitInfo.ReadmeData)
 
-		for {
-			if err := it.Next(&pkg, &hitInfo.DocInfo); err != nil {
-				if errorsp.Cause(err) == io.EOF {
-					break
-				}
-				it.Close()
-				return nil, err
-			}
-			filterDocInfo(&hitInfo.DocInfo)
-
-			hitInfo.Imported = importsDB.IdsOfToken(hitInfo.Package)
-			hitInfo.ImportedLen = len(hitInfo.Imported)
-			hitInfo.TestImported = testImportsDB.IdsOfToken(hitInfo.Package)
-			hitInfo.TestImportedLen = len(hitInfo.TestImported)
-			realTestImported := excludeImports(testImportsDB.IdsOfToken(hitInfo.Package), hitInfo.Imported)
-
-			var (
-				prj               = FullProjectOfPackage(hitInfo.Package)
-				impPrjsCnt        = len(prjImportsDB.IdsOfToken(prj))
-				assignedStarCount = float64(prjStars[prj].StarCount)
-			)
-
-			if prj != hitInfo.Package {
-				if impPrjsCnt == 0 {
-					assignedStarCount = 0
-				} else {
-					perStarCount := float64(prjStars[prj].StarCount) / float64(impPrjsCnt)
-
-					var projects stringsp.Set
-					for _, imp := range hitInfo.Imported {
-						projects.Add(FullProjectOfPackage(imp))
-					}
-					for _, imp := range hitInfo.TestImported {
-						projects.Add(FullProjectOfPackage(imp))
-					}
-					assignedStarCount = perStarCount * float64(len(projects))
-				}
-			}
-			hitInfo.AssignedStarCount = assignedStarCount
+	hitInfo.ImportantSentences = ChooseImportantSentenses(readme,
+		hitInfo.Name, hitInfo.Package)
+	// StaticScore is calculated after setting all other fields of
+	// hitInfo
+	hitInfo.StaticScore = CalcStaticScore(hitInfo)
+	hitInfo.TestStaticScore = CalcTestStaticScore(hitInfo, realTestImported)
+	setBM25FFields(hitInfo, readme)
+}
 
-			readme := ReadmeToText(hitInfo.ReadmeFn, hitInfo.ReadmeData)
+// sortHitsByStaticScore returns the indexes of hits in descending order of the
+// static scores.
+func sortHitsByStaticScore(hits []HitInfo) []int {
+	return sortp.IndexSortF(len(hits), func(i, j int) bool {
+		return hits[i].StaticScore > hits[j].StaticScore
+	})
+}
+
+func Index(docDB mr.Input, outDir string) (*index.TokenSetSearcher, error) {
+	utils.DumpMemStats()
+
+	log.Printf("Generating importsDB ...")
+	c, err := newIndexContext(docDB)
+	if err != nil {
+		return nil, err
+	}
 
-			hitInfo.ImportantSentences = ChooseImportantSentenses(readme,
-				hitInfo.Name, hitInfo.Package)
-			// StaticScore is calculated after setting all other fields of
-			// hitInfo
-			hitInfo.StaticScore = CalcStaticScore(&hitInfo)
-			hitInfo.TestStaticScore = CalcTestStaticScore(&hitInfo, realTestImported)
-			hits = append(hits, hitInfo)
+	utils.DumpMemStats()
+	log.Printf("Making HitInfos ...")
+	hits := make([]HitInfo, 0, c.docCount)
+	state := &IndexState{Docs: make(map[string]IndexDocState, c.docCount)}
+	if err := forEachDoc(docDB, func(pkg string, docInfo *DocInfo) error {
+		hitInfo := HitInfo{DocInfo: *docInfo}
+		c.fillDeps(&hitInfo)
+		docState, err := newIndexDocState(&hitInfo)
+		if err != nil {
+			return err
 		}
-		it.Close()
+		state.Docs[hitInfo.Package] = docState
+		finishHit(&hitInfo)
+		hits = append(hits, hitInfo)
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	utils.DumpMemStats()
-	importsDB = nil
-	testImportsDB = nil
+	c = nil
 	utils.DumpMemStats()
 	log.Printf("%d hits collected, sorting static-scores in descending order", len(hits))
 
-	idxs := sortp.IndexSortF(len(hits), func(i, j int) bool {
-		return hits[i].StaticScore > hits[j].StaticScore
-	})
+	idxs := sortHitsByStaticScore(hits)
+	state.Scores = make([]float64, len(idxs))
+	for i, idx := range idxs {
+		state.Scores[i] = hits[idx].StaticScore
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	if err := saveGob(path.Join(outDir, IndexStateFn), state); err != nil {
+		return nil, err
+	}
 
 	ts := &index.TokenSetSearcher{}
 	utils.DumpMemStats()
@@ -0,0 +1,221 @@
+package gcse
+
+import (
+	"bytes"
+	"encoding/gob"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/strings"
+
+	"github.com/daviddengcn/go-index"
+	"github.com/daviddengcn/go-villa"
+	"github.com/daviddengcn/sophie/mr"
+)
+
+const (
+	// IndexStateFn is the file of the IndexState in a full index segment.
+	IndexStateFn = "state.gob"
+	// IndexDeltaFn is the file of the DeltaInfo in a delta index segment.
+	IndexDeltaFn = "delta.gob"
+)
+
+// IndexDocState is the state of a package in a full index, used to find the
+// packages to reindex in a delta.
+type IndexDocState struct {
+	// Hash of the DocInfo.
+	DocHash uint64
+	// Hash of the fields of the HitInfo computed from the other docs, e.g.
+	// Imported and AssignedStarCount.
+	DepHash uint64
+}
+
+// IndexState is saved with a full index.
+type IndexState struct {
+	Docs map[string]IndexDocState
+	// The static scores of all the hits, in descending order.
+	Scores []float64
+}
+
+// rankOf returns the StaticRank of a hit of score in the full index.
+func (s *IndexState) rankOf(score float64) int {
+	return sort.Search(len(s.Scores), func(i int) bool {
+		return s.Scores[i] <= score
+	})
+}
+
+// DeltaInfo is saved in a delta index segment, whose hits are the packages
+// added or changed since the full index in the Base segment. At query time,
+// the hits of the delta replace those of the same packages in the base.
+type DeltaInfo struct {
+	// Name of the segment of the full index.
+	Base string
+	// Number of the packages in the full index.
+	BaseDocs int
+	// Number of the hits of the packages not in the full index.
+	Added int
+	// Packages removed since the full index, the tombstones.
+	Deleted []string
+}
+
+func gobHash(v interface{}) (uint64, error) {
+	h := fnv.New64a()
+	if err := gob.NewEncoder(h).Encode(v); err != nil {
+		return 0, errorsp.WithStacks(err)
+	}
+	return h.Sum64(), nil
+}
+
+func sortedCopy(strs []string) []string {
+	strs = append([]string(nil), strs...)
+	sort.Strings(strs)
+	return strs
+}
+
+// newIndexDocState returns the state of a hit after indexContext.fillDeps.
+func newIndexDocState(hit *HitInfo) (IndexDocState, error) {
+	docHash, err := gobHash(hit.DocInfo)
+	if err != nil {
+		return IndexDocState{}, err
+	}
+	freshnessBucket, releaseBucket := freshnessBuckets(hit)
+	depHash, err := gobHash(struct {
+		Imported          []string
+		TestImported      []string
+		AssignedStarCount float64
+		ForkOf            string
+		PageRankBucket    int
+		FreshnessBucket   int
+		ReleaseBucket     int
+	}{
+		sortedCopy(hit.Imported),
+		sortedCopy(hit.TestImported),
+		hit.AssignedStarCount,
+		hit.ForkOf,
+		pageRankBucket(hit.PageRank),
+		freshnessBucket,
+		releaseBucket,
+	})
+	if err != nil {
+		return IndexDocState{}, err
+	}
+	return IndexDocState{DocHash: docHash, DepHash: depHash}, nil
+}
+
+func saveGob(fn string, v interface{}) error {
+	return errorsp.WithStacksAndMessage(safeSave(villa.Path(fn), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(v)
+	}), "saving %v failed", fn)
+}
+
+func loadGob(fn string, v interface{}) error {
+	bs, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	return errorsp.WithStacksAndMessage(gob.NewDecoder(bytes.NewReader(bs)).Decode(v), "decoding %v failed", fn)
+}
+
+// LoadIndexState loads the IndexState of the full index in dir.
+func LoadIndexState(dir string) (*IndexState, error) {
+	var state IndexState
+	if err := loadGob(path.Join(dir, IndexStateFn), &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ReadDeltaInfo returns the DeltaInfo of the index in dir, nil if it is a
+// full index.
+func ReadDeltaInfo(dir string) (*DeltaInfo, error) {
+	var info DeltaInfo
+	if err := loadGob(path.Join(dir, IndexDeltaFn), &info); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &info, nil
+}
+
+// IndexDelta indexes into outDir the docs in docDB changed since the full
+// index in baseDir, including the ones whose HitInfo changed because of the
+// other docs, e.g. a package whose importers changed. The DeltaInfo is saved
+// in outDir and returned.
+//
+// The StaticRank of a hit is its rank among the hits of the full index.
+func IndexDelta(docDB mr.Input, baseDir, outDir string) (*index.TokenSetSearcher, *DeltaInfo, error) {
+	state, err := LoadIndexState(baseDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Generating importsDB ...")
+	c, err := newIndexContext(docDB)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Making changed HitInfos ...")
+	var hits []HitInfo
+	var seen stringsp.Set
+	added := 0
+	if err := forEachDoc(docDB, func(pkg string, docInfo *DocInfo) error {
+		hitInfo := HitInfo{DocInfo: *docInfo}
+		seen.Add(hitInfo.Package)
+		c.fillDeps(&hitInfo)
+		docState, err := newIndexDocState(&hitInfo)
+		if err != nil {
+			return err
+		}
+		baseState, ok := state.Docs[hitInfo.Package]
+		if ok && baseState == docState {
+			return nil
+		}
+		if !ok {
+			added++
+		}
+		finishHit(&hitInfo)
+		hits = append(hits, hitInfo)
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+	c = nil
+
+	info := &DeltaInfo{Base: filepath.Base(baseDir), BaseDocs: len(state.Docs), Added: added}
+	for pkg := range state.Docs {
+		if !seen.Contain(pkg) {
+			info.Deleted = append(info.Deleted, pkg)
+		}
+	}
+	sort.Strings(info.Deleted)
+	log.Printf("%d hits changed, %d deleted since %v", len(hits), len(info.Deleted), baseDir)
+
+	idxs := sortHitsByStaticScore(hits)
+	ts := &index.TokenSetSearcher{}
+	hitsArr, err := index.CreateConstArray(path.Join(outDir, HitsArrFn))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer hitsArr.Close()
+
+	if err := indexAndSaveRankedHits(ts, hits, idxs, func(i int) int {
+		return state.rankOf(hits[idxs[i]].StaticScore)
+	}, func(hit *HitInfo) error {
+		_, err := hitsArr.AppendGob(*hit)
+		return err
+	}); err != nil {
+		return nil, nil, err
+	}
+	if err := saveGob(path.Join(outDir, IndexDeltaFn), info); err != nil {
+		return nil, nil, err
+	}
+	return ts, info, nil
+}
@@ -0,0 +1,130 @@
+package gcse
+
+import (
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/daviddengcn/go-index"
+	"github.com/daviddengcn/go-villa"
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/mr"
+	"github.com/golangplus/testing/assert"
+)
+
+func docsInput(docs []DocInfo) mr.Input {
+	return &mr.InputStruct{
+		PartCountF: func() (int, error) {
+			return 1, nil
+		},
+		IteratorF: func(int) (sophie.IterateCloser, error) {
+			index := 0
+			return &sophie.IterateCloserStruct{
+				NextF: func(key, val sophie.SophieReader) error {
+					if index >= len(docs) {
+						return io.EOF
+					}
+					*key.(*sophie.RawString) = sophie.RawString(docs[index].Package)
+					*val.(*DocInfo) = docs[index]
+					val.(*DocInfo).Imports = append([]string{}, docs[index].Imports...)
+					val.(*DocInfo).TestImports = append([]string{}, docs[index].TestImports...)
+					index++
+					return nil
+				},
+			}, nil
+		},
+	}
+}
+
+// hitsInDir returns the hits saved in the index in dir by packages, with the
+// StaticRank cleared.
+func hitsInDir(t *testing.T, dir villa.Path) map[string]HitInfo {
+	arr, err := index.OpenConstArray(path.Join(dir.S(), HitsArrFn))
+	assert.NoErrorOrDie(t, err)
+	defer arr.Close()
+
+	hits := make(map[string]HitInfo)
+	assert.NoError(t, arr.ForEachGob(func(_ int, e interface{}) error {
+		hit := e.(HitInfo)
+		hit.StaticRank = 0
+		hits[hit.Package] = hit
+		return nil
+	}))
+	return hits
+}
+
+func TestIndexDelta(t *testing.T) {
+	const (
+		pkgA    = "github.com/x/a"
+		pkgB    = "github.com/y/b"
+		pkgBSub = "github.com/y/b/sub"
+		pkgC    = "github.com/z/c"
+		pkgD    = "github.com/w/d"
+		pkgE    = "github.com/u/e"
+		pkgF    = "github.com/v/f"
+	)
+	tmpPath := villa.Path(os.TempDir()).Join("gcse_index_delta_testing")
+	assert.NoError(t, tmpPath.RemoveAll())
+	defer tmpPath.RemoveAll()
+	dirBase, dirDelta, dirFull := tmpPath.Join("base"), tmpPath.Join("delta"), tmpPath.Join("full")
+	for _, dir := range []villa.Path{dirBase, dirDelta, dirFull} {
+		assert.NoErrorOrDie(t, dir.MkdirAll(0755))
+	}
+
+	docs := []DocInfo{
+		{Package: pkgA, Name: "a", Imports: []string{pkgB}},
+		{Package: pkgB, Name: "b", StarCount: 10},
+		{Package: pkgBSub, Name: "sub", StarCount: 10},
+		{Package: pkgC, Name: "c", StarCount: 5},
+		{Package: pkgD, Name: "d", Imports: []string{pkgC}},
+		{Package: pkgF, Name: "f", Description: "Package f is unrelated."},
+	}
+	_, err := Index(docsInput(docs), dirBase.S())
+	assert.NoErrorOrDie(t, err)
+	baseHits := hitsInDir(t, dirBase)
+
+	// a imports c and b/sub instead of b, d is deleted and e is added. f is
+	// not changed.
+	docs = []DocInfo{
+		{Package: pkgA, Name: "a", Imports: []string{pkgC, pkgBSub}},
+		docs[1], docs[2], docs[3],
+		{Package: pkgE, Name: "e", Imports: []string{pkgC}},
+		docs[5],
+	}
+	ts, info, err := IndexDelta(docsInput(docs), dirBase.S(), dirDelta.S())
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "info", *info, DeltaInfo{Base: "base", BaseDocs: 6, Added: 1, Deleted: []string{pkgD}})
+	assert.Equal(t, "ts.DocCount()", ts.DocCount(), 5)
+
+	saved, err := ReadDeltaInfo(dirDelta.S())
+	assert.NoError(t, err)
+	assert.Equal(t, "saved", saved, info)
+	saved, err = ReadDeltaInfo(dirBase.S())
+	assert.NoError(t, err)
+	assert.True(t, "saved == nil", saved == nil)
+
+	_, err = Index(docsInput(docs), dirFull.S())
+	assert.NoErrorOrDie(t, err)
+	fullHits := hitsInDir(t, dirFull)
+
+	// The delta contains exactly the hits changed since the base, identical to
+	// the ones of a full index.
+	deltaHits := hitsInDir(t, dirDelta)
+	var changed, inDelta []string
+	for pkg, hit := range fullHits {
+		if base, ok := baseHits[pkg]; !ok || !reflect.DeepEqual(base, hit) {
+			changed = append(changed, pkg)
+		}
+	}
+	for pkg, hit := range deltaHits {
+		inDelta = append(inDelta, pkg)
+		assert.Equal(t, "hit of "+pkg, hit, fullHits[pkg])
+	}
+	sort.Strings(changed)
+	sort.Strings(inDelta)
+	assert.Equal(t, "inDelta", inDelta, changed)
+	assert.Equal(t, "inDelta", inDelta, []string{pkgE, pkgA, pkgB, pkgBSub, pkgC})
+}
@@ -0,0 +1,278 @@
+package gcse
+
+import (
+	"log"
+	"os"
+
+	"github.com/golangplus/strings"
+
+	"github.com/daviddengcn/gcse/utils"
+	"github.com/daviddengcn/go-index"
+)
+
+// indexPart is a loaded index segment, full or delta.
+type indexPart struct {
+	segm utils.Segment
+	ts   index.TokenSetSearcher
+	hits *index.ConstArrayReader
+}
+
+func openIndexPart(segm utils.Segment) (*indexPart, error) {
+	p := &indexPart{segm: segm}
+	if err := func() error {
+		f, err := os.Open(segm.Join(IndexFn))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return p.ts.Load(f)
+	}(); err != nil {
+		return nil, err
+	}
+	hitsPath := segm.Join(HitsArrFn)
+	var err error
+	if p.hits, err = index.OpenConstArray(hitsPath); err != nil {
+		log.Printf("OpenConstArray %v failed: %v", hitsPath, err)
+		return nil, err
+	}
+	return p, nil
+}
+
+// findFullPackage returns the full hit of the package and its doc ID.
+func (p *indexPart) findFullPackage(id string) (HitInfo, int32, bool) {
+	var hit HitInfo
+	docID, found := int32(-1), false
+	if err := p.ts.Search(index.SingleFieldQuery(IndexPkgField, id), func(id int32, _ interface{}) error {
+		h, err := p.hits.GetGob(int(id))
+		if err != nil {
+			return err
+		}
+		hit, docID, found = h.(HitInfo), id, true
+		return nil
+	}); err != nil {
+		return HitInfo{}, -1, false
+	}
+	return hit, docID, found
+}
+
+func (p *indexPart) close() {
+	p.hits.Close()
+}
+
+// IndexReader reads a full index, and optionally a delta of it whose hits
+// replace those of the same packages in the full one. The doc IDs of the delta
+// follow those of the full index. A nil *IndexReader is an empty index.
+type IndexReader struct {
+	base  *indexPart
+	delta *indexPart
+	// Doc IDs in base replaced by or deleted in the delta.
+	overridden map[int32]bool
+
+	avgFieldLens []float64
+}
+
+// OpenIndexReader opens the index in segm, with its full index if it is a
+// delta. The full index of cur, if not nil, is reused if it is the same one.
+func OpenIndexReader(segm utils.Segment, cur *IndexReader) (*IndexReader, error) {
+	info, err := ReadDeltaInfo(string(segm))
+	if err != nil {
+		return nil, err
+	}
+	baseSegm, err := IndexBaseSegment(segm)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &IndexReader{}
+	if cur != nil && cur.base != nil && cur.base.segm == baseSegm {
+		r.base = cur.base
+	} else {
+		if r.base, err = openIndexPart(baseSegm); err != nil {
+			return nil, err
+		}
+	}
+	if info != nil {
+		if r.delta, err = openIndexPart(segm); err != nil {
+			if cur == nil || r.base != cur.base {
+				r.base.close()
+			}
+			return nil, err
+		}
+		r.overridden = make(map[int32]bool)
+		override := func(pkg string) {
+			r.base.ts.Search(index.SingleFieldQuery(IndexPkgField, pkg), func(docID int32, _ interface{}) error {
+				r.overridden[docID] = true
+				return nil
+			})
+		}
+		r.delta.ts.Search(nil, func(_ int32, data interface{}) error {
+			override(data.(HitInfo).Package)
+			return nil
+		})
+		for _, pkg := range info.Deleted {
+			override(pkg)
+		}
+	}
+	r.avgFieldLens = AvgFieldLens(func(f func(hit *HitInfo)) {
+		r.Search(nil, func(_ int32, data interface{}) error {
+			hit := data.(HitInfo)
+			f(&hit)
+			return nil
+		})
+	})
+	return r, nil
+}
+
+// BaseSegment returns the segment of the full index.
+func (r *IndexReader) BaseSegment() utils.Segment {
+	if r == nil || r.base == nil {
+		return ""
+	}
+	return r.base.segm
+}
+
+// SharesBase returns whether r and o share the loaded full index.
+func (r *IndexReader) SharesBase(o *IndexReader) bool {
+	return r != nil && o != nil && r.base != nil && r.base == o.base
+}
+
+// CloseExcept closes the parts of r not shared with newer, which is opened
+// with r as the current one.
+func (r *IndexReader) CloseExcept(newer *IndexReader) {
+	if r == nil {
+		return
+	}
+	if r.base != nil && !r.SharesBase(newer) {
+		r.base.close()
+	}
+	if r.delta != nil {
+		r.delta.close()
+	}
+}
+
+func (r *IndexReader) Close() {
+	r.CloseExcept(nil)
+}
+
+func (r *IndexReader) PackageCount() int {
+	if r == nil || r.base == nil {
+		return 0
+	}
+	cnt := r.base.ts.DocCount()
+	if r.delta != nil {
+		cnt += r.delta.ts.DocCount() - len(r.overridden)
+	}
+	return cnt
+}
+
+// AvgFieldLens returns the average lengths of the fields of the hits, for
+// BM25F.
+func (r *IndexReader) AvgFieldLens() []float64 {
+	if r == nil {
+		return nil
+	}
+	return r.avgFieldLens
+}
+
+func (r *IndexReader) FindFullPackage(id string) (HitInfo, bool) {
+	if r == nil {
+		log.Print("Database not loaded!")
+		return HitInfo{}, false
+	}
+	if r.delta != nil {
+		if hit, _, found := r.delta.findFullPackage(id); found {
+			return hit, true
+		}
+	}
+	if r.base == nil {
+		return HitInfo{}, false
+	}
+	hit, docID, found := r.base.findFullPackage(id)
+	if !found || r.overridden[docID] {
+		return HitInfo{}, false
+	}
+	return hit, true
+}
+
+func (r *IndexReader) ForEachFullPackage(out func(HitInfo) error) error {
+	if r == nil || r.base == nil {
+		return nil
+	}
+	if err := r.base.hits.ForEachGob(func(idx int, hit interface{}) error {
+		if r.overridden[int32(idx)] {
+			return nil
+		}
+		return out(hit.(HitInfo))
+	}); err != nil {
+		return err
+	}
+	if r.delta == nil {
+		return nil
+	}
+	return r.delta.hits.ForEachGob(func(_ int, hit interface{}) error {
+		return out(hit.(HitInfo))
+	})
+}
+
+func (r *IndexReader) PackageCountOfToken(field, token string) int {
+	if r == nil || r.base == nil {
+		return 0
+	}
+	docIDs := r.base.ts.TokenDocList(field, token)
+	if r.delta == nil {
+		return len(docIDs)
+	}
+	cnt := len(r.delta.ts.TokenDocList(field, token))
+	for _, docID := range docIDs {
+		if !r.overridden[docID] {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// Search outputs the hits in descending order of the static scores, the order
+// of the hits in both the full index and the delta.
+func (r *IndexReader) Search(q map[string]stringsp.Set, out func(docID int32, data interface{}) error) error {
+	if r == nil || r.base == nil {
+		return nil
+	}
+	if r.delta == nil {
+		return r.base.ts.Search(q, out)
+	}
+
+	type deltaHit struct {
+		docID int32
+		hit   HitInfo
+	}
+	var deltaHits []deltaHit
+	baseCount := int32(r.base.ts.DocCount())
+	if err := r.delta.ts.Search(q, func(docID int32, data interface{}) error {
+		deltaHits = append(deltaHits, deltaHit{docID: baseCount + docID, hit: data.(HitInfo)})
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := r.base.ts.Search(q, func(docID int32, data interface{}) error {
+		if r.overridden[docID] {
+			return nil
+		}
+		score := data.(HitInfo).StaticScore
+		for len(deltaHits) > 0 && deltaHits[0].hit.StaticScore > score {
+			if err := out(deltaHits[0].docID, deltaHits[0].hit); err != nil {
+				return err
+			}
+			deltaHits = deltaHits[1:]
+		}
+		return out(docID, data)
+	}); err != nil {
+		return err
+	}
+	for _, h := range deltaHits {
+		if err := out(h.docID, h.hit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package gcse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/gcse/utils"
+)
+
+// IndexPinFn is the file in the folder of the index segments naming the
+// segment to serve instead of the latest one.
+const IndexPinFn = ".pinned"
+
+// PinnedIndexSegment returns the pinned segment, empty if none.
+func PinnedIndexSegment(segms utils.Segments) (utils.Segment, error) {
+	bs, err := ioutil.ReadFile(filepath.Join(string(segms), IndexPinFn))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", errorsp.WithStacks(err)
+	}
+	name := strings.TrimSpace(string(bs))
+	if name == "" {
+		return "", nil
+	}
+	return segms.Join(name), nil
+}
+
+// PinIndexSegment pins the done segment of the name, so that it is served
+// instead of the latest one and is never removed.
+func PinIndexSegment(segms utils.Segments, name string) error {
+	segm := segms.Join(name)
+	if name == "" || filepath.Base(name) != name || !segm.IsDone() {
+		return errorsp.NewWithStacks("%q is not a done index segment", name)
+	}
+	return errorsp.WithStacks(ioutil.WriteFile(filepath.Join(string(segms), IndexPinFn), []byte(name+"\n"), 0644))
+}
+
+// UnpinIndexSegment removes the pin, so that the latest segment is served.
+func UnpinIndexSegment(segms utils.Segments) error {
+	err := os.Remove(filepath.Join(string(segms), IndexPinFn))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return errorsp.WithStacks(err)
+}
+
+// ServedIndexSegment returns the segment to serve, the pinned one if any,
+// or the latest done one.
+func ServedIndexSegment(segms utils.Segments) (utils.Segment, error) {
+	pinned, err := PinnedIndexSegment(segms)
+	if err != nil {
+		return "", err
+	}
+	if pinned != "" && pinned.IsDone() {
+		return pinned, nil
+	}
+	return segms.FindMaxDone()
+}
+
+// DoneIndexSegments returns the done segments, the latest first.
+func DoneIndexSegments(segms utils.Segments) ([]utils.Segment, error) {
+	dones, err := segms.ListDones()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(dones, func(i, j int) bool {
+		return utils.SegmentLess(dones[j], dones[i])
+	})
+	return dones, nil
+}
+
+// IndexBaseSegment returns the segment of the full index of a delta segment,
+// or segm itself if it is a full one.
+func IndexBaseSegment(segm utils.Segment) (utils.Segment, error) {
+	info, err := ReadDeltaInfo(string(segm))
+	if err != nil {
+		return "", err
+	}
+	if info == nil {
+		return segm, nil
+	}
+	return utils.Segment(filepath.Join(filepath.Dir(string(segm)), info.Base)), nil
+}
+
+// PreviousIndexSegment returns the latest done segment older than segm, which
+// can be served, i.e. its full index exists. Empty if none.
+func PreviousIndexSegment(segms utils.Segments, segm utils.Segment) (utils.Segment, error) {
+	dones, err := DoneIndexSegments(segms)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range dones {
+		if !utils.SegmentLess(s, segm) {
+			continue
+		}
+		base, err := IndexBaseSegment(s)
+		if err != nil {
+			return "", err
+		}
+		if base.IsDone() {
+			return s, nil
+		}
+	}
+	return "", nil
+}
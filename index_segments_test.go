@@ -0,0 +1,77 @@
+package gcse
+
+import (
+	"os"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/gcse/utils"
+	"github.com/daviddengcn/go-villa"
+)
+
+func TestIndexSegments(t *testing.T) {
+	tmpPath := villa.Path(os.TempDir()).Join("gcse_index_segments_testing")
+	assert.NoError(t, tmpPath.RemoveAll())
+	defer tmpPath.RemoveAll()
+	segms := utils.Segments(tmpPath.S())
+
+	makeSegm := func(name, base string, done bool) utils.Segment {
+		segm := segms.Join(name)
+		assert.NoErrorOrDie(t, segm.Make())
+		if base != "" {
+			assert.NoErrorOrDie(t, saveGob(segm.Join(IndexDeltaFn), &DeltaInfo{Base: base}))
+		}
+		if done {
+			assert.NoErrorOrDie(t, segm.Done())
+		}
+		return segm
+	}
+	s1 := makeSegm("1", "", true)
+	s2 := makeSegm("2", "1", true)
+	s3 := makeSegm("3", "", true)
+	s4 := makeSegm("4", "3", true)
+	makeSegm("5", "", false)
+
+	dones, err := DoneIndexSegments(segms)
+	assert.NoError(t, err)
+	assert.Equal(t, "dones", dones, []utils.Segment{s4, s3, s2, s1})
+
+	base, err := IndexBaseSegment(s4)
+	assert.NoError(t, err)
+	assert.Equal(t, "base", base, s3)
+	base, err = IndexBaseSegment(s3)
+	assert.NoError(t, err)
+	assert.Equal(t, "base", base, s3)
+
+	served, err := ServedIndexSegment(segms)
+	assert.NoError(t, err)
+	assert.Equal(t, "served", served, s4)
+
+	prev, err := PreviousIndexSegment(segms, s4)
+	assert.NoError(t, err)
+	assert.Equal(t, "prev", prev, s3)
+
+	// Roll back to 2.
+	assert.Error(t, PinIndexSegment(segms, "5"))
+	assert.Error(t, PinIndexSegment(segms, "../1"))
+	assert.NoError(t, PinIndexSegment(segms, "2"))
+	pinned, err := PinnedIndexSegment(segms)
+	assert.NoError(t, err)
+	assert.Equal(t, "pinned", pinned, s2)
+	served, err = ServedIndexSegment(segms)
+	assert.NoError(t, err)
+	assert.Equal(t, "served", served, s2)
+
+	// A delta whose full index is gone can't be rolled back to.
+	assert.NoError(t, s1.Remove())
+	prev, err = PreviousIndexSegment(segms, s3)
+	assert.NoError(t, err)
+	assert.Equal(t, "prev", prev, utils.Segment(""))
+
+	assert.NoError(t, UnpinIndexSegment(segms))
+	assert.NoError(t, UnpinIndexSegment(segms))
+	served, err = ServedIndexSegment(segms)
+	assert.NoError(t, err)
+	assert.Equal(t, "served", served, s4)
+}
@@ -200,3 +200,34 @@ func TestIndex_DescNotIndexedBug(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "results", results, hits)
 }
+
+func TestMarkForks(t *testing.T) {
+	hits := []HitInfo{{
+		DocInfo: DocInfo{Package: "github.com/a/lib", StarCount: 100, Signature: "s0"},
+	}, {
+		DocInfo: DocInfo{Package: "github.com/a/lib/sub", Signature: "s1"},
+	}, {
+		// A known fork
+		DocInfo: DocInfo{Package: "github.com/b/lib/sub", ForkedFrom: "github.com/a/lib", Signature: "s2"},
+	}, {
+		// A mirror with the same files but more stars.
+		DocInfo: DocInfo{Package: "gitlab.com/c/lib", StarCount: 200, Archived: true, Signature: "s0"},
+	}, {
+		// Same files in another folder of the same project.
+		DocInfo: DocInfo{Package: "github.com/a/lib/vendor/sub", Signature: "s1"},
+	}, {
+		// A fork whose origin is not indexed.
+		DocInfo: DocInfo{Package: "github.com/d/other", ForkedFrom: "github.com/e/other"},
+	}, {
+		// Packages without a signature are not copies of each other.
+		DocInfo: DocInfo{Package: "github.com/f/empty"},
+	}, {
+		DocInfo: DocInfo{Package: "gitlab.com/g/empty", StarCount: 10},
+	}}
+	markForks(hits)
+	var forkOfs []string
+	for _, hit := range hits {
+		forkOfs = append(forkOfs, hit.ForkOf)
+	}
+	assert.Equal(t, "forkOfs", forkOfs, []string{"", "", "github.com/a/lib/sub", "github.com/a/lib", "", "", "", ""})
+}
@@ -0,0 +1,134 @@
+package gcse
+
+import (
+	"log"
+	"math"
+)
+
+const (
+	// pageRankDamping is the probability of following an import rather than
+	// jumping to a random package.
+	pageRankDamping = 0.85
+	// sameAuthorEdgeWeight is the weight of an import by a package of the
+	// same author or project, relative to 1 of the others, so that a
+	// package is not ranked up by its siblings. The rest of the rank passed
+	// by such an import is spread evenly, as of a package importing nothing.
+	sameAuthorEdgeWeight = 0.2
+
+	pageRankMaxIterations = 50
+	// The iterations stop when the L1 change of the ranks, which sum to 1,
+	// drops below this.
+	pageRankTolerance = 1e-6
+)
+
+// importGraph is the import graph of the packages in a compact form, 4 bytes
+// per import, so that PageRank runs over millions of packages in bounded
+// memory. Packages are identified by their indexes in pkgs.
+type importGraph struct {
+	pkgs []string
+	// The importers of package i are srcs[starts[i]:starts[i+1]], bitwise
+	// negated if of the same author or project.
+	starts []int
+	srcs   []int32
+	// The number and the total weight of the imports of each package.
+	outDegrees []int32
+	outWeights []float64
+}
+
+func isSameAuthorImport(imp, pkg string) bool {
+	impAuthor := AuthorOfPackage(imp)
+	return impAuthor != "" && impAuthor == AuthorOfPackage(pkg) ||
+		ProjectOfPackage(imp) == ProjectOfPackage(pkg)
+}
+
+// newImportGraph returns the import graph of pkgs, with importers returning
+// the packages importing a package. Importers not in pkgs are ignored.
+func newImportGraph(pkgs []string, importers func(pkg string) []string) *importGraph {
+	g := &importGraph{
+		pkgs:       pkgs,
+		starts:     make([]int, len(pkgs)+1),
+		outDegrees: make([]int32, len(pkgs)),
+		outWeights: make([]float64, len(pkgs)),
+	}
+	ids := make(map[string]int32, len(pkgs))
+	for i, pkg := range pkgs {
+		ids[pkg] = int32(i)
+	}
+	for i, pkg := range pkgs {
+		for _, imp := range importers(pkg) {
+			id, ok := ids[imp]
+			if !ok || int(id) == i {
+				continue
+			}
+			g.outDegrees[id]++
+			if isSameAuthorImport(imp, pkg) {
+				g.outWeights[id] += sameAuthorEdgeWeight
+				id = ^id
+			} else {
+				g.outWeights[id]++
+			}
+			g.srcs = append(g.srcs, id)
+		}
+		g.starts[i+1] = len(g.srcs)
+	}
+	return g
+}
+
+// pageRanks returns the PageRanks of the packages, scaled so that the average
+// is 1. The rank of a package without importers is 1-pageRankDamping plus its
+// share of the ranks of the packages importing nothing.
+func (g *importGraph) pageRanks() []float64 {
+	n := len(g.pkgs)
+	if n == 0 {
+		return nil
+	}
+	ranks := make([]float64, n)
+	for i := range ranks {
+		ranks[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+	for iter := 0; iter < pageRankMaxIterations; iter++ {
+		// The ranks of the packages importing nothing, and the discounted
+		// parts of the others, are spread evenly.
+		dangling := 0.
+		for i, deg := range g.outDegrees {
+			if deg == 0 {
+				dangling += ranks[i]
+			} else {
+				dangling += ranks[i] * (1 - g.outWeights[i]/float64(deg))
+			}
+		}
+		base := (1-pageRankDamping)/float64(n) + pageRankDamping*dangling/float64(n)
+		diff := 0.
+		for i := range next {
+			s := 0.
+			for _, src := range g.srcs[g.starts[i]:g.starts[i+1]] {
+				w := 1.
+				if src < 0 {
+					src, w = ^src, sameAuthorEdgeWeight
+				}
+				s += ranks[src] * w / float64(g.outDegrees[src])
+			}
+			next[i] = base + pageRankDamping*s
+			diff += math.Abs(next[i] - ranks[i])
+		}
+		ranks, next = next, ranks
+		if diff < pageRankTolerance {
+			log.Printf("PageRank converged after %d iterations", iter+1)
+			break
+		}
+	}
+	for i := range ranks {
+		ranks[i] *= float64(n)
+	}
+	return ranks
+}
+
+// pageRankBucket quantizes a PageRank so that the small changes, which happen
+// whenever any package changes, do not count as changes of the hit.
+func pageRankBucket(pr float64) int {
+	if pr <= 0 {
+		return math.MinInt32
+	}
+	return int(math.Round(math.Log2(pr) * 4))
+}
@@ -0,0 +1,85 @@
+package gcse
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func pageRanksOf(imports map[string][]string) map[string]float64 {
+	importers := make(map[string][]string)
+	var pkgs []string
+	for pkg, imps := range imports {
+		pkgs = append(pkgs, pkg)
+		for _, imp := range imps {
+			importers[imp] = append(importers[imp], pkg)
+		}
+	}
+	ranks := newImportGraph(pkgs, func(pkg string) []string {
+		return importers[pkg]
+	}).pageRanks()
+	res := make(map[string]float64)
+	for i, pkg := range pkgs {
+		res[pkg] = ranks[i]
+	}
+	return res
+}
+
+func TestPageRanks(t *testing.T) {
+	const (
+		core  = "github.com/hub/core"
+		x     = "github.com/lib/x"
+		y     = "github.com/lib/y"
+		throw = "github.com/throw/away"
+	)
+	imports := map[string][]string{
+		core:  {x},
+		x:     nil,
+		y:     nil,
+		throw: {y},
+		// Not in the graph, ignored.
+		"github.com/z/z": {"github.com/missing/pkg"},
+	}
+	for _, author := range []string{"a", "b", "c", "d", "e"} {
+		imports["github.com/"+author+"/app"] = []string{core}
+	}
+	ranks := pageRanksOf(imports)
+
+	sum := 0.
+	for _, r := range ranks {
+		sum += r
+	}
+	assert.True(t, "average 1", math.Abs(sum/float64(len(ranks))-1) < 1e-4)
+	// Both imported once, x by an important package.
+	assert.True(t, "x > y", ranks[x] > ranks[y])
+	assert.True(t, "y > throw", ranks[y] > ranks[throw])
+}
+
+func TestPageRanks_SameAuthor(t *testing.T) {
+	ranks := pageRanksOf(map[string][]string{
+		"github.com/a/p": nil,
+		"github.com/a/q": {"github.com/a/p"},
+		"github.com/b/r": nil,
+		"github.com/c/s": {"github.com/b/r"},
+	})
+	assert.True(t, "same author discounted", ranks["github.com/a/p"] < ranks["github.com/b/r"])
+}
+
+func TestPageRanks_Empty(t *testing.T) {
+	assert.Equal(t, "ranks", len(newImportGraph(nil, nil).pageRanks()), 0)
+}
+
+func TestPageRankBucket(t *testing.T) {
+	assert.Equal(t, "small change", pageRankBucket(1.01), pageRankBucket(1))
+	assert.NotEqual(t, "doubled", pageRankBucket(2), pageRankBucket(1))
+}
+
+func TestCalcStaticScore_PageRank(t *testing.T) {
+	hit := &HitInfo{DocInfo: DocInfo{Name: "x", Package: "github.com/lib/x"}}
+	score := CalcStaticScore(hit)
+	hit.PageRank = 0.5
+	assert.Equal(t, "below average", CalcStaticScore(hit), score)
+	hit.PageRank = 8
+	assert.Equal(t, "PageRank 8", CalcStaticScore(hit), score+3*pageRankWeight)
+}
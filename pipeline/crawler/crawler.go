@@ -0,0 +1,241 @@
+// Package crawler is the stage of the pipeline crawling the packages and
+// persons listed by tocrawl, and generating the new docs for mergedocs.
+package crawler
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/strings"
+
+	"github.com/daviddengcn/bolthelper"
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/spider"
+	"github.com/daviddengcn/gcse/spider/github"
+	"github.com/daviddengcn/gcse/utils"
+	"github.com/daviddengcn/gddo/doc"
+	"github.com/daviddengcn/go-easybi"
+	"github.com/daviddengcn/go-villa"
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/kv"
+)
+
+var (
+	AppStopTime time.Time
+	cDB         *gcse.CrawlerDB
+	fileCache   *spider.BoltFileCache
+)
+
+func init() {
+	if configs.CrawlerGithubClientID != "" {
+		log.Printf("Github clientid: %s", configs.CrawlerGithubClientID)
+		doc.SetGithubCredentials(configs.CrawlerGithubClientID, configs.CrawlerGithubClientSecret)
+	}
+	doc.SetUserAgent("Go-Search(http://go-search.org/)")
+	// doc.SetUserAgent("Go-Search(http://go.gigawatt.io/)")
+}
+
+func syncDatabases() error {
+	utils.DumpMemStats()
+	log.Printf("Synchronizing databases to disk...")
+	if err := cDB.Sync(); err != nil {
+		return errorsp.WithStacksAndMessage(err, "cdb.Sync() failed")
+	}
+	utils.DumpMemStats()
+	runtime.GC()
+	utils.DumpMemStats()
+	return nil
+}
+
+func loadAllDocsPkgs(in kv.DirInput) error {
+	cnt, err := in.PartCount()
+	if err != nil {
+		return err
+	}
+	for part := 0; part < cnt; part++ {
+		c, err := in.Iterator(part)
+		if err != nil {
+			return err
+		}
+		for {
+			var key sophie.RawString
+			var val gcse.DocInfo
+			if err := c.Next(&key, &val); err != nil {
+				if errorsp.Cause(err) == io.EOF {
+					break
+				}
+				return err
+			}
+			allDocsPkgs.Add(string(key))
+			for _, imp := range val.Imports {
+				importedCounts[imp]++
+			}
+		}
+	}
+	return nil
+}
+
+type crawlerMapper struct {
+}
+
+// Mapper interface
+func (crawlerMapper) NewKey() sophie.Sophier {
+	return new(sophie.RawString)
+}
+
+// Mapper interface
+func (crawlerMapper) NewVal() sophie.Sophier {
+	return new(gcse.CrawlingEntry)
+}
+
+// Mapper interface
+func (crawlerMapper) MapEnd(c []sophie.Collector) error {
+	return nil
+}
+
+// reportFileCacheStats logs the statistics of the file cache and reports them
+// to bi.
+func reportFileCacheStats() {
+	st, err := fileCache.Stats()
+	if err != nil {
+		log.Printf("Reading file cache stats failed: %v", err)
+		return
+	}
+	log.Printf("File cache: %d entries, %d bytes, hit ratio %.3f", st.Entries, st.Bytes, st.HitRatio())
+	bi.AddValue(bi.Max, "crawler.filecache.entries", int(st.Entries))
+	bi.AddValue(bi.Max, "crawler.filecache.bytes", int(st.Bytes))
+	bi.AddValue(bi.Max, "crawler.filecache.hit_ratio_permille", int(st.HitRatio()*1000))
+}
+
+// sleep sleeps for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+func cleanTempDir() {
+	tmpPath := villa.Path(os.TempDir()).Join("gddo")
+	if err := tmpPath.RemoveAll(); err != nil {
+		log.Printf("Cleaning up temporary directory %q failed: %v", tmpPath, err)
+	}
+}
+
+// Setup sets up the github spider with the file cache, and the popularity of
+// the packages. The returned function closes the file cache, and has to be
+// called when crawling is done.
+func Setup(httpClient doc.HttpClient) (func(), error) {
+	log.Printf("Using personal github token: %v", configs.CrawlerGithubPersonal != "")
+	gcse.GithubSpider = github.NewSpiderWithToken(configs.CrawlerGithubPersonal, httpClient)
+
+	fileCachePath := configs.FileCacheBoltPath()
+	db, err := bh.Open(fileCachePath, 0644, nil)
+	if err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "failed to open bolt file cache %q", fileCachePath)
+	}
+	log.Printf("Using file cache %q", fileCachePath)
+	fileCache = &spider.BoltFileCache{
+		DB:         db,
+		IncCounter: bi.Inc,
+		MaxEntries: configs.CrawlerFileCacheMaxEntries,
+		MaxBytes:   int64(configs.CrawlerFileCacheMaxBytes),
+	}
+	if err := fileCache.Init(); err != nil {
+		db.Close()
+		return nil, errorsp.WithStacksAndMessage(err, "failed to initialize file cache %q", fileCachePath)
+	}
+	gcse.GithubSpider.FileCache = fileCache
+
+	gcse.ConfigurePopularity(httpClient, configs.CrawlerGiteaHosts, func(pkg string) int {
+		return importedCounts[pkg]
+	}, configs.CrawlerDisabledPopularity)
+
+	return func() {
+		if err := fileCache.Flush(); err != nil {
+			log.Printf("Flushing file cache %q failed: %v", fileCachePath, err)
+		}
+		if err := db.Close(); err != nil {
+			log.Printf("Closing file cache %q failed: %v", fileCachePath, err)
+		}
+	}, nil
+}
+
+// Run crawls the packages and persons listed by tocrawl, and generates the
+// new docs in the crawler DB folder. Crawling stops after
+// configs.CrawlerDuePerRun, or when ctx is done, between packages and
+// persons.
+func Run(ctx context.Context) error {
+	httpClient := gcse.NewHTTPClient("")
+	closeCache, err := Setup(httpClient)
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	cleanTempDir()
+	defer cleanTempDir()
+
+	log.Println("Crawler started...")
+
+	if err := configs.Mkdirs(); err != nil {
+		return errorsp.WithStacks(err)
+	}
+
+	// Load CrawlerDB
+	cDB = gcse.LoadCrawlerDB()
+	defer cDB.Close()
+
+	allDocsPkgs = stringsp.Set{}
+	importedCounts = make(map[string]int)
+	fpDocs := configs.DocsDBFsPath()
+	dirInput := kv.DirInput(fpDocs)
+	if err := loadAllDocsPkgs(dirInput); err != nil {
+		return errorsp.WithStacksAndMessage(err, "loadAllDocsPkgs: loading data from %v", dirInput.Path)
+	}
+	log.Printf("%d docs loaded!", len(allDocsPkgs))
+
+	AppStopTime = time.Now().Add(configs.CrawlerDuePerRun)
+
+	//pathToCrawl := gcse.DataRoot.Join(gcse.FnToCrawl)
+	fpCrawler := configs.CrawlerDBFsPath()
+	fpToCrawl := configs.ToCrawlFsPath()
+
+	fpNewDocs := fpCrawler.Join(configs.FnNewDocs)
+	fpNewDocs.Remove()
+
+	if err := processImports(); err != nil {
+		return errorsp.WithStacksAndMessage(err, "processImports failed")
+	}
+
+	pkgEnd := make(chan error, 1)
+	go crawlPackages(ctx, httpClient, fpToCrawl.Join(configs.FnPackage), fpNewDocs, pkgEnd)
+
+	psnEnd := make(chan error, 1)
+	go crawlPersons(ctx, httpClient, fpToCrawl.Join(configs.FnPerson), psnEnd)
+
+	errPkg, errPsn := <-pkgEnd, <-psnEnd
+	reportFileCacheStats()
+	bi.Flush()
+	bi.Process()
+	if err := syncDatabases(); err != nil {
+		return err
+	}
+	if errPkg != nil || errPsn != nil {
+		return errorsp.NewWithStacks("One or more of the jobs failed; packages: %v, persons: %v", errPkg, errPsn)
+	}
+	if err := ctx.Err(); err != nil {
+		return errorsp.WithStacksAndMessage(err, "crawling canceled")
+	}
+
+	log.Println("Crawler finished OK")
+	return nil
+}
@@ -1,4 +1,4 @@
-package main
+package crawler
 
 import (
 	"log"
@@ -29,7 +29,5 @@ func processImports() error {
 			log.Printf("Remove %v failed: %v", segm, err)
 		}
 	}
-	syncDatabases()
-
-	return nil
+	return syncDatabases()
 }
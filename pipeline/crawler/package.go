@@ -1,4 +1,4 @@
-package main
+package crawler
 
 import (
 	"context"
@@ -13,6 +13,7 @@ import (
 	"github.com/daviddengcn/sophie"
 	"github.com/daviddengcn/sophie/kv"
 	"github.com/daviddengcn/sophie/mr"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/golangplus/errors"
 	"github.com/golangplus/strings"
 	"github.com/golangplus/time"
@@ -34,6 +35,9 @@ var (
 	crawlOverdueLimit = time.Minute * 10
 
 	allDocsPkgs stringsp.Set
+	// Number of the docs importing a package, for the popularity of packages
+	// not on the hosts reporting stars.
+	importedCounts = make(map[string]int)
 )
 
 // schedulePackageNextCrawl scheduled a package for next crawling cycle,
@@ -43,6 +47,22 @@ func schedulePackageNextCrawl(pkg string, etag string) {
 	cDB.SchedulePackage(pkg, time.Now().Add(d), etag)
 }
 
+// appendCrawlEvent appends the result of crawling the package, with the etag
+// seen and the error if failed, to its history.
+func appendCrawlEvent(site, path string, a gpb.HistoryEvent_Action_Enum, etag string, crawlErr error) {
+	ts, _ := ptypes.TimestampProto(time.Now())
+	e := &gpb.HistoryEvent{
+		Timestamp:      ts,
+		Action:         a,
+		Etag:           etag,
+		CrawlerVersion: gcse.CrawlerVersion,
+	}
+	if crawlErr != nil {
+		e.FailureReason = crawlErr.Error()
+	}
+	utils.LogError(store.AppendPackageHistoryEvent(site, path, e), "AppendPackageHistoryEvent %v %v failed", site, path)
+}
+
 func appendNewPackage(pkg, foundWay string) {
 	cDB.AppendPackage(pkg, allDocsPkgs.Contain)
 
@@ -69,6 +89,7 @@ func fillPackageInfo(p *gcse.Package, pi *gpb.PackageInfo) {
 	pi.ReadmeData = p.ReadmeData
 	pi.Exported = p.Exported
 	pi.References = p.References
+	pi.License = p.License
 
 	pi.Imports = nil
 	for _, imp := range p.Imports {
@@ -125,6 +146,17 @@ func packageToDoc(p *gcse.Package) gcse.DocInfo {
 		ReadmeFn:    p.ReadmeFn,
 		ReadmeData:  p.ReadmeData,
 		Exported:    p.Exported,
+		License:     p.License.GetSpdxId(),
+		ForkedFrom:  p.ForkedFrom,
+		Archived:    p.Archived,
+		Signature:   p.Signature,
+		Deprecated:  p.Deprecated,
+		Retracted:   p.Retracted,
+		Quality:     qualityToDoc(p.Quality),
+
+		RepoUpdated:   p.RepoUpdated,
+		LatestCommit:  p.LatestCommit,
+		LatestRelease: p.LatestRelease,
 	}
 
 	d.Imports = nil
@@ -152,9 +184,24 @@ func packageToDoc(p *gcse.Package) gcse.DocInfo {
 	return d
 }
 
+func qualityToDoc(q *gpb.QualityInfo) gcse.QualityInfo {
+	return gcse.QualityInfo{
+		TestFiles:  int(q.GetTestFiles()),
+		TestSize:   int(q.GetTestSize()),
+		Examples:   int(q.GetExamples()),
+		Exported:   int(q.GetExported()),
+		Documented: int(q.GetDocumented()),
+		UsesCgo:    q.GetUsesCgo(),
+		UsesUnsafe: q.GetUsesUnsafe(),
+		HasReadme:  q.GetHasReadme(),
+	}
+}
+
 type PackageCrawler struct {
 	crawlerMapper
 
+	// Crawling stops when ctx is done.
+	ctx        context.Context
 	part       int
 	failCount  int
 	httpClient doc.HttpClient
@@ -162,12 +209,15 @@ type PackageCrawler struct {
 
 // OnlyMapper.Map
 func (pc *PackageCrawler) Map(key, val sophie.SophieWriter, c []sophie.Collector) error {
-	// TODO add context in villa
-	ctx := context.Background()
+	ctx := pc.ctx
 	if time.Now().After(AppStopTime) {
 		log.Printf("[Part %d] Timeout(key = %v), PackageCrawler returns EOM", pc.part, key)
 		return mr.EOM
 	}
+	if ctx.Err() != nil {
+		log.Printf("[Part %d] Canceled(key = %v), PackageCrawler returns EOM", pc.part, key)
+		return mr.EOM
+	}
 	pkg := string(*key.(*sophie.RawString))
 	ent := val.(*gcse.CrawlingEntry)
 	if ent.Version < gcse.CrawlerVersion {
@@ -195,7 +245,7 @@ func (pc *PackageCrawler) Map(key, val sophie.SophieWriter, c []sophie.Collector
 	if err != nil && errorsp.Cause(err) != gcse.ErrPackageNotModifed {
 		log.Printf("[Part %d] Crawling pkg %v failed: %v", pc.part, pkg, err)
 		if gcse.IsBadPackage(err) {
-			utils.LogError(store.AppendPackageEvent(site, path, "", time.Now(), gpb.HistoryEvent_Action_Invalid), "AppendPackageEvent %v %v failed", site, path)
+			appendCrawlEvent(site, path, gpb.HistoryEvent_Action_Invalid, ent.Etag, err)
 			bi.AddValue(bi.Sum, "crawler.package.wrong-package", 1)
 			// a wrong path
 			nda := gcse.NewDocAction{
@@ -205,7 +255,7 @@ func (pc *PackageCrawler) Map(key, val sophie.SophieWriter, c []sophie.Collector
 			cDB.PackageDB.Delete(pkg)
 			log.Printf("[Part %d] Removed bad (i.e. wrong) package %v", pc.part, pkg)
 		} else {
-			utils.LogError(store.AppendPackageEvent(site, path, "", time.Now(), gpb.HistoryEvent_Action_Failed), "AppendPackageEvent %v %v failed", site, path)
+			appendCrawlEvent(site, path, gpb.HistoryEvent_Action_Failed, ent.Etag, err)
 			bi.Inc("crawler.package.failed")
 			if strings.HasPrefix(pkg, "github.com/") {
 				bi.Inc("crawler.package.failed.github")
@@ -225,14 +275,18 @@ func (pc *PackageCrawler) Map(key, val sophie.SophieWriter, c []sophie.Collector
 				}
 
 				log.Printf("[Part %d] Last ten crawling packages failed, sleep for a while...(current: %v)", pc.part, pkg)
-				time.Sleep(durToSleep)
+				sleep(ctx, durToSleep)
 				pc.failCount = 0
 			}
 		}
 		return nil
 	}
 
-	utils.LogError(store.AppendPackageEvent(site, path, "", time.Now(), gpb.HistoryEvent_Action_Success), "AppendPackageEvent %v %v failed", site, path)
+	etag := ent.Etag
+	if p != nil {
+		etag = p.Etag
+	}
+	appendCrawlEvent(site, path, gpb.HistoryEvent_Action_Success, etag, nil)
 	pc.failCount = 0
 	if errorsp.Cause(err) == gcse.ErrPackageNotModifed {
 		// TODO crawling stars for unchanged project
@@ -267,13 +321,13 @@ func (pc *PackageCrawler) Map(key, val sophie.SophieWriter, c []sophie.Collector
 
 	if !strings.HasPrefix(pkg, "github.com/") {
 		// github.com throttling is done within the GithubSpider.
-		time.Sleep(10 * time.Second)
+		sleep(ctx, 10*time.Second)
 	}
 	return nil
 }
 
 // crawlPackages crawls packages and sends any error back to "end" channel.
-func crawlPackages(httpClient doc.HttpClient, fpToCrawlPkg, fpOutNewDocs sophie.FsPath, end chan error) {
+func crawlPackages(ctx context.Context, httpClient doc.HttpClient, fpToCrawlPkg, fpOutNewDocs sophie.FsPath, end chan error) {
 	timeout := configs.CrawlerDuePerRun + crawlOverdueLimit
 
 	time.AfterFunc(timeout, func() {
@@ -283,11 +337,12 @@ func crawlPackages(httpClient doc.HttpClient, fpToCrawlPkg, fpOutNewDocs sophie.
 		outNewDocs := kv.DirOutput(fpOutNewDocs)
 		outNewDocs.Clean()
 		job := mr.MapOnlyJob{
-			Source: []mr.Input{
+			Source: utils.LimitInputs([]mr.Input{
 				kv.DirInput(fpToCrawlPkg),
-			},
+			}, configs.CrawlerWorkers),
 			NewMapperF: func(src, part int) mr.OnlyMapper {
 				return &PackageCrawler{
+					ctx:        ctx,
 					part:       part,
 					httpClient: httpClient,
 				}
@@ -1,4 +1,4 @@
-package main
+package crawler
 
 import (
 	"context"
@@ -17,6 +17,7 @@ import (
 
 	"github.com/daviddengcn/gcse"
 	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/utils"
 )
 
 const (
@@ -26,6 +27,8 @@ const (
 type PersonCrawler struct {
 	crawlerMapper
 
+	// Crawling stops when ctx is done.
+	ctx        context.Context
 	part       int
 	failCount  int
 	httpClient doc.HttpClient
@@ -40,12 +43,16 @@ func pushPerson(p *gcse.Person) {
 
 // OnlyMapper.Map
 func (pc *PersonCrawler) Map(key, val sophie.SophieWriter, c []sophie.Collector) error {
-	ctx := context.Background()
+	ctx := pc.ctx
 
 	if time.Now().After(AppStopTime) {
 		log.Printf("[Part %d] Timeout(key = %v), PersonCrawler returns EOM", pc.part, key)
 		return mr.EOM
 	}
+	if ctx.Err() != nil {
+		log.Printf("[Part %d] Canceled(key = %v), PersonCrawler returns EOM", pc.part, key)
+		return mr.EOM
+	}
 
 	id := string(*key.(*sophie.RawString))
 	// ent := val.(*gcse.CrawlingEntry)
@@ -69,7 +76,7 @@ func (pc *PersonCrawler) Map(key, val sophie.SophieWriter, c []sophie.Collector)
 			}
 
 			log.Printf("[Part %d] Last ten crawling persons failed, sleep for a while...(current: %s)", pc.part, id)
-			time.Sleep(durToSleep)
+			sleep(ctx, durToSleep)
 			pc.failCount = 0
 		}
 		return nil
@@ -80,7 +87,7 @@ func (pc *PersonCrawler) Map(key, val sophie.SophieWriter, c []sophie.Collector)
 	log.Printf("[Part %d] Push person %s success", pc.part, id)
 	pc.failCount = 0
 
-	time.Sleep(10 * time.Second)
+	sleep(ctx, 10*time.Second)
 
 	return nil
 }
@@ -91,6 +98,7 @@ type PeresonCrawlerFactory struct {
 
 func (pcf PeresonCrawlerFactory) NewMapper(part int) mr.OnlyMapper {
 	pc := &PersonCrawler{
+		ctx:        context.Background(),
 		part:       part,
 		httpClient: pcf.httpClient,
 	}
@@ -98,7 +106,7 @@ func (pcf PeresonCrawlerFactory) NewMapper(part int) mr.OnlyMapper {
 }
 
 // crawl packages, send error back to end
-func crawlPersons(httpClient doc.HttpClient, fpToCrawlPerson sophie.FsPath, end chan error) {
+func crawlPersons(ctx context.Context, httpClient doc.HttpClient, fpToCrawlPerson sophie.FsPath, end chan error) {
 	timeout := configs.CrawlerDuePerRun + crawlOverdueLimit
 
 	time.AfterFunc(timeout, func() {
@@ -107,11 +115,12 @@ func crawlPersons(httpClient doc.HttpClient, fpToCrawlPerson sophie.FsPath, end
 
 	end <- func() error {
 		job := mr.MapOnlyJob{
-			Source: []mr.Input{
+			Source: utils.LimitInputs([]mr.Input{
 				kv.DirInput(fpToCrawlPerson),
-			},
+			}, configs.CrawlerWorkers),
 			NewMapperF: func(src, part int) mr.OnlyMapper {
 				return &PersonCrawler{
+					ctx:        ctx,
 					part:       part,
 					httpClient: httpClient,
 				}
@@ -0,0 +1,267 @@
+// Package indexer is the stage of the pipeline analyzing the docs and
+// generating the index for searching.
+package indexer
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/strings"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/store"
+	"github.com/daviddengcn/gcse/utils"
+	"github.com/daviddengcn/go-easybi"
+	"github.com/daviddengcn/go-index"
+	"github.com/daviddengcn/sophie/kv"
+)
+
+// Options of Run.
+type Options struct {
+	// Builds a full index even if a delta is enough.
+	Full bool
+	// Marks the new segment done even if it fails the validation. Its
+	// Validation is saved anyway, for validating the deltas of it.
+	SkipValidation bool
+}
+
+// Stats is the result of Run.
+type Stats struct {
+	// Whether a delta is built.
+	Delta bool
+	// Number of the docs in the index, or the changed ones in the delta.
+	Docs int
+	// Number of the docs deleted, in the delta only.
+	Deleted int
+}
+
+// clearOutdatedIndex removes the segments other than the latest keep done
+// ones, the pinned one, and the full indexes of the kept deltas.
+func clearOutdatedIndex(keep int) error {
+	segms := configs.IndexSegments()
+	dones, err := gcse.DoneIndexSegments(segms)
+	if err != nil {
+		return err
+	}
+	if keep < 1 {
+		keep = 1
+	}
+	if len(dones) > keep {
+		dones = dones[:keep]
+	}
+	pinned, err := gcse.PinnedIndexSegment(segms)
+	if err != nil {
+		return err
+	}
+	if pinned != "" {
+		dones = append(dones, pinned)
+	}
+	var kept stringsp.Set
+	for _, s := range dones {
+		base, err := gcse.IndexBaseSegment(s)
+		if err != nil {
+			return err
+		}
+		kept.Add(string(s), string(base))
+	}
+
+	all, err := segms.ListAll()
+	if err != nil {
+		return err
+	}
+	for _, s := range all {
+		if kept.Contain(string(s)) {
+			continue
+		}
+		err := s.Remove()
+		if err != nil {
+			return err
+		}
+		log.Printf("Outdated segment %v removed!", s)
+	}
+	return nil
+}
+
+// deltaBase returns the segment of the full index to build a delta on, empty
+// if a full index should be built.
+func deltaBase() (utils.Segment, error) {
+	segm, err := configs.IndexSegments().FindMaxDone()
+	if segm == "" || err != nil {
+		return "", err
+	}
+	if segm, err = gcse.IndexBaseSegment(segm); err != nil {
+		return "", err
+	}
+	st, err := os.Stat(segm.Join(gcse.IndexStateFn))
+	if err != nil {
+		// e.g. indexed by an older version.
+		log.Printf("No index state in %v, building a full index: %v", segm, err)
+		return "", nil
+	}
+	if age := time.Since(st.ModTime()); age > configs.IndexerFullInterval {
+		log.Printf("Full index %v is %v old, building a full index", segm, age)
+		return "", nil
+	}
+	return segm, nil
+}
+
+// Run removes the undone and outdated index segments, and indexes the docs
+// into a new segment, a delta of the changed docs since the last full index
+// if possible. The segment is marked done, i.e. to be served, only if it
+// passes the validation against the last good one.
+func Run(opts Options) (Stats, error) {
+	log.Println("indexer started...")
+
+	if err := configs.IndexSegments().ClearUndones(); err != nil {
+		log.Printf("Indexer: ClearUndones failed: %v", err)
+	}
+
+	if err := clearOutdatedIndex(configs.IndexerKeepSegments); err != nil {
+		log.Printf("Indexer: clearOutdatedIndex failed: %v", err)
+	}
+	return doIndex(opts)
+}
+
+// validateSegment validates the new segment idxSegm, a delta of base if info
+// is not nil, against prevSegm, and saves the Validation if passed, or if
+// force is true, in which case the failure is still returned.
+func validateSegment(idxSegm utils.Segment, ts *index.TokenSetSearcher, info *gcse.DeltaInfo, base, prevSegm utils.Segment, force bool) error {
+	v := &validator{
+		MaxDocDrop:        configs.IndexerMaxDocDrop,
+		MaxZeroScoreRatio: configs.IndexerMaxZeroScoreRatio,
+		SampleQueries:     configs.IndexerSampleQueries,
+	}
+	var prev *Validation
+	if prevSegm != "" {
+		var err error
+		if prev, err = ReadValidation(prevSegm); err != nil {
+			return err
+		}
+	}
+	var res *Validation
+	var failed error
+	if info == nil {
+		res, failed = v.validateFull(ts, prev)
+	} else {
+		baseV, err := ReadValidation(base)
+		if err != nil {
+			return err
+		}
+		res, failed = v.validateDelta(ts, info, baseV, prev)
+	}
+	if failed != nil && !force {
+		return failed
+	}
+	if failed == nil {
+		log.Printf("Segment %v passed the validation: %d docs, samples found: %v", idxSegm, res.Docs, res.Found)
+	}
+	if err := saveValidation(idxSegm, res); err != nil {
+		return err
+	}
+	return failed
+}
+
+func doIndex(opts Options) (Stats, error) {
+	var base utils.Segment
+	if !opts.Full {
+		var err error
+		if base, err = deltaBase(); err != nil {
+			log.Printf("Indexer: deltaBase failed, building a full index: %v", err)
+		}
+	}
+
+	// The new segment is validated against the one served.
+	prevSegm, err := gcse.ServedIndexSegment(configs.IndexSegments())
+	if err != nil {
+		return Stats{}, errorsp.WithStacksAndMessage(err, "ServedIndexSegment failed")
+	}
+
+	idxSegm, err := configs.IndexSegments().GenMaxSegment()
+	if err != nil {
+		return Stats{}, errorsp.WithStacksAndMessage(err, "GenMaxSegment failed")
+	}
+
+	runtime.GC()
+	utils.DumpMemStats()
+
+	fpDocDB := configs.DocsDBFsPath()
+	var st Stats
+	var ts *index.TokenSetSearcher
+	var info *gcse.DeltaInfo
+	if base != "" {
+		log.Printf("Indexing the delta of %v to %v ...", base, idxSegm)
+		ts, info, err = gcse.IndexDelta(kv.DirInput(fpDocDB), string(base), string(idxSegm))
+		if err != nil {
+			return Stats{}, errorsp.WithStacksAndMessage(err, "delta indexing failed")
+		}
+		st = Stats{Delta: true, Docs: ts.DocCount(), Deleted: len(info.Deleted)}
+		if changed := st.Docs + st.Deleted; float64(changed) > configs.IndexerDeltaMaxRatio*float64(info.BaseDocs) {
+			log.Printf("%d of %d docs changed since %v, building a full index", changed, info.BaseDocs, base)
+			ts, info = nil, nil
+			if err := idxSegm.Remove(); err != nil {
+				return Stats{}, err
+			}
+			if err := idxSegm.Make(); err != nil {
+				return Stats{}, errorsp.WithStacks(err)
+			}
+		}
+	}
+	if ts == nil {
+		log.Printf("Indexing to %v ...", idxSegm)
+		if ts, err = gcse.Index(kv.DirInput(fpDocDB), string(idxSegm)); err != nil {
+			return Stats{}, errorsp.WithStacksAndMessage(err, "indexing failed")
+		}
+		st = Stats{Docs: ts.DocCount()}
+	}
+
+	if err := func() error {
+		f, err := os.Create(idxSegm.Join(gcse.IndexFn))
+		if err != nil {
+			return errorsp.WithStacksAndMessage(err, "create index file failed")
+		}
+		defer f.Close()
+
+		log.Printf("Saving index to %v ...", idxSegm)
+		return errorsp.WithStacksAndMessage(ts.Save(f), "ts.Save failed")
+	}(); err != nil {
+		return Stats{}, err
+	}
+	runtime.GC()
+	utils.DumpMemStats()
+
+	storePath := idxSegm.Join(configs.FnStore)
+	log.Printf("Saving store snapshot to %v", storePath)
+	if err := store.SaveSnapshot(storePath); err != nil {
+		log.Printf("SaveSnapshot %v failed: %v", storePath, err)
+	}
+
+	if err := validateSegment(idxSegm, ts, info, base, prevSegm, opts.SkipValidation); err != nil {
+		gcse.AddBiValueAndProcess(bi.Sum, "index.validation-failures", 1)
+		if !opts.SkipValidation {
+			return st, errorsp.WithStacksAndMessage(err, "segment %v is not served", idxSegm)
+		}
+		log.Printf("Segment %v failed the validation, served anyway: %v", idxSegm, err)
+	}
+
+	if err := idxSegm.Done(); err != nil {
+		return Stats{}, errorsp.WithStacksAndMessage(err, "segm.Done failed")
+	}
+
+	if st.Delta {
+		log.Printf("Delta indexing success: %s (%d changed, %d deleted)", idxSegm, st.Docs, st.Deleted)
+	} else {
+		log.Printf("Indexing success: %s (%d)", idxSegm, st.Docs)
+		gcse.AddBiValueAndProcess(bi.Average, "index.doc-count", st.Docs)
+	}
+
+	ts = nil
+	utils.DumpMemStats()
+	runtime.GC()
+	utils.DumpMemStats()
+
+	return st, nil
+}
@@ -0,0 +1,184 @@
+package indexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/strings"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/utils"
+	"github.com/daviddengcn/go-index"
+)
+
+// ValidationFn is the file of the Validation in a segment which passed the
+// validation.
+const ValidationFn = "validation.json"
+
+// Validation is the result of the validation of an index segment.
+type Validation struct {
+	// Number of the docs served, including those of the full index for a
+	// delta.
+	Docs int
+	// The expected packages found by the sample queries, by queries.
+	Found map[string][]string `json:",omitempty"`
+}
+
+// ReadValidation returns the Validation of segm, nil if not validated, e.g.
+// built by an older version.
+func ReadValidation(segm utils.Segment) (*Validation, error) {
+	bs, err := ioutil.ReadFile(segm.Join(ValidationFn))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errorsp.WithStacks(err)
+	}
+	var v Validation
+	if err := json.Unmarshal(bs, &v); err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "decoding %v failed", segm.Join(ValidationFn))
+	}
+	return &v, nil
+}
+
+func saveValidation(segm utils.Segment, v *Validation) error {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return errorsp.WithStacks(ioutil.WriteFile(segm.Join(ValidationFn), bs, 0644))
+}
+
+// validator checks a new index segment against the last good one.
+type validator struct {
+	MaxDocDrop        float64
+	MaxZeroScoreRatio float64
+	SampleQueries     map[string][]string
+}
+
+// ValidationError lists the failed checks of a segment.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "validation failed: " + strings.Join(e, "; ")
+}
+
+func (v *validator) checkDocs(docs int, prev *Validation, errs *ValidationError) {
+	if prev == nil || prev.Docs == 0 {
+		return
+	}
+	if float64(docs) < float64(prev.Docs)*(1-v.MaxDocDrop) {
+		*errs = append(*errs, fmt.Sprintf("%d docs, dropped from %d by more than %.0f%%", docs, prev.Docs, v.MaxDocDrop*100))
+	}
+}
+
+// checkScores checks the ratio of the hits in ts with zero or invalid static
+// scores.
+func (v *validator) checkScores(ts *index.TokenSetSearcher, errs *ValidationError) {
+	bad := 0
+	ts.Search(nil, func(_ int32, data interface{}) error {
+		s := data.(gcse.HitInfo).StaticScore
+		if s <= 0 || math.IsNaN(s) || math.IsInf(s, 0) {
+			bad++
+		}
+		return nil
+	})
+	if bad > 0 && float64(bad) > float64(ts.DocCount())*v.MaxZeroScoreRatio {
+		*errs = append(*errs, fmt.Sprintf("%d of %d hits with zero or invalid static scores", bad, ts.DocCount()))
+	}
+}
+
+// matchedPackages returns the packages in ts matching the query.
+func matchedPackages(ts *index.TokenSetSearcher, q string) stringsp.Set {
+	var pkgs stringsp.Set
+	ts.Search(map[string]stringsp.Set{gcse.IndexTextField: gcse.AppendTokens(nil, []byte(q))}, func(_ int32, data interface{}) error {
+		pkgs.Add(data.(gcse.HitInfo).Package)
+		return nil
+	})
+	return pkgs
+}
+
+// checkSamples records the expected packages found, by found(q, pkg), into
+// res, and fails if one is not. The ones found in prev are reported as
+// regressions.
+func (v *validator) checkSamples(found func(q, pkg string) bool, prev, res *Validation, errs *ValidationError) {
+	for q, pkgs := range v.SampleQueries {
+		var prevFound stringsp.Set
+		if prev != nil {
+			prevFound.Add(prev.Found[q]...)
+		}
+		for _, pkg := range pkgs {
+			if found(q, pkg) {
+				if res.Found == nil {
+					res.Found = make(map[string][]string)
+				}
+				res.Found[q] = append(res.Found[q], pkg)
+			} else if prevFound.Contain(pkg) {
+				*errs = append(*errs, fmt.Sprintf("%s not found by %q, found by the last good segment", pkg, q))
+			} else {
+				*errs = append(*errs, fmt.Sprintf("%s not found by %q", pkg, q))
+			}
+		}
+	}
+	sort.Strings(*errs)
+}
+
+// validateFull validates the full index ts against the last good segment. The
+// Validation is returned even if it fails.
+func (v *validator) validateFull(ts *index.TokenSetSearcher, prev *Validation) (*Validation, error) {
+	res := &Validation{Docs: ts.DocCount()}
+	var errs ValidationError
+	v.checkDocs(res.Docs, prev, &errs)
+	v.checkScores(ts, &errs)
+	matched := make(map[string]stringsp.Set)
+	v.checkSamples(func(q, pkg string) bool {
+		if _, ok := matched[q]; !ok {
+			matched[q] = matchedPackages(ts, q)
+		}
+		return matched[q].Contain(pkg)
+	}, prev, res, &errs)
+	if len(errs) > 0 {
+		return res, errs
+	}
+	return res, nil
+}
+
+// validateDelta validates the delta ts of the full index validated as base,
+// against the last good segment. The Validation is returned even if it fails.
+func (v *validator) validateDelta(ts *index.TokenSetSearcher, info *gcse.DeltaInfo, base, prev *Validation) (*Validation, error) {
+	res := &Validation{Docs: info.BaseDocs + info.Added - len(info.Deleted)}
+	var errs ValidationError
+	v.checkDocs(res.Docs, prev, &errs)
+	v.checkScores(ts, &errs)
+
+	deleted := stringsp.NewSet(info.Deleted...)
+	var inDelta stringsp.Set
+	ts.Search(nil, func(_ int32, data interface{}) error {
+		inDelta.Add(data.(gcse.HitInfo).Package)
+		return nil
+	})
+	matched := make(map[string]stringsp.Set)
+	v.checkSamples(func(q, pkg string) bool {
+		if deleted.Contain(pkg) {
+			return false
+		}
+		if inDelta.Contain(pkg) {
+			if _, ok := matched[q]; !ok {
+				matched[q] = matchedPackages(ts, q)
+			}
+			return matched[q].Contain(pkg)
+		}
+		// Unchanged since the full index.
+		return base != nil && stringsp.NewSet(base.Found[q]...).Contain(pkg)
+	}, prev, res, &errs)
+	if len(errs) > 0 {
+		return res, errs
+	}
+	return res, nil
+}
@@ -0,0 +1,139 @@
+package indexer
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/golangplus/strings"
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/go-index"
+)
+
+type testHit struct {
+	pkg, text string
+	score     float64
+}
+
+func newTestSearcher(hits ...testHit) *index.TokenSetSearcher {
+	ts := &index.TokenSetSearcher{}
+	for _, h := range hits {
+		ts.AddDoc(map[string]stringsp.Set{
+			gcse.IndexTextField: gcse.AppendTokens(nil, []byte(h.text)),
+			gcse.IndexPkgField:  stringsp.NewSet(h.pkg),
+		}, gcse.HitInfo{
+			DocInfo:     gcse.DocInfo{Package: h.pkg},
+			StaticScore: h.score,
+		})
+	}
+	return ts
+}
+
+func TestValidateFull(t *testing.T) {
+	v := &validator{
+		MaxDocDrop:        0.2,
+		MaxZeroScoreRatio: 0.25,
+		SampleQueries: map[string][]string{
+			"mux": {"github.com/gorilla/mux"},
+		},
+	}
+	ts := newTestSearcher(
+		testHit{"github.com/gorilla/mux", "mux router", 2},
+		testHit{"github.com/a/a", "a", 1},
+		testHit{"github.com/b/b", "b", 1},
+		testHit{"github.com/c/c", "c", 1},
+	)
+
+	// No previous segment.
+	res, err := v.validateFull(ts, nil)
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "res", *res, Validation{
+		Docs:  4,
+		Found: map[string][]string{"mux": {"github.com/gorilla/mux"}},
+	})
+
+	// Within the threshold of the doc drop.
+	_, err = v.validateFull(ts, &Validation{Docs: 5})
+	assert.NoError(t, err)
+
+	// Dropped too many docs.
+	_, err = v.validateFull(ts, &Validation{Docs: 6})
+	assert.Error(t, err)
+
+	// An expected sample is missing, even without a previous segment.
+	v.SampleQueries["mux"] = append(v.SampleQueries["mux"], "github.com/x/mux")
+	_, err = v.validateFull(ts, nil)
+	assert.Equal(t, "err", err, error(ValidationError{`github.com/x/mux not found by "mux"`}))
+
+	// A sample found by the previous segment is missing.
+	_, err = v.validateFull(ts, &Validation{
+		Docs:  4,
+		Found: map[string][]string{"mux": {"github.com/x/mux"}},
+	})
+	assert.Equal(t, "err", err, error(ValidationError{`github.com/x/mux not found by "mux", found by the last good segment`}))
+	v.SampleQueries["mux"] = v.SampleQueries["mux"][:1]
+
+	// Zero and invalid scores.
+	ts = newTestSearcher(
+		testHit{"github.com/gorilla/mux", "mux router", 0},
+		testHit{"github.com/a/a", "a", math.NaN()},
+		testHit{"github.com/b/b", "b", 1},
+		testHit{"github.com/c/c", "c", 1},
+	)
+	_, err = v.validateFull(ts, nil)
+	assert.Equal(t, "err", err, error(ValidationError{"2 of 4 hits with zero or invalid static scores"}))
+}
+
+func TestValidateDelta(t *testing.T) {
+	const (
+		pkgMux  = "github.com/gorilla/mux"
+		pkgGin  = "github.com/gin-gonic/gin"
+		pkgXMux = "github.com/x/mux"
+	)
+	v := &validator{
+		MaxDocDrop:        0.2,
+		MaxZeroScoreRatio: 0.01,
+		SampleQueries: map[string][]string{
+			"gin": {pkgGin},
+			"mux": {pkgMux, pkgXMux},
+		},
+	}
+	base := &Validation{
+		Docs:  100,
+		Found: map[string][]string{"gin": {pkgGin}, "mux": {pkgMux, pkgXMux}},
+	}
+	// mux is changed and x/mux is deleted, gin is unchanged.
+	ts := newTestSearcher(testHit{pkgMux, "mux router", 2})
+	info := &gcse.DeltaInfo{Base: "base", BaseDocs: 100, Added: 2, Deleted: []string{pkgXMux}}
+
+	res, err := v.validateDelta(ts, info, base, base)
+	assert.Equal(t, "err", err, error(ValidationError{`github.com/x/mux not found by "mux", found by the last good segment`}))
+	// The Validation is returned even if failed.
+	assert.Equal(t, "res.Found", res.Found, map[string][]string{"gin": {pkgGin}, "mux": {pkgMux}})
+
+	// Validated against a previous segment not finding x/mux, which is still
+	// expected.
+	prev := &Validation{Docs: 100, Found: map[string][]string{"gin": {pkgGin}, "mux": {pkgMux}}}
+	_, err = v.validateDelta(ts, info, base, prev)
+	assert.Equal(t, "err", err, error(ValidationError{`github.com/x/mux not found by "mux"`}))
+
+	// x/mux is no longer expected.
+	v.SampleQueries["mux"] = []string{pkgMux}
+	res, err = v.validateDelta(ts, info, base, prev)
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "res.Docs", res.Docs, 101)
+	assert.True(t, "res.Found", reflect.DeepEqual(res.Found, prev.Found))
+
+	// mux no longer matches the query.
+	ts = newTestSearcher(testHit{pkgMux, "router", 2})
+	_, err = v.validateDelta(ts, info, base, prev)
+	assert.Equal(t, "err", err, error(ValidationError{`github.com/gorilla/mux not found by "mux", found by the last good segment`}))
+
+	// Too many deleted.
+	info = &gcse.DeltaInfo{Base: "base", BaseDocs: 100, Deleted: make([]string, 30)}
+	ts = newTestSearcher()
+	_, err = v.validateDelta(ts, info, base, &Validation{Docs: 100})
+	assert.Error(t, err)
+}
@@ -0,0 +1,28 @@
+//go:build windows || plan9
+
+package pipeline
+
+import (
+	"os"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/go-villa"
+)
+
+// lockFile takes an exclusive lock of fn by creating it, which is released by
+// the returned function. ErrLocked is returned if it exists. Unlike flock, the
+// lock is left behind if the process dies, and has to be removed manually.
+func lockFile(fn villa.Path) (func(), error) {
+	f, err := os.OpenFile(fn.S(), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, ErrLocked
+		}
+		return nil, errorsp.WithStacks(err)
+	}
+	f.Close()
+	return func() {
+		fn.Remove()
+	}, nil
+}
@@ -0,0 +1,33 @@
+//go:build !windows && !plan9
+
+package pipeline
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/go-villa"
+)
+
+// lockFile takes an exclusive lock of fn, which is released by the returned
+// function or when the process exits. ErrLocked is returned if it is held by
+// others.
+func lockFile(fn villa.Path) (func(), error) {
+	f, err := os.OpenFile(fn.S(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, ErrLocked
+		}
+		return nil, errorsp.WithStacks(err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
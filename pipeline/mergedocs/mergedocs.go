@@ -0,0 +1,517 @@
+// Package mergedocs is the stage of the pipeline merging the newly crawled
+// docs back into the docs.
+//
+// Input
+//
+//	FnDocs of the current generation
+//	FnNewDocs
+//
+// Output
+//
+//	A new generation of the docs, which becomes the current one
+//
+// Only the partitions of the docs touched by the new docs are rewritten, the
+// others are linked from the current generation. The previous generation is
+// kept for recovery, see Rollback.
+package mergedocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/strings"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/utils"
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/kv"
+	"github.com/daviddengcn/sophie/mr"
+)
+
+// Stats is the numbers of the docs by how they are merged. Unchanged counts
+// the docs in the rewritten partitions only.
+type Stats struct {
+	Deleted, Updated, New, Unchanged int64
+	// The number of the partitions rewritten.
+	Rewritten int64
+}
+
+// MergeFn is the file of the MergeRecord in a generation of the docs.
+const MergeFn = "merge.json"
+
+// MergeRecord is saved in a generation of the docs.
+type MergeRecord struct {
+	// The generation merged into, empty for the legacy docs folder.
+	Base string `json:",omitempty"`
+	Time time.Time
+	// The number of the partitions.
+	DocsParts int
+	// Whether all the partitions were rewritten.
+	Full  bool
+	Stats Stats
+	// The partitions rewritten.
+	Partitions []int `json:",omitempty"`
+}
+
+// ReadMergeRecord returns the MergeRecord of a generation.
+func ReadMergeRecord(gen utils.Segment) (*MergeRecord, error) {
+	bs, err := ioutil.ReadFile(gen.Join(MergeFn))
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	var rec MergeRecord
+	if err := json.Unmarshal(bs, &rec); err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "decoding %v failed", gen.Join(MergeFn))
+	}
+	return &rec, nil
+}
+
+func saveMergeRecord(gen utils.Segment, rec *MergeRecord) error {
+	bs, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return errorsp.WithStacks(ioutil.WriteFile(gen.Join(MergeFn), bs, 0644))
+}
+
+// partPath returns the file of a partition in a kv.DirOutput.
+func partPath(dir string, part int) string {
+	return filepath.Join(dir, fmt.Sprintf("part-%05d", part))
+}
+
+// touchedPartitions returns the partitions of the docs of the packages in
+// the new docs, in ascending order.
+func touchedPartitions(newDocs kv.DirInput) ([]int, error) {
+	cnt, err := newDocs.PartCount()
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	touched := make(map[int]bool)
+	var parts []int
+	for i := 0; i < cnt; i++ {
+		it, err := newDocs.Iterator(i)
+		if err != nil {
+			return nil, errorsp.WithStacks(err)
+		}
+		var key sophie.RawString
+		val := gcse.NewNewDocAction()
+		for {
+			if err := it.Next(&key, val); err != nil {
+				if errorsp.Cause(err) == io.EOF {
+					break
+				}
+				it.Close()
+				return nil, errorsp.WithStacks(err)
+			}
+			part := gcse.CalcPackagePartition(string(key), configs.DocsParts)
+			if !touched[part] {
+				touched[part] = true
+				parts = append(parts, part)
+			}
+		}
+		it.Close()
+	}
+	sort.Ints(parts)
+	return parts, nil
+}
+
+// partialInput returns the input of the partitions parts of in.
+func partialInput(in mr.Input, parts []int) mr.Input {
+	return &mr.InputStruct{
+		PartCountF: func() (int, error) {
+			return len(parts), nil
+		},
+		IteratorF: func(i int) (sophie.IterateCloser, error) {
+			return in.Iterator(parts[i])
+		},
+	}
+}
+
+// linkPart links, or copies if linking is not supported, the file src to dst.
+func linkPart(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return errorsp.WithStacks(err)
+	}
+	return errorsp.WithStacks(out.Close())
+}
+
+// isFullDocs returns whether dir has all the partitions of the docs.
+func isFullDocs(dir string) bool {
+	for part := 0; part < configs.DocsParts; part++ {
+		if _, err := os.Stat(partPath(dir, part)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// setCurrentGeneration atomically makes gen the current generation.
+func setCurrentGeneration(gen utils.Segment) error {
+	fn := filepath.Join(string(configs.DocsGenerations()), configs.FnDocsGenCurrent)
+	if err := ioutil.WriteFile(fn+".new", []byte(gen.Name()+"\n"), 0644); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return errorsp.WithStacks(os.Rename(fn+".new", fn))
+}
+
+// pruneGenerations removes the generations other than the current one and
+// the one it is merged from, and the legacy docs folder once both are
+// generations.
+func pruneGenerations(cur utils.Segment, base string) error {
+	gens, err := configs.DocsGenerations().ListAll()
+	if err != nil {
+		return err
+	}
+	for _, gen := range gens {
+		if gen == cur || gen.Name() == base {
+			continue
+		}
+		if err := gen.Remove(); err != nil {
+			return err
+		}
+		log.Printf("Outdated docs generation %v removed!", gen)
+	}
+	if base != "" {
+		return errorsp.WithStacks(os.RemoveAll(configs.DataRoot.Join(configs.FnDocs).S()))
+	}
+	return nil
+}
+
+// Rollback makes the generation the current one was merged from the current
+// one again, e.g. after a bad merge. The current one is removed.
+func Rollback() error {
+	cur := configs.CurrentDocsGeneration()
+	if cur == "" {
+		return errorsp.NewWithStacks("no generation of the docs to roll back")
+	}
+	rec, err := ReadMergeRecord(cur)
+	if err != nil {
+		return err
+	}
+	if rec.Base == "" {
+		// Back to the legacy folder.
+		if legacy := configs.DataRoot.Join(configs.FnDocs).S(); !isFullDocs(legacy) {
+			return errorsp.NewWithStacks("legacy docs %v to roll back to is not found", legacy)
+		}
+		fn := filepath.Join(string(configs.DocsGenerations()), configs.FnDocsGenCurrent)
+		if err := os.Remove(fn); err != nil {
+			return errorsp.WithStacks(err)
+		}
+	} else {
+		base := configs.DocsGenerations().Join(rec.Base)
+		if !base.IsDone() {
+			return errorsp.NewWithStacks("generation %v to roll back to is not found", base)
+		}
+		if err := setCurrentGeneration(base); err != nil {
+			return err
+		}
+	}
+	log.Printf("Docs rolled back from %v to %q", cur, rec.Base)
+	return cur.Remove()
+}
+
+// Run merges the newly crawled docs into a new generation of the docs, which
+// becomes the current one, and returns the stats. All the partitions are
+// rewritten if the current generation is partitioned differently from
+// configs.DocsParts.
+func Run() (Stats, error) {
+	log.Println("Merging new crawled docs back...")
+	return merge(true)
+}
+
+// Repartition rewrites the docs into configs.DocsParts partitions of a new
+// generation, which becomes the current one.
+func Repartition() (Stats, error) {
+	log.Printf("Repartitioning the docs into %d partitions...", configs.DocsParts)
+	return merge(false)
+}
+
+// isCompatible returns whether the docs of gen, in dir, are partitioned as
+// configured, so that the partitions not touched can be reused.
+func isCompatible(gen utils.Segment, dir string) bool {
+	if gen == "" {
+		// The legacy docs, partitioned by an older hash.
+		return false
+	}
+	rec, err := ReadMergeRecord(gen)
+	if err != nil {
+		return false
+	}
+	return rec.DocsParts == configs.DocsParts && isFullDocs(dir)
+}
+
+// setChanged sets act.Changed by comparing it with orig, the doc of the same
+// package before the merge. The change of a new package is unknown.
+func setChanged(act, orig *gcse.DocInfo) {
+	if act.Signature != "" && orig.Signature != "" && act.Signature != orig.Signature {
+		act.Changed = act.LastUpdated
+	} else if act.Changed.IsZero() {
+		act.Changed = orig.Changed
+	}
+}
+
+// merge merges the new docs, if withNewDocs, into a new generation of the
+// docs.
+func merge(withNewDocs bool) (Stats, error) {
+	var nonStorePackage *regexp.Regexp
+	if len(configs.NonStorePackageRegexps) > 0 {
+		nonStorePackage = regexp.MustCompile(stringsp.FullJoin(configs.NonStorePackageRegexps, "(", ")|(", ")"))
+	}
+
+	fpDataRoot := sophie.LocalFsPath(configs.DataRoot.S())
+	fpCrawler := configs.CrawlerDBFsPath()
+	inNewDocs := kv.DirInput(fpCrawler.Join(configs.FnNewDocs))
+
+	gens := configs.DocsGenerations()
+	if err := gens.ClearUndones(); err != nil {
+		return Stats{}, err
+	}
+	baseGen := configs.CurrentDocsGeneration()
+	baseDocs := configs.DocsDBPath()
+
+	sources := []mr.Input{kv.DirInput(sophie.LocalFsPath(baseDocs))}
+	full := true
+	var parts []int
+	if withNewDocs {
+		var err error
+		if parts, err = touchedPartitions(inNewDocs); err != nil {
+			return Stats{}, err
+		}
+		full = !isCompatible(baseGen, baseDocs)
+		sources = append(sources, inNewDocs)
+	}
+	if !full && len(parts) == 0 {
+		log.Println("No new docs to merge.")
+		return Stats{}, nil
+	}
+	if full {
+		log.Printf("Rewriting all the partitions of %v", baseDocs)
+	} else {
+		log.Printf("Rewriting %d of %d partitions of %v", len(parts), configs.DocsParts, baseDocs)
+		sources[0] = partialInput(sources[0], parts)
+	}
+
+	gen, err := gens.GenMaxSegment()
+	if err != nil {
+		return Stats{}, err
+	}
+	genDocs := gen.Join(configs.FnDocs)
+
+	var cntDeleted, cntUpdated, cntNew, cntUnchanged int64
+
+	job := mr.MrJob{
+		// 0: docs, 1: new docs if withNewDocs
+		Source: utils.LimitInputs(sources, configs.MRWorkers),
+
+		NewMapperF: func(src, part int) mr.Mapper {
+			if src == 0 {
+				// Mapper for docs
+				return &mr.MapperStruct{
+					NewKeyF: sophie.NewRawString,
+					NewValF: gcse.NewDocInfo,
+					MapF: func(key, val sophie.SophieWriter, c mr.PartCollector) error {
+						var (
+							pkg = key.(*sophie.RawString).String()
+							di  = val.(*gcse.DocInfo)
+							act = gcse.NewDocAction{
+								Action:  gcse.NDA_ORIGINAL,
+								DocInfo: *di,
+							}
+							part = gcse.CalcPackagePartition(pkg, configs.DocsParts)
+						)
+						return c.CollectTo(part, key, &act)
+					},
+				}
+			}
+			// Mapper for new docs
+			return &mr.MapperStruct{
+				NewKeyF: sophie.NewRawString,
+				NewValF: gcse.NewNewDocAction,
+				MapF: func(key, val sophie.SophieWriter, c mr.PartCollector) error {
+					var (
+						pkg  = string(*key.(*sophie.RawString))
+						part = gcse.CalcPackagePartition(pkg, configs.DocsParts)
+					)
+					return c.CollectTo(part, key, val)
+				},
+			}
+		},
+
+		Sorter: mr.NewFileSorter(fpDataRoot.Join("tmp")),
+
+		NewReducerF: func(part int) mr.Reducer {
+			return &mr.ReducerStruct{
+				NewKeyF: sophie.NewRawString,
+				NewValF: gcse.NewNewDocAction,
+				ReduceF: func(key sophie.SophieWriter,
+					nextVal mr.SophierIterator, c []sophie.Collector) error {
+
+					if nonStorePackage != nil {
+						pkg := string(*key.(*sophie.RawString))
+						if nonStorePackage.MatchString(pkg) {
+							log.Printf("Ignoring non-store pkg: %s", pkg)
+							return nil
+						}
+					}
+
+					var act, orig gcse.DocInfo
+					isSet := false
+					isUpdated := false
+					hasOriginal := false
+					for {
+						val, err := nextVal()
+						if errorsp.Cause(err) == io.EOF {
+							break
+						}
+						if err != nil {
+							return err
+						}
+
+						cur := val.(*gcse.NewDocAction)
+						switch cur.Action {
+						case gcse.NDA_DEL:
+							// not collect out to delete it
+							atomic.AddInt64(&cntDeleted, 1)
+							return nil
+
+						case gcse.NDA_ORIGINAL:
+							hasOriginal = true
+							orig = cur.DocInfo
+						}
+
+						if !isSet {
+							isSet = true
+							act = cur.DocInfo
+						} else {
+							if cur.LastUpdated.After(act.LastUpdated) {
+								isUpdated = true
+								act = cur.DocInfo
+							}
+						}
+					}
+
+					if isSet {
+						if hasOriginal {
+							setChanged(&act, &orig)
+						}
+						if isUpdated {
+							atomic.AddInt64(&cntUpdated, 1)
+						} else if hasOriginal {
+							atomic.AddInt64(&cntUnchanged, 1)
+						} else {
+							atomic.AddInt64(&cntNew, 1)
+						}
+						return c[0].Collect(key, &act)
+					} else {
+						return nil
+					}
+				},
+			}
+		},
+
+		Dest: utils.LimitOutputs([]mr.Output{
+			kv.DirOutput(sophie.LocalFsPath(genDocs)),
+		}, configs.MRWorkers),
+	}
+
+	if err := job.Run(); err != nil {
+		return Stats{}, errorsp.WithStacksAndMessage(err, "job.Run failed")
+	}
+
+	// Fills the partitions not rewritten, links of the untouched ones and
+	// empty ones for those with all the docs deleted.
+	if err := os.MkdirAll(genDocs, 0755); err != nil {
+		return Stats{}, errorsp.WithStacks(err)
+	}
+	touched := make(map[int]bool)
+	for _, part := range parts {
+		touched[part] = true
+	}
+	for part := 0; part < configs.DocsParts; part++ {
+		dst := partPath(genDocs, part)
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if !full && !touched[part] {
+			if err := linkPart(partPath(baseDocs, part), dst); err != nil {
+				return Stats{}, err
+			}
+			continue
+		}
+		w, err := kv.NewWriter(sophie.LocalFsPath(dst))
+		if err != nil {
+			return Stats{}, errorsp.WithStacks(err)
+		}
+		if err := w.Close(); err != nil {
+			return Stats{}, errorsp.WithStacks(err)
+		}
+	}
+
+	st := Stats{
+		Deleted:   cntDeleted,
+		Updated:   cntUpdated,
+		New:       cntNew,
+		Unchanged: cntUnchanged,
+		Rewritten: int64(len(parts)),
+	}
+	if full {
+		st.Rewritten = int64(configs.DocsParts)
+	}
+	log.Printf("Deleted:   %v", st.Deleted)
+	log.Printf("Updated:   %v", st.Updated)
+	log.Printf("New:       %v", st.New)
+	log.Printf("Unchanged: %v", st.Unchanged)
+	log.Printf("Rewritten: %v partitions", st.Rewritten)
+
+	rec := &MergeRecord{
+		Time:      time.Now(),
+		DocsParts: configs.DocsParts,
+		Full:      full,
+		Stats:     st,
+	}
+	if baseGen != "" {
+		rec.Base = baseGen.Name()
+	}
+	if !full {
+		rec.Partitions = parts
+	}
+	if err := saveMergeRecord(gen, rec); err != nil {
+		return Stats{}, err
+	}
+	if err := gen.Done(); err != nil {
+		return Stats{}, err
+	}
+	if err := setCurrentGeneration(gen); err != nil {
+		return Stats{}, err
+	}
+	if err := pruneGenerations(gen, rec.Base); err != nil {
+		log.Printf("pruneGenerations failed: %v", err)
+	}
+
+	log.Printf("Success, docs in %v", genDocs)
+	return st, nil
+}
@@ -0,0 +1,224 @@
+package mergedocs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/utils"
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/kv"
+)
+
+// makeGeneration makes a done generation with all the partitions, merged from
+// base.
+func makeGeneration(t *testing.T, name, base string) utils.Segment {
+	gen := configs.DocsGenerations().Join(name)
+	assert.NoErrorOrDie(t, os.MkdirAll(gen.Join(configs.FnDocs), 0755))
+	for part := 0; part < configs.DocsParts; part++ {
+		assert.NoErrorOrDie(t, ioutil.WriteFile(partPath(gen.Join(configs.FnDocs), part), []byte(name), 0644))
+	}
+	assert.NoErrorOrDie(t, saveMergeRecord(gen, &MergeRecord{Base: base, DocsParts: configs.DocsParts}))
+	assert.NoErrorOrDie(t, gen.Done())
+	return gen
+}
+
+func TestGenerations(t *testing.T) {
+	assert.NoErrorOrDie(t, configs.SetTestingDataPath())
+	legacy := configs.DataRoot.Join(configs.FnDocs).S()
+	assert.Equal(t, "DocsDBPath", configs.DocsDBPath(), legacy)
+	assert.False(t, "isFullDocs", isFullDocs(legacy))
+
+	gen0 := makeGeneration(t, "0", "")
+	assert.Equal(t, "DocsDBPath", configs.DocsDBPath(), legacy)
+	assert.NoError(t, setCurrentGeneration(gen0))
+	assert.Equal(t, "current", configs.CurrentDocsGeneration(), gen0)
+	assert.Equal(t, "DocsDBPath", configs.DocsDBPath(), gen0.Join(configs.FnDocs))
+	assert.True(t, "isFullDocs", isFullDocs(configs.DocsDBPath()))
+
+	// Untouched partitions are shared with the base.
+	gen1 := makeGeneration(t, "1", "0")
+	dst := partPath(gen1.Join(configs.FnDocs), 0)
+	assert.NoError(t, os.Remove(dst))
+	assert.NoError(t, linkPart(partPath(gen0.Join(configs.FnDocs), 0), dst))
+	bs, err := ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "part 0", string(bs), "0")
+
+	gen2 := makeGeneration(t, "2", "1")
+	assert.NoError(t, setCurrentGeneration(gen2))
+	assert.NoError(t, pruneGenerations(gen2, "1"))
+	gens, err := configs.DocsGenerations().ListAll()
+	assert.NoError(t, err)
+	assert.Equal(t, "gens", gens, []utils.Segment{gen1, gen2})
+
+	assert.NoError(t, Rollback())
+	assert.Equal(t, "current", configs.CurrentDocsGeneration(), gen1)
+	assert.False(t, "gen2.IsDone()", gen2.IsDone())
+
+	// gen0 merged into by gen1 is gone.
+	assert.Error(t, Rollback())
+	assert.Equal(t, "current", configs.CurrentDocsGeneration(), gen1)
+}
+
+func TestIsCompatible(t *testing.T) {
+	assert.NoErrorOrDie(t, configs.SetTestingDataPath())
+	gen := makeGeneration(t, "0", "")
+	assert.True(t, "isCompatible", isCompatible(gen, gen.Join(configs.FnDocs)))
+	assert.False(t, "isCompatible", isCompatible("", gen.Join(configs.FnDocs)))
+
+	defer func(parts int) { configs.DocsParts = parts }(configs.DocsParts)
+	configs.DocsParts *= 2
+	assert.False(t, "isCompatible", isCompatible(gen, gen.Join(configs.FnDocs)))
+}
+
+func TestSetChanged(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.AddDate(1, 0, 0)
+	orig := gcse.DocInfo{Signature: "a", LastUpdated: t0, Changed: t0}
+
+	act := gcse.DocInfo{Signature: "a", LastUpdated: t1}
+	setChanged(&act, &orig)
+	assert.Equal(t, "Changed", act.Changed, t0)
+
+	act = gcse.DocInfo{Signature: "b", LastUpdated: t1}
+	setChanged(&act, &orig)
+	assert.Equal(t, "Changed", act.Changed, t1)
+
+	// Unknown signature.
+	act = gcse.DocInfo{LastUpdated: t1}
+	setChanged(&act, &orig)
+	assert.Equal(t, "Changed", act.Changed, t0)
+}
+
+// writeKV writes the values by the keys into a single partition of the
+// kv.DirOutput of fp.
+func writeKV(t *testing.T, fp sophie.FsPath, vals map[string]sophie.Sophier) {
+	out := kv.DirOutput(fp)
+	out.Clean()
+	c, err := out.Collector(0)
+	assert.NoErrorOrDie(t, err)
+	for key, val := range vals {
+		assert.NoErrorOrDie(t, c.Collect(sophie.RawString(key), val))
+	}
+	assert.NoErrorOrDie(t, c.Close())
+}
+
+// readDocs returns the docs in dir by the packages.
+func readDocs(t *testing.T, dir string) map[string]gcse.DocInfo {
+	in := kv.DirInput(sophie.LocalFsPath(dir))
+	cnt, err := in.PartCount()
+	assert.NoErrorOrDie(t, err)
+	docs := make(map[string]gcse.DocInfo)
+	for part := 0; part < cnt; part++ {
+		it, err := in.Iterator(part)
+		assert.NoErrorOrDie(t, err)
+		for {
+			var key sophie.RawString
+			var val gcse.DocInfo
+			if err := it.Next(&key, &val); err != nil {
+				if errorsp.Cause(err) == io.EOF {
+					break
+				}
+				t.Fatalf("it.Next failed: %v", err)
+			}
+			docs[string(key)] = val
+		}
+		assert.NoErrorOrDie(t, it.Close())
+	}
+	return docs
+}
+
+func docNames(docs map[string]gcse.DocInfo) map[string]string {
+	names := make(map[string]string)
+	for pkg, d := range docs {
+		names[pkg] = d.Name
+	}
+	return names
+}
+
+func TestMerge(t *testing.T) {
+	assert.NoErrorOrDie(t, configs.SetTestingDataPath())
+	defer func(parts int) { configs.DocsParts = parts }(configs.DocsParts)
+	configs.DocsParts = 4
+
+	const (
+		pkgA = "github.com/a/a"
+		pkgB = "github.com/b/b"
+		pkgC = "github.com/c/c"
+	)
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.AddDate(0, 1, 0)
+	newDocsPath := configs.CrawlerDBFsPath().Join(configs.FnNewDocs)
+
+	// The legacy docs, all rewritten into the first generation.
+	writeKV(t, configs.DataRootFsPath().Join(configs.FnDocs), map[string]sophie.Sophier{
+		pkgA: &gcse.DocInfo{Package: pkgA, Name: "a0", LastUpdated: t0},
+		pkgB: &gcse.DocInfo{Package: pkgB, Name: "b0", LastUpdated: t0},
+	})
+	writeKV(t, newDocsPath, map[string]sophie.Sophier{
+		pkgA: &gcse.NewDocAction{Action: gcse.NDA_UPDATE, DocInfo: gcse.DocInfo{Package: pkgA, Name: "a1", LastUpdated: t1}},
+		pkgC: &gcse.NewDocAction{Action: gcse.NDA_UPDATE, DocInfo: gcse.DocInfo{Package: pkgC, Name: "c1", LastUpdated: t1}},
+	})
+	st, err := Run()
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "st", st, Stats{Updated: 1, New: 1, Unchanged: 1, Rewritten: 4})
+	gen1 := configs.CurrentDocsGeneration()
+	assert.ValueShould(t, "gen1", gen1, gen1 != "", "is empty")
+	rec, err := ReadMergeRecord(gen1)
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "rec.Base", rec.Base, "")
+	assert.True(t, "rec.Full", rec.Full)
+	assert.Equal(t, "rec.DocsParts", rec.DocsParts, 4)
+	assert.Equal(t, "DocsDBPath", configs.DocsDBPath(), gen1.Join(configs.FnDocs))
+	assert.True(t, "isFullDocs", isFullDocs(configs.DocsDBPath()))
+	assert.Equal(t, "docs", docNames(readDocs(t, configs.DocsDBPath())), map[string]string{
+		pkgA: "a1", pkgB: "b0", pkgC: "c1",
+	})
+
+	// Only the partition of the deleted package is rewritten.
+	writeKV(t, newDocsPath, map[string]sophie.Sophier{
+		pkgB: &gcse.NewDocAction{Action: gcse.NDA_DEL},
+	})
+	partB := gcse.CalcPackagePartition(pkgB, configs.DocsParts)
+	unchanged := int64(0)
+	for _, pkg := range []string{pkgA, pkgC} {
+		if gcse.CalcPackagePartition(pkg, configs.DocsParts) == partB {
+			unchanged++
+		}
+	}
+	st, err = Run()
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "st", st, Stats{Deleted: 1, Unchanged: unchanged, Rewritten: 1})
+	gen2 := configs.CurrentDocsGeneration()
+	assert.ValueShould(t, "gen2", gen2, gen2 != gen1, "is not a new generation")
+	rec, err = ReadMergeRecord(gen2)
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "rec.Base", rec.Base, gen1.Name())
+	assert.False(t, "rec.Full", rec.Full)
+	assert.Equal(t, "rec.Partitions", rec.Partitions, []int{partB})
+	assert.True(t, "isFullDocs", isFullDocs(configs.DocsDBPath()))
+	assert.Equal(t, "docs", docNames(readDocs(t, configs.DocsDBPath())), map[string]string{
+		pkgA: "a1", pkgC: "c1",
+	})
+
+	// The base generation is kept for rollback, the legacy docs are removed.
+	gens, err := configs.DocsGenerations().ListAll()
+	assert.NoError(t, err)
+	assert.Equal(t, "gens", gens, []utils.Segment{gen1, gen2})
+	_, err = os.Stat(configs.DataRoot.Join(configs.FnDocs).S())
+	assert.True(t, "legacy removed", os.IsNotExist(err))
+
+	assert.NoError(t, Rollback())
+	assert.Equal(t, "current", configs.CurrentDocsGeneration(), gen1)
+	assert.Equal(t, "docs", docNames(readDocs(t, configs.DocsDBPath())), map[string]string{
+		pkgA: "a1", pkgB: "b0", pkgC: "c1",
+	})
+}
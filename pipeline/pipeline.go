@@ -0,0 +1,238 @@
+// Package pipeline runs the stages of GCSE, tocrawl, crawler, mergedocs and
+// indexer, in order, with timeouts, retries and persisted run records.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/gcse/pipeline/crawler"
+	"github.com/daviddengcn/gcse/pipeline/indexer"
+	"github.com/daviddengcn/gcse/pipeline/mergedocs"
+	"github.com/daviddengcn/gcse/pipeline/tocrawl"
+	"github.com/daviddengcn/go-villa"
+)
+
+// Stage is a stage of the pipeline.
+type Stage struct {
+	Name string
+	// Run runs the stage and returns the counts to record, e.g. the number of
+	// new docs.
+	Run func(ctx context.Context) (map[string]int64, error)
+}
+
+// Stages returns the stages of the pipeline, in the order to run.
+func Stages() []Stage {
+	return []Stage{{
+		Name: "tocrawl",
+		Run: func(ctx context.Context) (map[string]int64, error) {
+			st, err := tocrawl.Run(ctx, tocrawl.Options{})
+			return map[string]int64{
+				"Packages": int64(st.Packages),
+				"Persons":  int64(st.Persons),
+			}, err
+		},
+	}, {
+		Name: "crawler",
+		Run: func(ctx context.Context) (map[string]int64, error) {
+			return nil, crawler.Run(ctx)
+		},
+	}, {
+		Name: "mergedocs",
+		Run: func(ctx context.Context) (map[string]int64, error) {
+			st, err := mergedocs.Run()
+			return map[string]int64{
+				"Deleted":   st.Deleted,
+				"Updated":   st.Updated,
+				"New":       st.New,
+				"Unchanged": st.Unchanged,
+				"Rewritten": st.Rewritten,
+			}, err
+		},
+	}, {
+		Name: "indexer",
+		Run: func(ctx context.Context) (map[string]int64, error) {
+			st, err := indexer.Run(indexer.Options{})
+			counts := map[string]int64{"Docs": int64(st.Docs)}
+			if st.Delta {
+				counts["Delta"] = 1
+				counts["Deleted"] = int64(st.Deleted)
+			}
+			return counts, err
+		},
+	}}
+}
+
+// FindStage returns the stage of the name in stages, nil if not found.
+func FindStage(stages []Stage, name string) *Stage {
+	for i := range stages {
+		if stages[i].Name == name {
+			return &stages[i]
+		}
+	}
+	return nil
+}
+
+// ErrLocked is returned by RunOnce if another run holds the lock.
+var ErrLocked = errors.New("another run of the pipeline is in progress")
+
+// Runner runs the stages of the pipeline.
+type Runner struct {
+	// The directory of the lock and the run records.
+	Dir    villa.Path
+	Stages []Stage
+	// The timeouts of the stages by names, no limit if absent.
+	Timeouts map[string]time.Duration
+	// The number of retries of a failed stage, and the delay before each.
+	Retries    int
+	RetryDelay time.Duration
+	// The number of run records to keep, all if 0.
+	KeepRuns int
+	// Exec runs a stage. Stage.Run is called in-process if nil. Note that an
+	// in-process stage is abandoned rather than stopped when timed out, so a
+	// daemon should run the stages in subprocesses.
+	Exec func(ctx context.Context, st Stage) (map[string]int64, error)
+
+	mu      sync.Mutex
+	current *RunRecord
+}
+
+func (r *Runner) exec(ctx context.Context, st Stage) (map[string]int64, error) {
+	if r.Exec != nil {
+		return r.Exec(ctx, st)
+	}
+	type result struct {
+		counts map[string]int64
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		counts, err := st.Run(ctx)
+		done <- result{counts, err}
+	}()
+	select {
+	case res := <-done:
+		return res.counts, res.err
+	case <-ctx.Done():
+		return nil, errorsp.WithStacks(ctx.Err())
+	}
+}
+
+// runStage runs a stage with retries, and fills the record.
+func (r *Runner) runStage(ctx context.Context, st Stage, rec *StageRecord) error {
+	var err error
+	for attempt := 0; attempt <= r.Retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("Retrying stage %s in %v, failed: %v", st.Name, r.RetryDelay, err)
+			select {
+			case <-time.After(r.RetryDelay):
+			case <-ctx.Done():
+				return errorsp.WithStacks(ctx.Err())
+			}
+		}
+		r.update(func() { rec.Attempts++ })
+
+		stageCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout := r.Timeouts[st.Name]; timeout > 0 {
+			stageCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		var counts map[string]int64
+		counts, err = r.exec(stageCtx, st)
+		if err != nil && stageCtx.Err() == context.DeadlineExceeded {
+			err = errorsp.WithStacksAndMessage(err, "stage %s timed out after %v", st.Name, r.Timeouts[st.Name])
+		}
+		cancel()
+		if err == nil {
+			r.update(func() { rec.Counts = counts })
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+// update calls f with the lock of the current record held.
+func (r *Runner) update(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f()
+}
+
+// save saves the current record, with the lock held. Failures are logged
+// only.
+func (r *Runner) save(rec *RunRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := saveRunRecord(r.Dir, rec); err != nil {
+		log.Printf("Saving run record %s failed: %v", rec.ID, err)
+	}
+}
+
+// RunOnce runs all the stages in order, and returns the record of the run.
+// The run stops at the first stage failing after all the retries. ErrLocked
+// is returned if another run, of this or another process, is in progress.
+func (r *Runner) RunOnce(ctx context.Context) (*RunRecord, error) {
+	if err := r.Dir.MkdirAll(0755); err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	unlock, err := lockFile(r.Dir.Join("lock"))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	rec := &RunRecord{Start: time.Now()}
+	rec.ID = rec.Start.UTC().Format(runIDLayout)
+	r.update(func() { r.current = rec })
+	defer r.update(func() { r.current = nil })
+	r.save(rec)
+
+	for _, st := range r.Stages {
+		sr := &StageRecord{Name: st.Name, Start: time.Now()}
+		r.update(func() { rec.Stages = append(rec.Stages, sr) })
+		log.Printf("Running stage %s ...", st.Name)
+		err := r.runStage(ctx, st, sr)
+		r.update(func() {
+			sr.End = time.Now()
+			if err != nil {
+				sr.Error = err.Error()
+				rec.Error = sr.Error
+			}
+		})
+		r.save(rec)
+		if err != nil {
+			log.Printf("Stage %s failed: %v", st.Name, err)
+			break
+		}
+		log.Printf("Stage %s finished in %v: %v", st.Name, sr.End.Sub(sr.Start), sr.Counts)
+	}
+
+	r.update(func() { rec.End = time.Now() })
+	r.save(rec)
+	if r.KeepRuns > 0 {
+		if err := pruneRunRecords(r.Dir, r.KeepRuns); err != nil {
+			log.Printf("Pruning run records failed: %v", err)
+		}
+	}
+	if rec.Error != "" {
+		return rec, errorsp.NewWithStacks("run %s failed: %s", rec.ID, rec.Error)
+	}
+	return rec, nil
+}
+
+// Current returns a copy of the record of the run in progress, nil if none.
+func (r *Runner) Current() *RunRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == nil {
+		return nil
+	}
+	return r.current.clone()
+}
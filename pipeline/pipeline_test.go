@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/go-villa"
+)
+
+func newTestRunner(t *testing.T, name string, stages ...Stage) *Runner {
+	dir := villa.Path(os.TempDir()).Join("gcse_testing", name)
+	assert.NoError(t, dir.RemoveAll())
+	return &Runner{Dir: dir, Stages: stages}
+}
+
+func countStage(name string, cnt int64) Stage {
+	return Stage{Name: name, Run: func(context.Context) (map[string]int64, error) {
+		return map[string]int64{"Count": cnt}, nil
+	}}
+}
+
+func TestRunner_RunOnce(t *testing.T) {
+	fails := 1
+	r := newTestRunner(t, "TestRunner_RunOnce", countStage("a", 1), Stage{
+		Name: "b",
+		Run: func(context.Context) (map[string]int64, error) {
+			if fails > 0 {
+				fails--
+				return nil, errors.New("failed")
+			}
+			return map[string]int64{"New": 2}, nil
+		},
+	})
+	defer r.Dir.RemoveAll()
+	r.Retries = 1
+
+	rec, err := r.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "len(rec.Stages)", len(rec.Stages), 2)
+	assert.Equal(t, "rec.Stages[0].Counts", rec.Stages[0].Counts, map[string]int64{"Count": 1})
+	assert.Equal(t, "rec.Stages[1].Attempts", rec.Stages[1].Attempts, 2)
+	assert.Equal(t, "rec.Stages[1].Counts", rec.Stages[1].Counts, map[string]int64{"New": 2})
+	assert.Equal(t, "rec.Error", rec.Error, "")
+	assert.False(t, "rec.End.IsZero()", rec.End.IsZero())
+	assert.True(t, "Current() == nil", r.Current() == nil)
+
+	runs, err := ListRuns(r.Dir, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(runs)", len(runs), 1)
+	assert.Equal(t, "runs[0].ID", runs[0].ID, rec.ID)
+	assert.Equal(t, "runs[0].Stages[1].Counts", runs[0].Stages[1].Counts, map[string]int64{"New": 2})
+}
+
+func TestRunner_Failure(t *testing.T) {
+	bRun := false
+	r := newTestRunner(t, "TestRunner_Failure", Stage{
+		Name: "a",
+		Run: func(context.Context) (map[string]int64, error) {
+			return nil, errors.New("always")
+		},
+	}, Stage{
+		Name: "b",
+		Run: func(context.Context) (map[string]int64, error) {
+			bRun = true
+			return nil, nil
+		},
+	})
+	defer r.Dir.RemoveAll()
+	r.Retries = 2
+
+	rec, err := r.RunOnce(context.Background())
+	assert.Error(t, err)
+	assert.False(t, "bRun", bRun)
+	assert.Equal(t, "len(rec.Stages)", len(rec.Stages), 1)
+	assert.Equal(t, "rec.Stages[0].Attempts", rec.Stages[0].Attempts, 3)
+	assert.Equal(t, "rec.Stages[0].Error", rec.Stages[0].Error, "always")
+	assert.Equal(t, "rec.Error", rec.Error, "always")
+
+	runs, err := ListRuns(r.Dir, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "runs[0].Error", runs[0].Error, "always")
+}
+
+func TestRunner_Timeout(t *testing.T) {
+	r := newTestRunner(t, "TestRunner_Timeout", Stage{
+		Name: "a",
+		Run: func(context.Context) (map[string]int64, error) {
+			// Ignores the context, like most of the stages.
+			select {}
+		},
+	})
+	defer r.Dir.RemoveAll()
+	r.Timeouts = map[string]time.Duration{"a": 10 * time.Millisecond}
+
+	rec, err := r.RunOnce(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, "rec.Stages[0].Attempts", rec.Stages[0].Attempts, 1)
+	assert.True(t, "rec.Error", strings.Contains(rec.Error, "stage a timed out after 10ms"))
+}
+
+func TestRunner_Lock(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	r := newTestRunner(t, "TestRunner_Lock", countStage("a", 1), Stage{
+		Name: "b",
+		Run: func(context.Context) (map[string]int64, error) {
+			started <- struct{}{}
+			<-release
+			return nil, nil
+		},
+	})
+	defer r.Dir.RemoveAll()
+
+	done := make(chan error)
+	go func() {
+		_, err := r.RunOnce(context.Background())
+		done <- err
+	}()
+	<-started
+
+	// Another runner, e.g. of another process, sharing the directory.
+	_, err := (&Runner{Dir: r.Dir}).RunOnce(context.Background())
+	assert.Equal(t, "err", err, ErrLocked)
+
+	// The status shows the run in progress.
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var st Status
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &st))
+	assert.True(t, "st.Current != nil", st.Current != nil)
+	assert.Equal(t, "len(st.Current.Stages)", len(st.Current.Stages), 2)
+	assert.Equal(t, "st.Current.Stages[0].Counts", st.Current.Stages[0].Counts, map[string]int64{"Count": 1})
+	assert.True(t, "st.Current.End.IsZero()", st.Current.End.IsZero())
+	assert.Equal(t, "len(st.Runs)", len(st.Runs), 1)
+
+	close(release)
+	assert.NoError(t, <-done)
+
+	// Unlocked after the run.
+	_, err = r.RunOnce(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestRunner_KeepRuns(t *testing.T) {
+	r := newTestRunner(t, "TestRunner_KeepRuns", countStage("a", 1))
+	defer r.Dir.RemoveAll()
+	r.KeepRuns = 2
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		rec, err := r.RunOnce(context.Background())
+		assert.NoError(t, err)
+		ids = append(ids, rec.ID)
+	}
+	runs, err := ListRuns(r.Dir, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(runs)", len(runs), 2)
+	assert.Equal(t, "runs[0].ID", runs[0].ID, ids[2])
+	assert.Equal(t, "runs[1].ID", runs[1].ID, ids[1])
+
+	runs, err = ListRuns(r.Dir, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(runs)", len(runs), 1)
+}
@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/go-villa"
+)
+
+// runIDLayout is the time layout of the IDs of the runs. The IDs are in the
+// order of the start times.
+const runIDLayout = "20060102T150405.000000000Z"
+
+const runRecordExt = ".json"
+
+// StageRecord is the record of a stage in a run.
+type StageRecord struct {
+	Name       string
+	Start, End time.Time
+	// The number of the attempts, more than 1 if retried.
+	Attempts int
+	Counts   map[string]int64 `json:",omitempty"`
+	Error    string           `json:",omitempty"`
+}
+
+// RunRecord is the record of a run of the pipeline. End is zero if the run is
+// in progress, or the process died during it.
+type RunRecord struct {
+	ID         string
+	Start, End time.Time
+	Stages     []*StageRecord
+	// The error of the failed stage, empty if succeeded.
+	Error string `json:",omitempty"`
+}
+
+func (rec *RunRecord) clone() *RunRecord {
+	c := *rec
+	c.Stages = make([]*StageRecord, len(rec.Stages))
+	for i, sr := range rec.Stages {
+		s := *sr
+		c.Stages[i] = &s
+	}
+	return &c
+}
+
+func runRecordPath(dir villa.Path, id string) villa.Path {
+	return dir.Join(id + runRecordExt)
+}
+
+// saveRunRecord saves rec to a temporary file and renames it, so that readers
+// never see a partial record.
+func saveRunRecord(dir villa.Path, rec *RunRecord) error {
+	bs, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	fn := runRecordPath(dir, rec.ID)
+	tmp := fn + ".tmp"
+	if err := tmp.WriteFile(bs, 0644); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return errorsp.WithStacks(tmp.Rename(fn))
+}
+
+// runIDs returns the IDs of the records in dir, the latest first.
+func runIDs(dir villa.Path) ([]string, error) {
+	infos, err := dir.ReadDir()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errorsp.WithStacks(err)
+	}
+	var ids []string
+	for _, info := range infos {
+		if name := info.Name(); !info.IsDir() && strings.HasSuffix(name, runRecordExt) {
+			ids = append(ids, strings.TrimSuffix(name, runRecordExt))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+// ListRuns returns at most n records of the runs in dir, the latest first.
+// All the records are returned if n is not positive.
+func ListRuns(dir villa.Path, n int) ([]*RunRecord, error) {
+	ids, err := runIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 && len(ids) > n {
+		ids = ids[:n]
+	}
+	recs := make([]*RunRecord, 0, len(ids))
+	for _, id := range ids {
+		bs, err := runRecordPath(dir, id).ReadFile()
+		if err != nil {
+			return nil, errorsp.WithStacks(err)
+		}
+		var rec RunRecord
+		if err := json.Unmarshal(bs, &rec); err != nil {
+			return nil, errorsp.WithStacksAndMessage(err, "decoding run record %s failed", id)
+		}
+		recs = append(recs, &rec)
+	}
+	return recs, nil
+}
+
+// pruneRunRecords removes the records in dir other than the latest keep ones.
+func pruneRunRecords(dir villa.Path, keep int) error {
+	ids, err := runIDs(dir)
+	if err != nil {
+		return err
+	}
+	for len(ids) > keep {
+		if err := runRecordPath(dir, ids[len(ids)-1]).Remove(); err != nil {
+			return errorsp.WithStacks(err)
+		}
+		ids = ids[:len(ids)-1]
+	}
+	return nil
+}
+
+// statusRuns is the number of the latest runs in the status.
+const statusRuns = 10
+
+// Status is the status of the pipeline served by Runner.ServeHTTP.
+type Status struct {
+	// The run in progress, nil if none.
+	Current *RunRecord `json:",omitempty"`
+	// The latest runs, the latest first.
+	Runs []*RunRecord
+}
+
+// ServeHTTP serves the Status in JSON.
+func (r *Runner) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	runs, err := ListRuns(r.Dir, statusRuns)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(Status{Current: r.Current(), Runs: runs})
+}
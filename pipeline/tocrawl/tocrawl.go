@@ -0,0 +1,326 @@
+// Package tocrawl is the stage of the pipeline finding the packages and
+// persons to crawl.
+package tocrawl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/sort"
+	"github.com/golangplus/time"
+
+	"github.com/daviddengcn/gcse"
+	"github.com/daviddengcn/gcse/configs"
+	"github.com/daviddengcn/gcse/spider/github"
+	"github.com/daviddengcn/gcse/spider/godocorg"
+	"github.com/daviddengcn/gcse/store"
+	"github.com/daviddengcn/gcse/utils"
+	"github.com/daviddengcn/gddo/doc"
+	"github.com/daviddengcn/go-easybi"
+	"github.com/daviddengcn/go-villa"
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/kv"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// Options of Run.
+type Options struct {
+	// Number of packages per partition. Each partition will become an MR
+	// worker during crawl phase, at most configs.CrawlerWorkers of which run
+	// concurrently, and more workers consume the github API rate-limit quota
+	// faster. configs.CrawlerPartitionSize if 0.
+	PartitionSize int
+}
+
+// Stats is the numbers of the entries to crawl.
+type Stats struct {
+	Packages, Persons int
+}
+
+var cDB *gcse.CrawlerDB
+
+func loadPackageUpdateTimes(fpDocs sophie.FsPath) (map[string]time.Time, error) {
+	dir := kv.DirInput(fpDocs)
+	cnt, err := dir.PartCount()
+	if err != nil {
+		return nil, err
+	}
+
+	pkgUTs := make(map[string]time.Time)
+
+	var pkg sophie.RawString
+	var info gcse.DocInfo
+	for i := 0; i < cnt; i++ {
+		it, err := dir.Iterator(i)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			if err := it.Next(&pkg, &info); err != nil {
+				if errorsp.Cause(err) == io.EOF {
+					break
+				}
+				return nil, err
+			}
+
+			pkgUTs[string(pkg)] = info.LastUpdated
+		}
+	}
+	return pkgUTs, nil
+}
+
+// generateCrawlEntries writes the entries due to crawl to out, and returns the
+// number of them.
+func generateCrawlEntries(db *gcse.TypedDB[gcse.CrawlingEntry], hostFromIDFn func(id string) string, out kv.DirOutput, pkgUTs map[string]time.Time, partitionSize int) (int, error) {
+	type idAndCrawlingEntry struct {
+		id  string
+		ent *gcse.CrawlingEntry
+	}
+
+	type nameAndAges struct {
+		maxName string
+		maxAge  time.Duration
+
+		sumAgeHours float64
+		cnt         int
+
+		newCnt int // The number of packages not in pkgUTs.
+	}
+
+	var (
+		now            = time.Now()
+		groups         = make(map[string][]idAndCrawlingEntry)
+		count          = 0
+		skippedVendors = 0
+		ages           = map[string]nameAndAges{}
+		currentPart    = 0 // Track current partition shard.
+	)
+
+	dbIterFn := func(id string, ent gcse.CrawlingEntry) error {
+		if ent.Version == gcse.CrawlerVersion && ent.ScheduleTime.After(now) {
+			return nil
+		}
+		if strings.Contains(id, "/vendor/") {
+			// Ignore vendor directories.
+			skippedVendors++
+			return nil
+		}
+
+		host := hostFromIDFn(id)
+
+		// TODO Marked spot for review in implementing handlers for more than just
+		//      github.com.
+		if host != "github.com" {
+			return nil
+		}
+
+		partKey := fmt.Sprintf("%v-%v", host, currentPart)
+
+		// Check host blacklist.
+		if configs.NonCrawlHosts.Contain(host) {
+			return nil
+		}
+
+		if rand.Intn(10) == 0 {
+			// Randomly set Etag to empty to fetch stars.
+			ent.Etag = ""
+		}
+
+		groups[partKey] = append(groups[partKey], idAndCrawlingEntry{
+			id:  id,
+			ent: &ent,
+		})
+
+		if len(groups[partKey]) > partitionSize {
+			currentPart++
+		}
+
+		var (
+			age = now.Sub(ent.ScheduleTime)
+			na  = ages[host]
+		)
+
+		if age > na.maxAge {
+			na.maxName, na.maxAge = id, age
+		}
+		na.sumAgeHours += age.Hours()
+		na.cnt++
+		if _, ok := pkgUTs[id]; !ok {
+			na.newCnt++
+		}
+		ages[host] = na
+
+		count++
+
+		return nil
+	}
+
+	if err := db.Iterate(dbIterFn); err != nil {
+		return 0, errorsp.WithStacks(err)
+	}
+	if skippedVendors > 0 {
+		log.Printf("skippedVendors: %d", skippedVendors)
+	}
+	gcse.AddBiValueAndProcess(bi.Average, "crawler.skipped_vendor_packages", skippedVendors)
+
+	index := 0
+	for _, g := range groups {
+		sortp.SortF(len(g), func(i, j int) bool {
+			if pkgUTs != nil {
+				_, inDocsI := pkgUTs[g[i].id]
+				_, inDocsJ := pkgUTs[g[j].id]
+				if inDocsI != inDocsJ {
+					// The one not in docs should be crawled first.
+					// I.e. if g[i] in doc (inDocsI = true), g[j] not in doc (inDocsJ == false), shoud return false
+					// vice versa.
+					return inDocsJ
+				}
+			}
+			return g[i].ent.ScheduleTime.Before(g[j].ent.ScheduleTime)
+		}, func(i, j int) {
+			g[i], g[j] = g[j], g[i]
+		})
+		if err := func(index int, ies []idAndCrawlingEntry) error {
+			c, err := out.Collector(index)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			for i, ie := range ies {
+				if err := c.Collect(sophie.RawString(ie.id), ie.ent); err != nil {
+					return err
+				}
+				if i < 10 {
+					log.Printf("id: %s, ent: %+v", ie.id, *ie.ent)
+				}
+			}
+			return nil
+		}(index, g); err != nil {
+			log.Printf("Saving ents failed: %v", err)
+		}
+		index++
+	}
+
+	for host, na := range ages {
+		aveAge := time.Duration(na.sumAgeHours / float64(na.cnt) * float64(time.Hour))
+		log.Printf("%s age: max -> %v(%s), ave -> %v, new -> %v", host, na.maxAge, na.maxName, aveAge, na.newCnt)
+		// TODO Marked spot for review in implementing handlers for more than just
+		//      github.com.
+		if host == "github.com" && strings.Contains(out.Path, configs.FnPackage) {
+			gcse.AddBiValueAndProcess(bi.Average, "crawler.github_max_age.hours", int(na.maxAge.Hours()))
+			gcse.AddBiValueAndProcess(bi.Average, "crawler.github_max_age.days", int(na.maxAge/timep.Day))
+			gcse.AddBiValueAndProcess(bi.Average, "crawler.github_ave_age.hours", int(aveAge.Hours()))
+			gcse.AddBiValueAndProcess(bi.Average, "crawler.github_ave_age.days", int(aveAge/timep.Day))
+			gcse.AddBiValueAndProcess(bi.Average, "crawler.github_new_cnt", na.newCnt)
+		}
+	}
+	log.Printf("%d entries to crawl for folder %v", count, out.Path)
+	return count, nil
+}
+
+func syncDatabases() error {
+	utils.DumpMemStats()
+	log.Printf("Synchronizing databases to disk...")
+	if err := cDB.Sync(); err != nil {
+		return errorsp.WithStacksAndMessage(err, "cdb.Sync() failed")
+	}
+	utils.DumpMemStats()
+	runtime.GC()
+	utils.DumpMemStats()
+	return nil
+}
+
+// Run generates the lists of the packages and persons to crawl.
+func Run(ctx context.Context, opts Options) (Stats, error) {
+	partitionSize := opts.PartitionSize
+	if partitionSize <= 0 {
+		partitionSize = configs.CrawlerPartitionSize
+	}
+
+	httpClient := gcse.NewHTTPClient("")
+
+	log.Println("Running tocrawl tool, to generate crawling list")
+	log.Println("NonCrawlHosts: ", configs.NonCrawlHosts)
+	log.Println("CrawlGithubUpdate: ", configs.CrawlGithubUpdate)
+	log.Println("CrawlByGodocApi: ", configs.CrawlByGodocApi)
+
+	log.Printf("Using personal github token: %v", configs.CrawlerGithubPersonal != "")
+	gcse.GithubSpider = github.NewSpiderWithToken(configs.CrawlerGithubPersonal, httpClient)
+
+	if err := configs.Mkdirs(); err != nil {
+		return Stats{}, errorsp.WithStacks(err)
+	}
+
+	// Load CrawlerDB
+	cDB = gcse.LoadCrawlerDB()
+	defer cDB.Close()
+
+	// load pkgUTs
+	pkgUTs, err := loadPackageUpdateTimes(sophie.LocalFsPath(configs.DocsDBPath()))
+	if err != nil {
+		return Stats{}, errorsp.WithStacksAndMessage(err, "loadPackageUpdateTimes failed")
+	}
+	if configs.CrawlGithubUpdate || configs.CrawlByGodocApi {
+		if configs.CrawlGithubUpdate {
+			touchByGithubUpdates(ctx, pkgUTs)
+		}
+
+		if configs.CrawlByGodocApi {
+			pkgs, err := godocorg.FetchAllPackagesInGodoc(httpClient)
+			if err != nil {
+				return Stats{}, errorsp.WithStacksAndMessage(err, "FetchAllPackagesInGodoc failed")
+			}
+			gcse.AddBiValueAndProcess(bi.Max, "godoc.doc-count", len(pkgs))
+			log.Printf("FetchAllPackagesInGodoc returns %d entries", len(pkgs))
+			now := time.Now()
+			for _, pkg := range pkgs {
+				if !doc.IsValidRemotePath(pkg) {
+					continue
+				}
+				cDB.AppendPackage(pkg, func(pkg string) bool {
+					_, ok := pkgUTs[pkg]
+					return ok
+				})
+				site, path := utils.SplitPackage(pkg)
+				if err := store.AppendPackageEvent(site, path, "godoc", now, gpb.HistoryEvent_Action_None); err != nil {
+					log.Printf("UpdatePackageHistory %s %s failed: %v", site, path, err)
+				}
+			}
+		}
+		if err := syncDatabases(); err != nil {
+			return Stats{}, err
+		}
+	}
+
+	log.Printf("Package DB: %d entries", cDB.PackageDB.Count())
+	log.Printf("Person DB: %d entries", cDB.PersonDB.Count())
+
+	pathToCrawl := villa.Path(configs.ToCrawlPath())
+
+	var st Stats
+	kvPackage := kv.DirOutput(sophie.LocalFsPath(pathToCrawl.Join(configs.FnPackage).S()))
+	kvPackage.Clean()
+	if st.Packages, err = generateCrawlEntries(cDB.PackageDB, gcse.HostOfPackage, kvPackage, pkgUTs, partitionSize); err != nil {
+		return st, errorsp.WithStacksAndMessage(err, "generateCrawlEntries %v failed", kvPackage.Path)
+	}
+
+	kvPerson := kv.DirOutput(sophie.LocalFsPath(pathToCrawl.Join(configs.FnPerson).S()))
+
+	kvPerson.Clean()
+	if st.Persons, err = generateCrawlEntries(cDB.PersonDB, func(id string) string {
+		site, _ := gcse.ParsePersonID(id)
+		return site
+	}, kvPerson, nil, partitionSize); err != nil {
+		return st, errorsp.WithStacksAndMessage(err, "generateCrawlEntries %v failed", kvPerson.Path)
+	}
+	return st, nil
+}
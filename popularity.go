@@ -0,0 +1,57 @@
+package gcse
+
+import (
+	"context"
+	"strings"
+
+	"github.com/daviddengcn/gddo/doc"
+
+	"github.com/daviddengcn/gcse/spider/popularity"
+)
+
+// PopularityProviders are the providers of the star counts of the packages
+// whose stars are not fetched while crawling. Configured by
+// ConfigurePopularity.
+var PopularityProviders = popularity.NewProviders()
+
+// githubStats fetches the stats of github repositories with GithubSpider.
+type githubStats struct{}
+
+func (githubStats) RepoStats(ctx context.Context, pkg string) (popularity.RepoStats, error) {
+	parts := strings.SplitN(pkg, "/", 4)
+	if GithubSpider == nil || len(parts) < 3 {
+		return popularity.RepoStats{Stars: -1}, nil
+	}
+	ri := CrawlRepoInfo(ctx, "github.com", parts[1], parts[2])
+	if ri == nil {
+		return popularity.RepoStats{Stars: -1}, nil
+	}
+	return popularity.RepoStats{Stars: int(ri.Stars)}, nil
+}
+
+// ConfigurePopularity sets PopularityProviders for the well-known hosts and
+// the Gitea hosts. importedBy returns the number of packages importing a
+// package and is used only for the hosts, or the packages, without stars,
+// since the imports are ranked separately. Providers in disabled are removed.
+func ConfigurePopularity(httpClient doc.HttpClient, giteaHosts []string, importedBy func(pkg string) int, disabled []string) {
+	imported := popularity.ImportedBy("imported", importedBy)
+
+	PopularityProviders.Set("github.com", popularity.HostStars("github", githubStats{}))
+
+	PopularityProviders.Set("gitlab.com", popularity.HostStars("gitlab", popularity.GitLab{
+		Client:  httpClient,
+		APIBase: "https://gitlab.com/api/v4",
+	}))
+
+	for _, host := range giteaHosts {
+		PopularityProviders.Set(host, popularity.HostStars("gitea", popularity.Gitea{
+			Client:  httpClient,
+			APIBase: "https://" + host + "/api/v1",
+		}))
+	}
+
+	PopularityProviders.SetDefault(imported)
+	for _, name := range disabled {
+		PopularityProviders.Remove(name)
+	}
+}
@@ -0,0 +1,64 @@
+package gcse
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/gcse/spider/popularity"
+)
+
+func providerNames(providers []popularity.Provider) []string {
+	var names []string
+	for _, p := range providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// fakeHttpClient serves the bodies by the request URL.
+type fakeHttpClient map[string]string
+
+func (c fakeHttpClient) Do(req *http.Request) (*http.Response, error) {
+	body, ok := c[req.URL.String()]
+	if !ok {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       bytesp.NewPSlice([]byte("not found")),
+		}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       bytesp.NewPSlice([]byte(body)),
+	}, nil
+}
+
+func TestConfigurePopularity(t *testing.T) {
+	defer func(org *popularity.Providers) {
+		PopularityProviders = org
+	}(PopularityProviders)
+	PopularityProviders = popularity.NewProviders()
+
+	client := fakeHttpClient{
+		"https://codeberg.org/api/v1/repos/a/b": `{"stars_count": 7, "forks_count": 1}`,
+	}
+	ConfigurePopularity(client, []string{"codeberg.org"}, func(pkg string) int {
+		return map[string]int{"example.com/a/b": 7, "codeberg.org/a/b": 10, "codeberg.org/a/c": 4}[pkg]
+	}, []string{"gitlab-stars"})
+
+	assert.Equal(t, "github.com", providerNames(PopularityProviders.Of("github.com")), []string{"github-stars"})
+	assert.Equal(t, "gitlab.com", providerNames(PopularityProviders.Of("gitlab.com")), []string(nil))
+	assert.Equal(t, "codeberg.org", providerNames(PopularityProviders.Of("codeberg.org")), []string{"gitea-stars"})
+	assert.Equal(t, "example.com", providerNames(PopularityProviders.Of("example.com")), []string{"imported"})
+
+	ctx := context.Background()
+	assert.Equal(t, "example.com/a/b", PopularityProviders.Popularity(ctx, "example.com/a/b"), 7)
+	// The importers are ranked separately, so they are not fused into the
+	// stars, neither are the forks.
+	assert.Equal(t, "codeberg.org/a/b", PopularityProviders.Popularity(ctx, "codeberg.org/a/b"), 7)
+	// Without known stars, the importers are the fallback.
+	assert.Equal(t, "codeberg.org/a/c", PopularityProviders.Popularity(ctx, "codeberg.org/a/c"), 4)
+}
@@ -1,13 +1,14 @@
 package gcse
 
 import (
-	"bytes"
-	"log"
+	"fmt"
 	"math"
 	"strings"
 	"time"
 
 	"github.com/golangplus/strings"
+
+	"github.com/daviddengcn/gcse/configs"
 )
 
 func scoreOfPkgByProject(n int, sameProj bool) float64 {
@@ -143,180 +144,285 @@ func effectiveImported(imported []string, author, project string) float64 {
 	return s
 }
 
-var (
-	googleCodeReadonlyDate = time.Date(2015, time.August, 24, 0, 0, 0, 0, time.UTC)
-	googleCodeCloseDate    = time.Date(2016, time.January, 25, 0, 0, 0, 0, time.UTC)
-)
-
-func getCodeGoogleComFactor() float64 {
-	now := time.Now()
-	if now.After(googleCodeCloseDate) {
-		return 1e-2
+// LatestActivity returns the time of the latest activity of the package,
+// zero if unknown.
+func (d *DocInfo) LatestActivity() time.Time {
+	t := d.Changed
+	for _, at := range []time.Time{d.RepoUpdated, d.LatestCommit, d.LatestRelease} {
+		if at.After(t) {
+			t = at
+		}
 	}
+	return t
+}
 
-	if now.After(googleCodeReadonlyDate) {
-		return 1e-1
+// activityAge returns the age of the latest activity of doc at its
+// IndexTime, false if unknown.
+func activityAge(doc *HitInfo) (time.Duration, bool) {
+	at := doc.LatestActivity()
+	if doc.IndexTime.IsZero() || at.IsZero() {
+		return 0, false
 	}
-
-	return 0.25
+	return doc.IndexTime.Sub(at), true
 }
 
-func CalcStaticScore(doc *HitInfo) float64 {
-	s := float64(1)
-
-	author := doc.Author
-	if author == "" {
-		author = AuthorOfPackage(doc.Package)
+// releaseAge returns the age of the latest release of doc at its IndexTime,
+// false if unknown.
+func releaseAge(doc *HitInfo) (time.Duration, bool) {
+	if doc.IndexTime.IsZero() || doc.LatestRelease.IsZero() {
+		return 0, false
 	}
+	return doc.IndexTime.Sub(doc.LatestRelease), true
+}
 
-	project := ProjectOfPackage(doc.Package)
-
-	s += effectiveImported(doc.Imported, author, project)
-
-	desc := strings.TrimSpace(doc.Description)
-	if len(desc) > 0 {
-		s += 1
-		if len(desc) > 100 {
-			s += 0.5
-		}
-
-		if strings.HasPrefix(desc, "Package "+doc.Name) || strings.HasPrefix(desc, doc.Name+" package") {
-			s += 0.5
-		} else if strings.HasPrefix(desc, "package "+doc.Name) {
-			s += 0.4
-		}
+// freshnessFactor returns the factor multiplied to the static score of a
+// package whose latest activity is age old, see
+// configs.RankingFreshnessGrace.
+func freshnessFactor(age time.Duration) float64 {
+	halfLife := configs.RankingFreshnessHalfLife
+	if halfLife <= 0 || age <= configs.RankingFreshnessGrace {
+		return 1
 	}
+	f := math.Pow(0.5, float64(age-configs.RankingFreshnessGrace)/float64(halfLife))
+	return math.Max(f, configs.RankingFreshnessMinFactor)
+}
 
-	if doc.Name != "" && doc.Name != "main" {
-		s += 0.1
+// releaseBonus returns the bonus to the static score of a package whose
+// latest release is age old, see configs.RankingReleaseBonus.
+func releaseBonus(age time.Duration) float64 {
+	window := configs.RankingReleaseWindow
+	if window <= 0 || age >= window {
+		return 0
+	}
+	if age < 0 {
+		age = 0
 	}
+	return configs.RankingReleaseBonus * (1 - float64(age)/float64(window))
+}
 
-	starCount := doc.AssignedStarCount - 3
-	if starCount < 0 {
-		starCount = 0
+// freshnessBuckets quantizes the freshness factor and the release bonus of
+// doc, which change with the IndexTime, so that only the notable changes
+// count as changes of the hit.
+func freshnessBuckets(doc *HitInfo) (factor, bonus int) {
+	if age, ok := activityAge(doc); ok {
+		factor = int(math.Round(freshnessFactor(age) * 20))
 	}
-	frac := 1.
-	if len(doc.Imported)+len(doc.TestImported) > 0 {
-		frac = float64(len(doc.Imported)) / float64(len(doc.Imported)+len(doc.TestImported))
+	if age, ok := releaseAge(doc); ok {
+		bonus = int(math.Round(releaseBonus(age) * 20))
 	}
-	s += math.Sqrt(float64(starCount)) * 0.5 * frac
+	return factor, bonus
+}
 
-	if strings.HasPrefix(doc.Package, "code.google.com/") {
-		s *= getCodeGoogleComFactor()
+// hostFactor returns the host of pkg and the factor multiplied to its static
+// score, 1 if the host is not in configs.RankingHostFactors.
+func hostFactor(pkg string) (string, float64) {
+	host := pkg
+	if p := strings.Index(pkg, "/"); p >= 0 {
+		host = pkg[:p]
+	}
+	if f, ok := configs.RankingHostFactors[host]; ok {
+		return host, f
 	}
+	return host, 1
+}
+
+// year is in the unit of time.Duration.
+const year = 365.25 * 24 * float64(time.Hour)
 
+// QualityScore returns the contribution of the quality signals to the static
+// score: having tests, runnable examples, doc coverage of the exported
+// identifiers and a README.
+func QualityScore(q *QualityInfo) float64 {
+	s := 0.
+	if q.TestFiles > 0 {
+		s += 0.3
+	}
+	s += 0.1 * minFloat(float64(q.Examples), 5)
+	s += 0.5 * q.DocCoverage()
+	if q.HasReadme {
+		s += 0.1
+	}
 	return s
 }
 
-func CalcTestStaticScore(doc *HitInfo, realImported []string) float64 {
-	s := float64(1)
+// cgoFactor is multiplied to the static score of the packages using cgo,
+// which are harder to build and cross-compile.
+const cgoFactor = 0.9
+
+// archivedRepoFactor is multiplied to the static score of the packages in
+// archived repositories which are no longer maintained.
+const archivedRepoFactor = 0.3
+
+// pageRankWeight is the weight of the log2 of the PageRank in the static
+// score. PageRanks below the average of 1 do not count.
+const pageRankWeight = 1.
+
+// deprecatedFactor is multiplied to the static score of deprecated packages
+// so that their replacements rank higher.
+const deprecatedFactor = 0.2
+
+// ScoreItem is a component of a score.
+type ScoreItem struct {
+	Name string
+	// "+" if Value is added to the score, "*" if the score is multiplied by
+	// it.
+	Op    string
+	Value float64
+	// The inputs of Value, if any.
+	Detail string `json:",omitempty"`
+}
+
+// ScoreExplanation is the breakdown of a score, the items applied in order
+// to 0.
+type ScoreExplanation struct {
+	Items []ScoreItem
+	Score float64
 
-	author := doc.Author
-	if author == "" {
-		author = AuthorOfPackage(doc.Package)
+	// Only Score is calculated if true.
+	scoreOnly bool
+}
+
+func (e *ScoreExplanation) add(name string, v float64, format string, args ...interface{}) {
+	e.Score += v
+	if !e.scoreOnly {
+		e.Items = append(e.Items, ScoreItem{Name: name, Op: "+", Value: v, Detail: fmt.Sprintf(format, args...)})
 	}
+}
 
-	project := ProjectOfPackage(doc.Package)
+func (e *ScoreExplanation) mul(name string, f float64, format string, args ...interface{}) {
+	e.Score *= f
+	if !e.scoreOnly {
+		e.Items = append(e.Items, ScoreItem{Name: name, Op: "*", Value: f, Detail: fmt.Sprintf(format, args...)})
+	}
+}
 
-	importedScore := effectiveImported(realImported, author, project)
-	s += importedScore
+func authorOfHit(doc *HitInfo) string {
+	if doc.Author != "" {
+		return doc.Author
+	}
+	return AuthorOfPackage(doc.Package)
+}
 
+// explainDesc adds the bonuses of the description and the name of doc,
+// shared by the static score and the test static score.
+func explainDesc(e *ScoreExplanation, doc *HitInfo) {
 	desc := strings.TrimSpace(doc.Description)
 	if len(desc) > 0 {
-		s += 1
+		e.add("description", 1, "")
 		if len(desc) > 100 {
-			s += 0.5
+			e.add("long description", 0.5, "%d bytes", len(desc))
 		}
 
 		if strings.HasPrefix(desc, "Package "+doc.Name) || strings.HasPrefix(desc, doc.Name+" package") {
-			s += 0.5
+			e.add("description starts with name", 0.5, "")
 		} else if strings.HasPrefix(desc, "package "+doc.Name) {
-			s += 0.4
+			e.add("description starts with name", 0.4, "lower case")
 		}
 	}
 
 	if doc.Name != "" && doc.Name != "main" {
-		s += 0.1
+		e.add("library name", 0.1, "")
 	}
+}
+
+func explainStaticScore(e *ScoreExplanation, doc *HitInfo) {
+	e.add("base", 1, "")
+
+	author, project := authorOfHit(doc), ProjectOfPackage(doc.Package)
+	e.add("imported", effectiveImported(doc.Imported, author, project),
+		"%d importers, of author %q or project %q counted half", len(doc.Imported), author, project)
+	// Unlike effectiveImported, being imported by an important package
+	// counts more than by a throwaway one.
+	e.add("pagerank", pageRankWeight*math.Log2(math.Max(doc.PageRank, 1)), "PageRank=%.4g", doc.PageRank)
+
+	explainDesc(e, doc)
 
 	starCount := doc.AssignedStarCount - 3
 	if starCount < 0 {
 		starCount = 0
 	}
 	frac := 1.
-	if len(doc.Imported)+len(realImported) > 0 {
-		frac = float64(len(realImported)) / float64(len(doc.Imported)+len(realImported))
+	if len(doc.Imported)+len(doc.TestImported) > 0 {
+		frac = float64(len(doc.Imported)) / float64(len(doc.Imported)+len(doc.TestImported))
 	}
-	starScore := math.Sqrt(starCount) * 0.5 * frac
-	if starScore > importedScore {
-		starScore = importedScore
+	e.add("stars", math.Sqrt(float64(starCount))*0.5*frac,
+		"AssignedStarCount=%.4g, fraction of non-test importers=%.4g", doc.AssignedStarCount, frac)
+
+	e.add("quality", QualityScore(&doc.Quality), "%+v", doc.Quality)
+	if age, ok := releaseAge(doc); ok {
+		if b := releaseBonus(age); b > 0 {
+			e.add("release", b, "released %.2g years ago", float64(age)/year)
+		}
+	}
+	if doc.Quality.UsesCgo {
+		e.mul("cgo", cgoFactor, "")
 	}
-	s += starScore
 
-	return s
+	if host, f := hostFactor(doc.Package); f != 1 {
+		e.mul("host", f, "%s", host)
+	}
+	if age, ok := activityAge(doc); ok {
+		e.mul("freshness", freshnessFactor(age), "latest activity %.2g years ago", float64(age)/year)
+	}
+	if doc.Archived {
+		e.mul("archived", archivedRepoFactor, "")
+	}
+	if doc.Deprecated != "" {
+		e.mul("deprecated", deprecatedFactor, "%s", doc.Deprecated)
+	}
 }
 
-func dbgCalcTestStaticScore(doc *HitInfo) float64 {
-	s := float64(1)
+// ExplainStaticScore returns the breakdown of the static score of doc.
+func ExplainStaticScore(doc *HitInfo) *ScoreExplanation {
+	e := &ScoreExplanation{}
+	explainStaticScore(e, doc)
+	return e
+}
 
-	author := doc.Author
-	if author == "" {
-		author = AuthorOfPackage(doc.Package)
-	}
-	project := ProjectOfPackage(doc.Package)
+func CalcStaticScore(doc *HitInfo) float64 {
+	e := ScoreExplanation{scoreOnly: true}
+	explainStaticScore(&e, doc)
+	return e.Score
+}
 
-	log.Printf("author: %v, project: %v", author, project)
-	importedScore := effectiveImported(doc.TestImported, author, project)
-	s += importedScore
-	log.Printf("TestImported: %v, importedScore: %v", len(doc.TestImported), importedScore)
+func explainTestStaticScore(e *ScoreExplanation, doc *HitInfo, realImported []string) {
+	e.add("base", 1, "")
+
+	author, project := authorOfHit(doc), ProjectOfPackage(doc.Package)
+	importedScore := effectiveImported(realImported, author, project)
+	e.add("test imported", importedScore,
+		"%d importers only in tests, of author %q or project %q counted half", len(realImported), author, project)
+
+	explainDesc(e, doc)
 
-	desc := strings.TrimSpace(doc.Description)
-	if len(desc) > 0 {
-		s += 1
-		if len(desc) > 100 {
-			s += 0.5
-		}
-		if strings.HasPrefix(desc, "Package "+doc.Name) || strings.HasPrefix(desc, doc.Name+" package") {
-			s += 0.5
-		} else if strings.HasPrefix(desc, "package "+doc.Name) {
-			s += 0.4
-		}
-	}
-	if doc.Name != "" && doc.Name != "main" {
-		s += 0.1
-	}
 	starCount := doc.AssignedStarCount - 3
 	if starCount < 0 {
 		starCount = 0
 	}
 	frac := 1.
-	if len(doc.Imported)+len(doc.TestImported) > 0 {
-		frac = float64(len(doc.TestImported)) / float64(len(doc.Imported)+len(doc.TestImported))
+	if len(doc.Imported)+len(realImported) > 0 {
+		frac = float64(len(realImported)) / float64(len(doc.Imported)+len(realImported))
 	}
 	starScore := math.Sqrt(starCount) * 0.5 * frac
 	if starScore > importedScore {
 		starScore = importedScore
 	}
-	s += starScore
-
-	log.Printf("starCount: %v, frac: %v, starScore: %v", starCount, frac, starScore)
+	e.add("stars", starScore,
+		"AssignedStarCount=%.4g, fraction of test importers=%.4g, at most the test imported score", doc.AssignedStarCount, frac)
+}
 
-	return s
+// ExplainTestStaticScore returns the breakdown of the test static score of
+// doc, with realImported the packages importing it only in tests.
+func ExplainTestStaticScore(doc *HitInfo, realImported []string) *ScoreExplanation {
+	e := &ScoreExplanation{}
+	explainTestStaticScore(e, doc, realImported)
+	return e
 }
 
-func matchToken(token string, text string, tokens stringsp.Set) bool {
-	if strings.Index(text, token) >= 0 {
-		return true
-	}
-	if tokens.Contain(token) {
-		return true
-	}
-	for tk := range tokens {
-		if strings.HasPrefix(tk, token) || strings.HasSuffix(tk, token) {
-			return true
-		}
-	}
-	return false
+func CalcTestStaticScore(doc *HitInfo, realImported []string) float64 {
+	e := ScoreExplanation{scoreOnly: true}
+	explainTestStaticScore(&e, doc, realImported)
+	return e.Score
 }
 
 func removeHost(pkg string) string {
@@ -326,47 +432,3 @@ func removeHost(pkg string) string {
 	}
 	return pkg
 }
-
-func CalcMatchScore(doc *HitInfo, tokenList []string, textIdfs, nameIdfs []float64) float64 {
-	if len(tokenList) == 0 {
-		return 1.
-	}
-	s := float64(0.02 * float64(len(tokenList)))
-
-	filteredSyn := filterURLs([]byte(doc.Synopsis))
-	synopsis := string(bytes.ToLower(filteredSyn))
-	synTokens := AppendTokens(nil, filteredSyn)
-
-	name := strings.ToLower(doc.Name)
-	nameTokens := AppendTokens(nil, []byte(name))
-
-	pkgStr := removeHost(doc.Package)
-	pkg := strings.ToLower(pkgStr)
-	pkgTokens := AppendTokens(nil, []byte(pkgStr))
-
-	// Important sentenses tokens.
-	var isTokens stringsp.Set
-	isText := ""
-	for _, sent := range doc.ImportantSentences {
-		isTokens = AppendTokens(isTokens, []byte(sent))
-		isText += strings.ToLower(sent) + " "
-	}
-	for i, token := range tokenList {
-		textIdf := textIdfs[i]
-		nameIdf := nameIdfs[i]
-
-		if matchToken(token, synopsis, synTokens) {
-			s += 0.25 * textIdf
-		}
-		if matchToken(token, isText, isTokens) {
-			s += 0.25 * textIdf
-		}
-		if matchToken(token, name, nameTokens) {
-			s += 0.25 * nameIdf
-		}
-		if matchToken(token, pkg, pkgTokens) {
-			s += 0.1 * textIdf
-		}
-	}
-	return s
-}
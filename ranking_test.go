@@ -1,10 +1,14 @@
 package gcse
 
 import (
+	"math"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/gcse/configs"
 )
 
 func TestEffectiveImported(t *testing.T) {
@@ -325,3 +329,128 @@ func TestAuthorOfPackage(t *testing.T) {
 		assert.Equal(t, "author of "+PKG_AUTHOR[i], AuthorOfPackage(PKG_AUTHOR[i]), PKG_AUTHOR[i+1])
 	}
 }
+
+func TestCalcStaticScore_Archived(t *testing.T) {
+	hit := &HitInfo{DocInfo: DocInfo{Package: "github.com/a/b", Name: "b", Description: "Package b does things."}}
+	score := CalcStaticScore(hit)
+	hit.Archived = true
+	assert.Equal(t, "archived score", CalcStaticScore(hit), score*archivedRepoFactor)
+}
+
+func TestCalcStaticScore_Deprecated(t *testing.T) {
+	hit := &HitInfo{DocInfo: DocInfo{Package: "github.com/a/b", Name: "b", Description: "Package b does things."}}
+	score := CalcStaticScore(hit)
+	hit.Deprecated = "Use github.com/a/c instead."
+	assert.Equal(t, "deprecated score", CalcStaticScore(hit), score*deprecatedFactor)
+}
+
+func TestFreshnessFactor(t *testing.T) {
+	grace, halfLife := configs.RankingFreshnessGrace, configs.RankingFreshnessHalfLife
+	assert.Equal(t, "new", freshnessFactor(0), 1.)
+	assert.Equal(t, "in grace", freshnessFactor(grace), 1.)
+	assert.True(t, "a third of half-life",
+		math.Abs(freshnessFactor(grace+halfLife/3)-math.Pow(0.5, 1./3)) < 1e-9)
+	assert.Equal(t, "abandoned", freshnessFactor(20*grace), configs.RankingFreshnessMinFactor)
+
+	defer func() { configs.RankingFreshnessHalfLife = halfLife }()
+	configs.RankingFreshnessHalfLife = 0
+	assert.Equal(t, "disabled", freshnessFactor(20*grace), 1.)
+}
+
+func TestReleaseBonus(t *testing.T) {
+	bonus, window := configs.RankingReleaseBonus, configs.RankingReleaseWindow
+	assert.Equal(t, "just released", releaseBonus(0), bonus)
+	assert.Equal(t, "clock skew", releaseBonus(-time.Hour), bonus)
+	assert.Equal(t, "half window", releaseBonus(window/2), bonus/2)
+	assert.Equal(t, "out of window", releaseBonus(window), 0.)
+}
+
+func TestCalcStaticScore_Freshness(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	hit := &HitInfo{DocInfo: DocInfo{Package: "github.com/a/b", Name: "b", Description: "Package b does things."}}
+	score := CalcStaticScore(hit)
+
+	hit.LatestCommit = now.AddDate(-10, 0, 0)
+	assert.Equal(t, "no IndexTime", CalcStaticScore(hit), score)
+	hit.IndexTime = now
+	assert.Equal(t, "abandoned", CalcStaticScore(hit), score*configs.RankingFreshnessMinFactor)
+	hit.Changed = now.AddDate(0, -1, 0)
+	assert.Equal(t, "changed recently", CalcStaticScore(hit), score)
+
+	hit.LatestRelease = now
+	assert.Equal(t, "released", CalcStaticScore(hit), score+configs.RankingReleaseBonus)
+	assert.Equal(t, "LatestActivity", hit.LatestActivity(), now)
+}
+
+func TestCalcStaticScore_Host(t *testing.T) {
+	hit := &HitInfo{DocInfo: DocInfo{Package: "github.com/a/b", Name: "b", Description: "Package b does things."}}
+	score := CalcStaticScore(hit)
+	hit.Package = "code.google.com/p/b"
+	assert.Equal(t, "code.google.com", CalcStaticScore(hit), score*configs.RankingHostFactors["code.google.com"])
+
+	defer func(m map[string]float64) { configs.RankingHostFactors = m }(configs.RankingHostFactors)
+	configs.RankingHostFactors = map[string]float64{"example.com": 0.5}
+	assert.Equal(t, "code.google.com not configured", CalcStaticScore(hit), score)
+	hit.Package = "example.com/b"
+	assert.Equal(t, "example.com", CalcStaticScore(hit), score*0.5)
+}
+
+func TestQualityScore(t *testing.T) {
+	assert.Equal(t, "empty", QualityScore(&QualityInfo{}), 0.)
+	full := QualityScore(&QualityInfo{
+		TestFiles:  2,
+		Examples:   10,
+		Exported:   4,
+		Documented: 4,
+		HasReadme:  true,
+	})
+	assert.ValueShould(t, "full", full, math.Abs(full-1.4) < 1e-9, "should be 1.4")
+
+	hit := &HitInfo{DocInfo: DocInfo{Package: "github.com/a/b", Name: "b"}}
+	score := CalcStaticScore(hit)
+	hit.Quality = QualityInfo{TestFiles: 1, Exported: 2, Documented: 1}
+	withQuality := CalcStaticScore(hit)
+	assert.ValueShould(t, "with quality", withQuality, math.Abs(withQuality-score-0.55) < 1e-9, "should be 0.55 higher")
+}
+
+func TestExplainStaticScore(t *testing.T) {
+	hit := &HitInfo{
+		DocInfo: DocInfo{
+			Package:     "code.google.com/p/b",
+			Name:        "b",
+			Description: "Package b does things.",
+			Archived:    true,
+			Deprecated:  "Use c.",
+		},
+		Imported:          []string{"github.com/x/y", "github.com/z/w"},
+		TestImported:      []string{"github.com/t/t"},
+		AssignedStarCount: 20,
+		PageRank:          3,
+	}
+	hit.Quality = QualityInfo{TestFiles: 1, UsesCgo: true}
+
+	e := ExplainStaticScore(hit)
+	assert.Equal(t, "Score", e.Score, CalcStaticScore(hit))
+	s := 0.
+	var names []string
+	for _, item := range e.Items {
+		if item.Op == "*" {
+			s *= item.Value
+		} else {
+			s += item.Value
+		}
+		names = append(names, item.Name)
+	}
+	assert.Equal(t, "replayed", s, e.Score)
+	assert.StringEqual(t, "names", names, []string{
+		"base", "imported", "pagerank", "description", "description starts with name",
+		"library name", "stars", "quality", "cgo", "host", "archived", "deprecated",
+	})
+
+	// The star score is at most the test imported score, 1.
+	hit.AssignedStarCount = 100
+	realImported := []string{"github.com/t/t"}
+	e = ExplainTestStaticScore(hit, realImported)
+	assert.Equal(t, "test Score", e.Score, CalcTestStaticScore(hit, realImported))
+	assert.Equal(t, "test stars", e.Items[len(e.Items)-1].Value, 1.)
+}
@@ -0,0 +1,180 @@
+package gcse
+
+import (
+	"errors"
+	"log"
+	"math"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/sort"
+	"github.com/golangplus/strings"
+)
+
+// SearchIndex is an index searched by SearchAndRank, e.g. an IndexReader.
+type SearchIndex interface {
+	PackageCount() int
+	AvgFieldLens() []float64
+	PackageCountOfToken(field, token string) int
+	Search(q map[string]stringsp.Set, out func(docID int32, data interface{}) error) error
+}
+
+// ScoredHit is a hit of a query with its scores.
+type ScoredHit struct {
+	HitInfo
+	MatchScore float64
+	Score      float64
+}
+
+// queryScorer scores the matches of the hits in an index to the tokens of a
+// query.
+type queryScorer struct {
+	tokens []string
+	idfs   []float64
+	bm25f  *BM25F
+}
+
+func newQueryScorer(idx SearchIndex, tokens stringsp.Set, sc *Scoring) *queryScorer {
+	qs := &queryScorer{tokens: tokens.Elements(), bm25f: sc.NewBM25F(idx.AvgFieldLens())}
+	N := idx.PackageCount()
+	qs.idfs = make([]float64, len(qs.tokens))
+	for i := range qs.idfs {
+		qs.idfs[i] = BM25FIdf(idx.PackageCountOfToken(IndexTextField, qs.tokens[i]), N)
+	}
+	return qs
+}
+
+// MaxFilterOnlyHits is the maximum number of the hits of a query without any
+// token, e.g. "license:mit", which would match every hit otherwise.
+const MaxFilterOnlyHits = 1000
+
+var errEnoughHits = errors.New("enough hits")
+
+// SearchAndRank returns the hits of idx matching all the tokens and passing
+// filter, all if nil, in descending order of their scores by sc. Without any
+// token, only the first MaxFilterOnlyHits hits passing filter, in descending
+// order of their static scores, are returned.
+func SearchAndRank(idx SearchIndex, tokens stringsp.Set, filter func(*HitInfo) bool, sc *Scoring) ([]*ScoredHit, error) {
+	qs := newQueryScorer(idx, tokens, sc)
+
+	q, maxHits := map[string]stringsp.Set{IndexTextField: tokens}, -1
+	if len(tokens) == 0 {
+		// A nil query visits every hit, in the order of the static scores.
+		q, maxHits = nil, MaxFilterOnlyHits
+	}
+	var hits []*ScoredHit
+	if err := idx.Search(q,
+		func(docID int32, data interface{}) error {
+			hit := &ScoredHit{}
+			var ok bool
+			hit.HitInfo, ok = data.(HitInfo)
+			if !ok {
+				log.Print("ok = false")
+			}
+			if filter != nil && !filter(&hit.HitInfo) {
+				return nil
+			}
+
+			hit.MatchScore = qs.bm25f.Score(&hit.HitInfo, qs.tokens, qs.idfs)
+			hit.Score = sc.BlendScores(math.Max(hit.StaticScore, hit.TestStaticScore), hit.MatchScore)
+
+			hits = append(hits, hit)
+			if len(hits) == maxHits {
+				return errEnoughHits
+			}
+			return nil
+		}); err != nil && errorsp.Cause(err) != errEnoughHits {
+		return nil, err
+	}
+
+	swapHits := func(i, j int) {
+		hits[i], hits[j] = hits[j], hits[i]
+	}
+	sortp.SortF(len(hits), func(i, j int) bool {
+		// true if doc i is before doc j
+		ssi, ssj := hits[i].Score, hits[j].Score
+		if ssi > ssj {
+			return true
+		}
+		if ssi < ssj {
+			return false
+		}
+		sci, scj := hits[i].StarCount, hits[j].StarCount
+		if sci > scj {
+			return true
+		}
+		if sci < scj {
+			return false
+		}
+		pi, pj := hits[i].Package, hits[j].Package
+		if len(pi) < len(pj) {
+			return true
+		}
+		if len(pi) > len(pj) {
+			return false
+		}
+		return pi < pj
+	}, swapHits)
+
+	if len(hits) < 5000 {
+		// Adjust Score by down ranking duplicated packages
+		pkgCount := make(map[string]int)
+		for _, hit := range hits {
+			cnt := pkgCount[hit.Name] + 1
+			pkgCount[hit.Name] = cnt
+			if cnt > 1 && hit.ImportedLen == 0 && hit.TestImportedLen == 0 {
+				hit.Score /= float64(cnt)
+			}
+		}
+		// Re-sort
+		sortp.BubbleF(len(hits), func(i, j int) bool {
+			return hits[i].Score > hits[j].Score
+		}, swapHits)
+	}
+	return hits, nil
+}
+
+// HitExplanation is the breakdown of the score of a package for a query, and
+// its rank in the results.
+type HitExplanation struct {
+	Package    string
+	Static     *ScoreExplanation
+	TestStatic *ScoreExplanation
+	Match      *MatchExplanation
+	// The formula blending the larger one of the static scores and the match
+	// score into Score.
+	Blend string
+	Score float64
+	// Score after down ranking the duplicated names, by which the results are
+	// ranked. Zero if not in the results.
+	RankScore float64
+	// One-based rank in the results, 0 if not in them.
+	Rank         int
+	TotalResults int
+}
+
+// ExplainHit returns the breakdown of the score of hit for the query tokens,
+// as ranked by SearchAndRank with the same arguments.
+func ExplainHit(idx SearchIndex, tokens stringsp.Set, filter func(*HitInfo) bool, sc *Scoring, hit *HitInfo) (*HitExplanation, error) {
+	qs := newQueryScorer(idx, tokens, sc)
+	e := &HitExplanation{
+		Package:    hit.Package,
+		Static:     ExplainStaticScore(hit),
+		TestStatic: ExplainTestStaticScore(hit, excludeImports(hit.TestImported, hit.Imported)),
+		Match:      qs.bm25f.Explain(hit, qs.tokens, qs.idfs),
+		Blend:      sc.BlendFormula(),
+	}
+	e.Score = sc.BlendScores(math.Max(e.Static.Score, e.TestStatic.Score), e.Match.Score)
+
+	hits, err := SearchAndRank(idx, tokens, filter, sc)
+	if err != nil {
+		return nil, err
+	}
+	e.TotalResults = len(hits)
+	for i, h := range hits {
+		if h.Package == hit.Package {
+			e.Rank, e.RankScore = i+1, h.Score
+			break
+		}
+	}
+	return e, nil
+}
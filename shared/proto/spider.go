@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
+	google_protobuf "github.com/golang/protobuf/ptypes/timestamp"
 )
 
 func (ci *CrawlingInfo) CrawlingTimeAsTime() time.Time {
@@ -15,3 +16,31 @@ func (ci *CrawlingInfo) SetCrawlingTime(t time.Time) *CrawlingInfo {
 	ci.CrawlingTime, _ = ptypes.TimestampProto(t)
 	return ci
 }
+
+// timeOf returns the time of ts, zero if ts is nil or invalid.
+func timeOf(ts *google_protobuf.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// LastUpdatedAsTime returns when the repository was last pushed to, zero if
+// unknown.
+func (ri *RepoInfo) LastUpdatedAsTime() time.Time {
+	return timeOf(ri.GetLastUpdated())
+}
+
+// LatestCommitAsTime returns the time of the latest commit, zero if unknown.
+func (ri *RepoInfo) LatestCommitAsTime() time.Time {
+	return timeOf(ri.GetLatestCommit())
+}
+
+// LatestReleaseAsTime returns the time of the latest release, zero if none.
+func (ri *RepoInfo) LatestReleaseAsTime() time.Time {
+	return timeOf(ri.GetLatestRelease())
+}
@@ -17,11 +17,51 @@ It has these top-level messages:
 	HistoryEvent
 	HistoryInfo
 	Package
+	LicenseInfo
+	QualityInfo
+	HistoryRollup
 	PackageInfo
 	PersonInfo
 	Repository
+	Change
 	PackageCrawlHistoryReq
 	PackageCrawlHistoryResp
+	ReadPackageReq
+	ReadPackageResp
+	UpdatePackageReq
+	UpdatePackageResp
+	DeletePackageReq
+	DeletePackageResp
+	ListPackageSitesReq
+	ListPackageSitesResp
+	ListPackagesReq
+	ListPackagesResp
+	ReadRepositoryReq
+	ReadRepositoryResp
+	UpdateRepositoryReq
+	UpdateRepositoryResp
+	DeleteRepositoryReq
+	DeleteRepositoryResp
+	ListRepositorySitesReq
+	ListRepositorySitesResp
+	ListRepositoriesReq
+	ListRepositoriesResp
+	ReadPersonReq
+	ReadPersonResp
+	UpdatePersonReq
+	UpdatePersonResp
+	DeletePersonReq
+	DeletePersonResp
+	AppendPackageEventReq
+	AppendPackageEventResp
+	ReadHistoryReq
+	ReadHistoryResp
+	UpdateHistoryReq
+	UpdateHistoryResp
+	DeleteHistoryReq
+	DeleteHistoryResp
+	WatchChangesReq
+	WatchChangesResp
 */
 package gcsepb
 
@@ -103,6 +143,19 @@ type GoFileInfo struct {
 	Description string            `protobuf:"bytes,3,opt,name=description" json:"description,omitempty"`
 	IsTest      bool              `protobuf:"varint,4,opt,name=is_test,json=isTest" json:"is_test,omitempty"`
 	Imports     []string          `protobuf:"bytes,5,rep,name=imports" json:"imports,omitempty"`
+	// Message of the "Deprecated:" paragraph in the package comment.
+	Deprecated string `protobuf:"bytes,6,opt,name=deprecated" json:"deprecated,omitempty"`
+	// Size of the file in bytes
+	Size int32 `protobuf:"varint,7,opt,name=size" json:"size,omitempty"`
+	// Number of runnable Example functions, test files only
+	Examples int32 `protobuf:"varint,8,opt,name=examples" json:"examples,omitempty"`
+	// Number of exported identifiers and those with doc comments, non-test
+	// files only
+	Exported   int32 `protobuf:"varint,9,opt,name=exported" json:"exported,omitempty"`
+	Documented int32 `protobuf:"varint,10,opt,name=documented" json:"documented,omitempty"`
+	// The version of parseGoFile which generated this info. Cached infos of
+	// older versions are regenerated.
+	ParserVersion int32 `protobuf:"varint,11,opt,name=parser_version,json=parserVersion" json:"parser_version,omitempty"`
 }
 
 func (m *GoFileInfo) Reset()                    { *m = GoFileInfo{} }
@@ -145,15 +198,71 @@ func (m *GoFileInfo) GetImports() []string {
 	return nil
 }
 
+func (m *GoFileInfo) GetDeprecated() string {
+	if m != nil {
+		return m.Deprecated
+	}
+	return ""
+}
+
+func (m *GoFileInfo) GetSize() int32 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *GoFileInfo) GetExamples() int32 {
+	if m != nil {
+		return m.Examples
+	}
+	return 0
+}
+
+func (m *GoFileInfo) GetExported() int32 {
+	if m != nil {
+		return m.Exported
+	}
+	return 0
+}
+
+func (m *GoFileInfo) GetDocumented() int32 {
+	if m != nil {
+		return m.Documented
+	}
+	return 0
+}
+
+func (m *GoFileInfo) GetParserVersion() int32 {
+	if m != nil {
+		return m.ParserVersion
+	}
+	return 0
+}
+
 type RepoInfo struct {
 	// The timestamp this repo-info is crawled
 	CrawlingTime *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=crawling_time,json=crawlingTime" json:"crawling_time,omitempty"`
 	Stars        int32                      `protobuf:"varint,2,opt,name=stars" json:"stars,omitempty"`
 	Description  string                     `protobuf:"bytes,3,opt,name=description" json:"description,omitempty"`
-	// Where this project was forked from, full path
+	// Where this project was forked from, full path, e.g.
+	// "github.com/daviddengcn/gcse"
 	Source string `protobuf:"bytes,5,opt,name=source" json:"source,omitempty"`
 	// As far as we know, when this repo was updated
 	LastUpdated *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=last_updated,json=lastUpdated" json:"last_updated,omitempty"`
+	// License reported by the hosting service, if any
+	License *LicenseInfo `protobuf:"bytes,6,opt,name=license" json:"license,omitempty"`
+	// Whether the repository is archived (read-only) by the owner
+	Archived bool `protobuf:"varint,7,opt,name=archived" json:"archived,omitempty"`
+	// The upstream URL if the repository is a mirror
+	MirrorUrl string `protobuf:"bytes,8,opt,name=mirror_url,json=mirrorUrl" json:"mirror_url,omitempty"`
+	// The committer date of the latest commit of the default branch, if
+	// known
+	LatestCommit *google_protobuf.Timestamp `protobuf:"bytes,9,opt,name=latest_commit,json=latestCommit" json:"latest_commit,omitempty"`
+	// When the latest release was published, if any
+	LatestRelease *google_protobuf.Timestamp `protobuf:"bytes,10,opt,name=latest_release,json=latestRelease" json:"latest_release,omitempty"`
+	// The number of the forks
+	Forks int32 `protobuf:"varint,11,opt,name=forks" json:"forks,omitempty"`
 }
 
 func (m *RepoInfo) Reset()                    { *m = RepoInfo{} }
@@ -196,6 +305,48 @@ func (m *RepoInfo) GetLastUpdated() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *RepoInfo) GetLicense() *LicenseInfo {
+	if m != nil {
+		return m.License
+	}
+	return nil
+}
+
+func (m *RepoInfo) GetArchived() bool {
+	if m != nil {
+		return m.Archived
+	}
+	return false
+}
+
+func (m *RepoInfo) GetMirrorUrl() string {
+	if m != nil {
+		return m.MirrorUrl
+	}
+	return ""
+}
+
+func (m *RepoInfo) GetLatestCommit() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.LatestCommit
+	}
+	return nil
+}
+
+func (m *RepoInfo) GetLatestRelease() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.LatestRelease
+	}
+	return nil
+}
+
+func (m *RepoInfo) GetForks() int32 {
+	if m != nil {
+		return m.Forks
+	}
+	return 0
+}
+
 // Information for a non-repository folder.
 type FolderInfo struct {
 	// E.g. "sub"
@@ -276,6 +427,12 @@ func (m *CrawlingInfo) GetEtag() string {
 type HistoryEvent struct {
 	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=timestamp" json:"timestamp,omitempty"`
 	Action    HistoryEvent_Action_Enum   `protobuf:"varint,2,opt,name=action,enum=gcse.HistoryEvent_Action_Enum" json:"action,omitempty"`
+	// The error of a failed or invalid crawling.
+	FailureReason string `protobuf:"bytes,3,opt,name=failure_reason,json=failureReason" json:"failure_reason,omitempty"`
+	// The etag seen by the crawling.
+	Etag string `protobuf:"bytes,4,opt,name=etag" json:"etag,omitempty"`
+	// gcse.CrawlerVersion of the crawler.
+	CrawlerVersion int32 `protobuf:"varint,5,opt,name=crawler_version,json=crawlerVersion" json:"crawler_version,omitempty"`
 }
 
 func (m *HistoryEvent) Reset()                    { *m = HistoryEvent{} }
@@ -297,6 +454,27 @@ func (m *HistoryEvent) GetAction() HistoryEvent_Action_Enum {
 	return HistoryEvent_Action_None
 }
 
+func (m *HistoryEvent) GetFailureReason() string {
+	if m != nil {
+		return m.FailureReason
+	}
+	return ""
+}
+
+func (m *HistoryEvent) GetEtag() string {
+	if m != nil {
+		return m.Etag
+	}
+	return ""
+}
+
+func (m *HistoryEvent) GetCrawlerVersion() int32 {
+	if m != nil {
+		return m.CrawlerVersion
+	}
+	return 0
+}
+
 type HistoryEvent_Action struct {
 }
 
@@ -306,6 +484,8 @@ func (*HistoryEvent_Action) ProtoMessage()               {}
 func (*HistoryEvent_Action) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4, 0} }
 
 type HistoryInfo struct {
+	// The latest events, newest first. The full timeline is kept in the
+	// event log of the store.
 	Events    []*HistoryEvent            `protobuf:"bytes,1,rep,name=events" json:"events,omitempty"`
 	FoundTime *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=found_time,json=foundTime" json:"found_time,omitempty"`
 	// Possible value:
@@ -320,6 +500,8 @@ type HistoryInfo struct {
 	FoundWay      string                     `protobuf:"bytes,3,opt,name=found_way,json=foundWay" json:"found_way,omitempty"`
 	LatestSuccess *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=latest_success,json=latestSuccess" json:"latest_success,omitempty"`
 	LatestFailed  *google_protobuf.Timestamp `protobuf:"bytes,5,opt,name=latest_failed,json=latestFailed" json:"latest_failed,omitempty"`
+	// Daily counts of the events rolled up from the event log, oldest first.
+	Rollups []*HistoryRollup `protobuf:"bytes,6,rep,name=rollups" json:"rollups,omitempty"`
 }
 
 func (m *HistoryInfo) Reset()                    { *m = HistoryInfo{} }
@@ -362,6 +544,13 @@ func (m *HistoryInfo) GetLatestFailed() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *HistoryInfo) GetRollups() []*HistoryRollup {
+	if m != nil {
+		return m.Rollups
+	}
+	return nil
+}
+
 type Package struct {
 	// package "name"
 	Name string `protobuf:"bytes,1,opt,name=Name" json:"Name,omitempty"`
@@ -378,6 +567,15 @@ type Package struct {
 	TestImports []string `protobuf:"bytes,7,rep,name=TestImports" json:"TestImports,omitempty"`
 	// URL to the package source code.
 	Url string `protobuf:"bytes,8,opt,name=url" json:"url,omitempty"`
+	// License detected from the LICENSE/COPYING files of the folder.
+	License *LicenseInfo `protobuf:"bytes,10,opt,name=license" json:"license,omitempty"`
+	// Deprecation message from the package comment, or the module comment
+	// in the go.mod file of the folder. Empty if not deprecated.
+	Deprecated string `protobuf:"bytes,11,opt,name=deprecated" json:"deprecated,omitempty"`
+	// Versions retracted by the retract directives in the go.mod file.
+	Retracted []string `protobuf:"bytes,12,rep,name=retracted" json:"retracted,omitempty"`
+	// Quality signals computed from the files of the folder.
+	Quality *QualityInfo `protobuf:"bytes,13,opt,name=quality" json:"quality,omitempty"`
 }
 
 func (m *Package) Reset()                    { *m = Package{} }
@@ -448,6 +646,188 @@ func (m *Package) GetUrl() string {
 	return ""
 }
 
+func (m *Package) GetLicense() *LicenseInfo {
+	if m != nil {
+		return m.License
+	}
+	return nil
+}
+
+func (m *Package) GetDeprecated() string {
+	if m != nil {
+		return m.Deprecated
+	}
+	return ""
+}
+
+func (m *Package) GetRetracted() []string {
+	if m != nil {
+		return m.Retracted
+	}
+	return nil
+}
+
+func (m *Package) GetQuality() *QualityInfo {
+	if m != nil {
+		return m.Quality
+	}
+	return nil
+}
+
+type LicenseInfo struct {
+	// SPDX identifier, e.g. "MIT", "Apache-2.0", "GPL-3.0"
+	SpdxId string `protobuf:"bytes,1,opt,name=spdx_id,json=spdxId" json:"spdx_id,omitempty"`
+	// In the range of [0, 1], 1 for metadata or an explicit SPDX tag
+	Confidence float32 `protobuf:"fixed32,2,opt,name=confidence" json:"confidence,omitempty"`
+	// Where the license was detected, e.g. "LICENSE", "COPYING" or
+	// "github.com" for the host API metadata
+	Source string `protobuf:"bytes,3,opt,name=source" json:"source,omitempty"`
+}
+
+func (m *LicenseInfo) Reset()                    { *m = LicenseInfo{} }
+func (m *LicenseInfo) String() string            { return proto.CompactTextString(m) }
+func (*LicenseInfo) ProtoMessage()               {}
+func (*LicenseInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *LicenseInfo) GetSpdxId() string {
+	if m != nil {
+		return m.SpdxId
+	}
+	return ""
+}
+
+func (m *LicenseInfo) GetConfidence() float32 {
+	if m != nil {
+		return m.Confidence
+	}
+	return 0
+}
+
+func (m *LicenseInfo) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+type QualityInfo struct {
+	// Number and total size in bytes of the test files
+	TestFiles int32 `protobuf:"varint,1,opt,name=test_files,json=testFiles" json:"test_files,omitempty"`
+	TestSize  int32 `protobuf:"varint,2,opt,name=test_size,json=testSize" json:"test_size,omitempty"`
+	// Number of runnable Example functions
+	Examples int32 `protobuf:"varint,3,opt,name=examples" json:"examples,omitempty"`
+	// Number of exported identifiers and those with doc comments
+	Exported   int32 `protobuf:"varint,4,opt,name=exported" json:"exported,omitempty"`
+	Documented int32 `protobuf:"varint,5,opt,name=documented" json:"documented,omitempty"`
+	UsesCgo    bool  `protobuf:"varint,6,opt,name=uses_cgo,json=usesCgo" json:"uses_cgo,omitempty"`
+	UsesUnsafe bool  `protobuf:"varint,7,opt,name=uses_unsafe,json=usesUnsafe" json:"uses_unsafe,omitempty"`
+	HasReadme  bool  `protobuf:"varint,8,opt,name=has_readme,json=hasReadme" json:"has_readme,omitempty"`
+}
+
+func (m *QualityInfo) Reset()                    { *m = QualityInfo{} }
+func (m *QualityInfo) String() string            { return proto.CompactTextString(m) }
+func (*QualityInfo) ProtoMessage()               {}
+func (*QualityInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *QualityInfo) GetTestFiles() int32 {
+	if m != nil {
+		return m.TestFiles
+	}
+	return 0
+}
+
+func (m *QualityInfo) GetTestSize() int32 {
+	if m != nil {
+		return m.TestSize
+	}
+	return 0
+}
+
+func (m *QualityInfo) GetExamples() int32 {
+	if m != nil {
+		return m.Examples
+	}
+	return 0
+}
+
+func (m *QualityInfo) GetExported() int32 {
+	if m != nil {
+		return m.Exported
+	}
+	return 0
+}
+
+func (m *QualityInfo) GetDocumented() int32 {
+	if m != nil {
+		return m.Documented
+	}
+	return 0
+}
+
+func (m *QualityInfo) GetUsesCgo() bool {
+	if m != nil {
+		return m.UsesCgo
+	}
+	return false
+}
+
+func (m *QualityInfo) GetUsesUnsafe() bool {
+	if m != nil {
+		return m.UsesUnsafe
+	}
+	return false
+}
+
+func (m *QualityInfo) GetHasReadme() bool {
+	if m != nil {
+		return m.HasReadme
+	}
+	return false
+}
+
+// HistoryRollup counts the events of a day which are no longer in the event
+// log.
+type HistoryRollup struct {
+	// The start of the day in UTC.
+	Day     *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=day" json:"day,omitempty"`
+	Success int32                      `protobuf:"varint,2,opt,name=success" json:"success,omitempty"`
+	Failed  int32                      `protobuf:"varint,3,opt,name=failed" json:"failed,omitempty"`
+	Invalid int32                      `protobuf:"varint,4,opt,name=invalid" json:"invalid,omitempty"`
+}
+
+func (m *HistoryRollup) Reset()                    { *m = HistoryRollup{} }
+func (m *HistoryRollup) String() string            { return proto.CompactTextString(m) }
+func (*HistoryRollup) ProtoMessage()               {}
+func (*HistoryRollup) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *HistoryRollup) GetDay() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Day
+	}
+	return nil
+}
+
+func (m *HistoryRollup) GetSuccess() int32 {
+	if m != nil {
+		return m.Success
+	}
+	return 0
+}
+
+func (m *HistoryRollup) GetFailed() int32 {
+	if m != nil {
+		return m.Failed
+	}
+	return 0
+}
+
+func (m *HistoryRollup) GetInvalid() int32 {
+	if m != nil {
+		return m.Invalid
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*GoFileInfo)(nil), "gcse.GoFileInfo")
 	proto.RegisterType((*RepoInfo)(nil), "gcse.RepoInfo")
@@ -457,6 +837,9 @@ func init() {
 	proto.RegisterType((*HistoryEvent_Action)(nil), "gcse.HistoryEvent.Action")
 	proto.RegisterType((*HistoryInfo)(nil), "gcse.HistoryInfo")
 	proto.RegisterType((*Package)(nil), "gcse.Package")
+	proto.RegisterType((*LicenseInfo)(nil), "gcse.LicenseInfo")
+	proto.RegisterType((*QualityInfo)(nil), "gcse.QualityInfo")
+	proto.RegisterType((*HistoryRollup)(nil), "gcse.HistoryRollup")
 	proto.RegisterEnum("gcse.GoFileInfo_Status", GoFileInfo_Status_name, GoFileInfo_Status_value)
 	proto.RegisterEnum("gcse.HistoryEvent_Action_Enum", HistoryEvent_Action_Enum_name, HistoryEvent_Action_Enum_value)
 }
@@ -466,52 +849,80 @@ func init() {
 }
 
 var fileDescriptor0 = []byte{
-	// 748 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x54, 0xdd, 0x6a, 0xdb, 0x48,
-	0x14, 0x8e, 0xfc, 0x23, 0xcb, 0x47, 0x4e, 0x56, 0x0c, 0xcb, 0x46, 0xeb, 0x85, 0x60, 0x74, 0x65,
-	0xf6, 0x42, 0x82, 0x2c, 0x1b, 0x76, 0x59, 0x96, 0x36, 0x6d, 0xe2, 0xd6, 0xbd, 0x08, 0x41, 0x4e,
-	0x28, 0xf4, 0xc6, 0x8c, 0xa5, 0xb1, 0x2c, 0x22, 0xcd, 0x08, 0xcd, 0x28, 0xc1, 0x0f, 0xd2, 0x17,
-	0xe8, 0xa3, 0xf4, 0x31, 0xfa, 0x18, 0xbd, 0xeb, 0x5d, 0x99, 0x19, 0x29, 0x16, 0x04, 0x5a, 0x17,
-	0x7a, 0x77, 0x7e, 0xbe, 0x99, 0x33, 0xe7, 0x3b, 0xe7, 0x1b, 0xf8, 0x3b, 0x49, 0xc5, 0xa6, 0x5a,
-	0xf9, 0x11, 0xcb, 0x83, 0x18, 0xdf, 0xa7, 0x71, 0x4c, 0x68, 0x12, 0xd1, 0x20, 0x89, 0x38, 0x09,
-	0xf8, 0x06, 0x97, 0x24, 0x0e, 0x8a, 0x92, 0x09, 0x16, 0xf0, 0x22, 0x8d, 0x49, 0xe9, 0x2b, 0x07,
-	0xf5, 0x64, 0x7e, 0xfc, 0x5f, 0xeb, 0x70, 0xc2, 0x32, 0x4c, 0x13, 0x8d, 0x5d, 0x55, 0xeb, 0xa0,
-	0x10, 0xdb, 0x82, 0xf0, 0x40, 0xa4, 0x39, 0xe1, 0x02, 0xe7, 0xc5, 0xce, 0xd2, 0x57, 0x78, 0x9f,
-	0x0d, 0x80, 0x57, 0x6c, 0x96, 0x66, 0x64, 0x4e, 0xd7, 0x0c, 0x05, 0x60, 0x72, 0x81, 0x45, 0xc5,
-	0x5d, 0x63, 0x62, 0x4c, 0x8f, 0x4e, 0x8f, 0x7d, 0x59, 0xc2, 0xdf, 0x21, 0xfc, 0x85, 0x4a, 0x87,
-	0x35, 0x0c, 0x21, 0xe8, 0x51, 0x9c, 0x13, 0xb7, 0x33, 0x31, 0xa6, 0xc3, 0x50, 0xd9, 0x68, 0x02,
-	0x76, 0x4c, 0x78, 0x54, 0xa6, 0x85, 0x48, 0x19, 0x75, 0xbb, 0x2a, 0xd5, 0x0e, 0xa1, 0x63, 0x18,
-	0xa4, 0x7c, 0x29, 0x08, 0x17, 0x6e, 0x6f, 0x62, 0x4c, 0xad, 0xd0, 0x4c, 0xf9, 0x0d, 0xe1, 0x02,
-	0xb9, 0x30, 0x48, 0xf3, 0x82, 0x95, 0x82, 0xbb, 0xfd, 0x49, 0x77, 0x3a, 0x0c, 0x1b, 0xd7, 0x7b,
-	0x03, 0xa6, 0x2e, 0x8d, 0x6c, 0x18, 0xdc, 0xd2, 0x3b, 0xca, 0x1e, 0xa8, 0x73, 0x80, 0x1c, 0x18,
-	0x5d, 0xe3, 0x92, 0x93, 0x45, 0x15, 0x45, 0x84, 0x73, 0xc7, 0x40, 0xbf, 0x80, 0xad, 0x22, 0x33,
-	0x9c, 0x66, 0x24, 0x76, 0x3a, 0x12, 0xb2, 0xd8, 0xb0, 0x2a, 0x8b, 0xe7, 0x09, 0x65, 0x25, 0x71,
-	0xba, 0xde, 0x27, 0x03, 0xac, 0x90, 0x14, 0x4c, 0xb5, 0xfc, 0x0c, 0x0e, 0xa3, 0x12, 0x3f, 0x64,
-	0x29, 0x4d, 0x96, 0x92, 0x1d, 0xd5, 0xb9, 0x7d, 0x3a, 0xf6, 0x13, 0xc6, 0x92, 0x8c, 0xf8, 0x0d,
-	0x97, 0xfe, 0x4d, 0x43, 0x5d, 0x38, 0x6a, 0x0e, 0xc8, 0x10, 0xfa, 0x15, 0xfa, 0x5c, 0xe0, 0x92,
-	0x2b, 0x0e, 0xfa, 0xa1, 0x76, 0xf6, 0x20, 0xe1, 0x37, 0x30, 0x39, 0xab, 0xca, 0x88, 0xb8, 0x7d,
-	0x95, 0xac, 0x3d, 0xf4, 0x3f, 0x8c, 0x32, 0xcc, 0xc5, 0xb2, 0x2a, 0x62, 0x2c, 0x48, 0xac, 0x18,
-	0xfa, 0xf6, 0x7b, 0x6c, 0x89, 0xbf, 0xd5, 0x70, 0xef, 0x83, 0x01, 0x30, 0x63, 0x59, 0x4c, 0x4a,
-	0xd5, 0x5e, 0x33, 0x20, 0xa3, 0x35, 0x20, 0x04, 0xbd, 0x02, 0x8b, 0x4d, 0x33, 0x34, 0x69, 0x23,
-	0x07, 0xba, 0x7c, 0x83, 0xeb, 0x77, 0x4a, 0x13, 0xfd, 0x0e, 0xd6, 0x46, 0xe4, 0xd9, 0xb2, 0x2a,
-	0x33, 0xf5, 0x86, 0x61, 0x38, 0x90, 0xfe, 0x6d, 0x99, 0x3d, 0xe5, 0xac, 0xff, 0x63, 0x9c, 0x79,
-	0x11, 0x8c, 0x5e, 0xd6, 0xfe, 0xcf, 0x19, 0x02, 0x82, 0x1e, 0x11, 0x38, 0x69, 0x5a, 0x92, 0xb6,
-	0xf7, 0xd1, 0x80, 0xd1, 0xeb, 0x94, 0x0b, 0x56, 0x6e, 0x2f, 0xef, 0x09, 0x15, 0xe8, 0x1f, 0x18,
-	0x3e, 0xee, 0xff, 0x1e, 0x15, 0x76, 0x60, 0x74, 0x06, 0x26, 0x8e, 0xd4, 0x20, 0x3b, 0x4a, 0x17,
-	0x27, 0x5a, 0x17, 0xed, 0xdb, 0xfd, 0x73, 0x05, 0xf0, 0x2f, 0x69, 0x95, 0x87, 0x35, 0x7a, 0xfc,
-	0x1c, 0x4c, 0x1d, 0xf6, 0xce, 0xa0, 0x27, 0x33, 0xc8, 0x82, 0xde, 0x15, 0xa3, 0xc4, 0x39, 0x90,
-	0x7b, 0xbc, 0xdb, 0x5a, 0x00, 0xf3, 0x71, 0x61, 0x6d, 0x18, 0xcc, 0xe9, 0x3d, 0xce, 0xd2, 0xd8,
-	0xe9, 0x7a, 0xef, 0x3b, 0x60, 0xd7, 0x65, 0x14, 0x53, 0x7f, 0x82, 0x49, 0x64, 0x39, 0xa9, 0xd0,
-	0xee, 0xd4, 0x3e, 0x45, 0x4f, 0x5f, 0x12, 0xd6, 0x08, 0xf4, 0x2f, 0xc0, 0x9a, 0x55, 0x34, 0xd6,
-	0x94, 0x76, 0xbe, 0xdf, 0xb0, 0x42, 0x2b, 0x3e, 0xff, 0x00, 0xed, 0x2c, 0x1f, 0xf0, 0xb6, 0x5e,
-	0x0a, 0x4b, 0x05, 0xde, 0xe2, 0x2d, 0x3a, 0x87, 0xa3, 0x0c, 0x4b, 0xf5, 0x2e, 0xb9, 0x6e, 0x60,
-	0x8f, 0x1d, 0x3d, 0xd4, 0x27, 0xea, 0x8e, 0xe5, 0xc0, 0xeb, 0x2b, 0xd6, 0xaa, 0xed, 0x7d, 0x36,
-	0x48, 0x1f, 0xd0, 0x34, 0x79, 0x5f, 0x0c, 0x18, 0x5c, 0xe3, 0xe8, 0x0e, 0x27, 0x6a, 0xf8, 0x57,
-	0xad, 0x1d, 0xbf, 0xaa, 0x77, 0xfc, 0xba, 0xb5, 0xe3, 0xd2, 0x46, 0x63, 0xb0, 0x16, 0x5b, 0xca,
-	0x0a, 0x9e, 0x72, 0x77, 0xa8, 0x7b, 0x6a, 0x7c, 0xa9, 0xd7, 0x8b, 0xa7, 0x7a, 0x6d, 0x85, 0xe4,
-	0xe9, 0x90, 0xe0, 0x38, 0x27, 0x33, 0x5a, 0xeb, 0xe1, 0xd1, 0x47, 0x27, 0x00, 0xda, 0xbe, 0xc0,
-	0x02, 0xd7, 0x7a, 0x6e, 0x45, 0xe4, 0xbf, 0x36, 0xaf, 0xff, 0x35, 0x53, 0xff, 0x6b, 0xb5, 0x2b,
-	0xeb, 0xca, 0x9f, 0xaf, 0xc9, 0x0e, 0x54, 0xb6, 0x1d, 0x92, 0xca, 0x94, 0x12, 0xb4, 0xb4, 0x32,
-	0xab, 0x32, 0x7b, 0x61, 0xbd, 0x33, 0xe5, 0xd0, 0x8b, 0xd5, 0xca, 0x54, 0x3c, 0xfd, 0xf5, 0x35,
-	0x00, 0x00, 0xff, 0xff, 0xde, 0x45, 0xb0, 0x53, 0x41, 0x06, 0x00, 0x00,
+	// 1194 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0xef, 0x6e, 0xdc, 0x44,
+	0x10, 0xef, 0xfd, 0xf3, 0xf9, 0xc6, 0x97, 0xf4, 0x58, 0x10, 0x75, 0x03, 0x94, 0xe8, 0x24, 0x44,
+	0xc4, 0x9f, 0x3b, 0x29, 0x88, 0x0a, 0x84, 0x10, 0x94, 0xb6, 0x81, 0x20, 0x54, 0x15, 0xa7, 0x01,
+	0x89, 0x0f, 0x9c, 0x36, 0xf6, 0x9e, 0x6f, 0x55, 0xdb, 0x6b, 0x76, 0xed, 0xb4, 0xc7, 0x1b, 0xf0,
+	0x91, 0x57, 0x40, 0x82, 0x27, 0xe1, 0x31, 0x78, 0x14, 0x3e, 0xa0, 0x99, 0x5d, 0xdf, 0x39, 0x44,
+	0x6a, 0x82, 0xc4, 0xb7, 0x99, 0xdf, 0xcc, 0xee, 0xec, 0xfc, 0x5f, 0xf8, 0x30, 0x95, 0xd5, 0xaa,
+	0x3e, 0x9b, 0xc5, 0x2a, 0x9f, 0x27, 0xfc, 0x5c, 0x26, 0x89, 0x28, 0xd2, 0xb8, 0x98, 0xa7, 0xb1,
+	0x11, 0x73, 0xb3, 0xe2, 0x5a, 0x24, 0xf3, 0x52, 0xab, 0x4a, 0xcd, 0x4d, 0x29, 0x13, 0xa1, 0x67,
+	0xc4, 0xb0, 0x3e, 0xca, 0xf7, 0x3e, 0x69, 0x1d, 0x4e, 0x55, 0xc6, 0x8b, 0xd4, 0xea, 0x9e, 0xd5,
+	0xcb, 0x79, 0x59, 0xad, 0x4b, 0x61, 0xe6, 0x95, 0xcc, 0x85, 0xa9, 0x78, 0x5e, 0x6e, 0x29, 0x7b,
+	0xc5, 0xf4, 0xf7, 0x1e, 0xc0, 0x97, 0xea, 0x48, 0x66, 0xe2, 0xb8, 0x58, 0x2a, 0x36, 0x07, 0xcf,
+	0x54, 0xbc, 0xaa, 0x4d, 0xd8, 0xd9, 0xef, 0x1c, 0xec, 0x1e, 0xde, 0x9a, 0xa1, 0x89, 0xd9, 0x56,
+	0x63, 0x76, 0x42, 0xe2, 0xc8, 0xa9, 0x31, 0x06, 0xfd, 0x82, 0xe7, 0x22, 0xec, 0xee, 0x77, 0x0e,
+	0x46, 0x11, 0xd1, 0x6c, 0x1f, 0x82, 0x44, 0x98, 0x58, 0xcb, 0xb2, 0x92, 0xaa, 0x08, 0x7b, 0x24,
+	0x6a, 0x43, 0xec, 0x16, 0x0c, 0xa5, 0x59, 0x54, 0xc2, 0x54, 0x61, 0x7f, 0xbf, 0x73, 0xe0, 0x47,
+	0x9e, 0x34, 0x4f, 0x84, 0xa9, 0x58, 0x08, 0x43, 0x99, 0x97, 0x4a, 0x57, 0x26, 0x1c, 0xec, 0xf7,
+	0x0e, 0x46, 0x51, 0xc3, 0xb2, 0x3b, 0x00, 0x89, 0x28, 0xb5, 0x88, 0x79, 0x25, 0x92, 0xd0, 0xa3,
+	0x3b, 0x5b, 0x08, 0x3e, 0xc4, 0xc8, 0x9f, 0x45, 0x38, 0xdc, 0xef, 0x1c, 0x0c, 0x22, 0xa2, 0xd9,
+	0x1e, 0xf8, 0xe2, 0x39, 0xcf, 0xcb, 0x4c, 0x98, 0xd0, 0x27, 0x7c, 0xc3, 0x5b, 0x19, 0x5e, 0x2d,
+	0x92, 0x70, 0xd4, 0xc8, 0x2c, 0x4f, 0xb6, 0x54, 0x5c, 0xe7, 0xa2, 0x40, 0x29, 0x90, 0xb4, 0x85,
+	0xb0, 0xb7, 0x60, 0xb7, 0xe4, 0xda, 0x08, 0xbd, 0x38, 0x17, 0xda, 0xa0, 0x8f, 0x01, 0xe9, 0xec,
+	0x58, 0xf4, 0x3b, 0x0b, 0x4e, 0xbf, 0x06, 0xcf, 0x46, 0x8b, 0x05, 0x30, 0x3c, 0x2d, 0x9e, 0x16,
+	0xea, 0x59, 0x31, 0xb9, 0xc1, 0x26, 0x30, 0x7e, 0x8c, 0x7a, 0x27, 0x75, 0x1c, 0x0b, 0x63, 0x26,
+	0x1d, 0x76, 0x13, 0x02, 0x42, 0x8e, 0xb8, 0xcc, 0x44, 0x32, 0xe9, 0xa2, 0xca, 0xc9, 0x4a, 0xd5,
+	0x59, 0x72, 0x9c, 0x16, 0x4a, 0x8b, 0x49, 0x6f, 0xfa, 0x57, 0x0f, 0xfc, 0x48, 0x94, 0x8a, 0xb2,
+	0xf4, 0x19, 0xec, 0xc4, 0x9a, 0x3f, 0xcb, 0x64, 0x91, 0x2e, 0x30, 0xa1, 0x94, 0xac, 0xe0, 0x70,
+	0x6f, 0x96, 0x2a, 0x95, 0x66, 0x62, 0xd6, 0xa4, 0x7f, 0xf6, 0xa4, 0xc9, 0x76, 0x34, 0x6e, 0x0e,
+	0x20, 0xc4, 0x5e, 0x81, 0x81, 0xa9, 0xb8, 0x36, 0x94, 0xb6, 0x41, 0x64, 0x99, 0x6b, 0xe4, 0xed,
+	0x55, 0xf0, 0x8c, 0xaa, 0x75, 0x2c, 0xc2, 0x01, 0x09, 0x1d, 0xc7, 0x3e, 0x85, 0x71, 0xc6, 0x4d,
+	0xb5, 0xa8, 0xcb, 0x84, 0xd2, 0xd3, 0xbf, 0xf2, 0x3d, 0x01, 0xea, 0x9f, 0x5a, 0x75, 0xf6, 0x2e,
+	0x0c, 0x33, 0x19, 0x8b, 0xc2, 0x08, 0x4a, 0x6c, 0x70, 0xf8, 0x92, 0x2d, 0xbb, 0x6f, 0x2c, 0x88,
+	0x3e, 0x47, 0x8d, 0x06, 0x26, 0x8e, 0xeb, 0x78, 0x25, 0xcf, 0x45, 0x42, 0xc9, 0xf6, 0xa3, 0x0d,
+	0xcf, 0xde, 0x00, 0xc8, 0xa5, 0xd6, 0x4a, 0x2f, 0x6a, 0x9d, 0x51, 0xca, 0x47, 0xd1, 0xc8, 0x22,
+	0xa7, 0x3a, 0xc3, 0xb8, 0x65, 0x1c, 0xab, 0x6e, 0x11, 0xab, 0x3c, 0x97, 0x55, 0x38, 0xba, 0xf2,
+	0x9d, 0x63, 0x7b, 0xe0, 0x3e, 0xe9, 0xb3, 0x7b, 0xb0, 0xeb, 0x2e, 0xd0, 0x22, 0x13, 0xdc, 0x88,
+	0x10, 0xae, 0xbc, 0xc1, 0x99, 0x8c, 0xec, 0x01, 0x0c, 0xfd, 0x52, 0xe9, 0xa7, 0xc6, 0x95, 0x8c,
+	0x65, 0xa6, 0xbf, 0x75, 0x00, 0x8e, 0x54, 0x96, 0x08, 0x4d, 0x09, 0x6e, 0xba, 0xaa, 0xd3, 0xea,
+	0x2a, 0x06, 0xfd, 0x92, 0x57, 0xab, 0xa6, 0xd3, 0x90, 0x66, 0x13, 0xe8, 0x99, 0x15, 0x77, 0x99,
+	0x42, 0x92, 0xdd, 0x06, 0x7f, 0x55, 0xe5, 0x19, 0xf9, 0xdf, 0x27, 0x78, 0x88, 0xbc, 0xf3, 0xfe,
+	0x62, 0xd5, 0x0c, 0xfe, 0x5b, 0xd5, 0x4c, 0x63, 0x18, 0xdf, 0x77, 0xfc, 0xff, 0x53, 0x86, 0x0c,
+	0xfa, 0xa2, 0xe2, 0x69, 0xe3, 0x12, 0xd2, 0xd3, 0x3f, 0xba, 0x30, 0xfe, 0x4a, 0x9a, 0x4a, 0xe9,
+	0xf5, 0xc3, 0x73, 0x51, 0x54, 0xec, 0x23, 0x18, 0x6d, 0x86, 0xd6, 0x35, 0x2c, 0x6c, 0x95, 0xd9,
+	0x5d, 0xf0, 0x78, 0x4c, 0xa5, 0xdc, 0xa5, 0x61, 0x76, 0xc7, 0x56, 0x55, 0xfb, 0xf6, 0xd9, 0x3d,
+	0x52, 0x98, 0x3d, 0x2c, 0xea, 0x3c, 0x72, 0xda, 0xd8, 0xde, 0x4b, 0x2e, 0xb3, 0x5a, 0x8b, 0x85,
+	0x16, 0xdc, 0x6c, 0x5a, 0x61, 0xc7, 0xa1, 0x11, 0x81, 0x9b, 0xd7, 0xf7, 0xb7, 0xaf, 0x67, 0x6f,
+	0xc3, 0x4d, 0xf2, 0xb0, 0x35, 0x1a, 0x06, 0x94, 0xe7, 0x5d, 0x07, 0xbb, 0xd9, 0xb0, 0xf7, 0x39,
+	0x78, 0xd6, 0xf4, 0xf4, 0x2e, 0xf4, 0xd1, 0x3a, 0xf3, 0xa1, 0xff, 0x48, 0x15, 0x62, 0x72, 0x03,
+	0xa7, 0xc5, 0x76, 0x36, 0x00, 0x78, 0x9b, 0xb1, 0x10, 0xc0, 0xf0, 0xb8, 0x38, 0xe7, 0x99, 0x4c,
+	0x26, 0xbd, 0xe9, 0x9f, 0x5d, 0x08, 0x9c, 0x2b, 0x94, 0x8d, 0x77, 0xc0, 0x13, 0xe8, 0x12, 0x8e,
+	0xee, 0xde, 0x41, 0x70, 0xc8, 0x2e, 0x7b, 0x1b, 0x39, 0x0d, 0xf6, 0x31, 0xc0, 0x52, 0xd5, 0x45,
+	0x62, 0xd3, 0xd6, 0xbd, 0x3a, 0xa8, 0xa4, 0x4d, 0x39, 0x7b, 0x0d, 0x2c, 0xb3, 0x78, 0xc6, 0xd7,
+	0x2e, 0x2e, 0x3e, 0x01, 0xdf, 0xf3, 0x75, 0xab, 0x3f, 0x8c, 0x75, 0x20, 0xec, 0x5f, 0xb7, 0x3f,
+	0x9c, 0xc7, 0xad, 0x1e, 0x5d, 0x92, 0xdb, 0xd7, 0xa9, 0x52, 0x7b, 0xc0, 0x86, 0x89, 0xbd, 0x0f,
+	0x43, 0xad, 0xb2, 0xac, 0x2e, 0x4d, 0xe8, 0x51, 0x20, 0x5e, 0xbe, 0x10, 0x88, 0x88, 0x64, 0x51,
+	0xa3, 0x33, 0xfd, 0xb5, 0x07, 0xc3, 0xc7, 0x3c, 0x7e, 0xca, 0x53, 0xaa, 0xc7, 0x47, 0xad, 0xb6,
+	0x7b, 0xe4, 0xda, 0xee, 0x71, 0xab, 0xed, 0x90, 0xc6, 0x11, 0x74, 0xb2, 0x2e, 0x54, 0x69, 0xa4,
+	0xa1, 0x11, 0x32, 0x8a, 0x36, 0x3c, 0x0e, 0xd1, 0x07, 0x97, 0x87, 0x68, 0x0b, 0xc2, 0xd3, 0x91,
+	0xe0, 0x49, 0x2e, 0x8e, 0x0a, 0x57, 0x3b, 0x1b, 0x1e, 0x37, 0x8f, 0xa5, 0x1f, 0xf0, 0x8a, 0xbb,
+	0x21, 0xdb, 0x42, 0x70, 0x3f, 0x1e, 0xbb, 0xfd, 0xe8, 0xd9, 0xfd, 0xe8, 0x58, 0xb4, 0x8b, 0x1b,
+	0xb4, 0x91, 0x0e, 0x49, 0xda, 0x86, 0x70, 0x58, 0x6c, 0xa7, 0x22, 0x92, 0xed, 0xb9, 0x0b, 0x57,
+	0xce, 0xdd, 0x8b, 0x0b, 0x38, 0xb8, 0xb4, 0x80, 0x5f, 0x87, 0x91, 0x16, 0x95, 0xe6, 0x31, 0x8a,
+	0xc7, 0x64, 0x7e, 0x0b, 0xa0, 0xa9, 0x9f, 0x6a, 0x9e, 0xc9, 0x6a, 0x1d, 0xee, 0xb4, 0x4d, 0x7d,
+	0x6b, 0x41, 0x6b, 0xca, 0x69, 0x4c, 0x7f, 0x84, 0xa0, 0xf5, 0x04, 0xfc, 0x2d, 0x98, 0x32, 0x79,
+	0xbe, 0x90, 0x89, 0xcb, 0x8c, 0x87, 0xec, 0x31, 0xed, 0xe9, 0x58, 0x15, 0x4b, 0x99, 0x88, 0x22,
+	0xb6, 0x65, 0xdc, 0x8d, 0x5a, 0x48, 0x6b, 0x5d, 0xf5, 0xda, 0xeb, 0x6a, 0xfa, 0x77, 0x07, 0x82,
+	0x96, 0x61, 0x5c, 0x1b, 0xb6, 0xe2, 0x24, 0xfe, 0x14, 0x3a, 0xd4, 0xb0, 0x23, 0x2a, 0x29, 0x04,
+	0xb0, 0xe4, 0x49, 0x4c, 0xff, 0x0b, 0xbb, 0x31, 0x7d, 0x2a, 0xd9, 0x7f, 0xff, 0x31, 0x7a, 0x2f,
+	0xf8, 0x63, 0xf4, 0x5f, 0xf8, 0xc7, 0x18, 0x5c, 0xfa, 0x63, 0xdc, 0x06, 0xbf, 0x36, 0xc2, 0x2c,
+	0xe2, 0x54, 0xd1, 0x52, 0xf4, 0xa3, 0x21, 0xf2, 0xf7, 0x53, 0xc5, 0xde, 0x84, 0x80, 0x44, 0x75,
+	0x61, 0xf8, 0x52, 0xb8, 0x25, 0x08, 0x08, 0x9d, 0x12, 0x82, 0xfe, 0xac, 0xb8, 0xc1, 0xe1, 0x95,
+	0xe4, 0x82, 0x12, 0xee, 0x47, 0xa3, 0x15, 0x37, 0xb6, 0x90, 0xa6, 0xbf, 0x74, 0x60, 0xe7, 0x42,
+	0x37, 0xb0, 0xf7, 0xa0, 0x97, 0xf0, 0xf5, 0x35, 0xa6, 0x2b, 0xaa, 0x61, 0x11, 0x36, 0xed, 0x6d,
+	0xa3, 0xd1, 0xb0, 0x18, 0x70, 0xd7, 0xb5, 0x36, 0x14, 0x8e, 0xc3, 0x13, 0xd2, 0x0e, 0x2e, 0x17,
+	0x87, 0x86, 0xfd, 0xc2, 0xff, 0xc1, 0xc3, 0x3a, 0x28, 0xcf, 0xce, 0x3c, 0x32, 0xf7, 0xc1, 0x3f,
+	0x03, 0x00, 0xc7, 0x43, 0x46, 0xa4, 0x0c, 0x0b, 0x00, 0x00,
 }
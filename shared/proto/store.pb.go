@@ -6,12 +6,37 @@ package gcsepb
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "github.com/golang/protobuf/ptypes/timestamp"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
+type Change_Op_Enum int32
+
+const (
+	Change_Op_None   Change_Op_Enum = 0
+	Change_Op_Update Change_Op_Enum = 1
+	Change_Op_Delete Change_Op_Enum = 2
+)
+
+var Change_Op_Enum_name = map[int32]string{
+	0: "None",
+	1: "Update",
+	2: "Delete",
+}
+var Change_Op_Enum_value = map[string]int32{
+	"None":   0,
+	"Update": 1,
+	"Delete": 2,
+}
+
+func (x Change_Op_Enum) String() string {
+	return proto.EnumName(Change_Op_Enum_name, int32(x))
+}
+func (Change_Op_Enum) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{3, 0, 0} }
+
 type PackageInfo struct {
 	Name         string        `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 	Package      string        `protobuf:"bytes,2,opt,name=package" json:"package,omitempty"`
@@ -30,7 +55,8 @@ type PackageInfo struct {
 	// Available if the package is not the repo's root.
 	FolderInfo *FolderInfo `protobuf:"bytes,14,opt,name=folder_info,json=folderInfo" json:"folder_info,omitempty"`
 	// Available if the package is the repo's root.
-	RepoInfo *RepoInfo `protobuf:"bytes,15,opt,name=repo_info,json=repoInfo" json:"repo_info,omitempty"`
+	RepoInfo *RepoInfo    `protobuf:"bytes,15,opt,name=repo_info,json=repoInfo" json:"repo_info,omitempty"`
+	License  *LicenseInfo `protobuf:"bytes,19,opt,name=license" json:"license,omitempty"`
 }
 
 func (m *PackageInfo) Reset()                    { *m = PackageInfo{} }
@@ -150,6 +176,13 @@ func (m *PackageInfo) GetRepoInfo() *RepoInfo {
 	return nil
 }
 
+func (m *PackageInfo) GetLicense() *LicenseInfo {
+	if m != nil {
+		return m.License
+	}
+	return nil
+}
+
 type PersonInfo struct {
 	CrawlingInfo *CrawlingInfo `protobuf:"bytes,1,opt,name=crawling_info,json=crawlingInfo" json:"crawling_info,omitempty"`
 }
@@ -231,10 +264,74 @@ func (m *Repository) GetCrawlingInfo() *CrawlingInfo {
 	return nil
 }
 
+// An entry of the change log of the store.
+type Change struct {
+	// Increasing sequence number of the change, starting from 1.
+	Seq int64 `protobuf:"varint,1,opt,name=seq" json:"seq,omitempty"`
+	// "package", "person", "repository", "package_history" or
+	// "person_history".
+	Kind string `protobuf:"bytes,2,opt,name=kind" json:"kind,omitempty"`
+	// The key of the record, e.g. "github.com/daviddengcn/gcse" of a package.
+	Key       string                     `protobuf:"bytes,3,opt,name=key" json:"key,omitempty"`
+	Op        Change_Op_Enum             `protobuf:"varint,4,opt,name=op,enum=gcse.Change_Op_Enum" json:"op,omitempty"`
+	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,5,opt,name=timestamp" json:"timestamp,omitempty"`
+}
+
+func (m *Change) Reset()                    { *m = Change{} }
+func (m *Change) String() string            { return proto.CompactTextString(m) }
+func (*Change) ProtoMessage()               {}
+func (*Change) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
+
+func (m *Change) GetSeq() int64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *Change) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *Change) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Change) GetOp() Change_Op_Enum {
+	if m != nil {
+		return m.Op
+	}
+	return Change_Op_None
+}
+
+func (m *Change) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+type Change_Op struct {
+}
+
+func (m *Change_Op) Reset()                    { *m = Change_Op{} }
+func (m *Change_Op) String() string            { return proto.CompactTextString(m) }
+func (*Change_Op) ProtoMessage()               {}
+func (*Change_Op) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3, 0} }
+
 func init() {
 	proto.RegisterType((*PackageInfo)(nil), "gcse.PackageInfo")
 	proto.RegisterType((*PersonInfo)(nil), "gcse.PersonInfo")
 	proto.RegisterType((*Repository)(nil), "gcse.Repository")
+	proto.RegisterType((*Change)(nil), "gcse.Change")
+	proto.RegisterType((*Change_Op)(nil), "gcse.Change.Op")
+	proto.RegisterEnum("gcse.Change_Op_Enum", Change_Op_Enum_name, Change_Op_Enum_value)
 }
 
 func init() {
@@ -242,39 +339,49 @@ func init() {
 }
 
 var fileDescriptor1 = []byte{
-	// 538 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x53, 0x5d, 0x6b, 0x13, 0x41,
-	0x14, 0x65, 0x9b, 0x8f, 0x6e, 0xee, 0xb6, 0xb5, 0x0e, 0x22, 0x43, 0x94, 0xba, 0xc6, 0x97, 0x80,
-	0x90, 0x60, 0x54, 0x94, 0x3e, 0xaa, 0x2d, 0xd4, 0xa7, 0xb2, 0xe0, 0x8b, 0x2f, 0x61, 0xb2, 0x7b,
-	0x77, 0xb3, 0x36, 0x99, 0x59, 0xee, 0x4c, 0xaa, 0xf9, 0x27, 0xfe, 0x31, 0xff, 0x8f, 0xcc, 0x47,
-	0xb7, 0x91, 0x22, 0xe4, 0xed, 0x9e, 0x73, 0xee, 0x19, 0xee, 0xcc, 0x3d, 0x03, 0xef, 0xaa, 0xda,
-	0x2c, 0x37, 0x8b, 0x49, 0xae, 0xd6, 0xd3, 0x42, 0xdc, 0xd6, 0x45, 0x81, 0xb2, 0xca, 0xe5, 0xb4,
-	0xca, 0x35, 0x4e, 0xf5, 0x52, 0x10, 0x16, 0xd3, 0x86, 0x94, 0x51, 0x53, 0x6d, 0x14, 0xe1, 0xc4,
-	0xd5, 0xac, 0x6b, 0xe5, 0xe1, 0xfb, 0xfd, 0xbd, 0x4d, 0x5d, 0x20, 0x79, 0xf3, 0xe8, 0x77, 0x17,
-	0x92, 0x6b, 0x91, 0xdf, 0x88, 0x0a, 0xaf, 0x64, 0xa9, 0x18, 0x83, 0xae, 0x14, 0x6b, 0xe4, 0x51,
-	0x1a, 0x8d, 0x07, 0x99, 0xab, 0x19, 0x87, 0xc3, 0xc6, 0xb7, 0xf0, 0x03, 0x47, 0xdf, 0x41, 0xf6,
-	0x14, 0xfa, 0x62, 0x63, 0x96, 0x8a, 0x78, 0xc7, 0x09, 0x01, 0xb1, 0x27, 0xd0, 0xd3, 0x46, 0x90,
-	0xe6, 0xdd, 0x34, 0x1a, 0xf7, 0x32, 0x0f, 0xd8, 0x10, 0x62, 0xbd, 0x95, 0xaa, 0xd1, 0xb5, 0xe6,
-	0x3d, 0xd7, 0xdf, 0x62, 0x96, 0x42, 0x52, 0xa0, 0xce, 0xa9, 0x6e, 0x4c, 0xad, 0x24, 0xef, 0x3b,
-	0x79, 0x97, 0x62, 0x2f, 0x20, 0x69, 0x48, 0xfd, 0xc0, 0xdc, 0xcc, 0x37, 0xb4, 0xe2, 0x87, 0xae,
-	0x03, 0x02, 0xf5, 0x8d, 0x56, 0xec, 0x19, 0x0c, 0x08, 0x45, 0xb1, 0xc6, 0x79, 0x29, 0x79, 0xec,
-	0xcf, 0xf7, 0xc4, 0xa5, 0x73, 0x07, 0xb1, 0x10, 0x46, 0xf0, 0x81, 0x77, 0x7b, 0xea, 0x8b, 0x30,
-	0xc2, 0x5e, 0xb2, 0x5e, 0x37, 0x8a, 0x8c, 0xe6, 0x90, 0x76, 0xec, 0x25, 0x03, 0x64, 0x2f, 0xe1,
-	0xc8, 0xa0, 0x36, 0xf3, 0x3b, 0x39, 0x71, 0x72, 0x62, 0xb9, 0xab, 0xd0, 0x32, 0x84, 0x18, 0x7f,
-	0xd9, 0x12, 0x0b, 0x7e, 0xe4, 0xe4, 0x16, 0xb3, 0x33, 0x00, 0xc2, 0x12, 0x09, 0x65, 0x8e, 0x9a,
-	0x33, 0xa7, 0xee, 0x30, 0xec, 0x03, 0x1c, 0xe7, 0x24, 0x7e, 0xae, 0x6a, 0x59, 0xcd, 0x6b, 0x59,
-	0x2a, 0xfe, 0x38, 0x8d, 0xc6, 0xc9, 0x8c, 0x4d, 0xec, 0xe6, 0x26, 0x9f, 0x83, 0x64, 0x97, 0x93,
-	0x1d, 0xe5, 0x3b, 0x88, 0xbd, 0x81, 0xa4, 0x54, 0xab, 0x02, 0xc9, 0xdb, 0x4e, 0x9c, 0xed, 0xd4,
-	0xdb, 0x2e, 0x9d, 0xe0, 0x4c, 0x50, 0xb6, 0x35, 0x7b, 0x6d, 0x9f, 0xa8, 0x51, 0xde, 0xf0, 0xc8,
-	0x19, 0x4e, 0xbc, 0x21, 0xc3, 0x46, 0xb9, 0xf6, 0x98, 0x42, 0x35, 0xba, 0x00, 0xb8, 0x46, 0xd2,
-	0x4a, 0x3a, 0xeb, 0x83, 0x31, 0xa3, 0xfd, 0xc6, 0x1c, 0xfd, 0x39, 0x00, 0xb0, 0xa7, 0xeb, 0xda,
-	0x28, 0xda, 0xda, 0xc8, 0x2c, 0x48, 0xc8, 0x7c, 0x19, 0x76, 0x1c, 0x10, 0x7b, 0x0e, 0x03, 0x5d,
-	0x57, 0x52, 0x98, 0x0d, 0x61, 0x58, 0xee, 0x3d, 0xc1, 0xce, 0x21, 0x0e, 0x99, 0xd3, 0x3c, 0x4e,
-	0x3b, 0xe3, 0x64, 0x76, 0x76, 0x3f, 0xb7, 0x3f, 0x79, 0x12, 0x62, 0xac, 0x2f, 0xa4, 0xa1, 0x6d,
-	0xd6, 0xf6, 0xdb, 0xe5, 0x64, 0x21, 0x06, 0x21, 0xbf, 0x2d, 0xb6, 0xcb, 0xc9, 0xda, 0x0c, 0x84,
-	0x10, 0xef, 0x30, 0xff, 0x09, 0xf2, 0x83, 0xb7, 0xe8, 0xed, 0xf7, 0x16, 0xc3, 0xaf, 0x70, 0xfc,
-	0xcf, 0x94, 0xec, 0x14, 0x3a, 0x37, 0xb8, 0x0d, 0xbf, 0xcd, 0x96, 0xec, 0x15, 0xf4, 0x6e, 0xc5,
-	0x6a, 0xe3, 0xbf, 0x5a, 0x32, 0x3b, 0xf6, 0x67, 0x06, 0x57, 0xe6, 0xb5, 0xf3, 0x83, 0x8f, 0xd1,
-	0xa7, 0xf8, 0x7b, 0xdf, 0x4a, 0xcd, 0x62, 0xd1, 0x77, 0x5f, 0xf9, 0xed, 0xdf, 0x00, 0x00, 0x00,
-	0xff, 0xff, 0xd7, 0xe5, 0xfe, 0xf4, 0x3f, 0x04, 0x00, 0x00,
+	// 697 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x4b, 0x6f, 0xeb, 0x44,
+	0x14, 0xc6, 0x79, 0xd5, 0x39, 0x6e, 0x4b, 0xee, 0x70, 0x85, 0x46, 0x01, 0x5d, 0x42, 0x60, 0x11,
+	0xe9, 0x4a, 0x8e, 0x08, 0x20, 0xaa, 0xb2, 0xa3, 0x0f, 0xa9, 0x08, 0xd1, 0xca, 0xa2, 0x1b, 0x36,
+	0xd1, 0xc4, 0x3e, 0x76, 0x4c, 0x9d, 0x99, 0x61, 0x66, 0x5c, 0xc8, 0x0f, 0x64, 0xcf, 0x86, 0xff,
+	0x83, 0xe6, 0x91, 0x07, 0xaa, 0x90, 0xba, 0x3b, 0xe7, 0x7b, 0x1c, 0xcf, 0xf1, 0x7c, 0x36, 0x7c,
+	0x53, 0xd5, 0x66, 0xdd, 0xae, 0xd2, 0x5c, 0x6c, 0xe6, 0x05, 0x7b, 0xae, 0x8b, 0x02, 0x79, 0x95,
+	0xf3, 0x79, 0x95, 0x6b, 0x9c, 0xeb, 0x35, 0x53, 0x58, 0xcc, 0xa5, 0x12, 0x46, 0xcc, 0xb5, 0x11,
+	0x0a, 0x53, 0x57, 0x93, 0x9e, 0xa5, 0xc7, 0xdf, 0xbe, 0xde, 0x2b, 0xeb, 0x02, 0x95, 0x37, 0x8f,
+	0xbf, 0x3f, 0xb2, 0x55, 0xa2, 0x61, 0xbc, 0xf2, 0xaa, 0x55, 0x5b, 0xce, 0xa5, 0xd9, 0x4a, 0xd4,
+	0x73, 0x53, 0x6f, 0x50, 0x1b, 0xb6, 0x91, 0x87, 0xca, 0x9b, 0xa7, 0x7f, 0xf5, 0x20, 0x79, 0x60,
+	0xf9, 0x13, 0xab, 0xf0, 0x8e, 0x97, 0x82, 0x10, 0xe8, 0x71, 0xb6, 0x41, 0x1a, 0x4d, 0xa2, 0xd9,
+	0x30, 0x73, 0x35, 0xa1, 0x70, 0x22, 0xbd, 0x84, 0x76, 0x1c, 0xbc, 0x6b, 0xc9, 0xc7, 0x30, 0x60,
+	0xad, 0x59, 0x0b, 0x45, 0xbb, 0x8e, 0x08, 0x1d, 0x79, 0x0b, 0x7d, 0x6d, 0x98, 0xd2, 0xb4, 0x37,
+	0x89, 0x66, 0xfd, 0xcc, 0x37, 0x64, 0x0c, 0xb1, 0xde, 0x72, 0x21, 0x75, 0xad, 0x69, 0xdf, 0xe9,
+	0xf7, 0x3d, 0x99, 0x40, 0x52, 0xa0, 0xce, 0x55, 0x2d, 0x4d, 0x2d, 0x38, 0x1d, 0x38, 0xfa, 0x18,
+	0x22, 0x9f, 0x41, 0x22, 0x95, 0xf8, 0x0d, 0x73, 0xb3, 0x6c, 0x55, 0x43, 0x4f, 0x9c, 0x02, 0x02,
+	0xf4, 0xa8, 0x1a, 0xf2, 0x09, 0x0c, 0x15, 0xb2, 0x62, 0x83, 0xcb, 0x92, 0xd3, 0xd8, 0xcf, 0xf7,
+	0xc0, 0xad, 0x73, 0x07, 0xb2, 0x60, 0x86, 0xd1, 0xa1, 0x77, 0x7b, 0xe8, 0x9a, 0x19, 0x66, 0x97,
+	0xac, 0x37, 0x52, 0x28, 0xa3, 0x29, 0x4c, 0xba, 0x76, 0xc9, 0xd0, 0x92, 0xcf, 0xe1, 0xd4, 0xa0,
+	0x36, 0xcb, 0x1d, 0x9d, 0x38, 0x3a, 0xb1, 0xd8, 0x5d, 0x90, 0x8c, 0x21, 0xc6, 0x3f, 0x6d, 0x89,
+	0x05, 0x3d, 0x75, 0xf4, 0xbe, 0x27, 0xef, 0x00, 0x14, 0x96, 0xa8, 0x90, 0xe7, 0xa8, 0x29, 0x71,
+	0xec, 0x11, 0x42, 0xbe, 0x83, 0xb3, 0x5c, 0xb1, 0x3f, 0x9a, 0x9a, 0x57, 0xcb, 0x9a, 0x97, 0x82,
+	0xbe, 0x99, 0x44, 0xb3, 0x64, 0x41, 0x52, 0x7b, 0xed, 0xe9, 0x55, 0xa0, 0xec, 0xe5, 0x64, 0xa7,
+	0xf9, 0x51, 0x47, 0xbe, 0x82, 0xa4, 0x14, 0x4d, 0x81, 0xca, 0xdb, 0xce, 0x9d, 0x6d, 0xe4, 0x6d,
+	0xb7, 0x8e, 0x70, 0x26, 0x28, 0xf7, 0x35, 0x79, 0x6f, 0x5f, 0x91, 0x14, 0xde, 0xf0, 0xa1, 0x33,
+	0x9c, 0x7b, 0x43, 0x86, 0x52, 0x38, 0x79, 0xac, 0x42, 0x45, 0xde, 0xc3, 0x49, 0x53, 0xe7, 0xc8,
+	0x35, 0xd2, 0x8f, 0x9c, 0xf4, 0x8d, 0x97, 0xfe, 0xe4, 0x41, 0xa7, 0xde, 0x29, 0xa6, 0x37, 0x00,
+	0x0f, 0xa8, 0xb4, 0xe0, 0xce, 0xfa, 0x62, 0xa7, 0xe8, 0x75, 0x3b, 0x4d, 0xff, 0xe9, 0x00, 0xd8,
+	0xa3, 0xe8, 0xda, 0x08, 0xb5, 0xb5, 0xf9, 0x5a, 0x29, 0xc6, 0xf3, 0x75, 0x08, 0x44, 0xe8, 0xc8,
+	0xa7, 0x30, 0xd4, 0x75, 0xc5, 0x99, 0x69, 0x15, 0x86, 0x24, 0x1c, 0x00, 0x72, 0x09, 0x71, 0x08,
+	0xa8, 0xa6, 0xf1, 0xa4, 0x3b, 0x4b, 0x16, 0xef, 0x0e, 0x4b, 0xfa, 0xc9, 0x69, 0xc8, 0xbc, 0xbe,
+	0xe1, 0x46, 0x6d, 0xb3, 0xbd, 0xde, 0xde, 0x64, 0x16, 0x32, 0x13, 0xc2, 0xbe, 0xef, 0xed, 0x4d,
+	0x66, 0xfb, 0xc0, 0x84, 0xc4, 0x1f, 0x21, 0xff, 0x93, 0xfa, 0x17, 0xef, 0xa2, 0xff, 0xba, 0x77,
+	0x31, 0xfe, 0x11, 0xce, 0xfe, 0x73, 0x4a, 0x32, 0x82, 0xee, 0x13, 0x6e, 0xc3, 0xa7, 0x69, 0x4b,
+	0xf2, 0x05, 0xf4, 0x9f, 0x59, 0xd3, 0xfa, 0xef, 0x32, 0x59, 0x9c, 0xf9, 0x99, 0xc1, 0x95, 0x79,
+	0xee, 0xb2, 0x73, 0x11, 0x4d, 0xff, 0x8e, 0x60, 0x70, 0xb5, 0x66, 0xbc, 0x42, 0x3b, 0x45, 0xe3,
+	0xef, 0x6e, 0x4a, 0x37, 0xb3, 0xa5, 0xfd, 0xe6, 0x9f, 0x6a, 0x5e, 0x84, 0x7d, 0x5d, 0xbd, 0x7b,
+	0x56, 0xf7, 0xf0, 0xac, 0x2f, 0xa1, 0x23, 0xa4, 0x5b, 0xed, 0x7c, 0xf1, 0x36, 0x1c, 0xde, 0x4d,
+	0x4c, 0xef, 0x65, 0x7a, 0xc3, 0xdb, 0x4d, 0xd6, 0x11, 0x92, 0x5c, 0xc0, 0x70, 0xff, 0x8b, 0x09,
+	0x9b, 0x8e, 0xd3, 0x4a, 0x88, 0xaa, 0x09, 0xff, 0xba, 0x55, 0x5b, 0xa6, 0xbf, 0xec, 0x14, 0xd9,
+	0x41, 0x3c, 0x4e, 0xa1, 0x73, 0x2f, 0xa7, 0x33, 0xe8, 0xd9, 0x59, 0x24, 0x86, 0xde, 0xcf, 0x82,
+	0xe3, 0xe8, 0x03, 0x02, 0x30, 0x78, 0x94, 0x05, 0x33, 0x38, 0x8a, 0x6c, 0x7d, 0x8d, 0x0d, 0x1a,
+	0x1c, 0x75, 0x7e, 0x88, 0x7f, 0x1d, 0xd8, 0x43, 0xc8, 0xd5, 0x6a, 0xe0, 0x06, 0x7f, 0xfd, 0xef,
+	0x00, 0x04, 0x8c, 0xa6, 0xbd, 0x7c, 0x05, 0x00, 0x00,
 }
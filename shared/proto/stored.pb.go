@@ -6,6 +6,7 @@ package gcsepb
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "github.com/golang/protobuf/ptypes/timestamp"
 
 import (
 	context "golang.org/x/net/context"
@@ -18,7 +19,9 @@ var _ = fmt.Errorf
 var _ = math.Inf
 
 type PackageCrawlHistoryReq struct {
-	Package string `protobuf:"bytes,1,opt,name=package" json:"package,omitempty"`
+	Package   string `protobuf:"bytes,1,opt,name=package" json:"package,omitempty"`
+	PageSize  int32  `protobuf:"varint,2,opt,name=page_size,json=pageSize" json:"page_size,omitempty"`
+	PageToken string `protobuf:"bytes,3,opt,name=page_token,json=pageToken" json:"page_token,omitempty"`
 }
 
 func (m *PackageCrawlHistoryReq) Reset()                    { *m = PackageCrawlHistoryReq{} }
@@ -33,86 +36,1602 @@ func (m *PackageCrawlHistoryReq) GetPackage() string {
 	return ""
 }
 
+func (m *PackageCrawlHistoryReq) GetPageSize() int32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+func (m *PackageCrawlHistoryReq) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
 type PackageCrawlHistoryResp struct {
-	Info *HistoryInfo `protobuf:"bytes,1,opt,name=info" json:"info,omitempty"`
+	Info   *HistoryInfo    `protobuf:"bytes,1,opt,name=info" json:"info,omitempty"`
+	Events []*HistoryEvent `protobuf:"bytes,2,rep,name=events" json:"events,omitempty"`
+	// Empty if there are no more events.
+	NextPageToken string `protobuf:"bytes,3,opt,name=next_page_token,json=nextPageToken" json:"next_page_token,omitempty"`
+}
+
+func (m *PackageCrawlHistoryResp) Reset()                    { *m = PackageCrawlHistoryResp{} }
+func (m *PackageCrawlHistoryResp) String() string            { return proto.CompactTextString(m) }
+func (*PackageCrawlHistoryResp) ProtoMessage()               {}
+func (*PackageCrawlHistoryResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{1} }
+
+func (m *PackageCrawlHistoryResp) GetInfo() *HistoryInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *PackageCrawlHistoryResp) GetEvents() []*HistoryEvent {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+func (m *PackageCrawlHistoryResp) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
+type ReadPackageReq struct {
+	Site string `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	Path string `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+}
+
+func (m *ReadPackageReq) Reset()                    { *m = ReadPackageReq{} }
+func (m *ReadPackageReq) String() string            { return proto.CompactTextString(m) }
+func (*ReadPackageReq) ProtoMessage()               {}
+func (*ReadPackageReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{2} }
+
+func (m *ReadPackageReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *ReadPackageReq) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type ReadPackageResp struct {
+	Info     *PackageInfo `protobuf:"bytes,1,opt,name=info" json:"info,omitempty"`
+	Revision int64        `protobuf:"varint,2,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *ReadPackageResp) Reset()                    { *m = ReadPackageResp{} }
+func (m *ReadPackageResp) String() string            { return proto.CompactTextString(m) }
+func (*ReadPackageResp) ProtoMessage()               {}
+func (*ReadPackageResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{3} }
+
+func (m *ReadPackageResp) GetInfo() *PackageInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *ReadPackageResp) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type UpdatePackageReq struct {
+	Site string       `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	Path string       `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+	Info *PackageInfo `protobuf:"bytes,3,opt,name=info" json:"info,omitempty"`
+	// The revision of the record read before the update.
+	Revision int64 `protobuf:"varint,4,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *UpdatePackageReq) Reset()                    { *m = UpdatePackageReq{} }
+func (m *UpdatePackageReq) String() string            { return proto.CompactTextString(m) }
+func (*UpdatePackageReq) ProtoMessage()               {}
+func (*UpdatePackageReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{4} }
+
+func (m *UpdatePackageReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *UpdatePackageReq) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *UpdatePackageReq) GetInfo() *PackageInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *UpdatePackageReq) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type UpdatePackageResp struct {
+	Revision int64 `protobuf:"varint,1,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *UpdatePackageResp) Reset()                    { *m = UpdatePackageResp{} }
+func (m *UpdatePackageResp) String() string            { return proto.CompactTextString(m) }
+func (*UpdatePackageResp) ProtoMessage()               {}
+func (*UpdatePackageResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{5} }
+
+func (m *UpdatePackageResp) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type DeletePackageReq struct {
+	Site string `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	Path string `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+}
+
+func (m *DeletePackageReq) Reset()                    { *m = DeletePackageReq{} }
+func (m *DeletePackageReq) String() string            { return proto.CompactTextString(m) }
+func (*DeletePackageReq) ProtoMessage()               {}
+func (*DeletePackageReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{6} }
+
+func (m *DeletePackageReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *DeletePackageReq) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type DeletePackageResp struct {
+}
+
+func (m *DeletePackageResp) Reset()                    { *m = DeletePackageResp{} }
+func (m *DeletePackageResp) String() string            { return proto.CompactTextString(m) }
+func (*DeletePackageResp) ProtoMessage()               {}
+func (*DeletePackageResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{7} }
+
+type ListPackageSitesReq struct {
+}
+
+func (m *ListPackageSitesReq) Reset()                    { *m = ListPackageSitesReq{} }
+func (m *ListPackageSitesReq) String() string            { return proto.CompactTextString(m) }
+func (*ListPackageSitesReq) ProtoMessage()               {}
+func (*ListPackageSitesReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{8} }
+
+type ListPackageSitesResp struct {
+	Sites []string `protobuf:"bytes,1,rep,name=sites" json:"sites,omitempty"`
+}
+
+func (m *ListPackageSitesResp) Reset()                    { *m = ListPackageSitesResp{} }
+func (m *ListPackageSitesResp) String() string            { return proto.CompactTextString(m) }
+func (*ListPackageSitesResp) ProtoMessage()               {}
+func (*ListPackageSitesResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{9} }
+
+func (m *ListPackageSitesResp) GetSites() []string {
+	if m != nil {
+		return m.Sites
+	}
+	return nil
+}
+
+type ListPackagesReq struct {
+	Site string `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	// Only the packages whose paths start with prefix are listed.
+	Prefix string `protobuf:"bytes,2,opt,name=prefix" json:"prefix,omitempty"`
+}
+
+func (m *ListPackagesReq) Reset()                    { *m = ListPackagesReq{} }
+func (m *ListPackagesReq) String() string            { return proto.CompactTextString(m) }
+func (*ListPackagesReq) ProtoMessage()               {}
+func (*ListPackagesReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{10} }
+
+func (m *ListPackagesReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *ListPackagesReq) GetPrefix() string {
+	if m != nil {
+		return m.Prefix
+	}
+	return ""
+}
+
+type ListPackagesResp struct {
+	Path string       `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
+	Info *PackageInfo `protobuf:"bytes,2,opt,name=info" json:"info,omitempty"`
+}
+
+func (m *ListPackagesResp) Reset()                    { *m = ListPackagesResp{} }
+func (m *ListPackagesResp) String() string            { return proto.CompactTextString(m) }
+func (*ListPackagesResp) ProtoMessage()               {}
+func (*ListPackagesResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{11} }
+
+func (m *ListPackagesResp) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *ListPackagesResp) GetInfo() *PackageInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+type ReadRepositoryReq struct {
+	Site string `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	User string `protobuf:"bytes,2,opt,name=user" json:"user,omitempty"`
+	Repo string `protobuf:"bytes,3,opt,name=repo" json:"repo,omitempty"`
+}
+
+func (m *ReadRepositoryReq) Reset()                    { *m = ReadRepositoryReq{} }
+func (m *ReadRepositoryReq) String() string            { return proto.CompactTextString(m) }
+func (*ReadRepositoryReq) ProtoMessage()               {}
+func (*ReadRepositoryReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{12} }
+
+func (m *ReadRepositoryReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *ReadRepositoryReq) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *ReadRepositoryReq) GetRepo() string {
+	if m != nil {
+		return m.Repo
+	}
+	return ""
+}
+
+type ReadRepositoryResp struct {
+	Repository *Repository `protobuf:"bytes,1,opt,name=repository" json:"repository,omitempty"`
+	Revision   int64       `protobuf:"varint,2,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *ReadRepositoryResp) Reset()                    { *m = ReadRepositoryResp{} }
+func (m *ReadRepositoryResp) String() string            { return proto.CompactTextString(m) }
+func (*ReadRepositoryResp) ProtoMessage()               {}
+func (*ReadRepositoryResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{13} }
+
+func (m *ReadRepositoryResp) GetRepository() *Repository {
+	if m != nil {
+		return m.Repository
+	}
+	return nil
+}
+
+func (m *ReadRepositoryResp) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type UpdateRepositoryReq struct {
+	Site       string      `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	User       string      `protobuf:"bytes,2,opt,name=user" json:"user,omitempty"`
+	Repo       string      `protobuf:"bytes,3,opt,name=repo" json:"repo,omitempty"`
+	Repository *Repository `protobuf:"bytes,4,opt,name=repository" json:"repository,omitempty"`
+	Revision   int64       `protobuf:"varint,5,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *UpdateRepositoryReq) Reset()                    { *m = UpdateRepositoryReq{} }
+func (m *UpdateRepositoryReq) String() string            { return proto.CompactTextString(m) }
+func (*UpdateRepositoryReq) ProtoMessage()               {}
+func (*UpdateRepositoryReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{14} }
+
+func (m *UpdateRepositoryReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *UpdateRepositoryReq) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *UpdateRepositoryReq) GetRepo() string {
+	if m != nil {
+		return m.Repo
+	}
+	return ""
+}
+
+func (m *UpdateRepositoryReq) GetRepository() *Repository {
+	if m != nil {
+		return m.Repository
+	}
+	return nil
+}
+
+func (m *UpdateRepositoryReq) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type UpdateRepositoryResp struct {
+	Revision int64 `protobuf:"varint,1,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *UpdateRepositoryResp) Reset()                    { *m = UpdateRepositoryResp{} }
+func (m *UpdateRepositoryResp) String() string            { return proto.CompactTextString(m) }
+func (*UpdateRepositoryResp) ProtoMessage()               {}
+func (*UpdateRepositoryResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{15} }
+
+func (m *UpdateRepositoryResp) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type DeleteRepositoryReq struct {
+	Site string `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	User string `protobuf:"bytes,2,opt,name=user" json:"user,omitempty"`
+	Repo string `protobuf:"bytes,3,opt,name=repo" json:"repo,omitempty"`
+}
+
+func (m *DeleteRepositoryReq) Reset()                    { *m = DeleteRepositoryReq{} }
+func (m *DeleteRepositoryReq) String() string            { return proto.CompactTextString(m) }
+func (*DeleteRepositoryReq) ProtoMessage()               {}
+func (*DeleteRepositoryReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{16} }
+
+func (m *DeleteRepositoryReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *DeleteRepositoryReq) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *DeleteRepositoryReq) GetRepo() string {
+	if m != nil {
+		return m.Repo
+	}
+	return ""
+}
+
+type DeleteRepositoryResp struct {
+}
+
+func (m *DeleteRepositoryResp) Reset()                    { *m = DeleteRepositoryResp{} }
+func (m *DeleteRepositoryResp) String() string            { return proto.CompactTextString(m) }
+func (*DeleteRepositoryResp) ProtoMessage()               {}
+func (*DeleteRepositoryResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{17} }
+
+type ListRepositorySitesReq struct {
+}
+
+func (m *ListRepositorySitesReq) Reset()                    { *m = ListRepositorySitesReq{} }
+func (m *ListRepositorySitesReq) String() string            { return proto.CompactTextString(m) }
+func (*ListRepositorySitesReq) ProtoMessage()               {}
+func (*ListRepositorySitesReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{18} }
+
+type ListRepositorySitesResp struct {
+	Sites []string `protobuf:"bytes,1,rep,name=sites" json:"sites,omitempty"`
+}
+
+func (m *ListRepositorySitesResp) Reset()                    { *m = ListRepositorySitesResp{} }
+func (m *ListRepositorySitesResp) String() string            { return proto.CompactTextString(m) }
+func (*ListRepositorySitesResp) ProtoMessage()               {}
+func (*ListRepositorySitesResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{19} }
+
+func (m *ListRepositorySitesResp) GetSites() []string {
+	if m != nil {
+		return m.Sites
+	}
+	return nil
+}
+
+type ListRepositoriesReq struct {
+	Site string `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+}
+
+func (m *ListRepositoriesReq) Reset()                    { *m = ListRepositoriesReq{} }
+func (m *ListRepositoriesReq) String() string            { return proto.CompactTextString(m) }
+func (*ListRepositoriesReq) ProtoMessage()               {}
+func (*ListRepositoriesReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{20} }
+
+func (m *ListRepositoriesReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+type ListRepositoriesResp struct {
+	User       string      `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Name       string      `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Repository *Repository `protobuf:"bytes,3,opt,name=repository" json:"repository,omitempty"`
+}
+
+func (m *ListRepositoriesResp) Reset()                    { *m = ListRepositoriesResp{} }
+func (m *ListRepositoriesResp) String() string            { return proto.CompactTextString(m) }
+func (*ListRepositoriesResp) ProtoMessage()               {}
+func (*ListRepositoriesResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{21} }
+
+func (m *ListRepositoriesResp) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *ListRepositoriesResp) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ListRepositoriesResp) GetRepository() *Repository {
+	if m != nil {
+		return m.Repository
+	}
+	return nil
+}
+
+type ReadPersonReq struct {
+	Site string `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	Id   string `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *ReadPersonReq) Reset()                    { *m = ReadPersonReq{} }
+func (m *ReadPersonReq) String() string            { return proto.CompactTextString(m) }
+func (*ReadPersonReq) ProtoMessage()               {}
+func (*ReadPersonReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{22} }
+
+func (m *ReadPersonReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *ReadPersonReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type ReadPersonResp struct {
+	Info     *PersonInfo `protobuf:"bytes,1,opt,name=info" json:"info,omitempty"`
+	Revision int64       `protobuf:"varint,2,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *ReadPersonResp) Reset()                    { *m = ReadPersonResp{} }
+func (m *ReadPersonResp) String() string            { return proto.CompactTextString(m) }
+func (*ReadPersonResp) ProtoMessage()               {}
+func (*ReadPersonResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{23} }
+
+func (m *ReadPersonResp) GetInfo() *PersonInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *ReadPersonResp) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type UpdatePersonReq struct {
+	Site     string      `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	Id       string      `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Info     *PersonInfo `protobuf:"bytes,3,opt,name=info" json:"info,omitempty"`
+	Revision int64       `protobuf:"varint,4,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *UpdatePersonReq) Reset()                    { *m = UpdatePersonReq{} }
+func (m *UpdatePersonReq) String() string            { return proto.CompactTextString(m) }
+func (*UpdatePersonReq) ProtoMessage()               {}
+func (*UpdatePersonReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{24} }
+
+func (m *UpdatePersonReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *UpdatePersonReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *UpdatePersonReq) GetInfo() *PersonInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *UpdatePersonReq) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type UpdatePersonResp struct {
+	Revision int64 `protobuf:"varint,1,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *UpdatePersonResp) Reset()                    { *m = UpdatePersonResp{} }
+func (m *UpdatePersonResp) String() string            { return proto.CompactTextString(m) }
+func (*UpdatePersonResp) ProtoMessage()               {}
+func (*UpdatePersonResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{25} }
+
+func (m *UpdatePersonResp) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type DeletePersonReq struct {
+	Site string `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	Id   string `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *DeletePersonReq) Reset()                    { *m = DeletePersonReq{} }
+func (m *DeletePersonReq) String() string            { return proto.CompactTextString(m) }
+func (*DeletePersonReq) ProtoMessage()               {}
+func (*DeletePersonReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{26} }
+
+func (m *DeletePersonReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *DeletePersonReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type DeletePersonResp struct {
+}
+
+func (m *DeletePersonResp) Reset()                    { *m = DeletePersonResp{} }
+func (m *DeletePersonResp) String() string            { return proto.CompactTextString(m) }
+func (*DeletePersonResp) ProtoMessage()               {}
+func (*DeletePersonResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{27} }
+
+type AppendPackageEventReq struct {
+	Site      string                     `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	Path      string                     `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+	FoundWay  string                     `protobuf:"bytes,3,opt,name=found_way,json=foundWay" json:"found_way,omitempty"`
+	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=timestamp" json:"timestamp,omitempty"`
+	Action    HistoryEvent_Action_Enum   `protobuf:"varint,5,opt,name=action,enum=gcse.HistoryEvent_Action_Enum" json:"action,omitempty"`
+	// If set, the event is appended with its details and timestamp and action
+	// are ignored.
+	Event *HistoryEvent `protobuf:"bytes,6,opt,name=event" json:"event,omitempty"`
+}
+
+func (m *AppendPackageEventReq) Reset()                    { *m = AppendPackageEventReq{} }
+func (m *AppendPackageEventReq) String() string            { return proto.CompactTextString(m) }
+func (*AppendPackageEventReq) ProtoMessage()               {}
+func (*AppendPackageEventReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{28} }
+
+func (m *AppendPackageEventReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *AppendPackageEventReq) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *AppendPackageEventReq) GetFoundWay() string {
+	if m != nil {
+		return m.FoundWay
+	}
+	return ""
+}
+
+func (m *AppendPackageEventReq) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+func (m *AppendPackageEventReq) GetAction() HistoryEvent_Action_Enum {
+	if m != nil {
+		return m.Action
+	}
+	return HistoryEvent_Action_None
+}
+
+func (m *AppendPackageEventReq) GetEvent() *HistoryEvent {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+type AppendPackageEventResp struct {
+}
+
+func (m *AppendPackageEventResp) Reset()                    { *m = AppendPackageEventResp{} }
+func (m *AppendPackageEventResp) String() string            { return proto.CompactTextString(m) }
+func (*AppendPackageEventResp) ProtoMessage()               {}
+func (*AppendPackageEventResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{29} }
+
+// The history of the person if person is true, of the package otherwise. id
+// is the path of the package or the id of the person.
+type ReadHistoryReq struct {
+	Person bool   `protobuf:"varint,1,opt,name=person" json:"person,omitempty"`
+	Site   string `protobuf:"bytes,2,opt,name=site" json:"site,omitempty"`
+	Id     string `protobuf:"bytes,3,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *ReadHistoryReq) Reset()                    { *m = ReadHistoryReq{} }
+func (m *ReadHistoryReq) String() string            { return proto.CompactTextString(m) }
+func (*ReadHistoryReq) ProtoMessage()               {}
+func (*ReadHistoryReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{30} }
+
+func (m *ReadHistoryReq) GetPerson() bool {
+	if m != nil {
+		return m.Person
+	}
+	return false
+}
+
+func (m *ReadHistoryReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *ReadHistoryReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type ReadHistoryResp struct {
+	Info     *HistoryInfo `protobuf:"bytes,1,opt,name=info" json:"info,omitempty"`
+	Revision int64        `protobuf:"varint,2,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *ReadHistoryResp) Reset()                    { *m = ReadHistoryResp{} }
+func (m *ReadHistoryResp) String() string            { return proto.CompactTextString(m) }
+func (*ReadHistoryResp) ProtoMessage()               {}
+func (*ReadHistoryResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{31} }
+
+func (m *ReadHistoryResp) GetInfo() *HistoryInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *ReadHistoryResp) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type UpdateHistoryReq struct {
+	Person   bool         `protobuf:"varint,1,opt,name=person" json:"person,omitempty"`
+	Site     string       `protobuf:"bytes,2,opt,name=site" json:"site,omitempty"`
+	Id       string       `protobuf:"bytes,3,opt,name=id" json:"id,omitempty"`
+	Info     *HistoryInfo `protobuf:"bytes,4,opt,name=info" json:"info,omitempty"`
+	Revision int64        `protobuf:"varint,5,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *UpdateHistoryReq) Reset()                    { *m = UpdateHistoryReq{} }
+func (m *UpdateHistoryReq) String() string            { return proto.CompactTextString(m) }
+func (*UpdateHistoryReq) ProtoMessage()               {}
+func (*UpdateHistoryReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{32} }
+
+func (m *UpdateHistoryReq) GetPerson() bool {
+	if m != nil {
+		return m.Person
+	}
+	return false
+}
+
+func (m *UpdateHistoryReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *UpdateHistoryReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *UpdateHistoryReq) GetInfo() *HistoryInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *UpdateHistoryReq) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type UpdateHistoryResp struct {
+	Revision int64 `protobuf:"varint,1,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *UpdateHistoryResp) Reset()                    { *m = UpdateHistoryResp{} }
+func (m *UpdateHistoryResp) String() string            { return proto.CompactTextString(m) }
+func (*UpdateHistoryResp) ProtoMessage()               {}
+func (*UpdateHistoryResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{33} }
+
+func (m *UpdateHistoryResp) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+// The event log is deleted along with the history.
+type DeleteHistoryReq struct {
+	Person bool   `protobuf:"varint,1,opt,name=person" json:"person,omitempty"`
+	Site   string `protobuf:"bytes,2,opt,name=site" json:"site,omitempty"`
+	Id     string `protobuf:"bytes,3,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *DeleteHistoryReq) Reset()                    { *m = DeleteHistoryReq{} }
+func (m *DeleteHistoryReq) String() string            { return proto.CompactTextString(m) }
+func (*DeleteHistoryReq) ProtoMessage()               {}
+func (*DeleteHistoryReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{34} }
+
+func (m *DeleteHistoryReq) GetPerson() bool {
+	if m != nil {
+		return m.Person
+	}
+	return false
+}
+
+func (m *DeleteHistoryReq) GetSite() string {
+	if m != nil {
+		return m.Site
+	}
+	return ""
+}
+
+func (m *DeleteHistoryReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type DeleteHistoryResp struct {
+}
+
+func (m *DeleteHistoryResp) Reset()                    { *m = DeleteHistoryResp{} }
+func (m *DeleteHistoryResp) String() string            { return proto.CompactTextString(m) }
+func (*DeleteHistoryResp) ProtoMessage()               {}
+func (*DeleteHistoryResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{35} }
+
+type WatchChangesReq struct {
+	// Only the changes after this sequence number are sent.
+	FromSeq int64 `protobuf:"varint,1,opt,name=from_seq,json=fromSeq" json:"from_seq,omitempty"`
+	// Whether to keep sending new changes after the existing ones.
+	Follow bool `protobuf:"varint,2,opt,name=follow" json:"follow,omitempty"`
+}
+
+func (m *WatchChangesReq) Reset()                    { *m = WatchChangesReq{} }
+func (m *WatchChangesReq) String() string            { return proto.CompactTextString(m) }
+func (*WatchChangesReq) ProtoMessage()               {}
+func (*WatchChangesReq) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{36} }
+
+func (m *WatchChangesReq) GetFromSeq() int64 {
+	if m != nil {
+		return m.FromSeq
+	}
+	return 0
+}
+
+func (m *WatchChangesReq) GetFollow() bool {
+	if m != nil {
+		return m.Follow
+	}
+	return false
+}
+
+type WatchChangesResp struct {
+	Change *Change `protobuf:"bytes,1,opt,name=change" json:"change,omitempty"`
+}
+
+func (m *WatchChangesResp) Reset()                    { *m = WatchChangesResp{} }
+func (m *WatchChangesResp) String() string            { return proto.CompactTextString(m) }
+func (*WatchChangesResp) ProtoMessage()               {}
+func (*WatchChangesResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{37} }
+
+func (m *WatchChangesResp) GetChange() *Change {
+	if m != nil {
+		return m.Change
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*PackageCrawlHistoryReq)(nil), "gcse.PackageCrawlHistoryReq")
+	proto.RegisterType((*PackageCrawlHistoryResp)(nil), "gcse.PackageCrawlHistoryResp")
+	proto.RegisterType((*ReadPackageReq)(nil), "gcse.ReadPackageReq")
+	proto.RegisterType((*ReadPackageResp)(nil), "gcse.ReadPackageResp")
+	proto.RegisterType((*UpdatePackageReq)(nil), "gcse.UpdatePackageReq")
+	proto.RegisterType((*UpdatePackageResp)(nil), "gcse.UpdatePackageResp")
+	proto.RegisterType((*DeletePackageReq)(nil), "gcse.DeletePackageReq")
+	proto.RegisterType((*DeletePackageResp)(nil), "gcse.DeletePackageResp")
+	proto.RegisterType((*ListPackageSitesReq)(nil), "gcse.ListPackageSitesReq")
+	proto.RegisterType((*ListPackageSitesResp)(nil), "gcse.ListPackageSitesResp")
+	proto.RegisterType((*ListPackagesReq)(nil), "gcse.ListPackagesReq")
+	proto.RegisterType((*ListPackagesResp)(nil), "gcse.ListPackagesResp")
+	proto.RegisterType((*ReadRepositoryReq)(nil), "gcse.ReadRepositoryReq")
+	proto.RegisterType((*ReadRepositoryResp)(nil), "gcse.ReadRepositoryResp")
+	proto.RegisterType((*UpdateRepositoryReq)(nil), "gcse.UpdateRepositoryReq")
+	proto.RegisterType((*UpdateRepositoryResp)(nil), "gcse.UpdateRepositoryResp")
+	proto.RegisterType((*DeleteRepositoryReq)(nil), "gcse.DeleteRepositoryReq")
+	proto.RegisterType((*DeleteRepositoryResp)(nil), "gcse.DeleteRepositoryResp")
+	proto.RegisterType((*ListRepositorySitesReq)(nil), "gcse.ListRepositorySitesReq")
+	proto.RegisterType((*ListRepositorySitesResp)(nil), "gcse.ListRepositorySitesResp")
+	proto.RegisterType((*ListRepositoriesReq)(nil), "gcse.ListRepositoriesReq")
+	proto.RegisterType((*ListRepositoriesResp)(nil), "gcse.ListRepositoriesResp")
+	proto.RegisterType((*ReadPersonReq)(nil), "gcse.ReadPersonReq")
+	proto.RegisterType((*ReadPersonResp)(nil), "gcse.ReadPersonResp")
+	proto.RegisterType((*UpdatePersonReq)(nil), "gcse.UpdatePersonReq")
+	proto.RegisterType((*UpdatePersonResp)(nil), "gcse.UpdatePersonResp")
+	proto.RegisterType((*DeletePersonReq)(nil), "gcse.DeletePersonReq")
+	proto.RegisterType((*DeletePersonResp)(nil), "gcse.DeletePersonResp")
+	proto.RegisterType((*AppendPackageEventReq)(nil), "gcse.AppendPackageEventReq")
+	proto.RegisterType((*AppendPackageEventResp)(nil), "gcse.AppendPackageEventResp")
+	proto.RegisterType((*ReadHistoryReq)(nil), "gcse.ReadHistoryReq")
+	proto.RegisterType((*ReadHistoryResp)(nil), "gcse.ReadHistoryResp")
+	proto.RegisterType((*UpdateHistoryReq)(nil), "gcse.UpdateHistoryReq")
+	proto.RegisterType((*UpdateHistoryResp)(nil), "gcse.UpdateHistoryResp")
+	proto.RegisterType((*DeleteHistoryReq)(nil), "gcse.DeleteHistoryReq")
+	proto.RegisterType((*DeleteHistoryResp)(nil), "gcse.DeleteHistoryResp")
+	proto.RegisterType((*WatchChangesReq)(nil), "gcse.WatchChangesReq")
+	proto.RegisterType((*WatchChangesResp)(nil), "gcse.WatchChangesResp")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for StoreService service
+
+type StoreServiceClient interface {
+	PackageCrawlHistory(ctx context.Context, in *PackageCrawlHistoryReq, opts ...grpc.CallOption) (*PackageCrawlHistoryResp, error)
+	ReadPackage(ctx context.Context, in *ReadPackageReq, opts ...grpc.CallOption) (*ReadPackageResp, error)
+	UpdatePackage(ctx context.Context, in *UpdatePackageReq, opts ...grpc.CallOption) (*UpdatePackageResp, error)
+	DeletePackage(ctx context.Context, in *DeletePackageReq, opts ...grpc.CallOption) (*DeletePackageResp, error)
+	ListPackageSites(ctx context.Context, in *ListPackageSitesReq, opts ...grpc.CallOption) (*ListPackageSitesResp, error)
+	ListPackages(ctx context.Context, in *ListPackagesReq, opts ...grpc.CallOption) (StoreService_ListPackagesClient, error)
+	ReadRepository(ctx context.Context, in *ReadRepositoryReq, opts ...grpc.CallOption) (*ReadRepositoryResp, error)
+	UpdateRepository(ctx context.Context, in *UpdateRepositoryReq, opts ...grpc.CallOption) (*UpdateRepositoryResp, error)
+	DeleteRepository(ctx context.Context, in *DeleteRepositoryReq, opts ...grpc.CallOption) (*DeleteRepositoryResp, error)
+	ListRepositorySites(ctx context.Context, in *ListRepositorySitesReq, opts ...grpc.CallOption) (*ListRepositorySitesResp, error)
+	ListRepositories(ctx context.Context, in *ListRepositoriesReq, opts ...grpc.CallOption) (StoreService_ListRepositoriesClient, error)
+	ReadPerson(ctx context.Context, in *ReadPersonReq, opts ...grpc.CallOption) (*ReadPersonResp, error)
+	UpdatePerson(ctx context.Context, in *UpdatePersonReq, opts ...grpc.CallOption) (*UpdatePersonResp, error)
+	DeletePerson(ctx context.Context, in *DeletePersonReq, opts ...grpc.CallOption) (*DeletePersonResp, error)
+	AppendPackageEvent(ctx context.Context, in *AppendPackageEventReq, opts ...grpc.CallOption) (*AppendPackageEventResp, error)
+	ReadHistory(ctx context.Context, in *ReadHistoryReq, opts ...grpc.CallOption) (*ReadHistoryResp, error)
+	UpdateHistory(ctx context.Context, in *UpdateHistoryReq, opts ...grpc.CallOption) (*UpdateHistoryResp, error)
+	DeleteHistory(ctx context.Context, in *DeleteHistoryReq, opts ...grpc.CallOption) (*DeleteHistoryResp, error)
+	WatchChanges(ctx context.Context, in *WatchChangesReq, opts ...grpc.CallOption) (StoreService_WatchChangesClient, error)
+}
+
+type storeServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewStoreServiceClient(cc *grpc.ClientConn) StoreServiceClient {
+	return &storeServiceClient{cc}
+}
+
+func (c *storeServiceClient) PackageCrawlHistory(ctx context.Context, in *PackageCrawlHistoryReq, opts ...grpc.CallOption) (*PackageCrawlHistoryResp, error) {
+	out := new(PackageCrawlHistoryResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/PackageCrawlHistory", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) ReadPackage(ctx context.Context, in *ReadPackageReq, opts ...grpc.CallOption) (*ReadPackageResp, error) {
+	out := new(ReadPackageResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/ReadPackage", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) UpdatePackage(ctx context.Context, in *UpdatePackageReq, opts ...grpc.CallOption) (*UpdatePackageResp, error) {
+	out := new(UpdatePackageResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/UpdatePackage", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) DeletePackage(ctx context.Context, in *DeletePackageReq, opts ...grpc.CallOption) (*DeletePackageResp, error) {
+	out := new(DeletePackageResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/DeletePackage", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) ListPackageSites(ctx context.Context, in *ListPackageSitesReq, opts ...grpc.CallOption) (*ListPackageSitesResp, error) {
+	out := new(ListPackageSitesResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/ListPackageSites", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) ListPackages(ctx context.Context, in *ListPackagesReq, opts ...grpc.CallOption) (StoreService_ListPackagesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_StoreService_serviceDesc.Streams[0], c.cc, "/gcse.StoreService/ListPackages", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storeServiceListPackagesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type StoreService_ListPackagesClient interface {
+	Recv() (*ListPackagesResp, error)
+	grpc.ClientStream
+}
+
+type storeServiceListPackagesClient struct {
+	grpc.ClientStream
+}
+
+func (x *storeServiceListPackagesClient) Recv() (*ListPackagesResp, error) {
+	m := new(ListPackagesResp)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storeServiceClient) ReadRepository(ctx context.Context, in *ReadRepositoryReq, opts ...grpc.CallOption) (*ReadRepositoryResp, error) {
+	out := new(ReadRepositoryResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/ReadRepository", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) UpdateRepository(ctx context.Context, in *UpdateRepositoryReq, opts ...grpc.CallOption) (*UpdateRepositoryResp, error) {
+	out := new(UpdateRepositoryResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/UpdateRepository", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) DeleteRepository(ctx context.Context, in *DeleteRepositoryReq, opts ...grpc.CallOption) (*DeleteRepositoryResp, error) {
+	out := new(DeleteRepositoryResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/DeleteRepository", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) ListRepositorySites(ctx context.Context, in *ListRepositorySitesReq, opts ...grpc.CallOption) (*ListRepositorySitesResp, error) {
+	out := new(ListRepositorySitesResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/ListRepositorySites", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) ListRepositories(ctx context.Context, in *ListRepositoriesReq, opts ...grpc.CallOption) (StoreService_ListRepositoriesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_StoreService_serviceDesc.Streams[1], c.cc, "/gcse.StoreService/ListRepositories", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storeServiceListRepositoriesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type StoreService_ListRepositoriesClient interface {
+	Recv() (*ListRepositoriesResp, error)
+	grpc.ClientStream
+}
+
+type storeServiceListRepositoriesClient struct {
+	grpc.ClientStream
+}
+
+func (x *storeServiceListRepositoriesClient) Recv() (*ListRepositoriesResp, error) {
+	m := new(ListRepositoriesResp)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storeServiceClient) ReadPerson(ctx context.Context, in *ReadPersonReq, opts ...grpc.CallOption) (*ReadPersonResp, error) {
+	out := new(ReadPersonResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/ReadPerson", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) UpdatePerson(ctx context.Context, in *UpdatePersonReq, opts ...grpc.CallOption) (*UpdatePersonResp, error) {
+	out := new(UpdatePersonResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/UpdatePerson", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) DeletePerson(ctx context.Context, in *DeletePersonReq, opts ...grpc.CallOption) (*DeletePersonResp, error) {
+	out := new(DeletePersonResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/DeletePerson", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) AppendPackageEvent(ctx context.Context, in *AppendPackageEventReq, opts ...grpc.CallOption) (*AppendPackageEventResp, error) {
+	out := new(AppendPackageEventResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/AppendPackageEvent", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) ReadHistory(ctx context.Context, in *ReadHistoryReq, opts ...grpc.CallOption) (*ReadHistoryResp, error) {
+	out := new(ReadHistoryResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/ReadHistory", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) UpdateHistory(ctx context.Context, in *UpdateHistoryReq, opts ...grpc.CallOption) (*UpdateHistoryResp, error) {
+	out := new(UpdateHistoryResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/UpdateHistory", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) DeleteHistory(ctx context.Context, in *DeleteHistoryReq, opts ...grpc.CallOption) (*DeleteHistoryResp, error) {
+	out := new(DeleteHistoryResp)
+	err := grpc.Invoke(ctx, "/gcse.StoreService/DeleteHistory", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) WatchChanges(ctx context.Context, in *WatchChangesReq, opts ...grpc.CallOption) (StoreService_WatchChangesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_StoreService_serviceDesc.Streams[2], c.cc, "/gcse.StoreService/WatchChanges", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storeServiceWatchChangesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type StoreService_WatchChangesClient interface {
+	Recv() (*WatchChangesResp, error)
+	grpc.ClientStream
+}
+
+type storeServiceWatchChangesClient struct {
+	grpc.ClientStream
+}
+
+func (x *storeServiceWatchChangesClient) Recv() (*WatchChangesResp, error) {
+	m := new(WatchChangesResp)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for StoreService service
+
+type StoreServiceServer interface {
+	PackageCrawlHistory(context.Context, *PackageCrawlHistoryReq) (*PackageCrawlHistoryResp, error)
+	ReadPackage(context.Context, *ReadPackageReq) (*ReadPackageResp, error)
+	UpdatePackage(context.Context, *UpdatePackageReq) (*UpdatePackageResp, error)
+	DeletePackage(context.Context, *DeletePackageReq) (*DeletePackageResp, error)
+	ListPackageSites(context.Context, *ListPackageSitesReq) (*ListPackageSitesResp, error)
+	ListPackages(*ListPackagesReq, StoreService_ListPackagesServer) error
+	ReadRepository(context.Context, *ReadRepositoryReq) (*ReadRepositoryResp, error)
+	UpdateRepository(context.Context, *UpdateRepositoryReq) (*UpdateRepositoryResp, error)
+	DeleteRepository(context.Context, *DeleteRepositoryReq) (*DeleteRepositoryResp, error)
+	ListRepositorySites(context.Context, *ListRepositorySitesReq) (*ListRepositorySitesResp, error)
+	ListRepositories(*ListRepositoriesReq, StoreService_ListRepositoriesServer) error
+	ReadPerson(context.Context, *ReadPersonReq) (*ReadPersonResp, error)
+	UpdatePerson(context.Context, *UpdatePersonReq) (*UpdatePersonResp, error)
+	DeletePerson(context.Context, *DeletePersonReq) (*DeletePersonResp, error)
+	AppendPackageEvent(context.Context, *AppendPackageEventReq) (*AppendPackageEventResp, error)
+	ReadHistory(context.Context, *ReadHistoryReq) (*ReadHistoryResp, error)
+	UpdateHistory(context.Context, *UpdateHistoryReq) (*UpdateHistoryResp, error)
+	DeleteHistory(context.Context, *DeleteHistoryReq) (*DeleteHistoryResp, error)
+	WatchChanges(*WatchChangesReq, StoreService_WatchChangesServer) error
+}
+
+func RegisterStoreServiceServer(s *grpc.Server, srv StoreServiceServer) {
+	s.RegisterService(&_StoreService_serviceDesc, srv)
+}
+
+func _StoreService_PackageCrawlHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PackageCrawlHistoryReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).PackageCrawlHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/PackageCrawlHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).PackageCrawlHistory(ctx, req.(*PackageCrawlHistoryReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_ReadPackage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadPackageReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).ReadPackage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/ReadPackage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).ReadPackage(ctx, req.(*ReadPackageReq))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (m *PackageCrawlHistoryResp) Reset()                    { *m = PackageCrawlHistoryResp{} }
-func (m *PackageCrawlHistoryResp) String() string            { return proto.CompactTextString(m) }
-func (*PackageCrawlHistoryResp) ProtoMessage()               {}
-func (*PackageCrawlHistoryResp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{1} }
+func _StoreService_UpdatePackage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePackageReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).UpdatePackage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/UpdatePackage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).UpdatePackage(ctx, req.(*UpdatePackageReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
 
-func (m *PackageCrawlHistoryResp) GetInfo() *HistoryInfo {
-	if m != nil {
-		return m.Info
+func _StoreService_DeletePackage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePackageReq)
+	if err := dec(in); err != nil {
+		return nil, err
 	}
-	return nil
+	if interceptor == nil {
+		return srv.(StoreServiceServer).DeletePackage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/DeletePackage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).DeletePackage(ctx, req.(*DeletePackageReq))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func init() {
-	proto.RegisterType((*PackageCrawlHistoryReq)(nil), "gcse.PackageCrawlHistoryReq")
-	proto.RegisterType((*PackageCrawlHistoryResp)(nil), "gcse.PackageCrawlHistoryResp")
+func _StoreService_ListPackageSites_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPackageSitesReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).ListPackageSites(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/ListPackageSites",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).ListPackageSites(ctx, req.(*ListPackageSitesReq))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func _StoreService_ListPackages_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListPackagesReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StoreServiceServer).ListPackages(m, &storeServiceListPackagesServer{stream})
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+type StoreService_ListPackagesServer interface {
+	Send(*ListPackagesResp) error
+	grpc.ServerStream
+}
 
-// Client API for StoreService service
+type storeServiceListPackagesServer struct {
+	grpc.ServerStream
+}
 
-type StoreServiceClient interface {
-	PackageCrawlHistory(ctx context.Context, in *PackageCrawlHistoryReq, opts ...grpc.CallOption) (*PackageCrawlHistoryResp, error)
+func (x *storeServiceListPackagesServer) Send(m *ListPackagesResp) error {
+	return x.ServerStream.SendMsg(m)
 }
 
-type storeServiceClient struct {
-	cc *grpc.ClientConn
+func _StoreService_ReadRepository_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadRepositoryReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).ReadRepository(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/ReadRepository",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).ReadRepository(ctx, req.(*ReadRepositoryReq))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func NewStoreServiceClient(cc *grpc.ClientConn) StoreServiceClient {
-	return &storeServiceClient{cc}
+func _StoreService_UpdateRepository_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRepositoryReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).UpdateRepository(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/UpdateRepository",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).UpdateRepository(ctx, req.(*UpdateRepositoryReq))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *storeServiceClient) PackageCrawlHistory(ctx context.Context, in *PackageCrawlHistoryReq, opts ...grpc.CallOption) (*PackageCrawlHistoryResp, error) {
-	out := new(PackageCrawlHistoryResp)
-	err := grpc.Invoke(ctx, "/gcse.StoreService/PackageCrawlHistory", in, out, c.cc, opts...)
-	if err != nil {
+func _StoreService_DeleteRepository_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRepositoryReq)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(StoreServiceServer).DeleteRepository(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/DeleteRepository",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).DeleteRepository(ctx, req.(*DeleteRepositoryReq))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-// Server API for StoreService service
+func _StoreService_ListRepositorySites_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRepositorySitesReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).ListRepositorySites(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/ListRepositorySites",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).ListRepositorySites(ctx, req.(*ListRepositorySitesReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
 
-type StoreServiceServer interface {
-	PackageCrawlHistory(context.Context, *PackageCrawlHistoryReq) (*PackageCrawlHistoryResp, error)
+func _StoreService_ListRepositories_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRepositoriesReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StoreServiceServer).ListRepositories(m, &storeServiceListRepositoriesServer{stream})
 }
 
-func RegisterStoreServiceServer(s *grpc.Server, srv StoreServiceServer) {
-	s.RegisterService(&_StoreService_serviceDesc, srv)
+type StoreService_ListRepositoriesServer interface {
+	Send(*ListRepositoriesResp) error
+	grpc.ServerStream
 }
 
-func _StoreService_PackageCrawlHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PackageCrawlHistoryReq)
+type storeServiceListRepositoriesServer struct {
+	grpc.ServerStream
+}
+
+func (x *storeServiceListRepositoriesServer) Send(m *ListRepositoriesResp) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _StoreService_ReadPerson_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadPersonReq)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(StoreServiceServer).PackageCrawlHistory(ctx, in)
+		return srv.(StoreServiceServer).ReadPerson(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/gcse.StoreService/PackageCrawlHistory",
+		FullMethod: "/gcse.StoreService/ReadPerson",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(StoreServiceServer).PackageCrawlHistory(ctx, req.(*PackageCrawlHistoryReq))
+		return srv.(StoreServiceServer).ReadPerson(ctx, req.(*ReadPersonReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_UpdatePerson_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePersonReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).UpdatePerson(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/UpdatePerson",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).UpdatePerson(ctx, req.(*UpdatePersonReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_DeletePerson_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePersonReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).DeletePerson(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/DeletePerson",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).DeletePerson(ctx, req.(*DeletePersonReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_AppendPackageEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendPackageEventReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).AppendPackageEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/AppendPackageEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).AppendPackageEvent(ctx, req.(*AppendPackageEventReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_ReadHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadHistoryReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).ReadHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/ReadHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).ReadHistory(ctx, req.(*ReadHistoryReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_UpdateHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateHistoryReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).UpdateHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/UpdateHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).UpdateHistory(ctx, req.(*UpdateHistoryReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_DeleteHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteHistoryReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).DeleteHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcse.StoreService/DeleteHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).DeleteHistory(ctx, req.(*DeleteHistoryReq))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StoreService_WatchChanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchChangesReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StoreServiceServer).WatchChanges(m, &storeServiceWatchChangesServer{stream})
+}
+
+type StoreService_WatchChangesServer interface {
+	Send(*WatchChangesResp) error
+	grpc.ServerStream
+}
+
+type storeServiceWatchChangesServer struct {
+	grpc.ServerStream
+}
+
+func (x *storeServiceWatchChangesServer) Send(m *WatchChangesResp) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _StoreService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "gcse.StoreService",
 	HandlerType: (*StoreServiceServer)(nil),
@@ -121,8 +1640,84 @@ var _StoreService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "PackageCrawlHistory",
 			Handler:    _StoreService_PackageCrawlHistory_Handler,
 		},
+		{
+			MethodName: "ReadPackage",
+			Handler:    _StoreService_ReadPackage_Handler,
+		},
+		{
+			MethodName: "UpdatePackage",
+			Handler:    _StoreService_UpdatePackage_Handler,
+		},
+		{
+			MethodName: "DeletePackage",
+			Handler:    _StoreService_DeletePackage_Handler,
+		},
+		{
+			MethodName: "ListPackageSites",
+			Handler:    _StoreService_ListPackageSites_Handler,
+		},
+		{
+			MethodName: "ReadRepository",
+			Handler:    _StoreService_ReadRepository_Handler,
+		},
+		{
+			MethodName: "UpdateRepository",
+			Handler:    _StoreService_UpdateRepository_Handler,
+		},
+		{
+			MethodName: "DeleteRepository",
+			Handler:    _StoreService_DeleteRepository_Handler,
+		},
+		{
+			MethodName: "ListRepositorySites",
+			Handler:    _StoreService_ListRepositorySites_Handler,
+		},
+		{
+			MethodName: "ReadPerson",
+			Handler:    _StoreService_ReadPerson_Handler,
+		},
+		{
+			MethodName: "UpdatePerson",
+			Handler:    _StoreService_UpdatePerson_Handler,
+		},
+		{
+			MethodName: "DeletePerson",
+			Handler:    _StoreService_DeletePerson_Handler,
+		},
+		{
+			MethodName: "AppendPackageEvent",
+			Handler:    _StoreService_AppendPackageEvent_Handler,
+		},
+		{
+			MethodName: "ReadHistory",
+			Handler:    _StoreService_ReadHistory_Handler,
+		},
+		{
+			MethodName: "UpdateHistory",
+			Handler:    _StoreService_UpdateHistory_Handler,
+		},
+		{
+			MethodName: "DeleteHistory",
+			Handler:    _StoreService_DeleteHistory_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListPackages",
+			Handler:       _StoreService_ListPackages_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListRepositories",
+			Handler:       _StoreService_ListRepositories_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchChanges",
+			Handler:       _StoreService_WatchChanges_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "github.com/daviddengcn/gcse/shared/proto/stored.proto",
 }
 
@@ -131,19 +1726,81 @@ func init() {
 }
 
 var fileDescriptor2 = []byte{
-	// 210 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x32, 0x4d, 0xcf, 0x2c, 0xc9,
-	0x28, 0x4d, 0xd2, 0x4b, 0xce, 0xcf, 0xd5, 0x4f, 0x49, 0x2c, 0xcb, 0x4c, 0x49, 0x49, 0xcd, 0x4b,
-	0x4f, 0xce, 0xd3, 0x4f, 0x4f, 0x2e, 0x4e, 0xd5, 0x2f, 0xce, 0x48, 0x2c, 0x4a, 0x4d, 0xd1, 0x2f,
-	0x28, 0xca, 0x2f, 0xc9, 0xd7, 0x2f, 0x2e, 0xc9, 0x2f, 0x4a, 0x4d, 0xd1, 0x03, 0x73, 0x84, 0x58,
-	0x40, 0xf2, 0x52, 0x24, 0x68, 0x2e, 0xc8, 0x4c, 0x49, 0x2d, 0x82, 0x68, 0x56, 0x32, 0xe2, 0x12,
-	0x0b, 0x48, 0x4c, 0xce, 0x4e, 0x4c, 0x4f, 0x75, 0x2e, 0x4a, 0x2c, 0xcf, 0xf1, 0xc8, 0x04, 0x19,
-	0x5d, 0x19, 0x94, 0x5a, 0x28, 0x24, 0xc1, 0xc5, 0x5e, 0x00, 0x91, 0x91, 0x60, 0x54, 0x60, 0xd4,
-	0xe0, 0x0c, 0x82, 0x71, 0x95, 0x1c, 0xb8, 0xc4, 0xb1, 0xea, 0x29, 0x2e, 0x10, 0x52, 0xe5, 0x62,
-	0xc9, 0xcc, 0x4b, 0xcb, 0x07, 0xeb, 0xe0, 0x36, 0x12, 0xd4, 0x03, 0xd9, 0xae, 0x07, 0x55, 0xe0,
-	0x99, 0x97, 0x96, 0x1f, 0x04, 0x96, 0x36, 0x4a, 0xe2, 0xe2, 0x09, 0x06, 0x79, 0x21, 0x38, 0xb5,
-	0xa8, 0x2c, 0x33, 0x39, 0x55, 0x28, 0x88, 0x4b, 0x18, 0x8b, 0x89, 0x42, 0x32, 0x10, 0xfd, 0xd8,
-	0x1d, 0x28, 0x25, 0x8b, 0x47, 0xb6, 0xb8, 0xc0, 0x89, 0x23, 0x8a, 0x0d, 0x24, 0x5f, 0x90, 0x94,
-	0xc4, 0x06, 0xf6, 0xaa, 0x31, 0x20, 0x00, 0x00, 0xff, 0xff, 0x4d, 0x64, 0x41, 0x27, 0x60, 0x01,
-	0x00, 0x00,
+	// 1213 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0x5f, 0x6f, 0x1b, 0x45,
+	0x10, 0xd7, 0xd9, 0x8e, 0x6b, 0x4f, 0xd3, 0xd8, 0x59, 0x3b, 0xb6, 0xbb, 0x6d, 0x50, 0x74, 0x02,
+	0x14, 0x10, 0xb2, 0xab, 0x94, 0x42, 0x54, 0x54, 0x41, 0x49, 0x2b, 0x51, 0x51, 0x68, 0xb9, 0x04,
+	0x55, 0xe2, 0x25, 0x3a, 0xfb, 0xd6, 0xce, 0xa9, 0xf6, 0xdd, 0xe6, 0xf6, 0x9c, 0x34, 0x95, 0xf8,
+	0x10, 0x48, 0xbc, 0xf3, 0x21, 0xf8, 0x72, 0x3c, 0xa2, 0xdb, 0x3f, 0xe7, 0xbd, 0xf3, 0x9e, 0x1b,
+	0x57, 0x79, 0xdb, 0x9d, 0xff, 0x33, 0x3b, 0x3b, 0xf3, 0x83, 0x47, 0x13, 0x3f, 0x3e, 0x9b, 0x0f,
+	0xfb, 0xa3, 0x70, 0x36, 0xf0, 0xdc, 0x0b, 0xdf, 0xf3, 0x48, 0x30, 0x19, 0x05, 0x83, 0xc9, 0x88,
+	0x91, 0x01, 0x3b, 0x73, 0x23, 0xe2, 0x0d, 0x68, 0x14, 0xc6, 0xe1, 0x80, 0xc5, 0x61, 0x44, 0xbc,
+	0x3e, 0xbf, 0xa0, 0x4a, 0xc2, 0xc7, 0x6b, 0x28, 0x53, 0xdf, 0x23, 0x91, 0x50, 0xc6, 0x5f, 0xaf,
+	0xe7, 0x53, 0x6a, 0x7d, 0xa7, 0x69, 0x4d, 0xc2, 0xa9, 0x1b, 0x4c, 0x84, 0xd0, 0x70, 0x3e, 0x1e,
+	0xd0, 0xf8, 0x8a, 0x12, 0x36, 0x88, 0xfd, 0x19, 0x61, 0xb1, 0x3b, 0xa3, 0x8b, 0x93, 0x50, 0xb6,
+	0x03, 0xe8, 0xbc, 0x76, 0x47, 0x6f, 0xdd, 0x09, 0x39, 0x8a, 0xdc, 0xcb, 0xe9, 0x4f, 0x7e, 0x62,
+	0xf9, 0xca, 0x21, 0xe7, 0xa8, 0x07, 0xb7, 0xa8, 0xe0, 0xf4, 0xac, 0x3d, 0x6b, 0xbf, 0xee, 0xa8,
+	0x2b, 0xba, 0x07, 0x75, 0xea, 0x4e, 0xc8, 0x29, 0xf3, 0xdf, 0x93, 0x5e, 0x69, 0xcf, 0xda, 0xdf,
+	0x70, 0x6a, 0x09, 0xe1, 0xd8, 0x7f, 0x4f, 0xd0, 0x2e, 0x00, 0x67, 0xc6, 0xe1, 0x5b, 0x12, 0xf4,
+	0xca, 0x5c, 0x93, 0x8b, 0x9f, 0x24, 0x04, 0xfb, 0x6f, 0x0b, 0xba, 0x46, 0x87, 0x8c, 0xa2, 0xcf,
+	0xa0, 0xe2, 0x07, 0xe3, 0x90, 0xbb, 0xbb, 0x7d, 0xb0, 0xdd, 0x4f, 0xd2, 0xee, 0x4b, 0x81, 0x17,
+	0xc1, 0x38, 0x74, 0x38, 0x1b, 0x7d, 0x09, 0x55, 0x72, 0x41, 0x82, 0x98, 0xf5, 0x4a, 0x7b, 0xe5,
+	0xfd, 0xdb, 0x07, 0x28, 0x23, 0xf8, 0x3c, 0x61, 0x39, 0x52, 0x02, 0x7d, 0x0e, 0x8d, 0x80, 0xbc,
+	0x8b, 0x4f, 0x97, 0x42, 0xba, 0x93, 0x90, 0x5f, 0xa7, 0x61, 0x1d, 0xc2, 0x96, 0x43, 0x5c, 0x4f,
+	0x46, 0x96, 0xa4, 0x8f, 0xa0, 0xc2, 0xfc, 0x58, 0xe5, 0xce, 0xcf, 0x09, 0x8d, 0xba, 0xf1, 0x19,
+	0xcf, 0xb9, 0xee, 0xf0, 0xb3, 0x7d, 0x02, 0x8d, 0x8c, 0x66, 0x51, 0x1e, 0x52, 0x40, 0xcb, 0x03,
+	0x43, 0x2d, 0x22, 0x17, 0x3e, 0xf3, 0xc3, 0x80, 0x5b, 0x2c, 0x3b, 0xe9, 0xdd, 0xfe, 0x13, 0x9a,
+	0xbf, 0x53, 0xcf, 0x8d, 0xc9, 0xfa, 0x11, 0xa5, 0xee, 0xcb, 0xd7, 0x77, 0x5f, 0xc9, 0xb9, 0x1f,
+	0xc0, 0x76, 0xce, 0x3d, 0xa3, 0x19, 0x05, 0x2b, 0xa7, 0xf0, 0x18, 0x9a, 0xcf, 0xc8, 0x94, 0x7c,
+	0x4c, 0xbc, 0x76, 0x0b, 0xb6, 0x73, 0xba, 0x8c, 0xda, 0x3b, 0xd0, 0x7a, 0xe9, 0xb3, 0x58, 0x92,
+	0x8e, 0xfd, 0x98, 0x30, 0x87, 0x9c, 0xdb, 0x5f, 0x41, 0x7b, 0x99, 0xcc, 0x28, 0x6a, 0xc3, 0x46,
+	0x62, 0x9f, 0xf5, 0xac, 0xbd, 0xf2, 0x7e, 0xdd, 0x11, 0x17, 0xfb, 0x09, 0x34, 0x34, 0x69, 0x56,
+	0x14, 0x54, 0x07, 0xaa, 0x34, 0x22, 0x63, 0xff, 0x9d, 0x0c, 0x4b, 0xde, 0xec, 0x5f, 0xa0, 0x99,
+	0x55, 0x67, 0x34, 0x4d, 0xc0, 0x32, 0x14, 0xbc, 0xb4, 0xb2, 0xe0, 0xf6, 0x2b, 0xd8, 0x4e, 0x3a,
+	0xc5, 0x21, 0x34, 0x64, 0xbe, 0xfa, 0x65, 0x05, 0x45, 0x9a, 0x33, 0x12, 0xa9, 0x22, 0x25, 0xe7,
+	0x84, 0x16, 0x11, 0x1a, 0xca, 0xee, 0xe5, 0x67, 0x7b, 0x08, 0x28, 0x6f, 0x90, 0x51, 0xf4, 0x00,
+	0x20, 0x4a, 0x29, 0xb2, 0x07, 0x9b, 0x22, 0x26, 0x4d, 0x52, 0x93, 0x59, 0xd9, 0x88, 0xff, 0x58,
+	0xd0, 0x12, 0xad, 0x70, 0x63, 0x71, 0xe7, 0x22, 0xac, 0xac, 0x19, 0xe1, 0x46, 0x2e, 0xc2, 0x03,
+	0x68, 0x2f, 0x07, 0xf8, 0x81, 0x76, 0xfd, 0x0d, 0x5a, 0xa2, 0xe5, 0x6e, 0xee, 0x31, 0x3a, 0xd0,
+	0x5e, 0x36, 0xc9, 0xa8, 0xdd, 0x83, 0x4e, 0xd2, 0x44, 0x0b, 0x6a, 0xda, 0xcb, 0x03, 0xe8, 0x1a,
+	0x39, 0x85, 0xed, 0xfc, 0x05, 0xb4, 0x32, 0x0a, 0x7e, 0x61, 0x4b, 0xdb, 0x14, 0xda, 0xcb, 0xa2,
+	0xa2, 0x7d, 0x79, 0x36, 0x56, 0x36, 0x9b, 0xc0, 0x9d, 0x11, 0x95, 0x61, 0x72, 0xce, 0x3d, 0x51,
+	0xf9, 0xc3, 0x4f, 0x64, 0x3f, 0x84, 0x3b, 0x7c, 0x0e, 0x92, 0x88, 0x85, 0x41, 0x51, 0x31, 0xb7,
+	0xa0, 0xe4, 0x7b, 0xd2, 0x51, 0xc9, 0xf7, 0x6c, 0x07, 0xb6, 0x74, 0x25, 0x46, 0xd1, 0xa7, 0x99,
+	0xd9, 0x29, 0x5d, 0x0a, 0xfe, 0x35, 0x47, 0xe7, 0x25, 0x34, 0xe4, 0xec, 0x5a, 0x27, 0x94, 0xd4,
+	0x71, 0xf9, 0xda, 0x8e, 0xf3, 0x43, 0xb3, 0x9f, 0xce, 0xec, 0x45, 0x3a, 0xab, 0x9a, 0xf0, 0x11,
+	0x34, 0xe4, 0xdc, 0x5b, 0xab, 0x66, 0x08, 0x9a, 0x59, 0x35, 0x46, 0xed, 0xff, 0x2c, 0xd8, 0x79,
+	0x4a, 0x29, 0x09, 0xd4, 0x1e, 0x12, 0x5b, 0x70, 0x8d, 0xa5, 0x71, 0x0f, 0xea, 0xe3, 0x70, 0x1e,
+	0x78, 0xa7, 0x97, 0xee, 0x95, 0xec, 0xeb, 0x1a, 0x27, 0xbc, 0x71, 0xaf, 0xd0, 0x21, 0xd4, 0x53,
+	0xdc, 0x20, 0xff, 0x2b, 0xee, 0x4f, 0xc2, 0x70, 0x32, 0x95, 0x18, 0x64, 0x38, 0x1f, 0xf7, 0x4f,
+	0x94, 0x84, 0xb3, 0x10, 0x46, 0xdf, 0x40, 0xd5, 0x1d, 0xc5, 0xea, 0xdb, 0x6e, 0x1d, 0x7c, 0xb2,
+	0xbc, 0xab, 0xfb, 0x4f, 0xb9, 0x40, 0xff, 0x79, 0x30, 0x9f, 0x39, 0x52, 0x1a, 0xed, 0xc3, 0x06,
+	0xdf, 0xe0, 0xbd, 0xea, 0x9e, 0x55, 0xb0, 0xe2, 0x85, 0x40, 0xf2, 0xbf, 0x4c, 0x99, 0x33, 0x6a,
+	0xbf, 0x14, 0xcd, 0xa5, 0x41, 0x9a, 0x64, 0xd0, 0xf3, 0xa2, 0xf1, 0x72, 0xd4, 0x1c, 0x79, 0x4b,
+	0x8b, 0x54, 0x5a, 0x2a, 0x7b, 0x39, 0x2d, 0xbb, 0xdc, 0xf3, 0x1f, 0x81, 0x57, 0x56, 0x35, 0xeb,
+	0x5f, 0x96, 0x6a, 0x9a, 0x9b, 0x09, 0x33, 0x8d, 0xa9, 0x72, 0xfd, 0x98, 0x36, 0x8a, 0x96, 0xbf,
+	0x9e, 0xeb, 0xaa, 0x46, 0xfe, 0x55, 0x75, 0xe4, 0x0d, 0x95, 0x3a, 0x05, 0x04, 0x5a, 0x00, 0xf6,
+	0x33, 0x68, 0xbc, 0x71, 0xe3, 0xd1, 0xd9, 0xd1, 0x99, 0x1b, 0xc8, 0x5d, 0x7e, 0x17, 0x6a, 0xe3,
+	0x28, 0x9c, 0x9d, 0x32, 0x72, 0x2e, 0x63, 0xba, 0x95, 0xdc, 0x8f, 0x85, 0xfb, 0x71, 0x38, 0x9d,
+	0x86, 0x97, 0xdc, 0x51, 0xcd, 0x91, 0x37, 0xfb, 0x10, 0x9a, 0x59, 0x2b, 0x7c, 0xe4, 0x54, 0x47,
+	0xfc, 0x2a, 0x1f, 0x72, 0x53, 0x14, 0x4d, 0x88, 0x38, 0x92, 0x77, 0xf0, 0x2f, 0xc0, 0xe6, 0x71,
+	0x1c, 0x46, 0xe4, 0x98, 0x44, 0x17, 0xfe, 0x88, 0x20, 0x07, 0x5a, 0x06, 0x20, 0x8b, 0xee, 0x67,
+	0xd6, 0x7f, 0x0e, 0x54, 0xe3, 0xdd, 0x15, 0x5c, 0x46, 0xd1, 0x63, 0xb8, 0xad, 0x81, 0x49, 0xd4,
+	0x56, 0x13, 0x57, 0x47, 0xa6, 0x78, 0xc7, 0x40, 0x65, 0x14, 0xfd, 0x00, 0x77, 0x32, 0x98, 0x0d,
+	0x75, 0x84, 0x5c, 0x1e, 0x47, 0xe2, 0xae, 0x91, 0x2e, 0x2c, 0x64, 0x80, 0x98, 0xb2, 0x90, 0x47,
+	0x76, 0xb8, 0x6b, 0xa4, 0x33, 0x8a, 0x5e, 0x64, 0x10, 0x13, 0xdf, 0x67, 0xe8, 0xae, 0x10, 0x36,
+	0xa0, 0x39, 0x8c, 0x8b, 0x58, 0x8c, 0xa2, 0xef, 0x61, 0x53, 0xa3, 0x33, 0xb4, 0xb3, 0x24, 0xcb,
+	0x4d, 0x74, 0x4c, 0x64, 0x46, 0x1f, 0x58, 0xe8, 0x48, 0x7c, 0xff, 0xc5, 0xba, 0x42, 0xdd, 0x45,
+	0xe1, 0x32, 0x7b, 0x1f, 0xf7, 0xcc, 0x0c, 0x91, 0x50, 0x1e, 0x5c, 0xa8, 0x84, 0x0c, 0xa8, 0x08,
+	0xe3, 0x22, 0x96, 0x30, 0x95, 0x07, 0x08, 0xca, 0x94, 0x01, 0x8b, 0x60, 0x5c, 0xc4, 0x62, 0x34,
+	0x69, 0x3d, 0x03, 0x72, 0x50, 0xad, 0x67, 0x86, 0x1b, 0x78, 0x77, 0x05, 0x97, 0x51, 0xf4, 0x33,
+	0x34, 0x33, 0x2c, 0x3f, 0xfb, 0x74, 0x39, 0xd0, 0x81, 0x71, 0x11, 0x8b, 0xd7, 0xfe, 0x5b, 0x80,
+	0xc5, 0x5e, 0x47, 0x2d, 0xad, 0x61, 0xd5, 0xaa, 0xc3, 0xed, 0x65, 0x22, 0xa3, 0xe8, 0x09, 0x6c,
+	0xea, 0x3b, 0x54, 0xbd, 0x7a, 0x6e, 0xa1, 0xe3, 0x8e, 0x89, 0x2c, 0xd4, 0xf5, 0xdd, 0xa8, 0xd4,
+	0x73, 0x6b, 0x16, 0x77, 0x4c, 0x64, 0x46, 0xd1, 0x2b, 0x40, 0xcb, 0xbb, 0x04, 0xdd, 0x13, 0xd2,
+	0xc6, 0xfd, 0x8a, 0xef, 0x17, 0x33, 0x17, 0xff, 0x59, 0xcd, 0x06, 0x2d, 0x67, 0x6d, 0x26, 0xec,
+	0x18, 0xa8, 0xfa, 0x7f, 0x56, 0xda, 0x99, 0xa4, 0x35, 0xfd, 0xae, 0x91, 0xae, 0xff, 0xe7, 0x9c,
+	0x85, 0xfc, 0xb0, 0xc6, 0x5d, 0x23, 0x5d, 0x7c, 0x42, 0x7d, 0x5c, 0xaa, 0x7a, 0xe6, 0x06, 0x31,
+	0xee, 0x98, 0xc8, 0x49, 0x23, 0xfc, 0x58, 0xfb, 0xa3, 0x9a, 0xb0, 0xe8, 0x70, 0x58, 0xe5, 0x40,
+	0xe1, 0xe1, 0xff, 0x03, 0x00, 0x4c, 0x1e, 0x9f, 0x43, 0x58, 0x11, 0x00, 0x00,
 }
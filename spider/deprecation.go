@@ -0,0 +1,106 @@
+package spider
+
+import (
+	"regexp"
+	"strings"
+)
+
+const deprecatedPrefix = "Deprecated:"
+
+// DeprecationMessage returns the message of the "Deprecated:" paragraph in a
+// package or module doc comment, e.g. "Use golang.org/x/net/context instead."
+// An empty string is returned if the doc is not deprecated.
+func DeprecationMessage(doc string) string {
+	var para []string
+	found := false
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if found {
+			if line == "" {
+				break
+			}
+			para = append(para, line)
+			continue
+		}
+		if strings.HasPrefix(line, deprecatedPrefix) {
+			found = true
+			para = append(para, strings.TrimSpace(line[len(deprecatedPrefix):]))
+		}
+	}
+	if !found {
+		return ""
+	}
+	msg := strings.TrimSpace(strings.Join(para, " "))
+	if msg == "" {
+		// Still deprecated even if no message is given.
+		msg = "deprecated"
+	}
+	return msg
+}
+
+var patImportPath = regexp.MustCompile(`\b[a-z0-9-]+(\.[a-z0-9-]+)+(/[A-Za-z0-9_.~-]+)+`)
+
+// SuggestedReplacement returns the first import path mentioned in a
+// deprecation message, which by convention is the package to use instead.
+// An empty string is returned if none is found.
+func SuggestedReplacement(msg string) string {
+	return strings.TrimRight(patImportPath.FindString(msg), ".")
+}
+
+// GoModInfo is the deprecation related information in a go.mod file.
+type GoModInfo struct {
+	// Message of the "// Deprecated:" comment of the module directive.
+	Deprecated string
+	// The retracted versions or version ranges, e.g. "v1.0.1" or
+	// "[v1.1.0, v1.1.3]".
+	Retracted []string
+}
+
+// stripComment returns the line without the trailing comment and the comment
+// text.
+func stripComment(line string) (string, string) {
+	if p := strings.Index(line, "//"); p >= 0 {
+		return strings.TrimSpace(line[:p]), strings.TrimSpace(line[p+2:])
+	}
+	return strings.TrimSpace(line), ""
+}
+
+// ParseGoMod extracts the module deprecation and the retract directives from
+// the contents of a go.mod file. The module is deprecated if the comment block
+// right before the module directive, or the comment at the end of its line,
+// contains a "Deprecated:" paragraph.
+func ParseGoMod(body string) GoModInfo {
+	var info GoModInfo
+	var comments []string
+	inRetract := false
+	for _, line := range strings.Split(body, "\n") {
+		stmt, comment := stripComment(line)
+		if stmt == "" {
+			if comment != "" {
+				comments = append(comments, comment)
+			} else {
+				comments = nil
+			}
+			continue
+		}
+		switch {
+		case inRetract:
+			if stmt == ")" {
+				inRetract = false
+			} else {
+				info.Retracted = append(info.Retracted, stmt)
+			}
+		case stmt == "module" || strings.HasPrefix(stmt, "module ") || strings.HasPrefix(stmt, "module\t"):
+			if comment != "" {
+				comments = append(comments, comment)
+			}
+			info.Deprecated = DeprecationMessage(strings.Join(comments, "\n"))
+		case stmt == "retract (" || stmt == "retract(":
+			inRetract = true
+		case strings.HasPrefix(stmt, "retract ") || strings.HasPrefix(stmt, "retract\t"):
+			info.Retracted = append(info.Retracted, strings.TrimSpace(stmt[len("retract"):]))
+		}
+		comments = nil
+	}
+	return info
+}
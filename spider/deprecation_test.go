@@ -0,0 +1,49 @@
+package spider
+
+import (
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func TestDeprecationMessage(t *testing.T) {
+	assert.Equal(t, "not deprecated", DeprecationMessage("Package a does things.\n"), "")
+	assert.Equal(t, "deprecated", DeprecationMessage(`Package context defines the Context type.
+
+Deprecated: Use the standard library
+context package instead.
+
+Other docs.
+`), "Use the standard library context package instead.")
+	assert.Equal(t, "no message", DeprecationMessage("Deprecated:\n"), "deprecated")
+}
+
+func TestSuggestedReplacement(t *testing.T) {
+	assert.Equal(t, "replacement", SuggestedReplacement("Use golang.org/x/net/context instead."), "golang.org/x/net/context")
+	assert.Equal(t, "replacement", SuggestedReplacement("moved to github.com/a/b."), "github.com/a/b")
+	assert.Equal(t, "no replacement", SuggestedReplacement("no longer maintained"), "")
+}
+
+func TestParseGoMod(t *testing.T) {
+	assert.Equal(t, "GoModInfo", ParseGoMod(`// Deprecated: use example.com/mod/v2 instead.
+module example.com/mod
+
+go 1.16
+
+require golang.org/x/net v0.0.1
+
+retract v1.0.0 // Published accidentally.
+retract (
+	[v1.1.0, v1.1.3]
+	v1.2.0 // Bug.
+)
+`), GoModInfo{
+		Deprecated: "use example.com/mod/v2 instead.",
+		Retracted:  []string{"v1.0.0", "[v1.1.0, v1.1.3]", "v1.2.0"},
+	})
+
+	assert.Equal(t, "trailing comment", ParseGoMod("module example.com/mod // Deprecated: gone\n"), GoModInfo{
+		Deprecated: "gone",
+	})
+	assert.Equal(t, "separated comment", ParseGoMod("// Deprecated: gone\n\nmodule example.com/mod\n"), GoModInfo{})
+}
@@ -1,8 +1,13 @@
 package spider
 
 import (
+	"encoding/binary"
+	"errors"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/boltdb/bolt"
 	"github.com/golangplus/bytes"
 	"github.com/golangplus/errors"
 
@@ -10,55 +15,338 @@ import (
 	"github.com/golang/protobuf/proto"
 )
 
+// FileCache caches the contents, e.g. the parsed info, of files by their
+// signatures. path is the file referencing the signature, none if empty.
 type FileCache interface {
-	Get(signature string, contents proto.Message) bool
-	Set(signature string, contents proto.Message)
+	Get(path, signature string, contents proto.Message) bool
+	Set(path, signature string, contents proto.Message)
 }
 
 type NullFileCache struct{}
 
-func (NullFileCache) Get(string, proto.Message) bool { return false }
-func (NullFileCache) Set(string, proto.Message)      {}
+func (NullFileCache) Get(string, string, proto.Message) bool { return false }
+func (NullFileCache) Set(string, string, proto.Message)      {}
 
 var _ FileCache = NullFileCache{}
 
+// BoltFileCache is a FileCache in a bolt DB. A signature is released once no
+// path references it, and the least recently used ones are evicted when the
+// cache exceeds MaxEntries or MaxBytes.
+//
+// The access times and the hit/miss counts of Gets are kept in memory and
+// saved by Set, by Flush, and periodically, so that a Get is a read-only
+// transaction unless it binds a new path.
 type BoltFileCache struct {
 	bh.DB
 	IncCounter func(string)
+	// The maximum number of signatures, no limit if 0.
+	MaxEntries int
+	// The maximum total size of the contents, no limit if 0.
+	MaxBytes int64
+
+	mu        sync.Mutex
+	accessed  map[string]time.Time
+	hits      int64
+	misses    int64
+	lastFlush time.Time
 }
 
-var _ FileCache = BoltFileCache{}
+var _ FileCache = (*BoltFileCache)(nil)
+
+// The pending accesses are flushed once there are this many of them or the
+// last flush is older than accessFlushInterval.
+const (
+	accessFlushSize     = 1000
+	accessFlushInterval = time.Minute
+)
 
 // Filecache folders:
-// s/<path>             - signature of this path
-// c/<signature>        - contents of a signagure
-// p/<signature>/<path> - list of paths referencing this signature
+// s/<path>                  - signature of this path
+// c/<signature>             - contents of a signagure
+// p/<signature>/<path>      - list of paths referencing this signature
+// a/<signature>             - last access time of a signature
+// l/<access-time><signature> - signatures ordered by the last access time
+// m/<name>                  - statistics, e.g. entries and bytes
+//
+// Times are 8-byte big-endian UnixNanos, and so are the statistics.
 
 var (
 	cacheSignatureKey = []byte("s")
 	cacheContentsKey  = []byte("c")
 	cachePathsKey     = []byte("p")
+	cacheAccessKey    = []byte("a")
+	cacheLRUKey       = []byte("l")
+	cacheStatsKey     = []byte("m")
+)
+
+// Names of the statistics under cacheStatsKey.
+const (
+	cacheStatEntries = "entries"
+	cacheStatBytes   = "bytes"
+	cacheStatHits    = "hits"
+	cacheStatMisses  = "misses"
+	// Set once the bookkeeping of the entries is initialized.
+	cacheStatVersion = "version"
 )
 
-func (bc BoltFileCache) inc(name string) {
+const cacheVersion = 1
+
+var errStopIter = errors.New("stop iteration")
+
+// FileCacheStats is the statistics of a BoltFileCache.
+type FileCacheStats struct {
+	// The number of signatures cached.
+	Entries int64
+	// The total size of the contents.
+	Bytes  int64
+	Hits   int64
+	Misses int64
+}
+
+// HitRatio returns the ratio of the hits in all Gets, 0 if none.
+func (s FileCacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (bc *BoltFileCache) inc(name string) {
 	if bc.IncCounter == nil {
 		return
 	}
 	bc.IncCounter(name)
 }
 
-func (bc BoltFileCache) Get(sign string, contents proto.Message) bool {
-	found := false
+func (bc *BoltFileCache) incN(name string, n int) {
+	for i := 0; i < n; i++ {
+		bc.inc(name)
+	}
+}
+
+func encodeUint64(v uint64) []byte {
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], v)
+	return bs[:]
+}
+
+func readStat(tx bh.Tx, name string) (v int64) {
+	tx.Value([][]byte{cacheStatsKey, []byte(name)}, func(bs bytesp.Slice) error {
+		if len(bs) == 8 {
+			v = int64(binary.BigEndian.Uint64(bs))
+		}
+		return nil
+	})
+	return v
+}
+
+func addStat(tx bh.Tx, name string, delta int64) error {
+	return tx.Put([][]byte{cacheStatsKey, []byte(name)}, encodeUint64(uint64(readStat(tx, name)+delta)))
+}
+
+// readString returns the value of k as a string, empty if not found.
+func readString(tx bh.Tx, k [][]byte) (s string) {
+	tx.Value(k, func(v bytesp.Slice) error {
+		s = string(v)
+		return nil
+	})
+	return s
+}
+
+// cacheCounts is the numbers of the signatures released and evicted in a
+// transaction, reported after it is committed.
+type cacheCounts struct {
+	released, evicted int
+}
+
+func (bc *BoltFileCache) report(cnt cacheCounts) {
+	bc.incN("crawler.filecache.released", cnt.released)
+	bc.incN("crawler.filecache.evicted", cnt.evicted)
+}
+
+// touch moves sign to the most recently used end of the LRU list.
+func touch(tx bh.Tx, sign string, now time.Time) error {
+	aKey := [][]byte{cacheAccessKey, []byte(sign)}
+	var last []byte
+	tx.Value(aKey, func(v bytesp.Slice) error {
+		last = append([]byte(nil), v...)
+		return nil
+	})
+	if last != nil {
+		if err := tx.Delete([][]byte{cacheLRUKey, append(last, sign...)}); err != nil {
+			return err
+		}
+	}
+	t := encodeUint64(uint64(now.UnixNano()))
+	if err := tx.Put(aKey, t); err != nil {
+		return err
+	}
+	return tx.Put([][]byte{cacheLRUKey, append(t, sign...)}, nil)
+}
+
+// drop removes a signature with its contents and references.
+func drop(tx bh.Tx, sign string) error {
+	cKey := [][]byte{cacheContentsKey, []byte(sign)}
+	size, found := int64(0), false
+	tx.Value(cKey, func(v bytesp.Slice) error {
+		size, found = int64(len(v)), true
+		return nil
+	})
+	if found {
+		if err := tx.Delete(cKey); err != nil {
+			return err
+		}
+		if err := addStat(tx, cacheStatEntries, -1); err != nil {
+			return err
+		}
+		if err := addStat(tx, cacheStatBytes, -size); err != nil {
+			return err
+		}
+	}
+	aKey := [][]byte{cacheAccessKey, []byte(sign)}
+	var last []byte
+	tx.Value(aKey, func(v bytesp.Slice) error {
+		last = append([]byte(nil), v...)
+		return nil
+	})
+	if last != nil {
+		if err := tx.Delete([][]byte{cacheLRUKey, append(last, sign...)}); err != nil {
+			return err
+		}
+		if err := tx.Delete(aKey); err != nil {
+			return err
+		}
+	}
+	pKey := [][]byte{cachePathsKey, []byte(sign)}
+	if _, ok := tx.Bucket(pKey); !ok {
+		// Deleting a missing key next to a bucket fails in bolt.
+		return nil
+	}
+	var paths []string
+	if err := tx.ForEach(pKey, func(_ bh.Bucket, k, _ bytesp.Slice) error {
+		paths = append(paths, string(k))
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		sKey := [][]byte{cacheSignatureKey, []byte(path)}
+		if readString(tx, sKey) != sign {
+			continue
+		}
+		if err := tx.Delete(sKey); err != nil {
+			return err
+		}
+	}
+	return tx.Delete(pKey)
+}
+
+// bind makes path reference sign, releasing the signature path referenced
+// before if no other path references it.
+func bind(tx bh.Tx, path, sign string, cnt *cacheCounts) error {
+	if path == "" {
+		return nil
+	}
+	sKey := [][]byte{cacheSignatureKey, []byte(path)}
+	old := readString(tx, sKey)
+	if old == sign {
+		return nil
+	}
+	if err := tx.Put(sKey, []byte(sign)); err != nil {
+		return err
+	}
+	if err := tx.Put([][]byte{cachePathsKey, []byte(sign), []byte(path)}, nil); err != nil {
+		return err
+	}
+	if old == "" {
+		return nil
+	}
+	oldPaths := [][]byte{cachePathsKey, []byte(old)}
+	if err := tx.Delete(append(oldPaths, []byte(path))); err != nil {
+		return err
+	}
+	referenced := false
+	if err := tx.ForEach(oldPaths, func(bh.Bucket, bytesp.Slice, bytesp.Slice) error {
+		referenced = true
+		return errStopIter
+	}); err != nil && err != errStopIter {
+		return err
+	}
+	if referenced {
+		return nil
+	}
+	cnt.released++
+	return drop(tx, old)
+}
+
+// evict drops the least recently used signatures until the cache is within
+// the limits.
+func (bc *BoltFileCache) evict(tx bh.Tx, cnt *cacheCounts) error {
+	entries, bytes := readStat(tx, cacheStatEntries), readStat(tx, cacheStatBytes)
+	overLimits := func() bool {
+		return bc.MaxEntries > 0 && entries > int64(bc.MaxEntries) || bc.MaxBytes > 0 && bytes > bc.MaxBytes
+	}
+	if !overLimits() {
+		return nil
+	}
+	var signs []string
+	if err := tx.ForEach([][]byte{cacheLRUKey}, func(_ bh.Bucket, k, _ bytesp.Slice) error {
+		if !overLimits() {
+			return errStopIter
+		}
+		sign := string(k[8:])
+		tx.Value([][]byte{cacheContentsKey, []byte(sign)}, func(v bytesp.Slice) error {
+			entries, bytes = entries-1, bytes-int64(len(v))
+			return nil
+		})
+		signs = append(signs, sign)
+		return nil
+	}); err != nil && err != errStopIter {
+		return err
+	}
+	for _, sign := range signs {
+		if err := drop(tx, sign); err != nil {
+			return err
+		}
+	}
+	cnt.evicted += len(signs)
+	return nil
+}
+
+// Get reads the contents of a signature and marks it as referenced by path
+// and recently used.
+func (bc *BoltFileCache) Get(path, sign string, contents proto.Message) bool {
+	found, bound := false, path == ""
 	if err := bc.View(func(tx bh.Tx) error {
-		return tx.Value([][]byte{cacheContentsKey, []byte(sign)}, func(v bytesp.Slice) error {
+		if err := tx.Value([][]byte{cacheContentsKey, []byte(sign)}, func(v bytesp.Slice) error {
 			found = true
 			return errorsp.WithStacks(proto.Unmarshal(v, contents))
-		})
+		}); err != nil {
+			return err
+		}
+		if found && !bound {
+			bound = readString(tx, [][]byte{cacheSignatureKey, []byte(path)}) == sign
+		}
+		return nil
 	}); err != nil {
 		log.Printf("Reading from file cache DB for %v failed: %v", sign, err)
 		bc.inc("crawler.filecache.get_error")
 		return false
 	}
+	if found && !bound {
+		var cnt cacheCounts
+		// Batch coalesces the bindings of concurrent Gets into a transaction,
+		// and may call the func more than once.
+		if err := bc.DB.DB.Batch(func(tx *bolt.Tx) error {
+			cnt = cacheCounts{}
+			return bind(bh.Tx{Tx: tx}, path, sign, &cnt)
+		}); err != nil {
+			log.Printf("Binding %v to %v in file cache DB failed: %v", path, sign, err)
+			bc.inc("crawler.filecache.get_error")
+		}
+		bc.report(cnt)
+	}
+	bc.recordAccess(sign, found)
 	if found {
 		bc.inc("crawler.filecache.hit")
 	} else {
@@ -67,16 +355,225 @@ func (bc BoltFileCache) Get(sign string, contents proto.Message) bool {
 	return found
 }
 
-func (bc BoltFileCache) Set(signature string, contents proto.Message) {
+// recordAccess records a Get in memory, flushing the pending ones if there
+// are many or they are old.
+func (bc *BoltFileCache) recordAccess(sign string, found bool) {
+	bc.mu.Lock()
+	now := time.Now()
+	if bc.lastFlush.IsZero() {
+		bc.lastFlush = now
+	}
+	if found {
+		if bc.accessed == nil {
+			bc.accessed = make(map[string]time.Time)
+		}
+		bc.accessed[sign] = now
+		bc.hits++
+	} else {
+		bc.misses++
+	}
+	due := len(bc.accessed) >= accessFlushSize || now.Sub(bc.lastFlush) >= accessFlushInterval
+	bc.mu.Unlock()
+	if due {
+		if err := bc.Flush(); err != nil {
+			log.Printf("Flushing file cache accesses failed: %v", err)
+		}
+	}
+}
+
+// takeAccesses returns the pending accesses and resets them.
+func (bc *BoltFileCache) takeAccesses() (accessed map[string]time.Time, hits, misses int64) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	accessed, hits, misses = bc.accessed, bc.hits, bc.misses
+	bc.accessed, bc.hits, bc.misses = nil, 0, 0
+	bc.lastFlush = time.Now()
+	return accessed, hits, misses
+}
+
+// restoreAccesses puts back the accesses failed to be saved.
+func (bc *BoltFileCache) restoreAccesses(accessed map[string]time.Time, hits, misses int64) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.accessed == nil {
+		bc.accessed = make(map[string]time.Time)
+	}
+	for sign, t := range accessed {
+		if cur, ok := bc.accessed[sign]; !ok || cur.Before(t) {
+			bc.accessed[sign] = t
+		}
+	}
+	bc.hits, bc.misses = bc.hits+hits, bc.misses+misses
+}
+
+// flushAccesses saves the pending accesses in tx and returns a func putting
+// them back, to be called if tx is rolled back.
+func (bc *BoltFileCache) flushAccesses(tx bh.Tx) (func(), error) {
+	accessed, hits, misses := bc.takeAccesses()
+	undo := func() {
+		bc.restoreAccesses(accessed, hits, misses)
+	}
+	for sign, t := range accessed {
+		// The signature may have been dropped since.
+		exists := false
+		tx.Value([][]byte{cacheContentsKey, []byte(sign)}, func(bytesp.Slice) error {
+			exists = true
+			return nil
+		})
+		if !exists {
+			continue
+		}
+		if err := touch(tx, sign, t); err != nil {
+			return undo, err
+		}
+	}
+	if hits != 0 {
+		if err := addStat(tx, cacheStatHits, hits); err != nil {
+			return undo, err
+		}
+	}
+	if misses != 0 {
+		if err := addStat(tx, cacheStatMisses, misses); err != nil {
+			return undo, err
+		}
+	}
+	return undo, nil
+}
+
+// Flush saves the access times and the hit/miss counts of the Gets since the
+// last flush. It has to be called before closing the DB.
+func (bc *BoltFileCache) Flush() error {
+	bc.mu.Lock()
+	pending := len(bc.accessed) > 0 || bc.hits > 0 || bc.misses > 0
+	bc.mu.Unlock()
+	if !pending {
+		return nil
+	}
+	var undo func()
+	err := bc.Update(func(tx bh.Tx) (err error) {
+		undo, err = bc.flushAccesses(tx)
+		return err
+	})
+	if err != nil && undo != nil {
+		undo()
+	}
+	return errorsp.WithStacks(err)
+}
+
+// Set saves the contents of a signature referenced by path. The signature
+// path referenced before is released if no other path references it.
+func (bc *BoltFileCache) Set(path, signature string, contents proto.Message) {
+	var cnt cacheCounts
+	var undo func()
 	if err := bc.Update(func(tx bh.Tx) error {
+		// The pending accesses are saved first for evict to see them.
+		var err error
+		if undo, err = bc.flushAccesses(tx); err != nil {
+			return err
+		}
 		bs, err := proto.Marshal(contents)
 		if err != nil {
 			return errorsp.WithStacksAndMessage(err, "Marshal %v failed", contents)
 		}
-		return tx.Put([][]byte{cacheContentsKey, []byte(signature)}, bs)
+		cKey := [][]byte{cacheContentsKey, []byte(signature)}
+		oldSize, found := int64(0), false
+		tx.Value(cKey, func(v bytesp.Slice) error {
+			oldSize, found = int64(len(v)), true
+			return nil
+		})
+		if !found {
+			if err := addStat(tx, cacheStatEntries, 1); err != nil {
+				return err
+			}
+		}
+		if err := addStat(tx, cacheStatBytes, int64(len(bs))-oldSize); err != nil {
+			return err
+		}
+		if err := tx.Put(cKey, bs); err != nil {
+			return err
+		}
+		if err := bind(tx, path, signature, &cnt); err != nil {
+			return err
+		}
+		if err := touch(tx, signature, time.Now()); err != nil {
+			return err
+		}
+		return bc.evict(tx, &cnt)
 	}); err != nil {
+		if undo != nil {
+			undo()
+		}
 		bc.inc("crawler.filecache.set_error")
 		log.Printf("Updating to file cache DB for %v failed: %v", signature, err)
+		return
 	}
+	bc.report(cnt)
 	bc.inc("crawler.filecache.sign_saved")
 }
+
+// Init initializes the bookkeeping of a cache written before it was
+// introduced. The contents cached are counted and put at the least recently
+// used end so that they are evicted first.
+func (bc *BoltFileCache) Init() error {
+	return bc.Update(func(tx bh.Tx) error {
+		if readStat(tx, cacheStatVersion) >= cacheVersion {
+			return nil
+		}
+		var entries, bytes int64
+		var signs []string
+		if err := tx.ForEach([][]byte{cacheContentsKey}, func(_ bh.Bucket, k, v bytesp.Slice) error {
+			entries, bytes = entries+1, bytes+int64(len(v))
+			signs = append(signs, string(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, sign := range signs {
+			if err := touch(tx, sign, time.Unix(0, 0)); err != nil {
+				return err
+			}
+		}
+		for name, v := range map[string]int64{
+			cacheStatEntries: entries,
+			cacheStatBytes:   bytes,
+			cacheStatVersion: cacheVersion,
+		} {
+			if err := tx.Put([][]byte{cacheStatsKey, []byte(name)}, encodeUint64(uint64(v))); err != nil {
+				return err
+			}
+		}
+		log.Printf("File cache initialized with %d entries of %d bytes", entries, bytes)
+		return nil
+	})
+}
+
+// Stats returns the statistics of the cache, including the hits and misses not
+// flushed yet.
+func (bc *BoltFileCache) Stats() (FileCacheStats, error) {
+	bc.mu.Lock()
+	s := FileCacheStats{Hits: bc.hits, Misses: bc.misses}
+	bc.mu.Unlock()
+	err := bc.View(func(tx bh.Tx) error {
+		s.Entries = readStat(tx, cacheStatEntries)
+		s.Bytes = readStat(tx, cacheStatBytes)
+		s.Hits += readStat(tx, cacheStatHits)
+		s.Misses += readStat(tx, cacheStatMisses)
+		return nil
+	})
+	return s, errorsp.WithStacks(err)
+}
+
+// Lookup returns the signature referenced by path, empty if none, and all the
+// paths referencing it.
+func (bc *BoltFileCache) Lookup(path string) (sign string, paths []string, err error) {
+	err = bc.View(func(tx bh.Tx) error {
+		if sign = readString(tx, [][]byte{cacheSignatureKey, []byte(path)}); sign == "" {
+			return nil
+		}
+		return tx.ForEach([][]byte{cachePathsKey, []byte(sign)}, func(_ bh.Bucket, k, _ bytesp.Slice) error {
+			paths = append(paths, string(k))
+			return nil
+		})
+	})
+	return sign, paths, errorsp.WithStacks(err)
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golangplus/testing/assert"
 
 	"github.com/daviddengcn/bolthelper"
@@ -14,8 +15,8 @@ import (
 
 func TestNullFileCache(t *testing.T) {
 	c := NullFileCache{}
-	c.Set("", nil)
-	assert.False(t, "c.Get", c.Get("", nil))
+	c.Set("", "", nil)
+	assert.False(t, "c.Get", c.Get("", "", nil))
 }
 
 func TestBoltFileCache(t *testing.T) {
@@ -26,7 +27,7 @@ func TestBoltFileCache(t *testing.T) {
 	assert.NoErrorOrDie(t, err)
 
 	counter := make(map[string]int)
-	c := BoltFileCache{
+	c := &BoltFileCache{
 		DB: db,
 		IncCounter: func(name string) {
 			counter[name] = counter[name] + 1
@@ -47,18 +48,18 @@ func TestBoltFileCache(t *testing.T) {
 	// New file found.
 	//////////////////////////////////////////////////////////////
 	// Get before set, should return false
-	assert.False(t, "c.Get", c.Get(sign1, fi))
+	assert.False(t, "c.Get", c.Get(gofile, sign1, fi))
 	assert.Equal(t, "counter", counter, map[string]int{
 		"crawler.filecache.missed": 1,
 	})
 	// Set the info.
-	c.Set(sign1, &gpb.GoFileInfo{Status: gpb.GoFileInfo_ShouldIgnore})
+	c.Set(gofile, sign1, &gpb.GoFileInfo{Status: gpb.GoFileInfo_ShouldIgnore})
 	assert.Equal(t, "counter", counter, map[string]int{
 		"crawler.filecache.missed":     1,
 		"crawler.filecache.sign_saved": 1,
 	})
 	// Now, should fetch the cache
-	assert.True(t, "c.Get", c.Get(sign1, fi))
+	assert.True(t, "c.Get", c.Get(gofile, sign1, fi))
 	assert.Equal(t, "fi", fi, &gpb.GoFileInfo{Status: gpb.GoFileInfo_ShouldIgnore})
 	assert.Equal(t, "counter", counter, map[string]int{
 		"crawler.filecache.missed":     1,
@@ -66,3 +67,141 @@ func TestBoltFileCache(t *testing.T) {
 		"crawler.filecache.hit":        1,
 	})
 }
+
+func openTestFileCache(t *testing.T, name string) *BoltFileCache {
+	fn := filepath.Join(os.TempDir(), name+".bolt")
+	assert.NoErrorOrDie(t, os.RemoveAll(fn))
+
+	db, err := bh.Open(fn, 0755, nil)
+	assert.NoErrorOrDie(t, err)
+	return &BoltFileCache{DB: db}
+}
+
+func TestBoltFileCache_RefCount(t *testing.T) {
+	c := openTestFileCache(t, "TestBoltFileCache_RefCount")
+	defer c.Close()
+	counter := make(map[string]int)
+	c.IncCounter = func(name string) {
+		counter[name]++
+	}
+	fi := &gpb.GoFileInfo{}
+
+	c.Set("a.go", "s1", &gpb.GoFileInfo{Name: "a.go"})
+	// b.go has the same contents as a.go.
+	assert.True(t, "c.Get", c.Get("b.go", "s1", fi))
+	sign, paths, err := c.Lookup("b.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "sign", sign, "s1")
+	assert.StringEqual(t, "paths", paths, []string{"a.go", "b.go"})
+
+	// a.go changed, s1 is still referenced by b.go.
+	c.Set("a.go", "s2", &gpb.GoFileInfo{Name: "a.go"})
+	assert.True(t, "c.Get", c.Get("", "s1", fi))
+	assert.Equal(t, "released", counter["crawler.filecache.released"], 0)
+
+	// b.go changed, s1 is released.
+	c.Set("b.go", "s3", &gpb.GoFileInfo{Name: "b.go"})
+	assert.False(t, "c.Get", c.Get("", "s1", fi))
+	assert.Equal(t, "released", counter["crawler.filecache.released"], 1)
+	sign, paths, err = c.Lookup("b.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "sign", sign, "s3")
+	assert.StringEqual(t, "paths", paths, []string{"b.go"})
+
+	st, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, "st.Entries", st.Entries, int64(2))
+	assert.Equal(t, "st.Hits", st.Hits, int64(2))
+	assert.Equal(t, "st.Misses", st.Misses, int64(1))
+	assert.Equal(t, "st.HitRatio", st.HitRatio(), 2.0/3)
+}
+
+func TestBoltFileCache_Evict(t *testing.T) {
+	c := openTestFileCache(t, "TestBoltFileCache_Evict")
+	defer c.Close()
+	c.MaxEntries = 2
+	fi := &gpb.GoFileInfo{}
+
+	c.Set("a.go", "s1", &gpb.GoFileInfo{Name: "a.go"})
+	c.Set("b.go", "s2", &gpb.GoFileInfo{Name: "b.go"})
+	// s1 is used more recently than s2.
+	assert.True(t, "c.Get", c.Get("a.go", "s1", fi))
+	c.Set("c.go", "s3", &gpb.GoFileInfo{Name: "c.go"})
+
+	assert.False(t, "c.Get s2", c.Get("b.go", "s2", fi))
+	assert.True(t, "c.Get s1", c.Get("a.go", "s1", fi))
+	assert.True(t, "c.Get s3", c.Get("c.go", "s3", fi))
+	sign, _, err := c.Lookup("b.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "sign", sign, "")
+
+	// Limits the size to the one of s3.
+	st, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, "st.Entries", st.Entries, int64(2))
+	c.MaxBytes = st.Bytes / 2
+	c.Set("c.go", "s3", &gpb.GoFileInfo{Name: "c.go"})
+	st, err = c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, "st.Entries", st.Entries, int64(1))
+	assert.Equal(t, "st.Bytes", st.Bytes, c.MaxBytes)
+	assert.True(t, "c.Get s3", c.Get("c.go", "s3", fi))
+}
+
+func TestBoltFileCache_Init(t *testing.T) {
+	c := openTestFileCache(t, "TestBoltFileCache_Init")
+	defer c.Close()
+	// Contents written without the bookkeeping.
+	assert.NoError(t, c.Update(func(tx bh.Tx) error {
+		bs, err := proto.Marshal(&gpb.GoFileInfo{Name: "a"})
+		if err != nil {
+			return err
+		}
+		return tx.Put([][]byte{cacheContentsKey, []byte("s1")}, bs)
+	}))
+	assert.NoError(t, c.Init())
+	assert.NoError(t, c.Init())
+	st, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, "st", st, FileCacheStats{Entries: 1, Bytes: 3})
+
+	// The old contents are evicted first.
+	c.MaxEntries = 1
+	c.Set("a.go", "s2", &gpb.GoFileInfo{Name: "a.go"})
+	assert.False(t, "c.Get s1", c.Get("", "s1", &gpb.GoFileInfo{}))
+	assert.True(t, "c.Get s2", c.Get("a.go", "s2", &gpb.GoFileInfo{}))
+}
+
+func TestBoltFileCache_Flush(t *testing.T) {
+	c := openTestFileCache(t, "TestBoltFileCache_Flush")
+	defer c.Close()
+	fi := &gpb.GoFileInfo{}
+
+	c.Set("a.go", "s1", &gpb.GoFileInfo{Name: "a.go"})
+	assert.True(t, "c.Get", c.Get("a.go", "s1", fi))
+	assert.False(t, "c.Get", c.Get("b.go", "s2", fi))
+	savedStats := func() (hits, misses int64) {
+		assert.NoError(t, c.View(func(tx bh.Tx) error {
+			hits, misses = readStat(tx, cacheStatHits), readStat(tx, cacheStatMisses)
+			return nil
+		}))
+		return hits, misses
+	}
+	// The accesses are kept in memory until flushed.
+	hits, misses := savedStats()
+	assert.Equal(t, "hits", hits, int64(0))
+	assert.Equal(t, "misses", misses, int64(0))
+	st, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, "st.Hits", st.Hits, int64(1))
+	assert.Equal(t, "st.Misses", st.Misses, int64(1))
+
+	assert.NoError(t, c.Flush())
+	hits, misses = savedStats()
+	assert.Equal(t, "hits", hits, int64(1))
+	assert.Equal(t, "misses", misses, int64(1))
+	st, err = c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, "st.Hits", st.Hits, int64(1))
+	assert.Equal(t, "st.Misses", st.Misses, int64(1))
+}
@@ -2,6 +2,8 @@ package github
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"go/ast"
@@ -10,13 +12,19 @@ import (
 	"log"
 	"net/http"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	gpb "github.com/daviddengcn/gcse/shared/proto"
 	"github.com/daviddengcn/gcse/spider"
+	"github.com/daviddengcn/gcse/spider/license"
 	"github.com/daviddengcn/gddo/doc"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golangplus/bytes"
 	"github.com/golangplus/errors"
@@ -38,6 +46,7 @@ type Spider struct {
 	client *github.Client
 
 	FileCache     spider.FileCache
+	goMods        goModCache
 	accessToken   string
 	httpClient    doc.HttpClient
 	lastRateCheck time.Time
@@ -199,15 +208,36 @@ func repoInfoFromGithub(repo *github.Repository) *gpb.RepoInfo {
 	ri := &gpb.RepoInfo{
 		Description: stringsp.Get(repo.Description),
 		Stars:       int32(getInt(repo.StargazersCount)),
+		Forks:       int32(getInt(repo.ForksCount)),
 	}
 	ri.CrawlingTime, _ = ptypes.TimestampProto(time.Now())
 	ri.LastUpdated, _ = ptypes.TimestampProto(getTimestamp(repo.PushedAt).Time)
 	if repo.Source != nil {
-		ri.Source = stringsp.Get(repo.Source.Name)
+		ri.Source = "github.com/" + stringsp.Get(repo.Source.FullName)
 	}
+	ri.Archived = getBool(repo.Archived)
+	ri.MirrorUrl = stringsp.Get(repo.MirrorURL)
+	ri.License = licenseFromGithub(repo.License)
 	return ri
 }
 
+// licenseFromGithub converts the license metadata detected by github. nil is
+// returned if github cannot tell, i.e. the SPDX ID is "NOASSERTION".
+func licenseFromGithub(l *github.License) *gpb.LicenseInfo {
+	if l == nil {
+		return nil
+	}
+	spdx := stringsp.Get(l.SPDXID)
+	if spdx == "" || spdx == "NOASSERTION" {
+		return nil
+	}
+	return &gpb.LicenseInfo{
+		SpdxId:     spdx,
+		Confidence: 1,
+		Source:     "github.com",
+	}
+}
+
 func (s *Spider) ReadUser(ctx context.Context, name string) (*User, error) {
 	s.waitForRate()
 	repos, _, err := s.client.Repositories.List(ctx, name, nil)
@@ -228,7 +258,11 @@ func (s *Spider) ReadUser(ctx context.Context, name string) (*User, error) {
 	return user, nil
 }
 
-func (s *Spider) ReadRepository(ctx context.Context, user string, name string) (*gpb.RepoInfo, error) {
+// ReadRepository reads the information of a repository. prev is the stored
+// information of it, nil if none. The dates of the latest commit and release
+// cost two more API calls, so they are copied from prev if the repository
+// has not been pushed since.
+func (s *Spider) ReadRepository(ctx context.Context, user string, name string, prev *gpb.RepoInfo) (*gpb.RepoInfo, error) {
 	s.waitForRate()
 	repo, _, err := s.client.Repositories.Get(ctx, user, name)
 	if err != nil {
@@ -237,7 +271,66 @@ func (s *Spider) ReadRepository(ctx context.Context, user string, name string) (
 		}
 		return nil, errorsp.WithStacks(err)
 	}
-	return repoInfoFromGithub(repo), nil
+	ri := repoInfoFromGithub(repo)
+	if activityKnown(prev, ri) {
+		ri.LatestCommit, ri.LatestRelease = prev.LatestCommit, prev.LatestRelease
+		return ri, nil
+	}
+	// The activity dates are for the freshness only, so errors are logged
+	// rather than failing the whole repository.
+	if t, err := s.latestCommitTime(ctx, user, name); err != nil {
+		log.Printf("latestCommitTime %v/%v failed: %v", user, name, err)
+	} else if !t.IsZero() {
+		ri.LatestCommit, _ = ptypes.TimestampProto(t)
+	}
+	if t, err := s.latestReleaseTime(ctx, user, name); err != nil {
+		log.Printf("latestReleaseTime %v/%v failed: %v", user, name, err)
+	} else if !t.IsZero() {
+		ri.LatestRelease, _ = ptypes.TimestampProto(t)
+	}
+	return ri, nil
+}
+
+// activityKnown returns whether the activity dates in prev are still those of
+// ri, i.e. they were read and the repository has not been pushed since.
+func activityKnown(prev, ri *gpb.RepoInfo) bool {
+	if prev == nil || prev.LatestCommit == nil || ri.LastUpdated == nil {
+		return false
+	}
+	return proto.Equal(prev.LastUpdated, ri.LastUpdated)
+}
+
+// latestCommitTime returns the committer date of the latest commit of the
+// default branch, zero if the repository is empty.
+func (s *Spider) latestCommitTime(ctx context.Context, user, name string) (time.Time, error) {
+	s.waitForRate()
+	commits, _, err := s.client.Repositories.ListCommits(ctx, user, name, &github.CommitsListOptions{
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	if err != nil {
+		if isNotFound(err) || isEmptyRepository(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, errorsp.WithStacks(err)
+	}
+	if len(commits) == 0 {
+		return time.Time{}, nil
+	}
+	return commits[0].GetCommit().GetCommitter().GetDate(), nil
+}
+
+// latestReleaseTime returns when the latest release was published, zero if
+// there is no release.
+func (s *Spider) latestReleaseTime(ctx context.Context, user, name string) (time.Time, error) {
+	s.waitForRate()
+	r, _, err := s.client.Repositories.GetLatestRelease(ctx, user, name)
+	if err != nil {
+		if isNotFound(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, errorsp.WithStacks(err)
+	}
+	return r.GetPublishedAt().Time, nil
 }
 
 func (s *Spider) getFile(ctx context.Context, user string, repo, path string) (string, error) {
@@ -254,6 +347,31 @@ func (s *Spider) getFile(ctx context.Context, user string, repo, path string) (s
 	return body, errorsp.WithStacks(err)
 }
 
+// readLicense fetches and classifies a license file whose blob SHA is sha. The
+// LicenseInfo is cached by sha, an unknown license as an empty one. nil is
+// returned if the file cannot be read or the license is unknown.
+func (s *Spider) readLicense(ctx context.Context, user, repo, path, sha, fullPath string) *gpb.LicenseInfo {
+	fn := path[strings.LastIndex(path, "/")+1:]
+	info := &gpb.LicenseInfo{}
+	if !s.FileCache.Get(fullPath, sha, info) {
+		body, err := s.getFile(ctx, user, repo, path)
+		if err != nil {
+			log.Printf("Get file %v failed: %v", path, err)
+			return nil
+		}
+		if detected := license.Detect(fn, body); detected != nil {
+			info = detected
+		}
+		s.FileCache.Set(fullPath, sha, info)
+	}
+	if info.SpdxId == "" {
+		return nil
+	}
+	// The same contents may be cached by a file of another name.
+	info.Source = fn
+	return info
+}
+
 func isReadmeFile(fn string) bool {
 	fn = fn[:len(fn)-len(path.Ext(fn))]
 	return strings.ToLower(fn) == "readme"
@@ -287,10 +405,20 @@ var (
 	goFileInfo_ParseFailed  = gpb.GoFileInfo{Status: gpb.GoFileInfo_ParseFailed}
 )
 
+// goFileParserVersion is the version of parseGoFile. Increase it when more
+// information is extracted so that the cached GoFileInfos are regenerated.
+const goFileParserVersion = 1
+
 func parseGoFile(path string, body string, info *gpb.GoFileInfo) {
 	info.IsTest = strings.HasSuffix(path, "_test.go")
 	fs := token.NewFileSet()
-	goF, err := parser.ParseFile(fs, "", body, parser.ImportsOnly|parser.ParseComments)
+	goF, err := parser.ParseFile(fs, "", body, parser.ParseComments)
+	fullParsed := err == nil
+	if err != nil {
+		// Syntax errors in function bodies do not prevent us from getting
+		// the imports.
+		goF, err = parser.ParseFile(fs, "", body, parser.ImportsOnly|parser.ParseComments)
+	}
 	if err != nil {
 		log.Printf("Parsing file %v failed: %v", path, err)
 		if info.IsTest {
@@ -312,6 +440,312 @@ func parseGoFile(path string, body string, info *gpb.GoFileInfo) {
 	info.Name = goF.Name.Name
 	if goF.Doc != nil {
 		info.Description = goF.Doc.Text()
+		info.Deprecated = spider.DeprecationMessage(info.Description)
+	}
+	info.Size = int32(len(body))
+	if !fullParsed {
+		return
+	}
+	if info.IsTest {
+		info.Examples = int32(countExamples(goF))
+	} else {
+		exported, documented := countExported(goF)
+		info.Exported, info.Documented = int32(exported), int32(documented)
+	}
+}
+
+// isExampleName returns true if name is the name of an Example function, i.e.
+// "Example", "ExampleF", "ExampleT_M" or "Example_suffix".
+func isExampleName(name string) bool {
+	suffix, ok := stringsp.MatchPrefix(name, "Example")
+	if !ok {
+		return false
+	}
+	if suffix == "" || suffix[0] == '_' {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(suffix)
+	return unicode.IsUpper(r)
+}
+
+// countExamples returns the number of runnable Example functions in a test
+// file, i.e. those having an "Output:" comment.
+func countExamples(f *ast.File) int {
+	cnt := 0
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Recv != nil || !isExampleName(fd.Name.Name) {
+			continue
+		}
+		if fd.Type.Params.NumFields() > 0 || fd.Type.Results.NumFields() > 0 || fd.Body == nil {
+			continue
+		}
+		for _, g := range f.Comments {
+			if g.Pos() >= fd.Body.Lbrace && g.End() <= fd.Body.Rbrace && hasOutputComment(g.Text()) {
+				cnt++
+				break
+			}
+		}
+	}
+	return cnt
+}
+
+func hasOutputComment(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "Output:") || strings.HasPrefix(text, "Unordered output:")
+}
+
+// receiverExported returns true if the receiver type of a method is exported.
+func receiverExported(recv *ast.FieldList) bool {
+	if recv == nil || len(recv.List) == 0 {
+		return true
+	}
+	t := recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	ident, ok := t.(*ast.Ident)
+	return ok && ident.IsExported()
+}
+
+// countExported returns the number of top-level exported identifiers (funcs,
+// methods of exported types, types, consts and vars) in f and the number of
+// those with doc comments.
+func countExported(f *ast.File) (exported, documented int) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() || !receiverExported(d.Recv) {
+				continue
+			}
+			exported++
+			if d.Doc != nil {
+				documented++
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				var names []*ast.Ident
+				hasDoc := d.Doc != nil
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					names = []*ast.Ident{sp.Name}
+					hasDoc = hasDoc || sp.Doc != nil
+				case *ast.ValueSpec:
+					names = sp.Names
+					hasDoc = hasDoc || sp.Doc != nil
+				}
+				for _, name := range names {
+					if !name.IsExported() {
+						continue
+					}
+					exported++
+					if hasDoc {
+						documented++
+					}
+				}
+			}
+		}
+	}
+	return exported, documented
+}
+
+// goFileInfo returns the GoFileInfo of a file, from the file cache if it was
+// generated by the current version of parseGoFile. fullPath references the
+// signature in the file cache.
+func (s *Spider) goFileInfo(ctx context.Context, user, repo, cPath, sha, fullPath string) (*gpb.GoFileInfo, error) {
+	fi := &gpb.GoFileInfo{}
+	if s.FileCache.Get(fullPath, sha, fi) {
+		if fi.ParserVersion == goFileParserVersion {
+			log.Printf("Cache for %v found(sha:%q)", fullPath, sha)
+			return fi, nil
+		}
+		log.Printf("Cache for %v (sha:%q) is outdated", fullPath, sha)
+		*fi = gpb.GoFileInfo{}
+	}
+	body, err := s.getFile(ctx, user, repo, cPath)
+	if err != nil {
+		if isTooLargeError(err) {
+			*fi = goFileInfo_ShouldIgnore
+		} else {
+			// Temporary error
+			return nil, err
+		}
+	} else {
+		parseGoFile(cPath, body, fi)
+	}
+	fi.ParserVersion = goFileParserVersion
+	s.FileCache.Set(fullPath, sha, fi)
+	log.Printf("Save file cache for %v (sha:%q)", fullPath, sha)
+	return fi, nil
+}
+
+// addFileQuality accumulates the quality signals of a Go file to q.
+func addFileQuality(q *gpb.QualityInfo, fi *gpb.GoFileInfo) {
+	if fi.IsTest {
+		q.TestFiles++
+		q.TestSize += fi.Size
+		q.Examples += fi.Examples
+	} else {
+		q.Exported += fi.Exported
+		q.Documented += fi.Documented
+	}
+	for _, imp := range fi.Imports {
+		switch imp {
+		case "C":
+			q.UsesCgo = true
+		case "unsafe":
+			q.UsesUnsafe = true
+		}
+	}
+}
+
+// goModCache caches the go.mod files of the repositories read by a Spider,
+// in memory for its life, e.g. a run of the crawler.
+type goModCache struct {
+	mu sync.Mutex
+	// The blob SHAs of the go.mod files by their folders, without the leading
+	// "/", by "<user>/<repo>".
+	dirs map[string]map[string]string
+	// The parsed go.mod files by their blob SHAs.
+	infos map[string]spider.GoModInfo
+}
+
+func (c *goModCache) getDirs(repo string) (map[string]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dirs, ok := c.dirs[repo]
+	return dirs, ok
+}
+
+func (c *goModCache) setDirs(repo string, dirs map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dirs == nil {
+		c.dirs = make(map[string]map[string]string)
+	}
+	c.dirs[repo] = dirs
+}
+
+func (c *goModCache) getInfo(sha string) (spider.GoModInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, ok := c.infos[sha]
+	return info, ok
+}
+
+func (c *goModCache) setInfo(sha string, info spider.GoModInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.infos == nil {
+		c.infos = make(map[string]spider.GoModInfo)
+	}
+	c.infos[sha] = info
+}
+
+// readGoMod reads and parses the go.mod file at path whose blob SHA is sha,
+// once for every sha. An empty GoModInfo is returned if the file can not be
+// read.
+func (s *Spider) readGoMod(ctx context.Context, user, repo, path, sha string) spider.GoModInfo {
+	if info, ok := s.goMods.getInfo(sha); ok {
+		return info
+	}
+	body, err := s.getFile(ctx, user, repo, path)
+	if err != nil {
+		log.Printf("Get file %v failed: %v", path, err)
+		return spider.GoModInfo{}
+	}
+	info := spider.ParseGoMod(body)
+	s.goMods.setInfo(sha, info)
+	return info
+}
+
+// goModDirs returns the blob SHAs of the go.mod files in tree by their
+// folders, without the leading "/".
+func goModDirs(tree *github.Tree) map[string]string {
+	dirs := make(map[string]string)
+	for _, te := range tree.Entries {
+		p := stringsp.Get(te.Path)
+		if stringsp.Get(te.Type) != "blob" || path.Base(p) != "go.mod" {
+			continue
+		}
+		d := path.Dir(p)
+		if d == "." {
+			d = ""
+		}
+		dirs[d] = stringsp.Get(te.SHA)
+	}
+	return dirs
+}
+
+// goModPath returns the path of the go.mod file in the folder dir, relative
+// to the repository.
+func goModPath(dir string) string {
+	if dir == "" {
+		return "go.mod"
+	}
+	return dir + "/go.mod"
+}
+
+// repoGoModDirs returns the blob SHAs of the go.mod files of the repository
+// by their folders, listed by its tree once for the life of s.
+func (s *Spider) repoGoModDirs(ctx context.Context, user, repo string) (map[string]string, error) {
+	if dirs, ok := s.goMods.getDirs(user + "/" + repo); ok {
+		return dirs, nil
+	}
+	tree, err := s.getTree(ctx, user, repo, "HEAD", true)
+	if err != nil {
+		return nil, err
+	}
+	dirs := goModDirs(tree)
+	s.goMods.setDirs(user+"/"+repo, dirs)
+	return dirs, nil
+}
+
+// parentFolder returns the parent of the folder at path, relative to the
+// repository, "" for the root.
+func parentFolder(path string) string {
+	path = strings.Trim(path, "/")
+	if p := strings.LastIndex(path, "/"); p >= 0 {
+		return path[:p]
+	}
+	return ""
+}
+
+// readModuleGoMod reads the go.mod file of the module containing the folder
+// at path, i.e. the nearest one in the ancestors of path, for a folder
+// without a go.mod file. The go.mod files are found by repoGoModDirs, without
+// requesting the missing ones. An empty GoModInfo is returned if none is
+// found.
+func (s *Spider) readModuleGoMod(ctx context.Context, user, repo, path string) spider.GoModInfo {
+	if strings.Trim(path, "/") == "" {
+		return spider.GoModInfo{}
+	}
+	dirs, err := s.repoGoModDirs(ctx, user, repo)
+	if err != nil {
+		log.Printf("Listing the go.mod files of %v/%v failed: %v", user, repo, err)
+		return spider.GoModInfo{}
+	}
+	for strings.Trim(path, "/") != "" {
+		path = parentFolder(path)
+		if sha, ok := dirs[path]; ok {
+			return s.readGoMod(ctx, user, repo, goModPath(path), sha)
+		}
+	}
+	return spider.GoModInfo{}
+}
+
+// moduleGoMod returns the GoModInfo of the module containing the folder at
+// path, with mods the go.mod files of the repository by their folders.
+func moduleGoMod(path string, mods map[string]spider.GoModInfo) spider.GoModInfo {
+	path = strings.Trim(path, "/")
+	for {
+		if info, ok := mods[path]; ok {
+			return info
+		}
+		if path == "" {
+			return spider.GoModInfo{}
+		}
+		path = parentFolder(path)
 	}
 }
 
@@ -341,6 +775,16 @@ func isTooLargeError(err error) bool {
 	return false
 }
 
+// isEmptyRepository returns whether err is the 409 Conflict github returns
+// when listing the commits of an empty repository.
+func isEmptyRepository(err error) bool {
+	errResp, ok := errorsp.Cause(err).(*github.ErrorResponse)
+	if !ok {
+		return false
+	}
+	return errResp.Response.StatusCode == http.StatusConflict
+}
+
 func isNotFound(err error) bool {
 	errResp, ok := errorsp.Cause(err).(*github.ErrorResponse)
 	if !ok {
@@ -366,6 +810,37 @@ type Package struct {
 	ReadmeData  string // Raw content, cound be md, txt, etc.
 	Imports     []string
 	TestImports []string
+	License     *gpb.LicenseInfo // nil if no license file is found
+	// Hash of the file-cache signatures of the Go files, identical for the
+	// copies of a package in forks and mirrors.
+	Signature string
+	// Deprecation message from the package comment, or the module comment in
+	// the go.mod file of the module containing the folder. Empty if not
+	// deprecated.
+	Deprecated string
+	// Versions retracted by the go.mod file of the module.
+	Retracted []string
+	Quality   *gpb.QualityInfo
+}
+
+// packageSignature returns the signature of a package from the file names and
+// the file-cache signatures of its Go files. An empty string is returned if
+// there is no Go file, so that such packages are not taken as copies of each
+// other.
+func packageSignature(fileSigns map[string]string) string {
+	if len(fileSigns) == 0 {
+		return ""
+	}
+	fns := make([]string, 0, len(fileSigns))
+	for fn := range fileSigns {
+		fns = append(fns, fn)
+	}
+	sort.Strings(fns)
+	h := sha1.New()
+	for _, fn := range fns {
+		fmt.Fprintf(h, "%s %s\n", fn, fileSigns[fn])
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // Even an error is returned, the folders may still contain useful elements.
@@ -391,6 +866,10 @@ func (s *Spider) ReadPackage(ctx context.Context, user string, repo string, path
 	}
 	var imports stringsp.Set
 	var testImports stringsp.Set
+	fileSigns := make(map[string]string)
+	var goMod spider.GoModInfo
+	hasGoMod := false
+	var quality gpb.QualityInfo
 	// Process files
 	for _, c := range cs {
 		fn := getString(c.Name)
@@ -401,27 +880,7 @@ func (s *Spider) ReadPackage(ctx context.Context, user string, repo string, path
 		cPath := path + "/" + fn
 		switch {
 		case strings.HasSuffix(fn, ".go"):
-			fi, err := func() (*gpb.GoFileInfo, error) {
-				fi := &gpb.GoFileInfo{}
-				if s.FileCache.Get(sha, fi) {
-					log.Printf("Cache for %v found(sha:%q)", calcFullPath(user, repo, path, fn), sha)
-					return fi, nil
-				}
-				body, err := s.getFile(ctx, user, repo, cPath)
-				if err != nil {
-					if isTooLargeError(err) {
-						*fi = goFileInfo_ShouldIgnore
-					} else {
-						// Temporary error
-						return nil, err
-					}
-				} else {
-					parseGoFile(cPath, body, fi)
-				}
-				s.FileCache.Set(sha, fi)
-				log.Printf("Save file cache for %v (sha:%q)", calcFullPath(user, repo, path, fn), sha)
-				return fi, nil
-			}()
+			fi, err := s.goFileInfo(ctx, user, repo, cPath, sha, calcFullPath(user, repo, path, fn))
 			if err != nil {
 				return nil, folders, err
 			}
@@ -431,6 +890,8 @@ func (s *Spider) ReadPackage(ctx context.Context, user string, repo string, path
 			if fi.Status == gpb.GoFileInfo_ShouldIgnore {
 				continue
 			}
+			fileSigns[fn] = sha
+			addFileQuality(&quality, fi)
 			if fi.IsTest {
 				testImports.Add(fi.Imports...)
 			} else {
@@ -448,8 +909,13 @@ func (s *Spider) ReadPackage(ctx context.Context, user string, repo string, path
 					}
 					pkg.Description += fi.Description
 				}
+				if pkg.Deprecated == "" {
+					pkg.Deprecated = fi.Deprecated
+				}
 				imports.Add(fi.Imports...)
 			}
+		case fn == "go.mod":
+			goMod, hasGoMod = s.readGoMod(ctx, user, repo, cPath, sha), true
 		case isReadmeFile(fn):
 			body, err := s.getFile(ctx, user, repo, cPath)
 			if err != nil {
@@ -458,6 +924,8 @@ func (s *Spider) ReadPackage(ctx context.Context, user string, repo string, path
 			}
 			pkg.ReadmeFn = fn
 			pkg.ReadmeData = string(body)
+		case license.IsLicenseFile(fn):
+			pkg.License = license.Better(pkg.License, s.readLicense(ctx, user, repo, cPath, sha, calcFullPath(user, repo, path, fn)))
 		}
 	}
 	if pkg.Name == "" {
@@ -465,6 +933,16 @@ func (s *Spider) ReadPackage(ctx context.Context, user string, repo string, path
 	}
 	pkg.Imports = imports.Elements()
 	pkg.TestImports = testImports.Elements()
+	pkg.Signature = packageSignature(fileSigns)
+	if !hasGoMod {
+		goMod = s.readModuleGoMod(ctx, user, repo, path)
+	}
+	if pkg.Deprecated == "" {
+		pkg.Deprecated = goMod.Deprecated
+	}
+	pkg.Retracted = goMod.Retracted
+	quality.HasReadme = pkg.ReadmeFn != ""
+	pkg.Quality = &quality
 	return &pkg, folders, nil
 }
 
@@ -521,6 +999,13 @@ func (s *Spider) ReadRepo(ctx context.Context, user string, repo string, sha str
 		return err
 	}
 	pkgs := make(map[string][]github.TreeEntry)
+	dirs := goModDirs(tree)
+	s.goMods.setDirs(user+"/"+repo, dirs)
+	// The go.mod files by their folders, without the leading "/".
+	mods := make(map[string]spider.GoModInfo)
+	for d, sha := range dirs {
+		mods[d] = s.readGoMod(ctx, user, repo, goModPath(d), sha)
+	}
 	for _, te := range tree.Entries {
 		if stringsp.Get(te.Type) != "blob" {
 			continue
@@ -544,33 +1029,14 @@ func (s *Spider) ReadRepo(ctx context.Context, user string, repo string, sha str
 		}
 		var imports stringsp.Set
 		var testImports stringsp.Set
+		var quality gpb.QualityInfo
 		for _, te := range teList {
 			fn := path.Base(*te.Path)
 			cPath := *te.Path
 			sha := *te.SHA
 			switch {
 			case strings.HasSuffix(fn, ".go"):
-				fi, err := func() (*gpb.GoFileInfo, error) {
-					fi := &gpb.GoFileInfo{}
-					if s.FileCache.Get(sha, fi) {
-						log.Printf("Cache for %v found(sha:%q)", "github.com/"+user+"/"+cPath, sha)
-						return fi, nil
-					}
-					body, err := s.getFile(ctx, user, repo, cPath)
-					if err != nil {
-						if isTooLargeError(err) {
-							*fi = goFileInfo_ShouldIgnore
-						} else {
-							// Temporary error
-							return nil, err
-						}
-					} else {
-						parseGoFile(cPath, body, fi)
-					}
-					s.FileCache.Set(sha, fi)
-					log.Printf("Save file cache for %v (sha:%q)", "github.com/"+user+"/"+cPath, sha)
-					return fi, nil
-				}()
+				fi, err := s.goFileInfo(ctx, user, repo, cPath, sha, calcFullPath(user, repo, d, fn))
 				if err != nil {
 					return err
 				}
@@ -580,6 +1046,7 @@ func (s *Spider) ReadRepo(ctx context.Context, user string, repo string, sha str
 				if fi.Status == gpb.GoFileInfo_ShouldIgnore {
 					continue
 				}
+				addFileQuality(&quality, fi)
 				if fi.IsTest {
 					testImports.Add(fi.Imports...)
 				} else {
@@ -596,6 +1063,9 @@ func (s *Spider) ReadRepo(ctx context.Context, user string, repo string, sha str
 						}
 						pkg.Description += fi.Description
 					}
+					if pkg.Deprecated == "" {
+						pkg.Deprecated = fi.Deprecated
+					}
 					imports.Add(fi.Imports...)
 				}
 			case isReadmeFile(fn):
@@ -606,6 +1076,8 @@ func (s *Spider) ReadRepo(ctx context.Context, user string, repo string, sha str
 				}
 				pkg.ReadmeFn = fn
 				pkg.ReadmeData = string(body)
+			case license.IsLicenseFile(fn):
+				pkg.License = license.Better(pkg.License, s.readLicense(ctx, user, repo, cPath, sha, calcFullPath(user, repo, d, fn)))
 			}
 		}
 		if pkg.Name == "" {
@@ -613,6 +1085,13 @@ func (s *Spider) ReadRepo(ctx context.Context, user string, repo string, sha str
 		}
 		pkg.Imports = imports.Elements()
 		pkg.TestImports = testImports.Elements()
+		goMod := moduleGoMod(d, mods)
+		if pkg.Deprecated == "" {
+			pkg.Deprecated = goMod.Deprecated
+		}
+		pkg.Retracted = goMod.Retracted
+		quality.HasReadme = pkg.ReadmeFn != ""
+		pkg.Quality = &quality
 		if err := errorsp.WithStacks(f(d, &pkg)); err != nil {
 			return err
 		}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/golangplus/errors"
 	"github.com/golangplus/testing/assert"
+	"github.com/google/go-github/github"
 
 	gcsepb "github.com/daviddengcn/gcse/shared/proto"
+	"github.com/daviddengcn/gcse/spider"
 )
 
 //func TestReadUser(t *testing.T) {
@@ -64,6 +68,87 @@ package main
 	assert.Equal(t, "fi", fi, &gcsepb.GoFileInfo{Status: gcsepb.GoFileInfo_ShouldIgnore})
 }
 
+func TestParseGoFile_Quality(t *testing.T) {
+	fi := &gcsepb.GoFileInfo{}
+	body := `package a
+
+import "unsafe"
+
+// F does things.
+func F() {}
+
+func G() {}
+
+func g() {}
+
+type t struct{}
+
+// M is exported but its receiver is not.
+func (t) M() {}
+
+// Doc of the group.
+const (
+	A = 1
+	B = 2
+)
+
+var X, y int
+`
+	parseGoFile("a.go", body, fi)
+	assert.Equal(t, "fi", fi, &gcsepb.GoFileInfo{
+		Status:     gcsepb.GoFileInfo_ParseSuccess,
+		Name:       "a",
+		Imports:    []string{"unsafe"},
+		Size:       int32(len(body)),
+		Exported:   5,
+		Documented: 3,
+	})
+
+	fi = &gcsepb.GoFileInfo{}
+	body = `package a
+
+import "fmt"
+
+func ExampleF() {
+	fmt.Println("hello")
+	// Output: hello
+}
+
+func Example_noOutput() {
+}
+
+func Examplefoo() {
+	// Output:
+}
+
+func TestF(t *testing.T) {}
+`
+	parseGoFile("a_test.go", body, fi)
+	assert.Equal(t, "fi", fi, &gcsepb.GoFileInfo{
+		Status:   gcsepb.GoFileInfo_ParseSuccess,
+		Name:     "a",
+		IsTest:   true,
+		Imports:  []string{"fmt"},
+		Size:     int32(len(body)),
+		Examples: 1,
+	})
+}
+
+func TestAddFileQuality(t *testing.T) {
+	var q gcsepb.QualityInfo
+	addFileQuality(&q, &gcsepb.GoFileInfo{Imports: []string{"C"}, Exported: 3, Documented: 2})
+	addFileQuality(&q, &gcsepb.GoFileInfo{IsTest: true, Size: 100, Examples: 2, Imports: []string{"unsafe"}})
+	assert.Equal(t, "q", q, gcsepb.QualityInfo{
+		TestFiles:  1,
+		TestSize:   100,
+		Examples:   2,
+		Exported:   3,
+		Documented: 2,
+		UsesCgo:    true,
+		UsesUnsafe: true,
+	})
+}
+
 func TestRepoBranchSHA(t *testing.T) {
 	ctx := context.Background()
 
@@ -152,12 +237,14 @@ func TestReadRepo(t *testing.T) {
 			Path:        "",
 			Imports:     []string{"github.com/daviddengcn/go-easybi"},
 			TestImports: []string{},
+			Quality:     &gcsepb.QualityInfo{Exported: 1},
 		},
 		"/sub": &gcsepb.Package{
 			Name:        "gcse",
 			Path:        "/sub",
 			Imports:     []string{"github.com/daviddengcn/go-easybi"},
 			TestImports: []string{},
+			Quality:     &gcsepb.QualityInfo{Exported: 1},
 		},
 	})
 }
@@ -168,3 +255,275 @@ func TestReadRepo_NotFound(t *testing.T) {
 	s := NewSpiderWithContents(map[string]string{})
 	assert.Equal(t, "err", errorsp.Cause(s.ReadRepo(ctx, "noone", "nothing", "sha-1", nil)), ErrInvalidRepository)
 }
+
+// memFileCache is a spider.FileCache in memory.
+type memFileCache map[string][]byte
+
+func (c memFileCache) Get(_, sign string, contents proto.Message) bool {
+	bs, ok := c[sign]
+	return ok && proto.Unmarshal(bs, contents) == nil
+}
+
+func (c memFileCache) Set(_, sign string, contents proto.Message) {
+	c[sign], _ = proto.Marshal(contents)
+}
+
+func TestReadRepo_License(t *testing.T) {
+	ctx := context.Background()
+
+	contents := map[string]string{
+		"/repos/daviddengcn/license/git/trees/sha-1?recursive=1": `
+			{
+				"sha": "sha-1",
+				"tree": [
+					{
+						"path": "a.go",
+						"type": "blob",
+						"sha": "sha-2"
+					},
+					{
+						"path": "LICENSE",
+						"type": "blob",
+						"sha": "sha-3"
+					}
+				],
+				"truncated": false
+			}`,
+		"/repos/daviddengcn/license/contents/a.go": `
+			{
+				"name": "a.go",
+				"path": "a.go",
+				"sha": "sha-2",
+				"content": "cGFja2FnZSBnY3NlCg==\n",
+				"encoding": "base64",
+				"type": "file"
+			}
+		`,
+		"/repos/daviddengcn/license/contents/LICENSE": `
+			{
+				"name": "LICENSE",
+				"path": "LICENSE",
+				"sha": "sha-3",
+				"content": "Q29weXJpZ2h0IChjKSAyMDE0IERhdmlkIERlbmcKClBlcm1pc3Npb24gaXMg\naGVyZWJ5IGdyYW50ZWQsIGZyZWUgb2YgY2hhcmdlLCB0byBhbnkgcGVyc29u\nIG9idGFpbmluZyBhIGNvcHkKb2YgdGhpcyBzb2Z0d2FyZSBhbmQgYXNzb2Np\nYXRlZCBkb2N1bWVudGF0aW9uIGZpbGVzICh0aGUgIlNvZnR3YXJlIikuCgpU\naGUgYWJvdmUgY29weXJpZ2h0IG5vdGljZSBhbmQgdGhpcyBwZXJtaXNzaW9u\nIG5vdGljZSBzaGFsbCBiZSBpbmNsdWRlZCBpbiBhbGwKY29waWVzIG9yIHN1\nYnN0YW50aWFsIHBvcnRpb25zIG9mIHRoZSBTb2Z0d2FyZS4KClRIRSBTT0ZU\nV0FSRSBJUyBQUk9WSURFRCAiQVMgSVMiLCBXSVRIT1VUIFdBUlJBTlRZIE9G\nIEFOWSBLSU5ELgo=\n",
+				"encoding": "base64",
+				"type": "file"
+			}
+		`,
+	}
+	s := NewSpiderWithContents(contents)
+	s.FileCache = memFileCache{}
+	for i := 0; i < 2; i++ {
+		pkgs := make(map[string]*gcsepb.Package)
+		assert.NoError(t, s.ReadRepo(ctx, "daviddengcn", "license", "sha-1", func(path string, pkg *gcsepb.Package) error {
+			pkgs[path] = pkg
+			return nil
+		}))
+		assert.Equal(t, "License", pkgs[""].GetLicense(), &gcsepb.LicenseInfo{
+			SpdxId:     "MIT",
+			Confidence: 1,
+			Source:     "LICENSE",
+		})
+		// The license is cached by its SHA, not read again.
+		delete(contents, "/repos/daviddengcn/license/contents/LICENSE")
+	}
+}
+
+func TestReadRepo_Deprecated(t *testing.T) {
+	ctx := context.Background()
+
+	s := NewSpiderWithContents(map[string]string{
+		"/repos/daviddengcn/deprecated/git/trees/sha-1?recursive=1": `
+			{
+				"sha": "sha-1",
+				"tree": [
+					{
+						"path": "a.go",
+						"type": "blob",
+						"sha": "sha-2"
+					},
+					{
+						"path": "go.mod",
+						"type": "blob",
+						"sha": "sha-3"
+					},
+					{
+						"path": "sub/b.go",
+						"type": "blob",
+						"sha": "sha-4"
+					}
+				],
+				"truncated": false
+			}`,
+		"/repos/daviddengcn/deprecated/contents/sub/b.go": `
+			{
+				"name": "b.go",
+				"path": "sub/b.go",
+				"sha": "sha-4",
+				"content": "cGFja2FnZSBzdWIK",
+				"encoding": "base64",
+				"type": "file"
+			}
+		`,
+		"/repos/daviddengcn/deprecated/contents/a.go": `
+			{
+				"name": "a.go",
+				"path": "a.go",
+				"sha": "sha-2",
+				"content": "Ly8gUGFja2FnZSBvbGQgaXMgb2xkLgovLwovLyBEZXByZWNhdGVkOiBVc2UgZ2l0aHViLmNvbS9kYXZpZGRlbmdjbi9uZXcgaW5zdGVhZC4KcGFja2FnZSBvbGQK",
+				"encoding": "base64",
+				"type": "file"
+			}
+		`,
+		"/repos/daviddengcn/deprecated/contents/go.mod": `
+			{
+				"name": "go.mod",
+				"path": "go.mod",
+				"sha": "sha-3",
+				"content": "bW9kdWxlIGdpdGh1Yi5jb20vZGF2aWRkZW5nY24vZGVwcmVjYXRlZAoKcmV0cmFjdCB2MS4wLjAgLy8gQnJva2VuLgo=",
+				"encoding": "base64",
+				"type": "file"
+			}
+		`,
+	})
+	pkgs := make(map[string]*gcsepb.Package)
+	assert.NoError(t, s.ReadRepo(ctx, "daviddengcn", "deprecated", "sha-1", func(path string, pkg *gcsepb.Package) error {
+		pkgs[path] = pkg
+		return nil
+	}))
+	assert.Equal(t, "Deprecated", pkgs[""].GetDeprecated(), "Use github.com/daviddengcn/new instead.")
+	assert.Equal(t, "Retracted", pkgs[""].GetRetracted(), []string{"v1.0.0"})
+	// The subpackage is in the same module.
+	assert.Equal(t, "sub Retracted", pkgs["/sub"].GetRetracted(), []string{"v1.0.0"})
+}
+
+func TestReadPackage_ModuleGoMod(t *testing.T) {
+	ctx := context.Background()
+
+	contents := map[string]string{
+		"/repos/daviddengcn/mod/git/trees/HEAD?recursive=1": `
+			{
+				"sha": "sha-1",
+				"tree": [
+					{
+						"path": "go.mod",
+						"type": "blob",
+						"sha": "sha-2"
+					},
+					{
+						"path": "sub/x/x.go",
+						"type": "blob",
+						"sha": "sha-3"
+					},
+					{
+						"path": "sub/y/x.go",
+						"type": "blob",
+						"sha": "sha-3"
+					}
+				],
+				"truncated": false
+			}`,
+		"/repos/daviddengcn/mod/contents/go.mod": `
+			{
+				"name": "go.mod",
+				"path": "go.mod",
+				"sha": "sha-2",
+				"content": "bW9kdWxlIGdpdGh1Yi5jb20vZGF2aWRkZW5nY24vbW9kCgpyZXRyYWN0IHYxLjAuMCAvLyBCcm9rZW4uCg==",
+				"encoding": "base64",
+				"type": "file"
+			}
+		`,
+	}
+	for _, dir := range []string{"sub/x", "sub/y"} {
+		contents["/repos/daviddengcn/mod/contents/"+dir] = `
+			[
+				{
+					"name": "x.go",
+					"path": "` + dir + `/x.go",
+					"sha": "sha-3",
+					"type": "file"
+				}
+			]`
+		contents["/repos/daviddengcn/mod/contents/"+dir+"/x.go"] = `
+			{
+				"name": "x.go",
+				"path": "` + dir + `/x.go",
+				"sha": "sha-3",
+				"content": "cGFja2FnZSB4Cg==",
+				"encoding": "base64",
+				"type": "file"
+			}
+		`
+	}
+	s := NewSpiderWithContents(contents)
+
+	pkg, _, err := s.ReadPackage(ctx, "daviddengcn", "mod", "sub/x")
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "Retracted", pkg.Retracted, []string{"v1.0.0"})
+
+	// The go.mod files of the repository are listed and read only once.
+	delete(contents, "/repos/daviddengcn/mod/git/trees/HEAD?recursive=1")
+	delete(contents, "/repos/daviddengcn/mod/contents/go.mod")
+	pkg, _, err = s.ReadPackage(ctx, "daviddengcn", "mod", "sub/y")
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "Retracted", pkg.Retracted, []string{"v1.0.0"})
+}
+
+func TestModuleGoMod(t *testing.T) {
+	root := spider.GoModInfo{Deprecated: "Use v2."}
+	nested := spider.GoModInfo{Retracted: []string{"v0.1.0"}}
+	mods := map[string]spider.GoModInfo{"": root, "tools": nested}
+	assert.Equal(t, "root", moduleGoMod("", mods), root)
+	assert.Equal(t, "sub", moduleGoMod("/a/b", mods), root)
+	assert.Equal(t, "nested module", moduleGoMod("/tools", mods), nested)
+	assert.Equal(t, "in nested module", moduleGoMod("/tools/x", mods), nested)
+	assert.Equal(t, "no go.mod", moduleGoMod("/a", nil), spider.GoModInfo{})
+}
+
+func TestLicenseFromGithub(t *testing.T) {
+	spdx := "Apache-2.0"
+	assert.Equal(t, "license", licenseFromGithub(&github.License{SPDXID: &spdx}), &gcsepb.LicenseInfo{
+		SpdxId:     "Apache-2.0",
+		Confidence: 1,
+		Source:     "github.com",
+	})
+	noAssertion := "NOASSERTION"
+	assert.Equal(t, "license", licenseFromGithub(&github.License{SPDXID: &noAssertion}), (*gcsepb.LicenseInfo)(nil))
+	assert.Equal(t, "license", licenseFromGithub(nil), (*gcsepb.LicenseInfo)(nil))
+}
+
+func TestRepoInfoFromGithub_Fork(t *testing.T) {
+	archived := true
+	sourceName := "daviddengcn/gcse"
+	forks := 12
+	ri := repoInfoFromGithub(&github.Repository{
+		Archived:   &archived,
+		ForksCount: &forks,
+		Source: &github.Repository{
+			FullName: &sourceName,
+		},
+	})
+	assert.Equal(t, "ri.Source", ri.Source, "github.com/daviddengcn/gcse")
+	assert.Equal(t, "ri.Archived", ri.Archived, true)
+	assert.Equal(t, "ri.Forks", ri.Forks, int32(12))
+}
+
+func TestPackageSignature(t *testing.T) {
+	sign := packageSignature(map[string]string{"a.go": "sha-1", "b.go": "sha-2"})
+	assert.Equal(t, "same files", packageSignature(map[string]string{"b.go": "sha-2", "a.go": "sha-1"}), sign)
+	assert.NotEqual(t, "changed file", packageSignature(map[string]string{"a.go": "sha-1", "b.go": "sha-3"}), sign)
+	assert.NotEqual(t, "renamed file", packageSignature(map[string]string{"a.go": "sha-1", "c.go": "sha-2"}), sign)
+	assert.Equal(t, "no files", packageSignature(nil), "")
+	assert.Equal(t, "empty files", packageSignature(map[string]string{}), "")
+}
+
+func TestActivityKnown(t *testing.T) {
+	pushed := &timestamp.Timestamp{Seconds: 1000}
+	ri := &gcsepb.RepoInfo{LastUpdated: &timestamp.Timestamp{Seconds: 1000}}
+	assert.False(t, "no prev", activityKnown(nil, ri))
+	assert.False(t, "never read", activityKnown(&gcsepb.RepoInfo{LastUpdated: pushed}, ri))
+
+	prev := &gcsepb.RepoInfo{LastUpdated: pushed, LatestCommit: &timestamp.Timestamp{Seconds: 900}}
+	assert.True(t, "not pushed", activityKnown(prev, ri))
+	ri.LastUpdated = &timestamp.Timestamp{Seconds: 2000}
+	assert.False(t, "pushed", activityKnown(prev, ri))
+}
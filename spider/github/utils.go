@@ -18,6 +18,13 @@ func getInt(i *int) int {
 	return *i
 }
 
+func getBool(b *bool) bool {
+	if b == nil {
+		return false
+	}
+	return *b
+}
+
 func getTimestamp(ts *github.Timestamp) github.Timestamp {
 	if ts == nil {
 		return github.Timestamp{}
@@ -0,0 +1,289 @@
+// Package license detects the license of a repository or a package from the
+// contents of its LICENSE/COPYING files. The classification is done locally
+// by matching the normalized text against the characteristic phrases of
+// well-known licenses, and the result is reported as an SPDX identifier
+// together with a confidence score.
+package license
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// MinConfidence is the minimum confidence for a classification to be kept.
+const MinConfidence = 0.6
+
+// template describes a license by the phrases that must appear in its text
+// and the phrases that must not, e.g. the non-endorsement clause to tell
+// BSD-2-Clause from BSD-3-Clause. All phrases are normalized by normalize.
+type template struct {
+	spdx    string
+	phrases []string
+	not     []string
+}
+
+var templates = []template{{
+	spdx: "MIT",
+	phrases: []string{
+		"permission is hereby granted free of charge to any person obtaining a copy",
+		"the above copyright notice and this permission notice shall be included in all copies or substantial portions of the software",
+		"the software is provided as is without warranty of any kind",
+	},
+}, {
+	spdx: "ISC",
+	phrases: []string{
+		"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted",
+		"provided that the above copyright notice and this permission notice appear in all copies",
+		"the software is provided as is and the author disclaims all warranties",
+	},
+}, {
+	spdx: "BSD-2-Clause",
+	phrases: []string{
+		"redistribution and use in source and binary forms with or without modification are permitted provided that the following conditions are met",
+		"redistributions of source code must retain the above copyright notice",
+		"redistributions in binary form must reproduce the above copyright notice",
+	},
+	not: []string{"endorse or promote products derived from this software", "all advertising materials"},
+}, {
+	spdx: "BSD-3-Clause",
+	phrases: []string{
+		"redistribution and use in source and binary forms with or without modification are permitted provided that the following conditions are met",
+		"redistributions of source code must retain the above copyright notice",
+		"redistributions in binary form must reproduce the above copyright notice",
+		"endorse or promote products derived from this software without specific prior written permission",
+	},
+	not: []string{"all advertising materials"},
+}, {
+	spdx: "Apache-2.0",
+	phrases: []string{
+		"apache license version 2 0",
+		"terms and conditions for use reproduction and distribution",
+		"grant of copyright license",
+		"grant of patent license",
+	},
+}, {
+	spdx: "MPL-2.0",
+	phrases: []string{
+		"mozilla public license version 2 0",
+		"this source code form is subject to the terms of the mozilla public license",
+		"incompatible with secondary licenses",
+	},
+}, {
+	// The GPL family is told apart by the title line which includes the
+	// version and its date. Other variants are mentioned in the texts, e.g.
+	// the GPL-3.0 refers to the AGPL-3.0, so they can not be excluded by
+	// name.
+	spdx: "GPL-2.0",
+	phrases: []string{
+		"gnu general public license version 2 june 1991",
+		"everyone is permitted to copy and distribute verbatim copies of this license document but changing it is not allowed",
+	},
+}, {
+	spdx: "GPL-3.0",
+	phrases: []string{
+		"gnu general public license version 3 29 june 2007",
+		"everyone is permitted to copy and distribute verbatim copies of this license document but changing it is not allowed",
+	},
+}, {
+	spdx: "LGPL-2.1",
+	phrases: []string{
+		"gnu lesser general public license version 2 1 february 1999",
+		"everyone is permitted to copy and distribute verbatim copies of this license document but changing it is not allowed",
+	},
+}, {
+	spdx: "LGPL-3.0",
+	phrases: []string{
+		"gnu lesser general public license version 3 29 june 2007",
+		"everyone is permitted to copy and distribute verbatim copies of this license document but changing it is not allowed",
+	},
+}, {
+	spdx: "AGPL-3.0",
+	phrases: []string{
+		"gnu affero general public license version 3 19 november 2007",
+		"everyone is permitted to copy and distribute verbatim copies of this license document but changing it is not allowed",
+	},
+}, {
+	spdx: "Unlicense",
+	phrases: []string{
+		"this is free and unencumbered software released into the public domain",
+		"unlicense org",
+	},
+}, {
+	spdx: "CC0-1.0",
+	phrases: []string{
+		"creative commons legal code",
+		"cc0 1 0 universal",
+	},
+}, {
+	spdx: "BSL-1.0",
+	phrases: []string{
+		"boost software license version 1 0",
+		"permission is hereby granted free of charge to any person or organization obtaining a copy of the software",
+	},
+}, {
+	spdx: "EPL-1.0",
+	phrases: []string{
+		"eclipse public license v 1 0",
+		"the accompanying program is provided under the terms of this eclipse public license",
+	},
+}, {
+	spdx: "EPL-2.0",
+	phrases: []string{
+		"eclipse public license v 2 0",
+		"the accompanying program is provided under the terms of this eclipse public license",
+	},
+}, {
+	spdx: "Zlib",
+	phrases: []string{
+		"in no event will the authors be held liable for any damages arising from the use of this software",
+		"altered source versions must be plainly marked as such",
+		"this notice may not be removed or altered from any source distribution",
+	},
+}, {
+	spdx: "WTFPL",
+	phrases: []string{
+		"do what the fuck you want to public license",
+	},
+}}
+
+// normalize lower-cases the text and replaces every sequence of non
+// letter/digit runes with a single space, so that phrases are matched
+// regardless of punctuation, line breaks and indentation.
+func normalize(text string) string {
+	var b strings.Builder
+	b.WriteByte(' ')
+	space := true
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			space = false
+		} else if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	if !space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func contains(normText, phrase string) bool {
+	return strings.Contains(normText, " "+phrase+" ")
+}
+
+var patSPDXTag = regexp.MustCompile(`(?i)SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+)`)
+
+// Classify returns the SPDX identifier of the license in text and the
+// confidence of the classification in the range of [0, 1]. An empty
+// identifier is returned if no known license matches.
+func Classify(text string) (spdx string, confidence float64) {
+	if m := patSPDXTag.FindStringSubmatch(text); m != nil {
+		return m[1], 1
+	}
+	norm := normalize(text)
+	best, bestCnt := -1, 0
+	for i, t := range templates {
+		excluded := false
+		for _, p := range t.not {
+			if contains(norm, p) {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			continue
+		}
+		cnt := 0
+		for _, p := range t.phrases {
+			if contains(norm, p) {
+				cnt++
+			}
+		}
+		if cnt == 0 {
+			continue
+		}
+		score := float64(cnt) / float64(len(t.phrases))
+		// Prefer the more specific template (more phrases matched) on ties.
+		if score > confidence || score == confidence && cnt > bestCnt {
+			best, bestCnt, confidence = i, cnt, score
+		}
+	}
+	if best < 0 {
+		return "", 0
+	}
+	return templates[best].spdx, confidence
+}
+
+// IsLicenseFile returns true if fn is the name of a file commonly used for
+// the license text, e.g. LICENSE, LICENSE.md, COPYING or LICENCE-MIT.
+func IsLicenseFile(fn string) bool {
+	fn = strings.ToLower(fn)
+	fn = strings.TrimSuffix(fn, path.Ext(fn))
+	for _, prefix := range []string{"license", "licence", "copying", "unlicense"} {
+		if fn == prefix || strings.HasPrefix(fn, prefix+"-") || strings.HasPrefix(fn, prefix+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect classifies the contents of the license file fn. nil is returned if
+// the license is unknown or the confidence is lower than MinConfidence.
+func Detect(fn, text string) *gpb.LicenseInfo {
+	spdx, confidence := Classify(text)
+	if spdx == "" || confidence < MinConfidence {
+		return nil
+	}
+	return &gpb.LicenseInfo{
+		SpdxId:     spdx,
+		Confidence: float32(confidence),
+		Source:     fn,
+	}
+}
+
+// Better returns the one of a and b with higher confidence. nil is treated as
+// the lowest.
+func Better(a, b *gpb.LicenseInfo) *gpb.LicenseInfo {
+	if b == nil || a != nil && a.Confidence >= b.Confidence {
+		return a
+	}
+	return b
+}
+
+// families maps the prefix of SPDX identifiers to the family token, so that a
+// search of "license:gpl" matches GPL-2.0 and GPL-3.0.
+var families = []string{"agpl", "lgpl", "gpl", "bsd", "mpl", "epl", "apache", "cc0", "bsl"}
+
+// Tokens returns the search tokens of an SPDX identifier: the lower-cased
+// identifier itself and, if any, its family, e.g. "gpl-3.0" and "gpl".
+func Tokens(spdx string) []string {
+	if spdx == "" {
+		return nil
+	}
+	id := NormalizeID(spdx)
+	tokens := []string{id}
+	for _, f := range families {
+		if strings.HasPrefix(id, f) {
+			if id != f {
+				tokens = append(tokens, f)
+			}
+			break
+		}
+	}
+	return tokens
+}
+
+// NormalizeID returns the normalized form of an SPDX identifier used as a
+// search token. The "-only"/"-or-later" suffixes are dropped.
+func NormalizeID(spdx string) string {
+	id := strings.ToLower(strings.TrimSpace(spdx))
+	id = strings.TrimSuffix(id, "-only")
+	id = strings.TrimSuffix(id, "-or-later")
+	id = strings.TrimSuffix(id, "+")
+	return id
+}
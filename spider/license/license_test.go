@@ -0,0 +1,117 @@
+package license
+
+import (
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+const mitText = `The MIT License (MIT)
+
+Copyright (c) 2014 David Deng
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY.
+`
+
+const bsd3Text = `Copyright (c) 2009 The Go Authors. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Google Inc. nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+`
+
+const gpl3Text = `                    GNU GENERAL PUBLIC LICENSE
+                       Version 3, 29 June 2007
+
+ Copyright (C) 2007 Free Software Foundation, Inc. <http://fsf.org/>
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+
+  13. Use with the GNU Affero General Public License.
+`
+
+const lgpl3Text = `                   GNU LESSER GENERAL PUBLIC LICENSE
+                       Version 3, 29 June 2007
+
+ Copyright (C) 2007 Free Software Foundation, Inc. <http://fsf.org/>
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+
+  This version of the GNU Lesser General Public License incorporates
+the terms and conditions of version 3 of the GNU General Public
+License.
+`
+
+func TestClassify(t *testing.T) {
+	for _, c := range []struct {
+		text       string
+		spdx       string
+		confidence float64
+	}{
+		{mitText, "MIT", 1},
+		{bsd3Text, "BSD-3-Clause", 1},
+		{gpl3Text, "GPL-3.0", 1},
+		{lgpl3Text, "LGPL-3.0", 1},
+		{"// SPDX-License-Identifier: Apache-2.0\n", "Apache-2.0", 1},
+		{"All rights reserved.", "", 0},
+	} {
+		spdx, confidence := Classify(c.text)
+		assert.Equal(t, "spdx", spdx, c.spdx)
+		assert.Equal(t, "confidence of "+c.spdx, confidence, c.confidence)
+	}
+
+	// A partial text of MIT.
+	spdx, confidence := Classify(`Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND`)
+	assert.Equal(t, "spdx", spdx, "MIT")
+	assert.ValueShould(t, "confidence", confidence, confidence < 1 && confidence >= MinConfidence, "in [MinConfidence, 1)")
+}
+
+func TestIsLicenseFile(t *testing.T) {
+	for _, fn := range []string{"LICENSE", "LICENSE.md", "license.txt", "LICENCE", "COPYING", "LICENSE-MIT", "UNLICENSE"} {
+		assert.True(t, fn, IsLicenseFile(fn))
+	}
+	for _, fn := range []string{"README.md", "licenses.go", "main.go", "COPYRIGHT"} {
+		assert.False(t, fn, IsLicenseFile(fn))
+	}
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, "Detect", Detect("LICENSE", mitText), &gpb.LicenseInfo{
+		SpdxId:     "MIT",
+		Confidence: 1,
+		Source:     "LICENSE",
+	})
+	assert.Equal(t, "Detect", Detect("LICENSE", "All rights reserved."), (*gpb.LicenseInfo)(nil))
+}
+
+func TestTokens(t *testing.T) {
+	assert.Equal(t, "Tokens", Tokens("GPL-3.0"), []string{"gpl-3.0", "gpl"})
+	assert.Equal(t, "Tokens", Tokens("LGPL-2.1-only"), []string{"lgpl-2.1", "lgpl"})
+	assert.Equal(t, "Tokens", Tokens("MIT"), []string{"mit"})
+	assert.Equal(t, "Tokens", Tokens(""), []string(nil))
+}
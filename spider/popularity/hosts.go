@@ -0,0 +1,105 @@
+package popularity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/gddo/doc"
+)
+
+// RepoStats are the statistics of a repository reported by its host. Negative
+// values are unknown.
+type RepoStats struct {
+	Stars int
+}
+
+// StatsFetcher fetches the RepoStats of the repository of a package.
+type StatsFetcher interface {
+	RepoStats(ctx context.Context, pkg string) (RepoStats, error)
+}
+
+// HostStars returns the provider of the stars of the repositories fetched by
+// f, named prefix + "-stars". The forks reported by the hosts are not stars
+// of overlapping users, so they are not provided to be fused with them.
+func HostStars(prefix string, f StatsFetcher) Provider {
+	return ProviderFunc(prefix+"-stars", func(ctx context.Context, pkg string) (int, error) {
+		stats, err := f.RepoStats(ctx, pkg)
+		return stats.Stars, err
+	})
+}
+
+// splitRepo returns the host, owner and name of the repository of pkg. Nil is
+// returned if pkg is not in the form of host/owner/repo[/path].
+func splitRepo(pkg string) []string {
+	parts := strings.SplitN(pkg, "/", 4)
+	if len(parts) < 3 || parts[1] == "" || parts[2] == "" {
+		return nil
+	}
+	return parts[:3]
+}
+
+func getJSON(ctx context.Context, client doc.HttpClient, u string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "NewRequest %v failed", u)
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "GET %v failed", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errorsp.NewWithStacks("GET %v returns status %v", u, resp.StatusCode)
+	}
+	return errorsp.WithStacksAndMessage(json.NewDecoder(resp.Body).Decode(v), "decoding %v failed", u)
+}
+
+// GitLab fetches RepoStats with the GitLab API v4.
+type GitLab struct {
+	Client doc.HttpClient
+	// e.g. "https://gitlab.com/api/v4"
+	APIBase string
+}
+
+func (g GitLab) RepoStats(ctx context.Context, pkg string) (RepoStats, error) {
+	parts := splitRepo(pkg)
+	if parts == nil {
+		return RepoStats{-1}, nil
+	}
+	var v struct {
+		StarCount int `json:"star_count"`
+	}
+	u := fmt.Sprintf("%s/projects/%s", g.APIBase, url.PathEscape(parts[1]+"/"+parts[2]))
+	if err := getJSON(ctx, g.Client, u, &v); err != nil {
+		return RepoStats{}, err
+	}
+	return RepoStats{Stars: v.StarCount}, nil
+}
+
+// Gitea fetches RepoStats with the Gitea API v1, e.g. of codeberg.org.
+type Gitea struct {
+	Client doc.HttpClient
+	// e.g. "https://codeberg.org/api/v1"
+	APIBase string
+}
+
+func (g Gitea) RepoStats(ctx context.Context, pkg string) (RepoStats, error) {
+	parts := splitRepo(pkg)
+	if parts == nil {
+		return RepoStats{-1}, nil
+	}
+	var v struct {
+		StarsCount int `json:"stars_count"`
+	}
+	u := fmt.Sprintf("%s/repos/%s/%s", g.APIBase, url.PathEscape(parts[1]), url.PathEscape(parts[2]))
+	if err := getJSON(ctx, g.Client, u, &v); err != nil {
+		return RepoStats{}, err
+	}
+	return RepoStats{Stars: v.StarsCount}, nil
+}
@@ -0,0 +1,66 @@
+package popularity
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/testing/assert"
+)
+
+// fakeClient serves the bodies by the request URL and counts the requests.
+type fakeClient struct {
+	bodies   map[string]string
+	requests int
+}
+
+func (c *fakeClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests++
+	body, ok := c.bodies[req.URL.String()]
+	if !ok {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       bytesp.NewPSlice([]byte("not found")),
+		}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       bytesp.NewPSlice([]byte(body)),
+	}, nil
+}
+
+func TestGitLab(t *testing.T) {
+	c := &fakeClient{bodies: map[string]string{
+		"https://gitlab.com/api/v4/projects/user%2Frepo": `{"star_count": 12, "forks_count": 3}`,
+	}}
+	g := GitLab{Client: c, APIBase: "https://gitlab.com/api/v4"}
+	stats, err := g.RepoStats(context.Background(), "gitlab.com/user/repo/sub")
+	assert.NoError(t, err)
+	assert.Equal(t, "stats", stats, RepoStats{Stars: 12})
+
+	_, err = g.RepoStats(context.Background(), "gitlab.com/user/missing")
+	assert.Error(t, err)
+}
+
+func TestGitea(t *testing.T) {
+	c := &fakeClient{bodies: map[string]string{
+		"https://codeberg.org/api/v1/repos/user/repo": `{"stars_count": 7, "forks_count": 1}`,
+	}}
+	g := Gitea{Client: c, APIBase: "https://codeberg.org/api/v1"}
+	stats, err := g.RepoStats(context.Background(), "codeberg.org/user/repo")
+	assert.NoError(t, err)
+	assert.Equal(t, "stats", stats, RepoStats{Stars: 7})
+}
+
+func TestHostStars(t *testing.T) {
+	c := &fakeClient{bodies: map[string]string{
+		"https://gitlab.com/api/v4/projects/user%2Frepo": `{"star_count": 12, "forks_count": 3}`,
+	}}
+	stars := HostStars("gitlab", GitLab{Client: c, APIBase: "https://gitlab.com/api/v4"})
+	assert.Equal(t, "stars.Name", stars.Name(), "gitlab-stars")
+
+	cnt, err := stars.Popularity(context.Background(), "gitlab.com/user/repo/sub")
+	assert.NoError(t, err)
+	assert.Equal(t, "stars", cnt, 12)
+}
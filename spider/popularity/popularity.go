@@ -0,0 +1,184 @@
+// Package popularity estimates the popularity of a package from pluggable
+// providers, e.g. the stars of its repository on the hosting service or the
+// number of packages importing it. Providers are configured per host and
+// their counts, all star counts of overlapping users, are fused into a single
+// star count.
+package popularity
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider reports a popularity count of a package, e.g. the stars of its
+// repository. A negative count means unknown.
+type Provider interface {
+	// Name identifies the provider in logs and when removing it.
+	Name() string
+	Popularity(ctx context.Context, pkg string) (int, error)
+}
+
+// Providers is a set of Providers configured per host. It is safe for
+// concurrent use.
+type Providers struct {
+	mu       sync.RWMutex
+	byHost   map[string][]Provider
+	defaults []Provider
+}
+
+// NewProviders returns an empty *Providers.
+func NewProviders() *Providers {
+	return &Providers{byHost: make(map[string][]Provider)}
+}
+
+// Set sets the providers of the packages of a host, e.g. "gitlab.com".
+func (ps *Providers) Set(host string, providers ...Provider) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.byHost[host] = providers
+}
+
+// SetDefault sets the providers of the hosts without specific ones. They are
+// also the fallback for the packages the providers of their host know nothing
+// about.
+func (ps *Providers) SetDefault(providers ...Provider) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.defaults = providers
+}
+
+func removeProvider(providers []Provider, name string) []Provider {
+	var res []Provider
+	for _, p := range providers {
+		if p.Name() != name {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Remove removes the providers of the name from all hosts, e.g. when the
+// service behind it is shut down.
+func (ps *Providers) Remove(name string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for host, providers := range ps.byHost {
+		ps.byHost[host] = removeProvider(providers, name)
+	}
+	ps.defaults = removeProvider(ps.defaults, name)
+}
+
+// Of returns the providers of a host.
+func (ps *Providers) Of(host string) []Provider {
+	providers, _ := ps.of(host)
+	return providers
+}
+
+// of returns the providers of a host and whether they are specific to it.
+func (ps *Providers) of(host string) ([]Provider, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	if providers, ok := ps.byHost[host]; ok {
+		return providers, true
+	}
+	return ps.defaults, false
+}
+
+func (ps *Providers) defaultProviders() []Provider {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return ps.defaults
+}
+
+func fusedPopularity(ctx context.Context, providers []Provider, pkg string) int {
+	var counts []int
+	for _, p := range providers {
+		cnt, err := p.Popularity(ctx, pkg)
+		if err != nil {
+			log.Printf("Popularity of %v from %v failed: %v", pkg, p.Name(), err)
+			continue
+		}
+		counts = append(counts, cnt)
+	}
+	return Fuse(counts...)
+}
+
+// Popularity queries all providers of the host of pkg and returns the fused
+// count. Failed providers are logged and ignored. If none of the specific
+// providers of the host knows the package, the default ones are queried. -1
+// is returned if no provider knows the package.
+func (ps *Providers) Popularity(ctx context.Context, pkg string) int {
+	host := strings.SplitN(pkg, "/", 2)[0]
+	providers, specific := ps.of(host)
+	cnt := fusedPopularity(ctx, providers, pkg)
+	if cnt < 0 && specific {
+		cnt = fusedPopularity(ctx, ps.defaultProviders(), pkg)
+	}
+	return cnt
+}
+
+func fuse2(a, b int) int {
+	if a > b {
+		a, b = b, a
+	}
+	/*
+		Now, a <= b
+		Supposing half of the stargzers are shared ones. The numbers could
+		be a/2, or b/2. The mean is (a + b) / 4. Exclude this from a + b,
+		and assure it greater than b.
+	*/
+	if a <= b/3 {
+		return b
+	}
+	return (a + b) * 3 / 4
+}
+
+// Fuse fuses the counts of different providers. Negative counts are unknown
+// and ignored, -1 is returned if all counts are unknown. The counts are fused
+// pairwise from the largest one.
+func Fuse(counts ...int) int {
+	var known []int
+	for _, c := range counts {
+		if c >= 0 {
+			known = append(known, c)
+		}
+	}
+	if len(known) == 0 {
+		return -1
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(known)))
+	res := known[0]
+	for _, c := range known[1:] {
+		res = fuse2(res, c)
+	}
+	return res
+}
+
+type funcProvider struct {
+	name string
+	f    func(ctx context.Context, pkg string) (int, error)
+}
+
+func (p funcProvider) Name() string {
+	return p.name
+}
+
+func (p funcProvider) Popularity(ctx context.Context, pkg string) (int, error) {
+	return p.f(ctx, pkg)
+}
+
+// ProviderFunc returns a Provider calling f.
+func ProviderFunc(name string, f func(ctx context.Context, pkg string) (int, error)) Provider {
+	return funcProvider{name: name, f: f}
+}
+
+// ImportedBy returns a Provider of a local signal: the number of packages
+// importing pkg returned by count.
+func ImportedBy(name string, count func(pkg string) int) Provider {
+	return ProviderFunc(name, func(_ context.Context, pkg string) (int, error) {
+		return count(pkg), nil
+	})
+}
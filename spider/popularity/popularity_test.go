@@ -0,0 +1,55 @@
+package popularity
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func TestFuse(t *testing.T) {
+	assert.Equal(t, "none", Fuse(), -1)
+	assert.Equal(t, "unknowns", Fuse(-1, -1), -1)
+	assert.Equal(t, "single", Fuse(-1, 10), 10)
+	// Same as the old two-input fusion.
+	assert.Equal(t, "small", Fuse(3, 100), 100)
+	assert.Equal(t, "close", Fuse(60, 100), 120)
+	assert.Equal(t, "three", Fuse(100, 60, 50), 127)
+}
+
+func fakeProvider(name string, cnt int, err error) Provider {
+	return ProviderFunc(name, func(context.Context, string) (int, error) {
+		return cnt, err
+	})
+}
+
+func TestProviders(t *testing.T) {
+	ctx := context.Background()
+	ps := NewProviders()
+	assert.Equal(t, "empty", ps.Popularity(ctx, "example.com/a/b"), -1)
+
+	ps.SetDefault(fakeProvider("imported", 5, nil))
+	ps.Set("gitlab.com", fakeProvider("gitlab-stars", 100, nil), fakeProvider("gitlab-mirror-stars", 60, nil),
+		fakeProvider("dead", 1000, errors.New("service is gone")))
+	assert.Equal(t, "default", ps.Popularity(ctx, "example.com/a/b"), 5)
+	assert.Equal(t, "gitlab.com", ps.Popularity(ctx, "gitlab.com/a/b/c"), 120)
+
+	ps.Remove("gitlab-mirror-stars")
+	assert.Equal(t, "providers", len(ps.Of("gitlab.com")), 2)
+	assert.Equal(t, "gitlab.com", ps.Popularity(ctx, "gitlab.com/a/b/c"), 100)
+
+	// The defaults are the fallback of the hosts whose providers know nothing.
+	ps.Set("github.com", fakeProvider("github-stars", -1, nil))
+	assert.Equal(t, "fallback", ps.Popularity(ctx, "github.com/a/b"), 5)
+}
+
+func TestImportedBy(t *testing.T) {
+	p := ImportedBy("imported", func(pkg string) int {
+		return len(pkg)
+	})
+	cnt, err := p.Popularity(context.Background(), "a/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "cnt", cnt, 3)
+	assert.Equal(t, "name", p.Name(), "imported")
+}
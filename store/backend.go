@@ -0,0 +1,371 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/bolthelper"
+	"github.com/daviddengcn/gcse/configs"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// Backend is the storage of the store package. Values are stored by
+// namespaced keys: all but the last element of a key are the namespaces,
+// e.g. {"pkgs", "github.com", "daviddengcn/gcse"}, and a key has at least
+// one namespace. Namespaces are created when a value is put in them.
+type Backend interface {
+	// View runs f in a read-only transaction.
+	View(f func(Tx) error) error
+	// Update runs f in a read-write transaction. Changes are committed if f
+	// returns nil, and rolled back otherwise. f must not open other
+	// transactions of the backend, which block until the update ends on some
+	// backends. Read what is needed with the Tx of the update instead.
+	Update(f func(Tx) error) error
+	Close() error
+}
+
+// Tx is a transaction of a Backend.
+type Tx interface {
+	// Get returns the value of the key, nil if not found or the key is a
+	// namespace. The returned slice is only valid in the transaction.
+	Get(k [][]byte) ([]byte, error)
+	// Put sets the value of the key creating the namespaces if necessary.
+	Put(k [][]byte, v []byte) error
+	// Delete deletes the value, or the namespace with everything in it, of
+	// the key. Deleting a non-existing key is not an error.
+	Delete(k [][]byte) error
+	// ForEach calls f with the direct children of the namespace ns in
+	// ascending order of keys. v is nil for sub-namespaces. Nothing is done
+	// if ns does not exist.
+	ForEach(ns [][]byte, f func(k, v []byte) error) error
+	// ForEachFrom is the same as ForEach but starts from the first key not
+	// less than from, without visiting the keys before it.
+	ForEachFrom(ns [][]byte, from []byte, f func(k, v []byte) error) error
+	// ForEachBefore calls f with the direct children of the namespace ns
+	// whose keys are less than before, or all if before is nil, in
+	// descending order of keys.
+	ForEachBefore(ns [][]byte, before []byte, f func(k, v []byte) error) error
+}
+
+var (
+	errTxReadOnly   = errors.New("transaction is read-only")
+	errNotNamespace = errors.New("key is a value, not a namespace")
+	errNotValue     = errors.New("key is a namespace, not a value")
+)
+
+// Names of the backends in configs.StoreBackend.
+const (
+	BoltBackendName   = "bolt"
+	MemoryBackendName = "memory"
+	SQLiteBackendName = "sqlite"
+)
+
+// OpenBackend opens the backend of the name with the paths in configs.
+func OpenBackend(name string) (Backend, error) {
+	switch name {
+	case BoltBackendName, "":
+		return NewBoltBackend(&bh.RefCountBox{DataPath: configs.StoreBoltPath}), nil
+	case MemoryBackendName:
+		return NewMemoryBackend(), nil
+	case SQLiteBackendName:
+		return OpenSQLiteBackend(configs.StoreSQLitePath())
+	}
+	return nil, errorsp.NewWithStacks("unknown store backend %q", name)
+}
+
+var (
+	backendMu sync.Mutex
+	backend   Backend
+)
+
+// Open opens the backend configured in configs.StoreBackend and migrates it
+// to the latest schema version, if no backend is in use yet. It is called by
+// the functions of the package on their first use, but can be called at
+// startup to fail early.
+func Open() error {
+	_, err := currentBackend()
+	return err
+}
+
+// currentBackend returns the backend in use, opening it with Open on the
+// first call. A failed open is retried on the next call.
+func currentBackend() (Backend, error) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	if backend != nil {
+		return backend, nil
+	}
+	b, err := OpenBackend(configs.StoreBackend)
+	if err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "OpenBackend %q failed", configs.StoreBackend)
+	}
+	var opts MigrateOptions
+	if configs.StoreBackupBeforeMigration {
+		opts.BackupPath = configs.StoreBackupPath()
+	}
+	if _, err := migrate(b, opts); err != nil {
+		b.Close()
+		return nil, errorsp.WithStacksAndMessage(err, "migrating the store failed")
+	}
+	backend = b
+	return backend, nil
+}
+
+// UseBackend replaces the backend in use with b and returns the old one,
+// which is nil if not opened yet. The old one is not closed.
+func UseBackend(b Backend) Backend {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	old := backend
+	backend = b
+	return old
+}
+
+func view(f func(Tx) error) error {
+	b, err := currentBackend()
+	if err != nil {
+		return err
+	}
+	return b.View(f)
+}
+
+func update(f func(Tx) error) error {
+	b, err := currentBackend()
+	if err != nil {
+		return err
+	}
+	return b.Update(f)
+}
+
+func unmarshalMessage(bs []byte, msg proto.Message) error {
+	return errorsp.WithStacksAndMessage(proto.Unmarshal(bs, msg), "Unmarshal %d bytes failed", len(bs))
+}
+
+// ErrCorruptRecord is returned when reading a value which can not be
+// unmarshaled and can not be quarantined, e.g. in a snapshot.
+var ErrCorruptRecord = errors.New("corrupt record")
+
+// getMessage reads the message of the key. msg is reset if the key is not
+// found, and ErrCorruptRecord is returned if the value can not be
+// unmarshaled.
+func getMessage(tx Tx, k [][]byte, msg proto.Message) error {
+	bs, err := tx.Get(k)
+	if err != nil {
+		return err
+	}
+	msg.Reset()
+	if bs == nil {
+		return nil
+	}
+	if err := unmarshalMessage(bs, msg); err != nil {
+		msg.Reset()
+		return errorsp.WithStacksAndMessage(ErrCorruptRecord, "%q: %v", bytes.Join(k, []byte("/")), err)
+	}
+	return nil
+}
+
+func putMessage(tx Tx, k [][]byte, msg proto.Message) error {
+	bs, err := proto.Marshal(msg)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "marshaling %v failed: %v", msg, err)
+	}
+	return tx.Put(k, bs)
+}
+
+// anyRevision skips the revision check of updateMessage.
+const anyRevision = -1
+
+// ErrRevisionMismatch is returned by an update expecting a revision different
+// from the current one of the record, i.e. the record has been updated since
+// it was read.
+var ErrRevisionMismatch = errors.New("revision mismatch")
+
+// revisionKey returns the key of the revision of the record of key k.
+func revisionKey(k [][]byte) [][]byte {
+	return append([][]byte{revisionsRoot}, k...)
+}
+
+// getRevision returns the revision of the record of the key, 0 if not found.
+func getRevision(tx Tx, k [][]byte) (int64, error) {
+	bs, err := tx.Get(revisionKey(k))
+	if err != nil || bs == nil {
+		return 0, err
+	}
+	rev, err := strconv.ParseInt(string(bs), 10, 64)
+	return rev, errorsp.WithStacksAndMessage(err, "invalid revision %q", string(bs))
+}
+
+// readMessage reads the message and the revision of the key. An unreadable
+// value is quarantined and deleted, i.e. read as not found.
+func readMessage(k [][]byte, msg proto.Message) (int64, error) {
+	rev, err := readMessageOnce(k, msg)
+	if errorsp.Cause(err) != ErrCorruptRecord {
+		return rev, err
+	}
+	if err := quarantineCorrupt(k, msg); err != nil {
+		return 0, err
+	}
+	// Reads again in case it was replaced since the first read.
+	return readMessageOnce(k, msg)
+}
+
+func readMessageOnce(k [][]byte, msg proto.Message) (int64, error) {
+	var rev int64
+	err := view(func(tx Tx) error {
+		if err := getMessage(tx, k, msg); err != nil {
+			return err
+		}
+		var err error
+		rev, err = getRevision(tx, k)
+		return err
+	})
+	return rev, err
+}
+
+// quarantineCorrupt quarantines the value of the key and deletes it with its
+// revision, if it still can not be unmarshaled into msg. The deletion is
+// logged.
+func quarantineCorrupt(k [][]byte, msg proto.Message) error {
+	deleted := false
+	if err := update(func(tx Tx) error {
+		bs, err := tx.Get(k)
+		if err != nil || bs == nil {
+			return err
+		}
+		err = unmarshalMessage(bs, msg)
+		msg.Reset()
+		if err == nil {
+			return nil
+		}
+		log.Printf("Unmarshal %q failed, quarantined: %v", bytes.Join(k, []byte("/")), err)
+		if err := quarantine(tx, k, bs); err != nil {
+			return err
+		}
+		deleted, err = deleteMessageInTx(tx, k)
+		return err
+	}); err != nil {
+		return err
+	}
+	if deleted {
+		changeNotifier.notify()
+	}
+	return nil
+}
+
+// updateMessage reads the message of the key, calls f with it and saves it
+// back, in a single transaction, and returns the new revision. If rev is not
+// anyRevision, ErrRevisionMismatch is returned if it is not the current
+// revision. An unreadable value is quarantined before being replaced. An
+// existing message left unchanged by f is not saved, and keeps its revision.
+func updateMessage(k [][]byte, msg proto.Message, rev int64, f func() error) (int64, error) {
+	return updateMessageTx(k, msg, rev, func(Tx) error {
+		return f()
+	})
+}
+
+// updateMessageTx is the same as updateMessage but f is also given the
+// transaction, for updating other values along with the message.
+func updateMessageTx(k [][]byte, msg proto.Message, rev int64, f func(Tx) error) (int64, error) {
+	var newRev int64
+	if err := update(func(tx Tx) (err error) {
+		newRev, err = updateMessageInTx(tx, k, msg, rev, f)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+	changeNotifier.notify()
+	return newRev, nil
+}
+
+// updateMessageInTx is the same as updateMessageTx but in the transaction
+// tx. The caller notifies changeNotifier after tx is committed.
+func updateMessageInTx(tx Tx, k [][]byte, msg proto.Message, rev int64, f func(Tx) error) (int64, error) {
+	cur, err := getRevision(tx, k)
+	if err != nil {
+		return 0, err
+	}
+	if rev != anyRevision && rev != cur {
+		return 0, ErrRevisionMismatch
+	}
+	bs, err := tx.Get(k)
+	if err != nil {
+		return 0, err
+	}
+	msg.Reset()
+	// The message read, nil if it does not exist or is unreadable.
+	var old proto.Message
+	if bs != nil {
+		if err := unmarshalMessage(bs, msg); err != nil {
+			log.Printf("Unmarshal %q failed, quarantined: %v", bytes.Join(k, []byte("/")), err)
+			if err := quarantine(tx, k, bs); err != nil {
+				return 0, err
+			}
+			msg.Reset()
+		} else {
+			old = proto.Clone(msg)
+		}
+	}
+	if err := errorsp.WithStacks(f(tx)); err != nil {
+		return 0, err
+	}
+	if old != nil && proto.Equal(old, msg) {
+		// Nothing changed, so neither the revision nor the change log is.
+		return cur, nil
+	}
+	if err := putMessage(tx, k, msg); err != nil {
+		return 0, err
+	}
+	newRev := cur + 1
+	if err := tx.Put(revisionKey(k), []byte(strconv.FormatInt(newRev, 10))); err != nil {
+		return 0, err
+	}
+	return newRev, logChange(tx, k, gpb.Change_Op_Update)
+}
+
+// deleteMessage deletes the message of the key with its revision. The
+// deletion is logged if the message exists.
+func deleteMessage(k [][]byte) error {
+	if err := update(func(tx Tx) error {
+		_, err := deleteMessageInTx(tx, k)
+		return err
+	}); err != nil {
+		return err
+	}
+	changeNotifier.notify()
+	return nil
+}
+
+// deleteMessageInTx is the same as deleteMessage but in the transaction tx,
+// and returns whether the message existed. The caller notifies
+// changeNotifier after tx is committed.
+func deleteMessageInTx(tx Tx, k [][]byte) (bool, error) {
+	bs, err := tx.Get(k)
+	if err != nil || bs == nil {
+		return false, err
+	}
+	if err := tx.Delete(k); err != nil {
+		return false, err
+	}
+	if err := tx.Delete(revisionKey(k)); err != nil {
+		return false, err
+	}
+	return true, logChange(tx, k, gpb.Change_Op_Delete)
+}
+
+// copyTo copies everything under the namespace ns of src to dst.
+func copyTo(dst, src Tx, ns [][]byte) error {
+	return src.ForEach(ns, func(k, v []byte) error {
+		key := append(append([][]byte{}, ns...), append([]byte{}, k...))
+		if v == nil {
+			return copyTo(dst, src, key)
+		}
+		return dst.Put(key, v)
+	})
+}
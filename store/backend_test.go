@@ -0,0 +1,279 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+)
+
+var backendNames = []string{BoltBackendName, MemoryBackendName, SQLiteBackendName}
+
+// conformanceTests are run against every backend, each with an empty one.
+var conformanceTests = []struct {
+	name string
+	f    func(*testing.T)
+}{
+	{"GetPut", testBackendGetPut},
+	{"Delete", testBackendDelete},
+	{"ForEach", testBackendForEach},
+	{"ForEachFrom", testBackendForEachFrom},
+	{"ForEachBefore", testBackendForEachBefore},
+	{"Rollback", testBackendRollback},
+	{"ReadOnly", testBackendReadOnly},
+	{"ForEachPackageSite", TestForEachPackageSite},
+	{"ForEachPackageOfSite", TestForEachPackageOfSite},
+	{"ForEachPackageWithPrefix", TestForEachPackageWithPrefix},
+	{"ForEachPackageWithPrefix_Update", TestForEachPackageWithPrefix_Update},
+	{"UpdateReadDeletePackage", TestUpdateReadDeletePackage},
+	{"UpdateReadDeletePerson", TestUpdateReadDeletePerson},
+	{"UpdateReadDeletePackageHistory", TestUpdateReadDeletePackageHistory},
+	{"AppendPackageEvent", TestAppendPackageEvent},
+	{"UpdateReadDeletePersonHistory", TestUpdateReadDeletePersonHistory},
+	{"SaveSnapshot", TestSaveSnapshot},
+	{"UpdateReadDeleteRepository", TestUpdateReadDeleteRepository},
+	{"ForEachRepositorySite", TestForEachRepositorySite},
+	{"ForEachRepositoryOfSite", TestForEachRepositoryOfSite},
+	{"Migrate_NewStore", TestMigrate_NewStore},
+	{"Migrate_Quarantine", TestMigrate_Quarantine},
+	{"Migrate_Order", TestMigrate_Order},
+	{"Migrate_Newer", TestMigrate_Newer},
+	{"UpdatePackage_Quarantine", TestUpdatePackage_Quarantine},
+	{"ReadPackage_Quarantine", TestReadPackage_Quarantine},
+	{"ForEachChange", TestForEachChange},
+	{"ForEachChange_Batches", TestForEachChange_Batches},
+	{"CompactChanges", TestCompactChanges},
+	{"ReadPackageEvents", TestReadPackageEvents},
+	{"AppendPackageEvent_Rollup", TestAppendPackageEvent_Rollup},
+	{"Migrate_HistoryEventLog", TestMigrate_HistoryEventLog},
+	{"BackupRestore", TestBackupRestore},
+	{"GC", TestGC},
+}
+
+func TestBackends(t *testing.T) {
+	defer func(name string) {
+		testingBackend = name
+		cleanDatabase(t)
+	}(testingBackend)
+
+	for _, name := range backendNames {
+		testingBackend = name
+		t.Run(name, func(t *testing.T) {
+			for _, test := range conformanceTests {
+				cleanDatabase(t)
+				t.Run(test.name, test.f)
+			}
+		})
+	}
+}
+
+func keyOf(parts ...string) [][]byte {
+	k := make([][]byte, len(parts))
+	for i, p := range parts {
+		k[i] = []byte(p)
+	}
+	return k
+}
+
+func get(t *testing.T, k [][]byte) []byte {
+	var v []byte
+	assert.NoError(t, view(func(tx Tx) error {
+		bs, err := tx.Get(k)
+		v = append([]byte(nil), bs...)
+		if bs != nil && v == nil {
+			v = []byte{}
+		}
+		return err
+	}))
+	return v
+}
+
+func put(t *testing.T, k [][]byte, v string) {
+	assert.NoError(t, update(func(tx Tx) error {
+		return tx.Put(k, []byte(v))
+	}))
+}
+
+func testBackendGetPut(t *testing.T) {
+	assert.Equal(t, "v", get(t, keyOf("a", "b")), []byte(nil))
+
+	put(t, keyOf("a", "b"), "1")
+	assert.Equal(t, "v", string(get(t, keyOf("a", "b"))), "1")
+	put(t, keyOf("a", "b"), "2")
+	assert.Equal(t, "v", string(get(t, keyOf("a", "b"))), "2")
+
+	// An empty value is not missing.
+	put(t, keyOf("a", "c"), "")
+	assert.Equal(t, "v", get(t, keyOf("a", "c")), []byte{})
+
+	// Namespaces have no values.
+	put(t, keyOf("x", "y", "z"), "3")
+	assert.Equal(t, "v", get(t, keyOf("x", "y")), []byte(nil))
+	assert.Equal(t, "v", string(get(t, keyOf("x", "y", "z"))), "3")
+
+	// Putting a value into a value is an error.
+	assert.Error(t, update(func(tx Tx) error {
+		return tx.Put(keyOf("a", "b", "c"), []byte("4"))
+	}))
+}
+
+func testBackendDelete(t *testing.T) {
+	put(t, keyOf("a", "b"), "1")
+	put(t, keyOf("a", "c", "d"), "2")
+	put(t, keyOf("a", "c", "e", "f"), "3")
+	put(t, keyOf("a", "cc", "d"), "4")
+
+	assert.NoError(t, update(func(tx Tx) error {
+		return tx.Delete(keyOf("a", "b"))
+	}))
+	assert.Equal(t, "v", get(t, keyOf("a", "b")), []byte(nil))
+
+	// Deleting a namespace deletes everything in it.
+	assert.NoError(t, update(func(tx Tx) error {
+		return tx.Delete(keyOf("a", "c"))
+	}))
+	assert.Equal(t, "v", get(t, keyOf("a", "c", "d")), []byte(nil))
+	assert.Equal(t, "v", get(t, keyOf("a", "c", "e", "f")), []byte(nil))
+	assert.Equal(t, "v", string(get(t, keyOf("a", "cc", "d"))), "4")
+
+	// Deleting a missing key is not an error.
+	assert.NoError(t, update(func(tx Tx) error {
+		return tx.Delete(keyOf("missing", "b"))
+	}))
+}
+
+func testBackendForEach(t *testing.T) {
+	put(t, keyOf("a", "b"), "1")
+	put(t, keyOf("a", "\xff"), "2")
+	put(t, keyOf("a", "a", "c"), "3")
+	put(t, keyOf("a", "A"), "4")
+
+	var keys, values []string
+	var isNs []bool
+	assert.NoError(t, view(func(tx Tx) error {
+		return tx.ForEach(keyOf("a"), func(k, v []byte) error {
+			keys = append(keys, string(k))
+			values = append(values, string(v))
+			isNs = append(isNs, v == nil)
+			return nil
+		})
+	}))
+	assert.Equal(t, "keys", keys, []string{"A", "a", "b", "\xff"})
+	assert.Equal(t, "values", values, []string{"4", "", "1", "2"})
+	assert.Equal(t, "isNs", isNs, []bool{false, true, false, false})
+
+	// Iterating a missing namespace does nothing.
+	assert.NoError(t, view(func(tx Tx) error {
+		return tx.ForEach(keyOf("missing"), func(k, v []byte) error {
+			t.Errorf("Unexpected key %q", k)
+			return nil
+		})
+	}))
+
+	// Errors stop the iteration.
+	errStop := errors.New("stop")
+	cnt := 0
+	assert.Equal(t, "err", view(func(tx Tx) error {
+		return tx.ForEach(keyOf("a"), func(k, v []byte) error {
+			cnt++
+			return errStop
+		})
+	}), errStop)
+	assert.Equal(t, "cnt", cnt, 1)
+}
+
+func testBackendForEachFrom(t *testing.T) {
+	for _, k := range []string{"a", "b", "bb", "c"} {
+		put(t, keyOf("ns", k), k)
+	}
+	put(t, keyOf("ns", "ba", "x"), "5")
+
+	for _, c := range []struct {
+		from string
+		keys []string
+	}{
+		{"", []string{"a", "b", "ba", "bb", "c"}},
+		{"b", []string{"b", "ba", "bb", "c"}},
+		{"b\x00", []string{"ba", "bb", "c"}},
+		{"bc", []string{"c"}},
+		{"d", nil},
+	} {
+		var keys []string
+		assert.NoError(t, view(func(tx Tx) error {
+			return tx.ForEachFrom(keyOf("ns"), []byte(c.from), func(k, v []byte) error {
+				keys = append(keys, string(k))
+				return nil
+			})
+		}))
+		assert.Equal(t, fmt.Sprintf("keys from %q", c.from), keys, c.keys)
+	}
+}
+
+func testBackendForEachBefore(t *testing.T) {
+	for _, k := range []string{"a", "b", "bb", "c"} {
+		put(t, keyOf("ns", k), k)
+	}
+	put(t, keyOf("ns", "ba", "x"), "5")
+
+	for _, c := range []struct {
+		before []byte
+		keys   []string
+	}{
+		{nil, []string{"c", "bb", "ba", "b", "a"}},
+		{[]byte("d"), []string{"c", "bb", "ba", "b", "a"}},
+		{[]byte("bb"), []string{"ba", "b", "a"}},
+		{[]byte("b\x00"), []string{"b", "a"}},
+		{[]byte("a"), nil},
+	} {
+		var keys []string
+		assert.NoError(t, view(func(tx Tx) error {
+			return tx.ForEachBefore(keyOf("ns"), c.before, func(k, v []byte) error {
+				keys = append(keys, string(k))
+				return nil
+			})
+		}))
+		assert.Equal(t, fmt.Sprintf("keys before %q", c.before), keys, c.keys)
+	}
+
+	// Iterating a missing namespace does nothing.
+	assert.NoError(t, view(func(tx Tx) error {
+		return tx.ForEachBefore(keyOf("missing"), nil, func(k, v []byte) error {
+			t.Errorf("Unexpected key %q", k)
+			return nil
+		})
+	}))
+}
+
+func testBackendRollback(t *testing.T) {
+	put(t, keyOf("a", "b"), "1")
+
+	errFailed := errors.New("failed")
+	assert.Equal(t, "err", update(func(tx Tx) error {
+		if err := tx.Put(keyOf("a", "b"), []byte("2")); err != nil {
+			return err
+		}
+		if err := tx.Put(keyOf("a", "c"), []byte("3")); err != nil {
+			return err
+		}
+		if err := tx.Delete(keyOf("a", "b")); err != nil {
+			return err
+		}
+		return errFailed
+	}), errFailed)
+	assert.Equal(t, "v", string(get(t, keyOf("a", "b"))), "1")
+	assert.Equal(t, "v", get(t, keyOf("a", "c")), []byte(nil))
+}
+
+func testBackendReadOnly(t *testing.T) {
+	put(t, keyOf("a", "b"), "1")
+
+	assert.Error(t, view(func(tx Tx) error {
+		return tx.Put(keyOf("a", "c"), []byte("2"))
+	}))
+	assert.Error(t, view(func(tx Tx) error {
+		return tx.Delete(keyOf("a", "b"))
+	}))
+	assert.Equal(t, "v", get(t, keyOf("a", "c")), []byte(nil))
+	assert.Equal(t, "v", string(get(t, keyOf("a", "b"))), "1")
+}
@@ -0,0 +1,528 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/bolthelper"
+)
+
+// A backup is a directory, or a gzipped tarball of it, containing a snapshot
+// of the store and a manifest with the checksums.
+const (
+	backupManifestFn = "MANIFEST.json"
+	backupStoreFn    = "store.bolt"
+	backupTarSuffix  = ".tar.gz"
+)
+
+// ErrStoreNotEmpty is returned when restoring a backup into a store with data
+// without forcing.
+var ErrStoreNotEmpty = errors.New("store is not empty")
+
+type BackupFile struct {
+	Name   string
+	Size   int64
+	SHA256 string
+}
+
+// BackupManifest describes a backup.
+type BackupManifest struct {
+	Name    string
+	Created time.Time
+	// The schema version of the snapshot.
+	SchemaVersion int
+	// The sequence number of the last change in the snapshot, from which the
+	// change log can be followed after restoring it.
+	LastChangeSeq int64
+	Files         []BackupFile
+}
+
+type BackupOptions struct {
+	// Name of the backup, the creation time by default.
+	Name string
+	// Tarball saves the backup as a gzipped tarball instead of a directory.
+	Tarball bool
+}
+
+// Backup saves a consistent snapshot of the store into a backup named after
+// opts.Name in dir, while the store keeps serving.
+func Backup(dir string, opts BackupOptions) (*BackupManifest, error) {
+	b, err := currentBackend()
+	if err != nil {
+		return nil, err
+	}
+	return backup(b, dir, opts)
+}
+
+func backup(b Backend, dir string, opts BackupOptions) (*BackupManifest, error) {
+	now := time.Now().UTC()
+	if opts.Name == "" {
+		opts.Name = now.Format("20060102-150405")
+	}
+	if strings.ContainsAny(opts.Name, `/\`) || strings.HasPrefix(opts.Name, ".") {
+		return nil, errorsp.NewWithStacks("invalid backup name %q", opts.Name)
+	}
+	dst := filepath.Join(dir, opts.Name)
+	if opts.Tarball {
+		dst += backupTarSuffix
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return nil, errorsp.NewWithStacks("backup %v exists", dst)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	// The backup is prepared in a hidden directory and renamed at last, so a
+	// failed backup leaves nothing listed.
+	tmp, err := ioutil.TempDir(dir, "."+opts.Name)
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	storePath := filepath.Join(tmp, backupStoreFn)
+	if err := saveSnapshot(b, storePath); err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "saving snapshot to %v failed", storePath)
+	}
+	m := &BackupManifest{Name: opts.Name, Created: now}
+	// Reads the point in time from the snapshot itself, which is consistent
+	// with its content.
+	if err := openBoltFile(storePath).View(func(tx Tx) (err error) {
+		if m.SchemaVersion, err = readSchemaVersion(tx); err != nil {
+			return err
+		}
+		m.LastChangeSeq, err = getSeq(tx, lastChangeSeqKey)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	f, err := hashFile(tmp, backupStoreFn)
+	if err != nil {
+		return nil, err
+	}
+	m.Files = append(m.Files, f)
+	if err := writeManifest(tmp, m); err != nil {
+		return nil, err
+	}
+	if !opts.Tarball {
+		return m, errorsp.WithStacks(os.Rename(tmp, dst))
+	}
+	if err := writeTarball(tmp, dst+".tmp"); err != nil {
+		os.Remove(dst + ".tmp")
+		return nil, err
+	}
+	return m, errorsp.WithStacks(os.Rename(dst+".tmp", dst))
+}
+
+func openBoltFile(path string) Backend {
+	return NewBoltBackend(&bh.RefCountBox{DataPath: func() string { return path }})
+}
+
+func hashFile(dir, fn string) (BackupFile, error) {
+	f, err := os.Open(filepath.Join(dir, fn))
+	if err != nil {
+		return BackupFile{}, errorsp.WithStacks(err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return BackupFile{}, errorsp.WithStacks(err)
+	}
+	return BackupFile{Name: fn, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func writeManifest(dir string, m *BackupManifest) error {
+	bs, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return errorsp.WithStacks(ioutil.WriteFile(filepath.Join(dir, backupManifestFn), bs, 0644))
+}
+
+func readManifest(r io.Reader) (*BackupManifest, error) {
+	m := &BackupManifest{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "invalid manifest")
+	}
+	return m, nil
+}
+
+func readManifestOf(dir string) (*BackupManifest, error) {
+	f, err := os.Open(filepath.Join(dir, backupManifestFn))
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	defer f.Close()
+	return readManifest(f)
+}
+
+// writeTarball writes the manifest and the files in dir into a gzipped
+// tarball at path. The manifest is the first so that it is found quickly.
+func writeTarball(dir, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	fns := []string{backupManifestFn, backupStoreFn}
+	for _, fn := range fns {
+		if err := addToTar(tw, dir, fn); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	if err := gw.Close(); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return errorsp.WithStacks(f.Close())
+}
+
+func addToTar(tw *tar.Writer, dir, fn string) error {
+	f, err := os.Open(filepath.Join(dir, fn))
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    fn,
+		Mode:    0644,
+		Size:    st.Size(),
+		ModTime: st.ModTime(),
+	}); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	_, err = io.Copy(tw, f)
+	return errorsp.WithStacks(err)
+}
+
+// forEachInTarball calls f with the files in the gzipped tarball at path.
+func forEachInTarball(path string, f func(fn string, r io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	defer file.Close()
+	gr, err := gzip.NewReader(file)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "reading %v failed", path)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errorsp.WithStacksAndMessage(err, "reading %v failed", path)
+		}
+		if h.Typeflag != tar.TypeReg || filepath.Base(h.Name) != h.Name {
+			return errorsp.NewWithStacks("unexpected entry %q in %v", h.Name, path)
+		}
+		if err := f(h.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// openBackup returns the directory of the backup at path, extracting it into
+// a temporary one if it is a tarball, and a func removing the temporary one.
+func openBackup(path string) (dir string, cleanup func(), err error) {
+	if !strings.HasSuffix(path, backupTarSuffix) {
+		return path, func() {}, nil
+	}
+	dir, err = ioutil.TempDir("", "gcse-backup")
+	if err != nil {
+		return "", nil, errorsp.WithStacks(err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	if err := forEachInTarball(path, func(fn string, r io.Reader) error {
+		f, err := os.Create(filepath.Join(dir, fn))
+		if err != nil {
+			return errorsp.WithStacks(err)
+		}
+		defer f.Close()
+		_, err = io.Copy(f, r)
+		return errorsp.WithStacks(err)
+	}); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dir, cleanup, nil
+}
+
+// VerifyBackup checks the checksums of the files of the backup at path and
+// that the snapshot is readable, and returns its manifest.
+func VerifyBackup(path string) (*BackupManifest, error) {
+	dir, cleanup, err := openBackup(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return verifyBackupDir(dir)
+}
+
+func verifyBackupDir(dir string) (*BackupManifest, error) {
+	m, err := readManifestOf(dir)
+	if err != nil {
+		return nil, err
+	}
+	hasStore := false
+	for _, expected := range m.Files {
+		if filepath.Base(expected.Name) != expected.Name {
+			return nil, errorsp.NewWithStacks("invalid file name %q in the manifest", expected.Name)
+		}
+		f, err := hashFile(dir, expected.Name)
+		if err != nil {
+			return nil, err
+		}
+		if f != expected {
+			return nil, errorsp.NewWithStacks("%v is of size %d and SHA-256 %v, expected %d and %v",
+				f.Name, f.Size, f.SHA256, expected.Size, expected.SHA256)
+		}
+		hasStore = hasStore || f.Name == backupStoreFn
+	}
+	if !hasStore {
+		return nil, errorsp.NewWithStacks("%v is not in the manifest", backupStoreFn)
+	}
+	if err := openBoltFile(filepath.Join(dir, backupStoreFn)).View(func(tx Tx) error {
+		v, err := readSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if v != m.SchemaVersion {
+			return errorsp.NewWithStacks("schema version %d of the snapshot mismatches %d in the manifest", v, m.SchemaVersion)
+		}
+		for _, root := range allRoots {
+			if err := forEachValue(tx, [][]byte{root}, func([][]byte, []byte) error {
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "reading the snapshot failed")
+	}
+	return m, nil
+}
+
+type RestoreOptions struct {
+	// Force replaces the data in the store if it is not empty.
+	Force bool
+}
+
+// RestoreBackup replaces everything in the store with the backup at path
+// after verifying it, and migrates it to the latest schema version. The
+// sequence number of the change log is kept if it is beyond the one of the
+// backup, so new changes are numbered after both. ErrStoreNotEmpty is
+// returned if the store has data and opts.Force is false.
+func RestoreBackup(path string, opts RestoreOptions) (*BackupManifest, error) {
+	b, err := currentBackend()
+	if err != nil {
+		return nil, err
+	}
+	return restoreBackup(b, path, opts)
+}
+
+func restoreBackup(b Backend, path string, opts RestoreOptions) (*BackupManifest, error) {
+	dir, cleanup, err := openBackup(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	m, err := verifyBackupDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if m.SchemaVersion > LatestSchemaVersion() {
+		return nil, errorsp.NewWithStacks("schema version %d of the backup is newer than the supported %d", m.SchemaVersion, LatestSchemaVersion())
+	}
+	if err := openBoltFile(filepath.Join(dir, backupStoreFn)).View(func(src Tx) error {
+		return b.Update(func(tx Tx) error {
+			if !opts.Force {
+				empty, err := isEmpty(tx)
+				if err != nil {
+					return err
+				}
+				if !empty {
+					return ErrStoreNotEmpty
+				}
+			}
+			seq, err := getSeq(tx, lastChangeSeqKey)
+			if err != nil {
+				return err
+			}
+			for _, root := range allRoots {
+				if err := tx.Delete([][]byte{root}); err != nil {
+					return err
+				}
+				if err := copyTo(tx, src, [][]byte{root}); err != nil {
+					return err
+				}
+			}
+			// Never moves the sequence number back, otherwise the numbers
+			// already seen by the consumers of the change log would be
+			// reused for different changes.
+			restored, err := getSeq(tx, lastChangeSeqKey)
+			if err != nil || restored >= seq {
+				return err
+			}
+			return tx.Put(lastChangeSeqKey, []byte(strconv.FormatInt(seq, 10)))
+		})
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := migrate(b, MigrateOptions{}); err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "migrating the restored store failed")
+	}
+	return m, nil
+}
+
+// BackupInfo is a backup found in a directory.
+type BackupInfo struct {
+	Path     string
+	Manifest *BackupManifest
+}
+
+// ListBackups returns the backups in dir, oldest first. Entries failing to be
+// read are logged and skipped.
+func ListBackups(dir string) ([]*BackupInfo, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errorsp.WithStacks(err)
+	}
+	var backups []*BackupInfo
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		var m *BackupManifest
+		switch {
+		case fi.IsDir():
+			m, err = readManifestOf(path)
+		case strings.HasSuffix(fi.Name(), backupTarSuffix):
+			err = forEachInTarball(path, func(fn string, r io.Reader) error {
+				if fn != backupManifestFn {
+					return nil
+				}
+				var err error
+				if m, err = readManifest(r); err != nil {
+					return err
+				}
+				return errStopIter
+			})
+			if err == errStopIter {
+				err = nil
+			} else if err == nil {
+				err = errorsp.NewWithStacks("%v not found", backupManifestFn)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("Reading backup %v failed, skipped: %v", path, err)
+			continue
+		}
+		backups = append(backups, &BackupInfo{Path: path, Manifest: m})
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Manifest.Created.Before(backups[j].Manifest.Created)
+	})
+	return backups, nil
+}
+
+// RetentionPolicy selects the backups to keep. A backup is kept if any of
+// the rules keeps it.
+type RetentionPolicy struct {
+	// KeepLast keeps the newest backups.
+	KeepLast int
+	// KeepDaily keeps the newest backup of each of the latest days with
+	// backups.
+	KeepDaily int
+	// KeepWeekly keeps the newest backup of each of the latest ISO weeks with
+	// backups.
+	KeepWeekly int
+}
+
+// prunedBackups returns the backups, oldest first as ListBackups, not kept by
+// the policy.
+func prunedBackups(backups []*BackupInfo, p RetentionPolicy) []*BackupInfo {
+	kept := make(map[*BackupInfo]bool)
+	keepPeriods := func(n int, period func(t time.Time) string) {
+		seen := make(map[string]bool)
+		for i := len(backups) - 1; i >= 0 && len(seen) < n; i-- {
+			if key := period(backups[i].Manifest.Created.UTC()); !seen[key] {
+				seen[key] = true
+				kept[backups[i]] = true
+			}
+		}
+	}
+	keepPeriods(p.KeepLast, func(t time.Time) string {
+		return t.Format(time.RFC3339Nano)
+	})
+	keepPeriods(p.KeepDaily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPeriods(p.KeepWeekly, func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", y, w)
+	})
+	var pruned []*BackupInfo
+	for _, b := range backups {
+		if !kept[b] {
+			pruned = append(pruned, b)
+		}
+	}
+	return pruned
+}
+
+// PruneBackups deletes the backups in dir not kept by the policy, unless
+// dryRun is true, and returns them.
+func PruneBackups(dir string, p RetentionPolicy, dryRun bool) ([]*BackupInfo, error) {
+	if p.KeepLast <= 0 && p.KeepDaily <= 0 && p.KeepWeekly <= 0 {
+		return nil, errorsp.NewWithStacks("the retention policy keeps no backups")
+	}
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+	pruned := prunedBackups(backups, p)
+	if dryRun {
+		return pruned, nil
+	}
+	for _, b := range pruned {
+		if err := os.RemoveAll(b.Path); err != nil {
+			return nil, errorsp.WithStacks(err)
+		}
+	}
+	return pruned, nil
+}
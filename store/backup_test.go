@@ -0,0 +1,173 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+func TestBackupRestore(t *testing.T) {
+	cleanDatabase(t)
+	// Sets the schema version as opening the store does.
+	_, err := Migrate(MigrateOptions{})
+	assert.NoErrorOrDie(t, err)
+
+	dir, err := ioutil.TempDir("", "TestBackupRestore")
+	assert.NoErrorOrDie(t, err)
+	defer os.RemoveAll(dir)
+
+	const site = "TestBackupRestore.com"
+	assert.NoError(t, UpdatePackage(site, "gcse", func(info *gpb.PackageInfo) error {
+		info.Name = "gcse"
+		return nil
+	}))
+
+	for _, tarball := range []bool{false, true} {
+		name := "dir"
+		if tarball {
+			name = "tarball"
+		}
+		seq, err := LastChangeSeq()
+		assert.NoError(t, err)
+		m, err := Backup(dir, BackupOptions{Name: name, Tarball: tarball})
+		assert.NoErrorOrDie(t, err)
+		assert.Equal(t, "m.SchemaVersion", m.SchemaVersion, LatestSchemaVersion())
+		assert.Equal(t, "m.LastChangeSeq", m.LastChangeSeq, seq)
+
+		path := filepath.Join(dir, name)
+		if tarball {
+			path += backupTarSuffix
+		}
+		_, err = VerifyBackup(path)
+		assert.NoError(t, err)
+
+		// Changes after the backup are gone after restoring it.
+		assert.NoError(t, UpdatePackage(site, "gcse", func(info *gpb.PackageInfo) error {
+			info.Name = "changed"
+			return nil
+		}))
+		_, err = RestoreBackup(path, RestoreOptions{})
+		assert.Equal(t, "err", errorsp.Cause(err), ErrStoreNotEmpty)
+
+		before, err := LastChangeSeq()
+		assert.NoError(t, err)
+		_, err = RestoreBackup(path, RestoreOptions{Force: true})
+		assert.NoError(t, err)
+		info, err := ReadPackage(site, "gcse")
+		assert.NoError(t, err)
+		assert.Equal(t, "info.Name", info.Name, "gcse")
+		// The sequence number is not moved back to the one of the backup.
+		after, err := LastChangeSeq()
+		assert.NoError(t, err)
+		assert.Equal(t, "after", after, before)
+		assert.NoError(t, UpdatePackage(site, "other", func(info *gpb.PackageInfo) error {
+			return nil
+		}))
+		last, err := LastChangeSeq()
+		assert.NoError(t, err)
+		assert.Equal(t, "last", last, before+1)
+	}
+
+	backups, err := ListBackups(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(backups)", len(backups), 2)
+
+	// Restoring into an empty store needs no forcing.
+	cleanDatabase(t)
+	_, err = RestoreBackup(backups[0].Path, RestoreOptions{})
+	assert.NoError(t, err)
+	info, err := ReadPackage(site, "gcse")
+	assert.NoError(t, err)
+	assert.Equal(t, "info.Name", info.Name, "gcse")
+}
+
+func TestVerifyBackup_Corrupted(t *testing.T) {
+	cleanDatabase(t)
+
+	dir, err := ioutil.TempDir("", "TestVerifyBackup_Corrupted")
+	assert.NoErrorOrDie(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = Backup(dir, BackupOptions{Name: "backup"})
+	assert.NoErrorOrDie(t, err)
+	path := filepath.Join(dir, "backup")
+	f, err := os.OpenFile(filepath.Join(path, backupStoreFn), os.O_WRONLY|os.O_APPEND, 0)
+	assert.NoErrorOrDie(t, err)
+	_, err = f.Write([]byte("garbage"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	_, err = VerifyBackup(path)
+	assert.Error(t, err)
+	_, err = RestoreBackup(path, RestoreOptions{Force: true})
+	assert.Error(t, err)
+}
+
+func TestPrunedBackups(t *testing.T) {
+	at := func(s string) *BackupInfo {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		assert.NoErrorOrDie(t, err)
+		return &BackupInfo{Path: s, Manifest: &BackupManifest{Created: tm}}
+	}
+	// 2020-01-06 is a Monday.
+	backups := []*BackupInfo{
+		at("2020-01-01 10:00"),
+		at("2020-01-02 10:00"),
+		at("2020-01-06 10:00"),
+		at("2020-01-07 10:00"),
+		at("2020-01-07 12:00"),
+		at("2020-01-08 10:00"),
+	}
+	paths := func(backups []*BackupInfo) []string {
+		var ps []string
+		for _, b := range backups {
+			ps = append(ps, b.Path)
+		}
+		return ps
+	}
+	assert.Equal(t, "pruned", paths(prunedBackups(backups, RetentionPolicy{KeepLast: 2})), []string{
+		"2020-01-01 10:00", "2020-01-02 10:00", "2020-01-06 10:00", "2020-01-07 10:00",
+	})
+	assert.Equal(t, "pruned", paths(prunedBackups(backups, RetentionPolicy{KeepDaily: 3})), []string{
+		"2020-01-01 10:00", "2020-01-02 10:00", "2020-01-07 10:00",
+	})
+	assert.Equal(t, "pruned", paths(prunedBackups(backups, RetentionPolicy{KeepLast: 1, KeepWeekly: 2})), []string{
+		"2020-01-01 10:00", "2020-01-06 10:00", "2020-01-07 10:00", "2020-01-07 12:00",
+	})
+}
+
+func TestPruneBackups(t *testing.T) {
+	cleanDatabase(t)
+
+	dir, err := ioutil.TempDir("", "TestPruneBackups")
+	assert.NoErrorOrDie(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := Backup(dir, BackupOptions{Name: name})
+		assert.NoErrorOrDie(t, err)
+	}
+	_, err = PruneBackups(dir, RetentionPolicy{}, false)
+	assert.Error(t, err)
+
+	pruned, err := PruneBackups(dir, RetentionPolicy{KeepLast: 1}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(pruned)", len(pruned), 2)
+	backups, err := ListBackups(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(backups)", len(backups), 3)
+
+	_, err = PruneBackups(dir, RetentionPolicy{KeepLast: 1}, false)
+	assert.NoError(t, err)
+	backups, err = ListBackups(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(backups)", len(backups), 1)
+	assert.Equal(t, "backups[0].Manifest.Name", backups[0].Manifest.Name, "c")
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"github.com/golangplus/bytes"
+
+	"github.com/daviddengcn/bolthelper"
+)
+
+// boltBackend is a Backend storing the values in a BoltDB file. Namespaces
+// are buckets.
+type boltBackend struct {
+	box *bh.RefCountBox
+}
+
+// NewBoltBackend returns a Backend over the BoltDB file of box.
+func NewBoltBackend(box *bh.RefCountBox) Backend {
+	return boltBackend{box: box}
+}
+
+func (b boltBackend) View(f func(Tx) error) error {
+	return b.box.View(func(tx bh.Tx) error {
+		return f(boltTx{tx})
+	})
+}
+
+func (b boltBackend) Update(f func(Tx) error) error {
+	return b.box.Update(func(tx bh.Tx) error {
+		return f(boltTx{tx})
+	})
+}
+
+// Close does nothing since the file is opened only within transactions.
+func (b boltBackend) Close() error {
+	return nil
+}
+
+// snapshot copies the BoltDB file directly.
+func (b boltBackend) snapshot(path string) error {
+	return b.box.View(func(tx bh.Tx) error {
+		return tx.CopyFile(path, 0644)
+	})
+}
+
+type boltTx struct {
+	tx bh.Tx
+}
+
+func (t boltTx) Get(k [][]byte) ([]byte, error) {
+	var res []byte
+	err := t.tx.Value(k, func(v bytesp.Slice) error {
+		res = v
+		return nil
+	})
+	return res, err
+}
+
+func (t boltTx) Put(k [][]byte, v []byte) error {
+	if v == nil {
+		v = []byte{}
+	}
+	return t.tx.Put(k, v)
+}
+
+func (t boltTx) Delete(k [][]byte) error {
+	return t.tx.Delete(k)
+}
+
+func (t boltTx) ForEach(ns [][]byte, f func(k, v []byte) error) error {
+	return t.tx.ForEach(ns, func(_ bh.Bucket, k, v bytesp.Slice) error {
+		return f(k, v)
+	})
+}
+
+func (t boltTx) ForEachFrom(ns [][]byte, from []byte, f func(k, v []byte) error) error {
+	b, ok := t.tx.Bucket(ns)
+	if !ok {
+		return nil
+	}
+	c := b.Cursor()
+	for k, v := c.Seek(from); k != nil; k, v = c.Next() {
+		if err := f(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t boltTx) ForEachBefore(ns [][]byte, before []byte, f func(k, v []byte) error) error {
+	b, ok := t.tx.Bucket(ns)
+	if !ok {
+		return nil
+	}
+	c := b.Cursor()
+	var k, v []byte
+	if before == nil {
+		k, v = c.Last()
+	} else if k, v = c.Seek(before); k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	for ; k != nil; k, v = c.Prev() {
+		if err := f(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
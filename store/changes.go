@@ -0,0 +1,214 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golangplus/errors"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+var (
+	// changes
+	//  - <8-byte big-endian seq> -> Change
+	changesRoot = []byte("changes")
+
+	lastChangeSeqKey = [][]byte{metaRoot, []byte("last_change_seq")}
+	// The sequence number of the last compacted change.
+	compactedChangeSeqKey = [][]byte{metaRoot, []byte("compacted_change_seq")}
+)
+
+// ErrChangesCompacted is returned when reading the changes after a sequence
+// number some of which have been compacted. The consumer has to rescan the
+// store.
+var ErrChangesCompacted = errors.New("changes have been compacted")
+
+func changeKey(seq int64) [][]byte {
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], uint64(seq))
+	return [][]byte{changesRoot, bs[:]}
+}
+
+func getSeq(tx Tx, k [][]byte) (int64, error) {
+	bs, err := tx.Get(k)
+	if err != nil || bs == nil {
+		return 0, err
+	}
+	seq, err := strconv.ParseInt(string(bs), 10, 64)
+	return seq, errorsp.WithStacksAndMessage(err, "invalid sequence number %q", string(bs))
+}
+
+// changeOf returns the kind and the key of a change of the record of key k.
+// An empty kind is returned if changes of the record are not logged.
+func changeOf(k [][]byte) (kind, key string) {
+	switch {
+	case bytes.Equal(k[0], pkgsRoot):
+		kind = "package"
+	case bytes.Equal(k[0], personsRoot):
+		kind = "person"
+	case bytes.Equal(k[0], reposRoot):
+		kind = "repository"
+	case bytes.Equal(k[0], historyRoot) && len(k) > 1 && bytes.Equal(k[1], pkgsRoot):
+		kind, k = "package_history", k[1:]
+	case bytes.Equal(k[0], historyRoot) && len(k) > 1 && bytes.Equal(k[1], personsRoot):
+		kind, k = "person_history", k[1:]
+	default:
+		return "", ""
+	}
+	return kind, string(bytes.Join(k[1:], []byte("/")))
+}
+
+// logChange appends a change of the record of key k to the change log.
+func logChange(tx Tx, k [][]byte, op gpb.Change_Op_Enum) error {
+	kind, key := changeOf(k)
+	if kind == "" {
+		return nil
+	}
+	seq, err := getSeq(tx, lastChangeSeqKey)
+	if err != nil {
+		return err
+	}
+	seq++
+	ts, _ := ptypes.TimestampProto(time.Now())
+	if err := putMessage(tx, changeKey(seq), &gpb.Change{
+		Seq:       seq,
+		Kind:      kind,
+		Key:       key,
+		Op:        op,
+		Timestamp: ts,
+	}); err != nil {
+		return err
+	}
+	return tx.Put(lastChangeSeqKey, []byte(strconv.FormatInt(seq, 10)))
+}
+
+// notifier wakes up the watchers of the change log.
+type notifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+var changeNotifier = &notifier{ch: make(chan struct{})}
+
+// wait returns a channel closed on the next notify.
+func (n *notifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ch
+}
+
+func (n *notifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
+
+// LastChangeSeq returns the sequence number of the last change, 0 if none.
+func LastChangeSeq() (int64, error) {
+	var seq int64
+	err := view(func(tx Tx) (err error) {
+		seq, err = getSeq(tx, lastChangeSeqKey)
+		return err
+	})
+	return seq, err
+}
+
+// ForEachChange calls f with the changes after fromSeq in order.
+// ErrChangesCompacted is returned if some of them have been compacted.
+func ForEachChange(fromSeq int64, f func(*gpb.Change) error) error {
+	if r := currentRemote(); r != nil {
+		return r.ForEachChange(fromSeq, f)
+	}
+	_, err := forEachChange(fromSeq, f)
+	return err
+}
+
+// changesBatchSize is the number of changes read in a transaction, so that
+// slow consumers do not block the store.
+var changesBatchSize = 1000
+
+// forEachChange calls f with the changes after fromSeq, and returns the
+// sequence number of the last one, or fromSeq if none.
+func forEachChange(fromSeq int64, f func(*gpb.Change) error) (int64, error) {
+	last := fromSeq
+	for {
+		var batch []*gpb.Change
+		if err := view(func(tx Tx) error {
+			compacted, err := getSeq(tx, compactedChangeSeqKey)
+			if err != nil {
+				return err
+			}
+			if last < compacted {
+				return ErrChangesCompacted
+			}
+			// Seeks to the change after last instead of skipping the ones
+			// before it.
+			if err := tx.ForEachFrom([][]byte{changesRoot}, changeKey(last + 1)[1], func(k, v []byte) error {
+				c := &gpb.Change{}
+				if err := unmarshalMessage(v, c); err != nil {
+					return err
+				}
+				batch = append(batch, c)
+				if len(batch) >= changesBatchSize {
+					return errStopIter
+				}
+				return nil
+			}); err != errStopIter {
+				return err
+			}
+			return nil
+		}); err != nil {
+			return last, err
+		}
+		for _, c := range batch {
+			if err := errorsp.WithStacks(f(c)); err != nil {
+				return last, err
+			}
+			last = c.Seq
+		}
+		if len(batch) < changesBatchSize {
+			return last, nil
+		}
+	}
+}
+
+// CompactChanges deletes the changes logged before t and returns the number
+// of deleted ones.
+func CompactChanges(t time.Time) (int, error) {
+	var seqs []int64
+	err := update(func(tx Tx) error {
+		if err := tx.ForEach([][]byte{changesRoot}, func(k, v []byte) error {
+			c := &gpb.Change{}
+			if err := unmarshalMessage(v, c); err != nil {
+				return err
+			}
+			if ts, _ := ptypes.Timestamp(c.Timestamp); !ts.Before(t) {
+				return errStopIter
+			}
+			seqs = append(seqs, c.Seq)
+			return nil
+		}); err != nil && err != errStopIter {
+			return err
+		}
+		if len(seqs) == 0 {
+			return nil
+		}
+		for _, seq := range seqs {
+			if err := tx.Delete(changeKey(seq)); err != nil {
+				return err
+			}
+		}
+		return tx.Put(compactedChangeSeqKey, []byte(strconv.FormatInt(seqs[len(seqs)-1], 10)))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(seqs), nil
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golangplus/testing/assert"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// changeString formats a change without its timestamp for comparing.
+func changeString(c *gpb.Change) string {
+	return c.Kind + " " + c.Key + " " + c.Op.String()
+}
+
+func changesFrom(t *testing.T, fromSeq int64) []string {
+	var changes []string
+	assert.NoError(t, ForEachChange(fromSeq, func(c *gpb.Change) error {
+		changes = append(changes, changeString(c))
+		return nil
+	}))
+	return changes
+}
+
+func TestForEachChange(t *testing.T) {
+	cleanDatabase(t)
+
+	const site = "TestForEachChange.com"
+	assert.NoError(t, UpdatePackage(site, "gcse", func(info *gpb.PackageInfo) error {
+		info.Name = "gcse"
+		return nil
+	}))
+	assert.NoError(t, UpdatePerson(site, "daviddengcn", func(info *gpb.PersonInfo) error {
+		return nil
+	}))
+	// A rolled back update is not logged.
+	failed := errors.New("failed")
+	assert.Equal(t, "err", UpdatePackage(site, "villa", func(info *gpb.PackageInfo) error {
+		return failed
+	}) != nil, true)
+	assert.NoError(t, DeletePackage(site, "gcse"))
+	// Deleting a missing record is not logged.
+	assert.NoError(t, DeletePackage(site, "gcse"))
+
+	assert.Equal(t, "changes", changesFrom(t, 0), []string{
+		"package " + site + "/gcse Update",
+		"person " + site + "/daviddengcn Update",
+		"package " + site + "/gcse Delete",
+	})
+	assert.Equal(t, "changes", changesFrom(t, 2), []string{
+		"package " + site + "/gcse Delete",
+	})
+	assert.Equal(t, "changes", changesFrom(t, 3), []string(nil))
+}
+
+func TestForEachChange_NoOpUpdate(t *testing.T) {
+	cleanDatabase(t)
+
+	const site = "TestForEachChange_NoOpUpdate.com"
+	setName := func(info *gpb.PackageInfo) error {
+		info.Name = "gcse"
+		return nil
+	}
+	assert.NoError(t, UpdatePackage(site, "gcse", setName))
+	// Updates changing nothing are neither saved nor logged.
+	rev, err := updatePackage(site, "gcse", anyRevision, func(info *gpb.PackageInfo) error {
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "rev", rev, int64(1))
+	assert.NoError(t, UpdatePackage(site, "gcse", setName))
+	// A new package is saved even if it is empty.
+	assert.NoError(t, UpdatePackage(site, "villa", func(info *gpb.PackageInfo) error {
+		return nil
+	}))
+
+	assert.Equal(t, "changes", changesFrom(t, 0), []string{
+		"package " + site + "/gcse Update",
+		"package " + site + "/villa Update",
+	})
+}
+
+func TestForEachChange_Batches(t *testing.T) {
+	cleanDatabase(t)
+	defer func(n int) { changesBatchSize = n }(changesBatchSize)
+	changesBatchSize = 2
+
+	const site = "TestForEachChange_Batches.com"
+	var all []string
+	for _, path := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(t, UpdatePackage(site, path, func(info *gpb.PackageInfo) error {
+			return nil
+		}))
+		all = append(all, "package "+site+"/"+path+" Update")
+	}
+	assert.Equal(t, "changes", changesFrom(t, 0), all)
+	assert.Equal(t, "changes", changesFrom(t, 1), all[1:])
+	assert.Equal(t, "changes", changesFrom(t, 4), all[4:])
+}
+
+func TestCompactChanges(t *testing.T) {
+	cleanDatabase(t)
+
+	const site = "TestCompactChanges.com"
+	for _, path := range []string{"a", "b"} {
+		assert.NoError(t, UpdatePackage(site, path, func(info *gpb.PackageInfo) error {
+			return nil
+		}))
+	}
+	n, err := CompactChanges(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, "n", n, 2)
+	assert.NoError(t, UpdatePackage(site, "c", func(info *gpb.PackageInfo) error {
+		return nil
+	}))
+
+	last, err := LastChangeSeq()
+	assert.NoError(t, err)
+	assert.Equal(t, "last", last, int64(3))
+	assert.Equal(t, "err", ForEachChange(1, func(*gpb.Change) error {
+		return nil
+	}), ErrChangesCompacted)
+	assert.Equal(t, "changes", changesFrom(t, 2), []string{"package " + site + "/c Update"})
+
+	// Nothing is compacted before the remaining changes.
+	n, err = CompactChanges(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, "n", n, 0)
+}
+
+func TestRemote_WatchChanges(t *testing.T) {
+	cleanDatabase(t)
+	r, stop := startRemote(t)
+	defer stop()
+
+	const site = "TestRemote_WatchChanges.com"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan *gpb.Change)
+	done := make(chan error)
+	go func() {
+		done <- r.WatchChanges(ctx, 0, true, func(c *gpb.Change) error {
+			changes <- c
+			return nil
+		})
+	}()
+	for _, path := range []string{"a", "b"} {
+		assert.NoError(t, UpdatePackage(site, path, func(info *gpb.PackageInfo) error {
+			return nil
+		}))
+		select {
+		case c := <-changes:
+			assert.Equal(t, "change", changeString(c), "package "+site+"/"+path+" Update")
+		case <-time.After(5 * time.Second):
+			t.Fatalf("change of %v not received", path)
+		}
+	}
+	cancel()
+	<-done
+}
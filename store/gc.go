@@ -0,0 +1,213 @@
+package store
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golangplus/errors"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// Categories of the garbage found by GC.
+const (
+	// Packages neither in the docs nor being crawled.
+	GCOrphanPackages = "orphan_packages"
+	// Histories of the orphan packages and persons.
+	GCOrphanHistories = "orphan_histories"
+	// Histories of the orphan packages which were found invalid at last.
+	GCInvalidHistories = "invalid_histories"
+	// Persons without any package.
+	GCOrphanPersons = "orphan_persons"
+	// Repositories without any package.
+	GCOrphanRepos = "orphan_repos"
+	// Repositories not crawled for longer than GCOptions.RepoMaxAge.
+	GCExpiredRepos = "expired_repos"
+	// RepoInfo of packages older than GCOptions.RepoMaxAge. They are cleared
+	// instead of deleting the packages.
+	GCExpiredRepoInfos = "expired_repo_infos"
+)
+
+type GCOptions struct {
+	// IsLivePackage returns whether the package is known, e.g. in the docs or
+	// being crawled. Everything else is collected.
+	IsLivePackage func(site, path string) bool
+	// Repositories and RepoInfo crawled longer ago are expired, none if 0.
+	RepoMaxAge time.Duration
+	// Delete deletes the garbage, otherwise it is only reported.
+	Delete bool
+	// Found, if not nil, is called with the category and the key, whose
+	// elements are joined by "/", of every garbage found.
+	Found func(category, key string)
+}
+
+// GCStats is the number of the garbage found in each category.
+type GCStats map[string]int
+
+// gcEntry is a garbage found.
+type gcEntry struct {
+	category string
+	k        [][]byte
+}
+
+// GC finds, and deletes if opts.Delete is true, the entries in the local
+// store which are no longer useful, and returns the numbers of them. The
+// garbage is found and deleted in a single transaction, so that nothing
+// becomes live in between. GC fails if the store is remote.
+func GC(opts GCOptions) (GCStats, error) {
+	if opts.IsLivePackage == nil {
+		return nil, errorsp.NewWithStacks("IsLivePackage is not set")
+	}
+	if currentRemote() != nil {
+		return nil, errorsp.NewWithStacks("GC runs on the local store only, run it where the store service is")
+	}
+	var garbage []gcEntry
+	find := func(tx Tx) (err error) {
+		garbage, err = findGarbage(tx, opts, time.Now())
+		return err
+	}
+	if !opts.Delete {
+		if err := view(find); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := update(func(tx Tx) error {
+			if err := find(tx); err != nil {
+				return err
+			}
+			for _, e := range garbage {
+				if err := collect(tx, e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		changeNotifier.notify()
+	}
+	stats := make(GCStats)
+	for _, e := range garbage {
+		stats[e.category]++
+		if opts.Found != nil {
+			opts.Found(e.category, string(bytes.Join(e.k, []byte("/"))))
+		}
+	}
+	return stats, nil
+}
+
+// collect deletes or fixes a garbage entry in the transaction tx. Deletions
+// go through deleteMessageInTx so that they are in the change log.
+func collect(tx Tx, e gcEntry) error {
+	switch e.category {
+	case GCOrphanHistories, GCInvalidHistories:
+		return deleteHistoryInTx(tx, e.k[1], string(e.k[2]), string(e.k[3]))
+	case GCExpiredRepoInfos:
+		info := &gpb.PackageInfo{}
+		_, err := updateMessageInTx(tx, e.k, info, anyRevision, func(Tx) error {
+			info.RepoInfo = nil
+			return nil
+		})
+		return err
+	}
+	_, err := deleteMessageInTx(tx, e.k)
+	return err
+}
+
+// ownerAndRepo returns the first one and two elements of the path of a
+// package, which are the owner and the repository on the hosts like GitHub.
+func ownerAndRepo(path string) (owner, repo string) {
+	parts := strings.SplitN(path, "/", 3)
+	owner = parts[0]
+	if len(parts) > 1 {
+		repo = parts[0] + "/" + parts[1]
+	}
+	return owner, repo
+}
+
+func findGarbage(tx Tx, opts GCOptions, now time.Time) ([]gcEntry, error) {
+	var garbage []gcEntry
+	// site + "/" + owner, and site + "/" + owner + "/" + repo of the live
+	// packages.
+	livePersons := make(map[string]bool)
+	liveRepos := make(map[string]bool)
+	isExpired := func(t time.Time) bool {
+		return opts.RepoMaxAge > 0 && now.Sub(t) > opts.RepoMaxAge
+	}
+	if err := forEachValue(tx, [][]byte{pkgsRoot}, func(k [][]byte, v []byte) error {
+		site, path := string(k[1]), string(k[2])
+		if !opts.IsLivePackage(site, path) {
+			garbage = append(garbage, gcEntry{GCOrphanPackages, k})
+			return nil
+		}
+		owner, repo := ownerAndRepo(path)
+		livePersons[site+"/"+owner] = true
+		if repo != "" {
+			liveRepos[site+"/"+repo] = true
+		}
+		info := &gpb.PackageInfo{}
+		if err := unmarshalMessage(v, info); err != nil {
+			log.Printf("Unmarshal %q failed, skipped: %v", bytes.Join(k, []byte("/")), err)
+			return nil
+		}
+		if info.RepoInfo != nil {
+			if t, _ := ptypes.Timestamp(info.RepoInfo.CrawlingTime); isExpired(t) {
+				garbage = append(garbage, gcEntry{GCExpiredRepoInfos, k})
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := forEachValue(tx, [][]byte{historyRoot, pkgsRoot}, func(k [][]byte, v []byte) error {
+		if opts.IsLivePackage(string(k[2]), string(k[3])) {
+			return nil
+		}
+		hi := &gpb.HistoryInfo{}
+		if err := unmarshalMessage(v, hi); err == nil && len(hi.Events) > 0 && hi.Events[0].Action == gpb.HistoryEvent_Action_Invalid {
+			garbage = append(garbage, gcEntry{GCInvalidHistories, k})
+			return nil
+		}
+		garbage = append(garbage, gcEntry{GCOrphanHistories, k})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := forEachValue(tx, [][]byte{personsRoot}, func(k [][]byte, v []byte) error {
+		if !livePersons[string(k[1])+"/"+string(k[2])] {
+			garbage = append(garbage, gcEntry{GCOrphanPersons, k})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := forEachValue(tx, [][]byte{historyRoot, personsRoot}, func(k [][]byte, v []byte) error {
+		if !livePersons[string(k[2])+"/"+string(k[3])] {
+			garbage = append(garbage, gcEntry{GCOrphanHistories, k})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := forEachValue(tx, [][]byte{reposRoot}, func(k [][]byte, v []byte) error {
+		if !liveRepos[string(k[1])+"/"+string(k[2])+"/"+string(k[3])] {
+			garbage = append(garbage, gcEntry{GCOrphanRepos, k})
+			return nil
+		}
+		doc := &gpb.Repository{}
+		if err := unmarshalMessage(v, doc); err != nil {
+			log.Printf("Unmarshal %q failed, skipped: %v", bytes.Join(k, []byte("/")), err)
+			return nil
+		}
+		if t, _ := ptypes.Timestamp(doc.GetCrawlingInfo().GetCrawlingTime()); doc.CrawlingInfo != nil && isExpired(t) {
+			garbage = append(garbage, gcEntry{GCExpiredRepos, k})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return garbage, nil
+}
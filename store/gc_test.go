@@ -0,0 +1,114 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golangplus/testing/assert"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+func TestGC(t *testing.T) {
+	cleanDatabase(t)
+
+	const site = "github.com"
+	old, _ := ptypes.TimestampProto(time.Now().Add(-400 * 24 * time.Hour))
+	recent, _ := ptypes.TimestampProto(time.Now())
+	for _, path := range []string{"live/repo", "live/repo/sub", "live/expired", "live/old", "gone/repo"} {
+		assert.NoError(t, UpdatePackage(site, path, func(info *gpb.PackageInfo) error {
+			info.RepoInfo = &gpb.RepoInfo{CrawlingTime: recent}
+			if path == "live/expired" {
+				info.RepoInfo.CrawlingTime = old
+			}
+			return nil
+		}))
+		assert.NoError(t, AppendPackageEvent(site, path, "test", time.Now(), gpb.HistoryEvent_Action_Success))
+	}
+	assert.NoError(t, AppendPackageEvent(site, "bad/repo", "test", time.Now(), gpb.HistoryEvent_Action_Invalid))
+	for _, id := range []string{"live", "gone"} {
+		assert.NoError(t, UpdatePerson(site, id, func(*gpb.PersonInfo) error {
+			return nil
+		}))
+	}
+	for _, repo := range []string{"repo", "expired", "old"} {
+		assert.NoError(t, UpdateRepository(site, "live", repo, func(doc *gpb.Repository) error {
+			doc.CrawlingInfo = &gpb.CrawlingInfo{CrawlingTime: recent}
+			if repo == "old" {
+				doc.CrawlingInfo.CrawlingTime = old
+			}
+			return nil
+		}))
+	}
+	assert.NoError(t, UpdateRepository(site, "gone", "repo", func(doc *gpb.Repository) error {
+		return nil
+	}))
+
+	opts := GCOptions{
+		IsLivePackage: func(site, path string) bool {
+			return path == "live/repo" || path == "live/repo/sub" || path == "live/expired" || path == "live/old"
+		},
+		RepoMaxAge: 365 * 24 * time.Hour,
+	}
+	found := make(map[string][]string)
+	opts.Found = func(category, key string) {
+		found[category] = append(found[category], key)
+	}
+	stats, err := GC(opts)
+	assert.NoError(t, err)
+	expected := map[string][]string{
+		GCOrphanPackages:   {"pkgs/github.com/gone/repo"},
+		GCExpiredRepoInfos: {"pkgs/github.com/live/expired"},
+		GCInvalidHistories: {"history/pkgs/github.com/bad/repo"},
+		GCOrphanHistories:  {"history/pkgs/github.com/gone/repo"},
+		GCOrphanPersons:    {"persons/github.com/gone"},
+		GCOrphanRepos:      {"repos/github.com/gone/repo"},
+		GCExpiredRepos:     {"repos/github.com/live/old"},
+	}
+	assert.Equal(t, "found", found, expected)
+	assert.Equal(t, "stats", stats, GCStats{
+		GCOrphanPackages:   1,
+		GCExpiredRepoInfos: 1,
+		GCInvalidHistories: 1,
+		GCOrphanHistories:  1,
+		GCOrphanPersons:    1,
+		GCOrphanRepos:      1,
+		GCExpiredRepos:     1,
+	})
+
+	// A dry run deletes nothing.
+	stats2, err := GC(GCOptions{IsLivePackage: opts.IsLivePackage, RepoMaxAge: opts.RepoMaxAge})
+	assert.NoError(t, err)
+	assert.Equal(t, "stats2", stats2, stats)
+
+	opts.Delete = true
+	opts.Found = nil
+	_, err = GC(opts)
+	assert.NoError(t, err)
+	stats, err = GC(GCOptions{IsLivePackage: opts.IsLivePackage, RepoMaxAge: opts.RepoMaxAge})
+	assert.NoError(t, err)
+	assert.Equal(t, "stats", stats, GCStats{})
+
+	info, err := ReadPackage(site, "live/expired")
+	assert.NoError(t, err)
+	assert.Equal(t, "info.RepoInfo", info.RepoInfo, (*gpb.RepoInfo)(nil))
+	events, _, err := ReadPackageEvents(site, "gone/repo", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(events)", len(events), 0)
+}
+
+func TestGC_Remote(t *testing.T) {
+	cleanDatabase(t)
+	r, stop := startRemote(t)
+	defer stop()
+	defer UseRemote(UseRemote(r))
+
+	_, err := GC(GCOptions{
+		IsLivePackage: func(site, path string) bool {
+			return false
+		},
+		Delete: true,
+	})
+	assert.Error(t, err)
+}
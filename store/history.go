@@ -1,39 +1,66 @@
 package store
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
 	"log"
+	"os"
+	"sort"
 	"time"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
-	"github.com/golangplus/bytes"
 	"github.com/golangplus/errors"
 
 	"github.com/daviddengcn/bolthelper"
 
+	"github.com/daviddengcn/gcse/configs"
+
 	gpb "github.com/daviddengcn/gcse/shared/proto"
 )
 
+// SaveSnapshot saves everything in the local store into a BoltDB file at
+// path. SaveSnapshot fails if the store is remote.
 func SaveSnapshot(path string) error {
-	return box.Update(func(tx bh.Tx) error {
-		return tx.CopyFile(path, 0644)
+	if currentRemote() != nil {
+		return errorsp.NewWithStacks("SaveSnapshot runs on the local store only, run it where the store service is")
+	}
+	b, err := currentBackend()
+	if err != nil {
+		return err
+	}
+	return saveSnapshot(b, path)
+}
+
+func saveSnapshot(b Backend, path string) error {
+	if bb, ok := b.(boltBackend); ok {
+		return bb.snapshot(path)
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	dst := &bh.RefCountBox{DataPath: func() string { return path }}
+	return b.View(func(src Tx) error {
+		return dst.Update(func(tx bh.Tx) error {
+			for _, root := range allRoots {
+				if err := copyTo(boltTx{tx}, src, [][]byte{root}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 	})
 }
 
 const (
+	// The number of the latest events kept in HistoryInfo.
 	maxHistoryEvents = 10
 )
 
-func readHistoryOf(box *bh.RefCountBox, root []byte, site, idOrPath string) (*gpb.HistoryInfo, error) {
+func readHistoryOf(b Backend, root []byte, site, idOrPath string) (*gpb.HistoryInfo, error) {
 	info := &gpb.HistoryInfo{}
-	if err := box.View(func(tx bh.Tx) error {
-		return tx.Value([][]byte{historyRoot, root, []byte(site), []byte(idOrPath)}, func(bs bytesp.Slice) error {
-			if err := errorsp.WithStacksAndMessage(proto.Unmarshal(bs, info), "Unmarshal %d bytes failed", len(bs)); err != nil {
-				log.Printf("Unmarshal failed: %v", err)
-				*info = gpb.HistoryInfo{}
-			}
-			return nil
-		})
+	if err := b.View(func(tx Tx) error {
+		return getMessage(tx, historyKey(root, site, idOrPath), info)
 	}); err != nil {
 		return nil, err
 	}
@@ -41,96 +68,342 @@ func readHistoryOf(box *bh.RefCountBox, root []byte, site, idOrPath string) (*gp
 }
 
 func readHistory(root []byte, site, idOrPath string) (*gpb.HistoryInfo, error) {
-	return readHistoryOf(box, root, site, idOrPath)
+	info, _, err := readHistoryRev(root, site, idOrPath)
+	return info, err
 }
 
 func ReadPackageHistory(site, path string) (*gpb.HistoryInfo, error) {
+	if r := currentRemote(); r != nil {
+		return r.ReadPackageHistory(site, path)
+	}
 	return readHistory(pkgsRoot, site, path)
 }
 
+// ReadPackageHistoryOf reads the package history in the BoltDB file of box,
+// e.g. a snapshot saved by SaveSnapshot. ErrCorruptRecord is returned if it
+// is unreadable.
 func ReadPackageHistoryOf(box *bh.RefCountBox, site, path string) (*gpb.HistoryInfo, error) {
-	return readHistoryOf(box, pkgsRoot, site, path)
+	return readHistoryOf(NewBoltBackend(box), pkgsRoot, site, path)
 }
 
 func ReadPersonHistory(site, path string) (*gpb.HistoryInfo, error) {
+	if r := currentRemote(); r != nil {
+		return r.ReadPersonHistory(site, path)
+	}
 	return readHistory(personsRoot, site, path)
 }
 
-func updateHistory(root []byte, site, idOrPath string, f func(*gpb.HistoryInfo) error) error {
-	return box.Update(func(tx bh.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([][]byte{historyRoot, root, []byte(site)})
+// historyOwnerRoot returns the root of the history of a person if person is
+// true, of a package otherwise.
+func historyOwnerRoot(person bool) []byte {
+	if person {
+		return personsRoot
+	}
+	return pkgsRoot
+}
+
+// readHistoryRev returns the history with its revision.
+func readHistoryRev(root []byte, site, idOrPath string) (*gpb.HistoryInfo, int64, error) {
+	info := &gpb.HistoryInfo{}
+	rev, err := readMessage(historyKey(root, site, idOrPath), info)
+	if err != nil {
+		return nil, 0, err
+	}
+	return info, rev, nil
+}
+
+func historyKey(root []byte, site, idOrPath string) [][]byte {
+	return [][]byte{historyRoot, root, []byte(site), []byte(idOrPath)}
+}
+
+// updateHistory updates the history and returns the new revision. See
+// updateMessage for rev.
+func updateHistory(root []byte, site, idOrPath string, rev int64, f func(*gpb.HistoryInfo) error) (int64, error) {
+	info := &gpb.HistoryInfo{}
+	return updateMessage(historyKey(root, site, idOrPath), info, rev, func() error {
+		return f(info)
+	})
+}
+
+func UpdatePackageHistory(site, path string, f func(*gpb.HistoryInfo) error) error {
+	if r := currentRemote(); r != nil {
+		return r.UpdatePackageHistory(site, path, f)
+	}
+	_, err := updateHistory(pkgsRoot, site, path, anyRevision, f)
+	return err
+}
+
+// eventsNamespace returns the namespace of the event log.
+func eventsNamespace(root []byte, site, idOrPath string) [][]byte {
+	return [][]byte{eventsRoot, root, []byte(site), []byte(idOrPath)}
+}
+
+// eventName returns the name of an event of time t in the event log, which
+// sorts the events in time order.
+func eventName(t time.Time) []byte {
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], uint64(t.UnixNano()))
+	return bs[:]
+}
+
+func eventKey(ns [][]byte, name []byte) [][]byte {
+	return append(append([][]byte{}, ns...), name)
+}
+
+// putEvent saves e in the event log of namespace ns. The event is moved a
+// nanosecond later if another one is of the same time.
+func putEvent(tx Tx, ns [][]byte, e *gpb.HistoryEvent) error {
+	t, err := ptypes.Timestamp(e.Timestamp)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "invalid timestamp %v", e.Timestamp)
+	}
+	for ; ; t = t.Add(time.Nanosecond) {
+		k := eventKey(ns, eventName(t))
+		bs, err := tx.Get(k)
 		if err != nil {
 			return err
 		}
-		info := &gpb.HistoryInfo{}
-		if err := b.Value([][]byte{[]byte(idOrPath)}, func(bs bytesp.Slice) error {
-			err := errorsp.WithStacksAndMessage(proto.Unmarshal(bs, info), "Unmarshal %d bytes", len(bs))
-			if err != nil {
-				log.Printf("Unmarshaling failed: %v", err)
-				*info = gpb.HistoryInfo{}
-			}
-			return nil
-		}); err != nil {
+		if bs == nil {
+			return putMessage(tx, k, e)
+		}
+	}
+}
+
+// addToRollups counts e in the rollup of its day.
+func addToRollups(hi *gpb.HistoryInfo, e *gpb.HistoryEvent) {
+	t, _ := ptypes.Timestamp(e.Timestamp)
+	day := t.UTC().Truncate(24 * time.Hour)
+	i := sort.Search(len(hi.Rollups), func(i int) bool {
+		d, _ := ptypes.Timestamp(hi.Rollups[i].Day)
+		return !d.Before(day)
+	})
+	found := false
+	if i < len(hi.Rollups) {
+		d, _ := ptypes.Timestamp(hi.Rollups[i].Day)
+		found = d.Equal(day)
+	}
+	if !found {
+		ts, _ := ptypes.TimestampProto(day)
+		hi.Rollups = append(hi.Rollups, nil)
+		copy(hi.Rollups[i+1:], hi.Rollups[i:])
+		hi.Rollups[i] = &gpb.HistoryRollup{Day: ts}
+	}
+	switch r := hi.Rollups[i]; e.Action {
+	case gpb.HistoryEvent_Action_Success:
+		r.Success++
+	case gpb.HistoryEvent_Action_Failed:
+		r.Failed++
+	case gpb.HistoryEvent_Action_Invalid:
+		r.Invalid++
+	}
+}
+
+// rollUpEvents moves the events in the event log of namespace ns older than
+// configs.StoreHistoryDetailAge before now into the rollups of hi, and drops
+// the rollups older than configs.StoreHistoryRetention.
+func rollUpEvents(tx Tx, ns [][]byte, hi *gpb.HistoryInfo, now time.Time) error {
+	detailFrom := eventName(now.Add(-configs.StoreHistoryDetailAge))
+	var names [][]byte
+	if err := tx.ForEach(ns, func(name, v []byte) error {
+		if bytes.Compare(name, detailFrom) >= 0 {
+			return errStopIter
+		}
+		e := &gpb.HistoryEvent{}
+		if err := unmarshalMessage(v, e); err != nil {
 			return err
 		}
-		if err := errorsp.WithStacks(f(info)); err != nil {
+		addToRollups(hi, e)
+		names = append(names, append([]byte{}, name...))
+		return nil
+	}); err != nil && err != errStopIter {
+		return err
+	}
+	for _, name := range names {
+		if err := tx.Delete(eventKey(ns, name)); err != nil {
 			return err
 		}
-		bs, err := proto.Marshal(info)
-		if err != nil {
-			return errorsp.WithStacksAndMessage(err, "marshaling %v failed: %v", info, err)
+	}
+	if configs.StoreHistoryRetention <= 0 {
+		return nil
+	}
+	from := now.Add(-configs.StoreHistoryRetention).UTC().Truncate(24 * time.Hour)
+	for len(hi.Rollups) > 0 {
+		if d, _ := ptypes.Timestamp(hi.Rollups[0].Day); !d.Before(from) {
+			break
 		}
-		return b.Put([][]byte{[]byte(idOrPath)}, bs)
-	})
+		hi.Rollups = hi.Rollups[1:]
+	}
+	return nil
 }
 
-func UpdatePackageHistory(site, path string, f func(*gpb.HistoryInfo) error) error {
-	return updateHistory(pkgsRoot, site, path, f)
+// copyEventsToLog saves the events kept in the package histories, which were
+// all the history before the event log, into the event logs.
+func copyEventsToLog(tx Tx) error {
+	type entry struct {
+		ns     [][]byte
+		events []*gpb.HistoryEvent
+	}
+	var entries []entry
+	if err := forEachValue(tx, [][]byte{historyRoot, pkgsRoot}, func(k [][]byte, v []byte) error {
+		hi := &gpb.HistoryInfo{}
+		if err := unmarshalMessage(v, hi); err != nil {
+			return err
+		}
+		entries = append(entries, entry{ns: eventsNamespace(pkgsRoot, string(k[2]), string(k[3])), events: hi.Events})
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, en := range entries {
+		for _, e := range en.events {
+			if e.Timestamp == nil {
+				continue
+			}
+			if err := putEvent(tx, en.ns, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func AppendPackageEvent(site, path, foundWay string, t time.Time, a gpb.HistoryEvent_Action_Enum) error {
-	return UpdatePackageHistory(site, path, func(hi *gpb.HistoryInfo) error {
+	if r := currentRemote(); r != nil {
+		return r.AppendPackageEvent(site, path, foundWay, t, a)
+	}
+	ts, err := ptypes.TimestampProto(t)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "invalid time %v", t)
+	}
+	return appendPackageEvent(site, path, foundWay, &gpb.HistoryEvent{Timestamp: ts, Action: a})
+}
+
+// AppendPackageHistoryEvent appends e, with the details of the crawling, to
+// the history of the package.
+func AppendPackageHistoryEvent(site, path string, e *gpb.HistoryEvent) error {
+	if r := currentRemote(); r != nil {
+		return r.AppendPackageHistoryEvent(site, path, e)
+	}
+	return appendPackageEvent(site, path, "", e)
+}
+
+// appendPackageEvent appends e to the event log of the package, and rolls up
+// the events which are old compared to e.
+func appendPackageEvent(site, path, foundWay string, e *gpb.HistoryEvent) error {
+	t, err := ptypes.Timestamp(e.Timestamp)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "invalid timestamp %v", e.Timestamp)
+	}
+	hi := &gpb.HistoryInfo{}
+	_, err = updateMessageTx(historyKey(pkgsRoot, site, path), hi, anyRevision, func(tx Tx) error {
 		if hi.FoundTime == nil {
 			// The first time the package was found
-			hi.FoundTime, _ = ptypes.TimestampProto(t)
+			hi.FoundTime = e.Timestamp
 			hi.FoundWay = foundWay
 		}
-		if a == gpb.HistoryEvent_Action_None {
+		if e.Action == gpb.HistoryEvent_Action_None {
 			return nil
 		}
 		// Insert the event
-		tsp, _ := ptypes.TimestampProto(t)
-		hi.Events = append([]*gpb.HistoryEvent{{
-			Action:    a,
-			Timestamp: tsp,
-		}}, hi.Events...)
+		hi.Events = append([]*gpb.HistoryEvent{e}, hi.Events...)
 		if len(hi.Events) > maxHistoryEvents {
 			hi.Events = hi.Events[:maxHistoryEvents]
 		}
-		switch a {
+		switch e.Action {
 		case gpb.HistoryEvent_Action_Success:
-			hi.LatestSuccess = tsp
+			hi.LatestSuccess = e.Timestamp
 		case gpb.HistoryEvent_Action_Failed:
-			hi.LatestFailed = tsp
+			hi.LatestFailed = e.Timestamp
 		}
-		return nil
+		ns := eventsNamespace(pkgsRoot, site, path)
+		if err := putEvent(tx, ns, e); err != nil {
+			return err
+		}
+		return rollUpEvents(tx, ns, hi, t)
 	})
+	return err
+}
+
+// ReadPackageEvents returns at most n, or all if n <= 0, events in the event
+// log of the package, newest first. The events older than those of the last
+// page are returned if pageToken is the token returned with the page. The
+// returned token is empty if there are no more events.
+func ReadPackageEvents(site, path, pageToken string, n int) ([]*gpb.HistoryEvent, string, error) {
+	if r := currentRemote(); r != nil {
+		return r.ReadPackageEvents(site, path, pageToken, n)
+	}
+	return readPackageEvents(site, path, pageToken, n)
+}
+
+func readPackageEvents(site, path, pageToken string, n int) ([]*gpb.HistoryEvent, string, error) {
+	before, err := hex.DecodeString(pageToken)
+	if err != nil {
+		return nil, "", errorsp.WithStacksAndMessage(err, "invalid page token %q", pageToken)
+	}
+	if len(before) == 0 {
+		before = nil
+	}
+	var events []*gpb.HistoryEvent
+	var token string
+	if err := view(func(tx Tx) error {
+		return tx.ForEachBefore(eventsNamespace(pkgsRoot, site, path), before, func(name, v []byte) error {
+			if n > 0 && len(events) == n {
+				// There are more events.
+				token = hex.EncodeToString(before)
+				return errStopIter
+			}
+			e := &gpb.HistoryEvent{}
+			if err := unmarshalMessage(v, e); err != nil {
+				log.Printf("Unmarshal event %x of %v/%v failed, ignored: %v", name, site, path, err)
+				return nil
+			}
+			before = append([]byte{}, name...)
+			events = append(events, e)
+			return nil
+		})
+	}); err != nil && err != errStopIter {
+		return nil, "", err
+	}
+	return events, token, nil
 }
 
 func UpdatePersonHistory(site, path string, f func(*gpb.HistoryInfo) error) error {
-	return updateHistory(personsRoot, site, path, f)
+	if r := currentRemote(); r != nil {
+		return r.UpdatePersonHistory(site, path, f)
+	}
+	_, err := updateHistory(personsRoot, site, path, anyRevision, f)
+	return err
 }
 
 func deleteHistory(root []byte, site, idOrPath string) error {
-	return box.Update(func(tx bh.Tx) error {
-		return tx.Delete([][]byte{historyRoot, root, []byte(site), []byte(idOrPath)})
-	})
+	if err := update(func(tx Tx) error {
+		return deleteHistoryInTx(tx, root, site, idOrPath)
+	}); err != nil {
+		return err
+	}
+	changeNotifier.notify()
+	return nil
+}
+
+// deleteHistoryInTx deletes the history with its event log in the
+// transaction tx.
+func deleteHistoryInTx(tx Tx, root []byte, site, idOrPath string) error {
+	if _, err := deleteMessageInTx(tx, historyKey(root, site, idOrPath)); err != nil {
+		return err
+	}
+	return tx.Delete(eventsNamespace(root, site, idOrPath))
 }
 
 func DeletePackageHistory(site, path string) error {
+	if r := currentRemote(); r != nil {
+		return r.DeletePackageHistory(site, path)
+	}
 	return deleteHistory(pkgsRoot, site, path)
 }
 
 func DeletePersonHistory(site, path string) error {
+	if r := currentRemote(); r != nil {
+		return r.DeletePersonHistory(site, path)
+	}
 	return deleteHistory(personsRoot, site, path)
 }
@@ -5,12 +5,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/golangplus/testing/assert"
 
 	"github.com/daviddengcn/bolthelper"
 	"github.com/daviddengcn/go-villa"
 
+	"github.com/daviddengcn/gcse/configs"
+
 	gpb "github.com/daviddengcn/gcse/shared/proto"
 )
 
@@ -89,6 +93,112 @@ func TestAppendPackageEvent(t *testing.T) {
 	})
 }
 
+func TestReadPackageEvents(t *testing.T) {
+	cleanDatabase(t)
+
+	const (
+		site = "TestReadPackageEvents.com"
+		path = "gcse"
+	)
+	start := time.Now().Add(-25 * time.Hour)
+	var expected []*gpb.HistoryEvent
+	for i := 0; i < 25; i++ {
+		ts, _ := ptypes.TimestampProto(start.Add(time.Duration(i) * time.Hour))
+		e := &gpb.HistoryEvent{
+			Timestamp:      ts,
+			Action:         gpb.HistoryEvent_Action_Failed,
+			FailureReason:  "403",
+			Etag:           "etag",
+			CrawlerVersion: 5,
+		}
+		assert.NoError(t, AppendPackageHistoryEvent(site, path, proto.Clone(e).(*gpb.HistoryEvent)))
+		expected = append([]*gpb.HistoryEvent{e}, expected...)
+	}
+	h, err := ReadPackageHistory(site, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "h.Events", h.Events, expected[:maxHistoryEvents])
+
+	var events []*gpb.HistoryEvent
+	token := ""
+	for pages := 1; ; pages++ {
+		page, next, err := ReadPackageEvents(site, path, token, 10)
+		assert.NoErrorOrDie(t, err)
+		events = append(events, page...)
+		if next == "" {
+			assert.Equal(t, "pages", pages, 3)
+			break
+		}
+		assert.Equal(t, "len(page)", len(page), 10)
+		token = next
+	}
+	assert.Equal(t, "events", events, expected)
+
+	all, next, err := ReadPackageEvents(site, path, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "all", all, expected)
+	assert.Equal(t, "next", next, "")
+
+	// An unreadable event is skipped.
+	put(t, eventKey(eventsNamespace(pkgsRoot, site, path), eventName(start.Add(30*time.Minute))), unreadable)
+	all, _, err = ReadPackageEvents(site, path, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "all", all, expected)
+
+	assert.NoError(t, DeletePackageHistory(site, path))
+	all, _, err = ReadPackageEvents(site, path, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "all", all, []*gpb.HistoryEvent(nil))
+}
+
+func TestAppendPackageEvent_Rollup(t *testing.T) {
+	cleanDatabase(t)
+	defer func(detailAge, retention time.Duration) {
+		configs.StoreHistoryDetailAge, configs.StoreHistoryRetention = detailAge, retention
+	}(configs.StoreHistoryDetailAge, configs.StoreHistoryRetention)
+	configs.StoreHistoryDetailAge = 2 * 24 * time.Hour
+	configs.StoreHistoryRetention = 10 * 24 * time.Hour
+
+	const (
+		site = "TestAppendPackageEvent_Rollup.com"
+		path = "gcse"
+	)
+	day := func(d int) *timestamp.Timestamp {
+		ts, _ := ptypes.TimestampProto(time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC))
+		return ts
+	}
+	base := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	appendEvent := func(d time.Duration, a gpb.HistoryEvent_Action_Enum) {
+		assert.NoError(t, AppendPackageEvent(site, path, "", base.Add(d), a))
+	}
+	appendEvent(0, gpb.HistoryEvent_Action_Success)
+	appendEvent(time.Hour, gpb.HistoryEvent_Action_Failed)
+	appendEvent(24*time.Hour, gpb.HistoryEvent_Action_Success)
+	appendEvent(5*24*time.Hour, gpb.HistoryEvent_Action_Invalid)
+
+	h, err := ReadPackageHistory(site, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "h.Rollups", h.Rollups, []*gpb.HistoryRollup{
+		{Day: day(1), Success: 1, Failed: 1},
+		{Day: day(2), Success: 1},
+	})
+	events, _, err := ReadPackageEvents(site, path, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(events)", len(events), 1)
+	// The latest events are kept in the history.
+	assert.Equal(t, "len(h.Events)", len(h.Events), 4)
+
+	// The rollups older than the retention are dropped.
+	appendEvent(15*24*time.Hour, gpb.HistoryEvent_Action_Success)
+	h, err = ReadPackageHistory(site, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "h.Rollups", h.Rollups, []*gpb.HistoryRollup{
+		{Day: day(6), Invalid: 1},
+	})
+	events, _, err = ReadPackageEvents(site, path, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "len(events)", len(events), 1)
+}
+
 func TestUpdateReadDeletePersonHistory(t *testing.T) {
 	const (
 		site     = "TestUpdateReadDeletePersonHistory.com"
@@ -139,3 +249,13 @@ func TestSaveSnapshot(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "h", h, &gpb.HistoryInfo{FoundWay: foundWay})
 }
+
+func TestSaveSnapshot_Remote(t *testing.T) {
+	cleanDatabase(t)
+	r, stop := startRemote(t)
+	defer stop()
+	defer UseRemote(UseRemote(r))
+
+	outPath := villa.Path(os.TempDir()).Join("TestSaveSnapshot_Remote").S()
+	assert.Error(t, SaveSnapshot(outPath))
+}
@@ -0,0 +1,30 @@
+//go:build windows || plan9
+
+package store
+
+import (
+	"os"
+	"time"
+
+	"github.com/golangplus/errors"
+)
+
+// lockFile takes an exclusive lock of fn by creating it, waiting for others
+// holding it. The lock is released by the returned function. Unlike flock,
+// the lock is left behind if the process dies, and has to be removed
+// manually.
+func lockFile(fn string) (func(), error) {
+	for {
+		f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return func() {
+				os.Remove(fn)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errorsp.WithStacks(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
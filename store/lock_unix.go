@@ -0,0 +1,27 @@
+//go:build !windows && !plan9
+
+package store
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/golangplus/errors"
+)
+
+// lockFile takes an exclusive lock of fn, waiting for others holding it. The
+// lock is released by the returned function or when the process exits.
+func lockFile(fn string) (func(), error) {
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, errorsp.WithStacks(err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
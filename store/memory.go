@@ -0,0 +1,196 @@
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+// memNode is a value, if value is non-nil, or a namespace.
+type memNode struct {
+	value    []byte
+	children map[string]*memNode
+}
+
+func newMemNamespace() *memNode {
+	return &memNode{children: make(map[string]*memNode)}
+}
+
+// memoryBackend is a Backend keeping everything in memory, e.g. for testing.
+// An update changes the data in place and records the replaced children in
+// an undo log, which is replayed backward if the update fails.
+type memoryBackend struct {
+	mu   sync.RWMutex
+	root *memNode
+}
+
+// NewMemoryBackend returns an empty in-memory Backend.
+func NewMemoryBackend() Backend {
+	return &memoryBackend{root: newMemNamespace()}
+}
+
+func (b *memoryBackend) View(f func(Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return f(memTx{root: b.root})
+}
+
+func (b *memoryBackend) Update(f func(Tx) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	undo := &memUndoLog{}
+	if err := f(memTx{root: b.root, undo: undo}); err != nil {
+		undo.rollback()
+		return err
+	}
+	return nil
+}
+
+func (b *memoryBackend) Close() error {
+	return nil
+}
+
+// memUndo records the child of a namespace before it was replaced. A nil
+// child means the key did not exist.
+type memUndo struct {
+	ns    *memNode
+	key   string
+	child *memNode
+}
+
+type memUndoLog []memUndo
+
+// set sets the child of ns and records the old one.
+func (l *memUndoLog) set(ns *memNode, key string, child *memNode) {
+	*l = append(*l, memUndo{ns: ns, key: key, child: ns.children[key]})
+	if child == nil {
+		delete(ns.children, key)
+	} else {
+		ns.children[key] = child
+	}
+}
+
+func (l *memUndoLog) rollback() {
+	for i := len(*l) - 1; i >= 0; i-- {
+		u := (*l)[i]
+		if u.child == nil {
+			delete(u.ns.children, u.key)
+		} else {
+			u.ns.children[u.key] = u.child
+		}
+	}
+	*l = nil
+}
+
+// memTx is writable if undo is not nil.
+type memTx struct {
+	root *memNode
+	undo *memUndoLog
+}
+
+// namespace returns the namespace of ns, nil if not found.
+func (t memTx) namespace(ns [][]byte) *memNode {
+	n := t.root
+	for _, k := range ns {
+		if n = n.children[string(k)]; n == nil || n.value != nil {
+			return nil
+		}
+	}
+	return n
+}
+
+func (t memTx) Get(k [][]byte) ([]byte, error) {
+	n := t.namespace(k[:len(k)-1])
+	if n == nil {
+		return nil, nil
+	}
+	if c := n.children[string(k[len(k)-1])]; c != nil {
+		return c.value, nil
+	}
+	return nil, nil
+}
+
+func (t memTx) Put(k [][]byte, v []byte) error {
+	if t.undo == nil {
+		return errTxReadOnly
+	}
+	n := t.root
+	for _, p := range k[:len(k)-1] {
+		c := n.children[string(p)]
+		if c == nil {
+			c = newMemNamespace()
+			t.undo.set(n, string(p), c)
+		} else if c.value != nil {
+			return errNotNamespace
+		}
+		n = c
+	}
+	if c := n.children[string(k[len(k)-1])]; c != nil && c.value == nil {
+		return errNotValue
+	}
+	t.undo.set(n, string(k[len(k)-1]), &memNode{value: append([]byte{}, v...)})
+	return nil
+}
+
+func (t memTx) Delete(k [][]byte) error {
+	if t.undo == nil {
+		return errTxReadOnly
+	}
+	if n := t.namespace(k[:len(k)-1]); n != nil && n.children[string(k[len(k)-1])] != nil {
+		t.undo.set(n, string(k[len(k)-1]), nil)
+	}
+	return nil
+}
+
+func (t memTx) ForEach(ns [][]byte, f func(k, v []byte) error) error {
+	return t.ForEachFrom(ns, nil, f)
+}
+
+func (t memTx) ForEachFrom(ns [][]byte, from []byte, f func(k, v []byte) error) error {
+	n := t.namespace(ns)
+	if n == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(n.children))
+	for k := range n.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys[sort.SearchStrings(keys, string(from)):] {
+		c := n.children[k]
+		if c == nil {
+			// Deleted by f.
+			continue
+		}
+		if err := f([]byte(k), c.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t memTx) ForEachBefore(ns [][]byte, before []byte, f func(k, v []byte) error) error {
+	n := t.namespace(ns)
+	if n == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(n.children))
+	for k := range n.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	end := len(keys)
+	if before != nil {
+		end = sort.SearchStrings(keys, string(before))
+	}
+	for i := end - 1; i >= 0; i-- {
+		c := n.children[keys[i]]
+		if c == nil {
+			// Deleted by f.
+			continue
+		}
+		if err := f([]byte(keys[i]), c.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
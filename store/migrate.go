@@ -0,0 +1,282 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/gcse/configs"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// Migration upgrades the store from schema version Version-1 to Version.
+type Migration struct {
+	Version int
+	Name    string
+	// Migrate runs in the transaction which also updates the schema version.
+	Migrate func(tx Tx) error
+}
+
+// migrations are the registered migrations ordered by versions.
+var migrations []Migration
+
+// RegisterMigration registers the migration to the next schema version.
+// Versions start from 1.
+func RegisterMigration(m Migration) {
+	if m.Version != len(migrations)+1 {
+		log.Panicf("Migration %q has version %d, expected %d", m.Name, m.Version, len(migrations)+1)
+	}
+	migrations = append(migrations, m)
+}
+
+func init() {
+	RegisterMigration(Migration{
+		Version: 1,
+		Name:    "quarantine-unreadable",
+		Migrate: quarantineUnreadable,
+	})
+	RegisterMigration(Migration{
+		Version: 2,
+		Name:    "history-event-log",
+		Migrate: copyEventsToLog,
+	})
+}
+
+// LatestSchemaVersion returns the schema version after all the registered
+// migrations.
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+func readSchemaVersion(tx Tx) (int, error) {
+	bs, err := tx.Get(schemaVersionKey)
+	if err != nil || bs == nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(string(bs))
+	return v, errorsp.WithStacksAndMessage(err, "invalid schema version %q", string(bs))
+}
+
+func writeSchemaVersion(tx Tx, v int) error {
+	return tx.Put(schemaVersionKey, []byte(strconv.Itoa(v)))
+}
+
+// SchemaVersion returns the schema version of the store. Stores created
+// before versioning are of version 0.
+func SchemaVersion() (int, error) {
+	var v int
+	err := view(func(tx Tx) (err error) {
+		v, err = readSchemaVersion(tx)
+		return err
+	})
+	return v, err
+}
+
+var errStopIter = errors.New("stop iteration")
+
+// isEmpty returns true if nothing but the metadata is in the store.
+func isEmpty(tx Tx) (bool, error) {
+	empty := true
+	for _, root := range allRoots {
+		if bytes.Equal(root, metaRoot) {
+			continue
+		}
+		if err := tx.ForEach([][]byte{root}, func(_, _ []byte) error {
+			empty = false
+			return errStopIter
+		}); err != nil && err != errStopIter {
+			return false, err
+		}
+	}
+	return empty, nil
+}
+
+type MigrateOptions struct {
+	// DryRun runs the pending migrations in a transaction which is rolled
+	// back, checking whether they succeed without changing the store.
+	DryRun bool
+	// BackupPath, if not empty, is where a snapshot of the store is saved
+	// before the migrations are applied.
+	BackupPath string
+}
+
+var errDryRun = errors.New("dry run")
+
+// Migrate applies the pending migrations in order, each in a transaction.
+// The pending migrations are returned. A new empty store is set to the
+// latest version directly.
+func Migrate(opts MigrateOptions) ([]Migration, error) {
+	b, err := currentBackend()
+	if err != nil {
+		return nil, err
+	}
+	return migrate(b, opts)
+}
+
+// PendingMigrations returns the migrations not applied to the store yet.
+func PendingMigrations() ([]Migration, error) {
+	b, err := currentBackend()
+	if err != nil {
+		return nil, err
+	}
+	return pendingMigrations(b)
+}
+
+func pendingMigrations(b Backend) ([]Migration, error) {
+	var from int
+	empty := false
+	if err := b.View(func(tx Tx) (err error) {
+		if from, err = readSchemaVersion(tx); err != nil || from > 0 {
+			return err
+		}
+		empty, err = isEmpty(tx)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if from > LatestSchemaVersion() {
+		return nil, errorsp.NewWithStacks("schema version %d of the store is newer than the supported %d", from, LatestSchemaVersion())
+	}
+	if empty {
+		return nil, nil
+	}
+	return migrations[from:], nil
+}
+
+// migrate holds the migration lock, unless in a dry run, so that processes
+// starting together do not migrate or back up the store at the same time.
+func migrate(b Backend, opts MigrateOptions) ([]Migration, error) {
+	if !opts.DryRun {
+		unlock, err := lockFile(configs.StoreMigrationLockPath())
+		if err != nil {
+			return nil, errorsp.WithStacksAndMessage(err, "locking the store migration failed")
+		}
+		defer unlock()
+	}
+	pending, err := pendingMigrations(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		if opts.DryRun {
+			return nil, nil
+		}
+		// Sets the version of a new store.
+		return nil, b.Update(func(tx Tx) error {
+			if v, err := readSchemaVersion(tx); err != nil || v == LatestSchemaVersion() {
+				return err
+			}
+			return writeSchemaVersion(tx, LatestSchemaVersion())
+		})
+	}
+	if opts.DryRun {
+		if err := b.Update(func(tx Tx) error {
+			for _, m := range pending {
+				if err := applyMigration(tx, m); err != nil {
+					return err
+				}
+			}
+			return errDryRun
+		}); err != errDryRun {
+			return nil, err
+		}
+		return pending, nil
+	}
+	if opts.BackupPath != "" {
+		log.Printf("Backing up the store to %v before migrating", opts.BackupPath)
+		if err := saveSnapshot(b, opts.BackupPath); err != nil {
+			return nil, errorsp.WithStacksAndMessage(err, "backing up to %v failed", opts.BackupPath)
+		}
+	}
+	for _, m := range pending {
+		if err := b.Update(func(tx Tx) error {
+			return applyMigration(tx, m)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return pending, nil
+}
+
+func applyMigration(tx Tx, m Migration) error {
+	log.Printf("Migrating the store to version %d: %s", m.Version, m.Name)
+	if err := m.Migrate(tx); err != nil {
+		return errorsp.WithStacksAndMessage(err, "migration %d %q failed", m.Version, m.Name)
+	}
+	return writeSchemaVersion(tx, m.Version)
+}
+
+// forEachValue calls f with the keys and values of all the values under the
+// namespace ns, recursively. k is a copy and v is only valid in the
+// transaction.
+func forEachValue(tx Tx, ns [][]byte, f func(k [][]byte, v []byte) error) error {
+	return tx.ForEach(ns, func(name, v []byte) error {
+		k := append(append([][]byte{}, ns...), append([]byte{}, name...))
+		if v == nil {
+			return forEachValue(tx, k, f)
+		}
+		return f(k, v)
+	})
+}
+
+// quarantine saves a copy of the unreadable value of the key in the
+// quarantine namespace.
+func quarantine(tx Tx, k [][]byte, v []byte) error {
+	return tx.Put(append([][]byte{quarantineRoot}, k...), append([]byte{}, v...))
+}
+
+// messageNamespaces are the namespaces of the messages and their types.
+var messageNamespaces = []struct {
+	root   []byte
+	newMsg func() proto.Message
+}{
+	{pkgsRoot, func() proto.Message { return &gpb.PackageInfo{} }},
+	{personsRoot, func() proto.Message { return &gpb.PersonInfo{} }},
+	{historyRoot, func() proto.Message { return &gpb.HistoryInfo{} }},
+	{reposRoot, func() proto.Message { return &gpb.Repository{} }},
+}
+
+// quarantineUnreadable moves all the values failing to unmarshal into the
+// quarantine namespace.
+func quarantineUnreadable(tx Tx) error {
+	type entry struct {
+		k [][]byte
+		v []byte
+	}
+	var unreadable []entry
+	for _, ns := range messageNamespaces {
+		if err := forEachValue(tx, [][]byte{ns.root}, func(k [][]byte, v []byte) error {
+			if err := unmarshalMessage(v, ns.newMsg()); err != nil {
+				log.Printf("Quarantining %q: %v", bytes.Join(k, []byte("/")), err)
+				unreadable = append(unreadable, entry{k: k, v: append([]byte{}, v...)})
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	for _, e := range unreadable {
+		if err := quarantine(tx, e.k, e.v); err != nil {
+			return err
+		}
+		if err := tx.Delete(e.k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachQuarantined calls f with the quarantined values and their original
+// keys, whose elements are joined by "/".
+func ForEachQuarantined(f func(key string, v []byte) error) error {
+	return view(func(tx Tx) error {
+		return forEachValue(tx, [][]byte{quarantineRoot}, func(k [][]byte, v []byte) error {
+			return errorsp.WithStacks(f(string(bytes.Join(k[1:], []byte("/"))), v))
+		})
+	})
+}
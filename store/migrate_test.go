@@ -0,0 +1,212 @@
+package store
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/bolthelper"
+	"github.com/daviddengcn/go-villa"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// An invalid protobuf: field 1 of wire type 7.
+const unreadable = "\x0f"
+
+func quarantinedKeys(t *testing.T) []string {
+	var keys []string
+	assert.NoError(t, ForEachQuarantined(func(key string, v []byte) error {
+		assert.Equal(t, "v", string(v), unreadable)
+		keys = append(keys, key)
+		return nil
+	}))
+	return keys
+}
+
+func TestMigrate_NewStore(t *testing.T) {
+	cleanDatabase(t)
+
+	pending, err := PendingMigrations()
+	assert.NoError(t, err)
+	assert.Equal(t, "len(pending)", len(pending), 0)
+
+	applied, err := Migrate(MigrateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "len(applied)", len(applied), 0)
+
+	v, err := SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "v", v, LatestSchemaVersion())
+}
+
+func TestMigrate_Quarantine(t *testing.T) {
+	cleanDatabase(t)
+
+	const site = "TestMigrate_Quarantine.com"
+	assert.NoError(t, UpdatePackage(site, "good", func(info *gpb.PackageInfo) error {
+		info.Name = "good"
+		return nil
+	}))
+	put(t, keyOf(string(pkgsRoot), site, "bad"), unreadable)
+	put(t, keyOf(string(reposRoot), site, "user", "bad"), unreadable)
+
+	pending, err := PendingMigrations()
+	assert.NoError(t, err)
+	assert.Equal(t, "len(pending)", len(pending), LatestSchemaVersion())
+
+	// A dry run changes nothing.
+	applied, err := Migrate(MigrateOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "len(applied)", len(applied), LatestSchemaVersion())
+	v, err := SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "v", v, 0)
+	assert.Equal(t, "bad", string(get(t, keyOf(string(pkgsRoot), site, "bad"))), unreadable)
+	assert.Equal(t, "quarantined", quarantinedKeys(t), []string(nil))
+
+	backupPath := villa.Path(os.TempDir()).Join("TestMigrate_Quarantine.bolt").S()
+	assert.NoError(t, os.RemoveAll(backupPath))
+	applied, err = Migrate(MigrateOptions{BackupPath: backupPath})
+	assert.NoError(t, err)
+	assert.Equal(t, "len(applied)", len(applied), LatestSchemaVersion())
+	v, err = SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "v", v, LatestSchemaVersion())
+
+	assert.Equal(t, "bad", get(t, keyOf(string(pkgsRoot), site, "bad")), []byte(nil))
+	info, err := ReadPackage(site, "good")
+	assert.NoError(t, err)
+	assert.Equal(t, "info", info, &gpb.PackageInfo{Name: "good"})
+	assert.Equal(t, "quarantined", quarantinedKeys(t), []string{
+		"pkgs/" + site + "/bad",
+		"repos/" + site + "/user/bad",
+	})
+
+	// The backup is taken before migrating.
+	assert.NoError(t, NewBoltBackend(&bh.RefCountBox{
+		DataPath: func() string { return backupPath },
+	}).View(func(tx Tx) error {
+		v, err := tx.Get(keyOf(string(pkgsRoot), site, "bad"))
+		assert.Equal(t, "bad", string(v), unreadable)
+		return err
+	}))
+
+	// Nothing is pending after migrating.
+	pending, err = PendingMigrations()
+	assert.NoError(t, err)
+	assert.Equal(t, "len(pending)", len(pending), 0)
+}
+
+func TestMigrate_Order(t *testing.T) {
+	cleanDatabase(t)
+	defer func(ms []Migration) {
+		migrations = ms
+	}(migrations)
+
+	var order []int
+	RegisterMigration(Migration{
+		Version: LatestSchemaVersion() + 1,
+		Name:    "first",
+		Migrate: func(tx Tx) error {
+			order = append(order, 1)
+			return nil
+		},
+	})
+	RegisterMigration(Migration{
+		Version: LatestSchemaVersion() + 1,
+		Name:    "second",
+		Migrate: func(tx Tx) error {
+			order = append(order, 2)
+			return tx.Put(keyOf(string(metaRoot), "migrated"), []byte("yes"))
+		},
+	})
+	assert.NoError(t, update(func(tx Tx) error {
+		if err := tx.Put(keyOf(string(pkgsRoot), "site", "path"), nil); err != nil {
+			return err
+		}
+		return writeSchemaVersion(tx, LatestSchemaVersion()-2)
+	}))
+
+	applied, err := Migrate(MigrateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "len(applied)", len(applied), 2)
+	assert.Equal(t, "order", order, []int{1, 2})
+	assert.Equal(t, "migrated", string(get(t, keyOf(string(metaRoot), "migrated"))), "yes")
+	v, err := SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "v", v, LatestSchemaVersion())
+}
+
+func TestMigrate_Newer(t *testing.T) {
+	cleanDatabase(t)
+
+	assert.NoError(t, update(func(tx Tx) error {
+		return writeSchemaVersion(tx, LatestSchemaVersion()+1)
+	}))
+	_, err := Migrate(MigrateOptions{})
+	assert.Error(t, err)
+}
+
+func TestUpdatePackage_Quarantine(t *testing.T) {
+	cleanDatabase(t)
+
+	const site = "TestUpdatePackage_Quarantine.com"
+	put(t, keyOf(string(pkgsRoot), site, "bad"), unreadable)
+
+	assert.NoError(t, UpdatePackage(site, "bad", func(info *gpb.PackageInfo) error {
+		assert.Equal(t, "info", info, &gpb.PackageInfo{})
+		info.Name = "bad"
+		return nil
+	}))
+	info, err := ReadPackage(site, "bad")
+	assert.NoError(t, err)
+	assert.Equal(t, "info", info, &gpb.PackageInfo{Name: "bad"})
+	assert.Equal(t, "quarantined", quarantinedKeys(t), []string{"pkgs/" + site + "/bad"})
+}
+
+func TestReadPackage_Quarantine(t *testing.T) {
+	cleanDatabase(t)
+
+	const site = "TestReadPackage_Quarantine.com"
+	put(t, keyOf(string(pkgsRoot), site, "bad"), unreadable)
+
+	info, err := ReadPackage(site, "bad")
+	assert.NoError(t, err)
+	assert.Equal(t, "info", info, &gpb.PackageInfo{})
+	assert.Equal(t, "quarantined", quarantinedKeys(t), []string{"pkgs/" + site + "/bad"})
+	assert.Equal(t, "v", get(t, packageKey(site, "bad")), []byte(nil))
+}
+
+func TestMigrate_HistoryEventLog(t *testing.T) {
+	cleanDatabase(t)
+
+	const (
+		site = "TestMigrate_HistoryEventLog.com"
+		path = "gcse"
+	)
+	ts1, _ := ptypes.TimestampProto(time.Now().Add(-time.Hour))
+	ts2, _ := ptypes.TimestampProto(time.Now())
+	events := []*gpb.HistoryEvent{
+		{Timestamp: ts2, Action: gpb.HistoryEvent_Action_Failed},
+		{Timestamp: ts1, Action: gpb.HistoryEvent_Action_Success},
+	}
+	// A history saved before the event log.
+	assert.NoError(t, update(func(tx Tx) error {
+		hi := &gpb.HistoryInfo{Events: events}
+		if err := putMessage(tx, historyKey(pkgsRoot, site, path), proto.Clone(hi)); err != nil {
+			return err
+		}
+		return writeSchemaVersion(tx, 1)
+	}))
+	_, err := Migrate(MigrateOptions{})
+	assert.NoError(t, err)
+
+	got, _, err := ReadPackageEvents(site, path, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "events", got, events)
+}
@@ -0,0 +1,392 @@
+package store
+
+import (
+	"context"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golangplus/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/daviddengcn/gcse/configs"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// Remote accesses the store served by a gcse-service-stored instance, so that
+// multiple binaries can share one store. Its methods are the same as the
+// functions of the package.
+type Remote struct {
+	conn   *grpc.ClientConn
+	client gpb.StoreServiceClient
+}
+
+// DialRemote connects to the store service at addr.
+func DialRemote(addr string, opts ...grpc.DialOption) (*Remote, error) {
+	conn, err := grpc.Dial(addr, append([]grpc.DialOption{grpc.WithInsecure()}, opts...)...)
+	if err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "dialing %v failed", addr)
+	}
+	return &Remote{conn: conn, client: gpb.NewStoreServiceClient(conn)}, nil
+}
+
+func (r *Remote) Close() error {
+	return r.conn.Close()
+}
+
+var (
+	remoteMu   sync.Mutex
+	remote     *Remote
+	remoteInit bool
+)
+
+// currentRemote returns the Remote the package functions are forwarded to,
+// dialing configs.StoreRemoteAddr on the first call. Nil is returned if the
+// store is local.
+func currentRemote() *Remote {
+	remoteMu.Lock()
+	defer remoteMu.Unlock()
+	if !remoteInit {
+		remoteInit = true
+		if configs.StoreRemoteAddr != "" {
+			r, err := DialRemote(configs.StoreRemoteAddr)
+			if err != nil {
+				log.Fatalf("DialRemote %v failed: %v", configs.StoreRemoteAddr, err)
+			}
+			remote = r
+		}
+	}
+	return remote
+}
+
+// UseRemote forwards the package functions to r, or accesses the local
+// store if r is nil, and returns the old Remote.
+func UseRemote(r *Remote) *Remote {
+	remoteMu.Lock()
+	defer remoteMu.Unlock()
+	old := remote
+	remote, remoteInit = r, true
+	return old
+}
+
+// maxUpdateRetries is the number of retries of an update conflicting with
+// others.
+const maxUpdateRetries = 10
+
+// retryUpdate calls f, which reads a record and updates it with the revision
+// read, until it succeeds or fails for other reasons than a conflict.
+func retryUpdate(f func(ctx context.Context) error) error {
+	for i := 0; ; i++ {
+		err := f(context.Background())
+		if status.Code(err) != codes.Aborted || i >= maxUpdateRetries {
+			return err
+		}
+		log.Printf("Update conflicted, retrying: %v", err)
+	}
+}
+
+func (r *Remote) ReadPackage(site, path string) (*gpb.PackageInfo, error) {
+	resp, err := r.client.ReadPackage(context.Background(), &gpb.ReadPackageReq{Site: site, Path: path})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Info == nil {
+		return &gpb.PackageInfo{}, nil
+	}
+	return resp.Info, nil
+}
+
+func (r *Remote) UpdatePackage(site, path string, f func(*gpb.PackageInfo) error) error {
+	return retryUpdate(func(ctx context.Context) error {
+		resp, err := r.client.ReadPackage(ctx, &gpb.ReadPackageReq{Site: site, Path: path})
+		if err != nil {
+			return err
+		}
+		info := resp.Info
+		if info == nil {
+			info = &gpb.PackageInfo{}
+		}
+		if err := errorsp.WithStacks(f(info)); err != nil {
+			return err
+		}
+		_, err = r.client.UpdatePackage(ctx, &gpb.UpdatePackageReq{Site: site, Path: path, Info: info, Revision: resp.Revision})
+		return err
+	})
+}
+
+func (r *Remote) DeletePackage(site, path string) error {
+	_, err := r.client.DeletePackage(context.Background(), &gpb.DeletePackageReq{Site: site, Path: path})
+	return err
+}
+
+func (r *Remote) ForEachPackageSite(f func(string) error) error {
+	resp, err := r.client.ListPackageSites(context.Background(), &gpb.ListPackageSitesReq{})
+	if err != nil {
+		return err
+	}
+	for _, site := range resp.Sites {
+		if err := errorsp.WithStacks(f(site)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Remote) ForEachPackageOfSite(site string, f func(string, *gpb.PackageInfo) error) error {
+	return r.ForEachPackageWithPrefix(site, "", f)
+}
+
+func (r *Remote) ForEachPackageWithPrefix(site, prefix string, f func(string, *gpb.PackageInfo) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := r.client.ListPackages(ctx, &gpb.ListPackagesReq{Site: site, Prefix: prefix})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := errorsp.WithStacks(f(resp.Path, resp.Info)); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Remote) ReadRepository(site, user, repo string) (*gpb.Repository, error) {
+	resp, err := r.client.ReadRepository(context.Background(), &gpb.ReadRepositoryReq{Site: site, User: user, Repo: repo})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Repository == nil {
+		return &gpb.Repository{}, nil
+	}
+	return resp.Repository, nil
+}
+
+func (r *Remote) UpdateRepository(site, user, repo string, f func(doc *gpb.Repository) error) error {
+	return retryUpdate(func(ctx context.Context) error {
+		resp, err := r.client.ReadRepository(ctx, &gpb.ReadRepositoryReq{Site: site, User: user, Repo: repo})
+		if err != nil {
+			return err
+		}
+		doc := resp.Repository
+		if doc == nil {
+			doc = &gpb.Repository{}
+		}
+		if err := errorsp.WithStacks(f(doc)); err != nil {
+			return err
+		}
+		_, err = r.client.UpdateRepository(ctx, &gpb.UpdateRepositoryReq{
+			Site: site, User: user, Repo: repo, Repository: doc, Revision: resp.Revision,
+		})
+		return err
+	})
+}
+
+func (r *Remote) DeleteRepository(site, user, repo string) error {
+	_, err := r.client.DeleteRepository(context.Background(), &gpb.DeleteRepositoryReq{Site: site, User: user, Repo: repo})
+	return err
+}
+
+func (r *Remote) ForEachRepositorySite(f func(site string) error) error {
+	resp, err := r.client.ListRepositorySites(context.Background(), &gpb.ListRepositorySitesReq{})
+	if err != nil {
+		return err
+	}
+	for _, site := range resp.Sites {
+		if err := errorsp.WithStacks(f(site)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Remote) ForEachRepositoryOfSite(site string, f func(user, name string, doc *gpb.Repository) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := r.client.ListRepositories(ctx, &gpb.ListRepositoriesReq{Site: site})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := errorsp.WithStacks(f(resp.User, resp.Name, resp.Repository)); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Remote) ReadPerson(site, id string) (*gpb.PersonInfo, error) {
+	resp, err := r.client.ReadPerson(context.Background(), &gpb.ReadPersonReq{Site: site, Id: id})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Info == nil {
+		return &gpb.PersonInfo{}, nil
+	}
+	return resp.Info, nil
+}
+
+func (r *Remote) UpdatePerson(site, id string, f func(*gpb.PersonInfo) error) error {
+	return retryUpdate(func(ctx context.Context) error {
+		resp, err := r.client.ReadPerson(ctx, &gpb.ReadPersonReq{Site: site, Id: id})
+		if err != nil {
+			return err
+		}
+		info := resp.Info
+		if info == nil {
+			info = &gpb.PersonInfo{}
+		}
+		if err := errorsp.WithStacks(f(info)); err != nil {
+			return err
+		}
+		_, err = r.client.UpdatePerson(ctx, &gpb.UpdatePersonReq{Site: site, Id: id, Info: info, Revision: resp.Revision})
+		return err
+	})
+}
+
+func (r *Remote) DeletePerson(site, id string) error {
+	_, err := r.client.DeletePerson(context.Background(), &gpb.DeletePersonReq{Site: site, Id: id})
+	return err
+}
+
+func (r *Remote) AppendPackageEvent(site, path, foundWay string, t time.Time, a gpb.HistoryEvent_Action_Enum) error {
+	ts, err := ptypes.TimestampProto(t)
+	if err != nil {
+		return errorsp.WithStacksAndMessage(err, "invalid time %v", t)
+	}
+	_, err = r.client.AppendPackageEvent(context.Background(), &gpb.AppendPackageEventReq{
+		Site: site, Path: path, FoundWay: foundWay, Timestamp: ts, Action: a,
+	})
+	return err
+}
+
+func (r *Remote) AppendPackageHistoryEvent(site, path string, e *gpb.HistoryEvent) error {
+	_, err := r.client.AppendPackageEvent(context.Background(), &gpb.AppendPackageEventReq{
+		Site: site, Path: path, Event: e,
+	})
+	return err
+}
+
+func (r *Remote) ReadPackageHistory(site, path string) (*gpb.HistoryInfo, error) {
+	resp, err := r.client.PackageCrawlHistory(context.Background(), &gpb.PackageCrawlHistoryReq{Package: site + "/" + path})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Info == nil {
+		return &gpb.HistoryInfo{}, nil
+	}
+	return resp.Info, nil
+}
+
+func (r *Remote) ReadPersonHistory(site, id string) (*gpb.HistoryInfo, error) {
+	resp, err := r.client.ReadHistory(context.Background(), &gpb.ReadHistoryReq{Person: true, Site: site, Id: id})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Info == nil {
+		return &gpb.HistoryInfo{}, nil
+	}
+	return resp.Info, nil
+}
+
+// updateHistory updates the history of the person if person is true, of the
+// package otherwise.
+func (r *Remote) updateHistory(person bool, site, id string, f func(*gpb.HistoryInfo) error) error {
+	return retryUpdate(func(ctx context.Context) error {
+		resp, err := r.client.ReadHistory(ctx, &gpb.ReadHistoryReq{Person: person, Site: site, Id: id})
+		if err != nil {
+			return err
+		}
+		info := resp.Info
+		if info == nil {
+			info = &gpb.HistoryInfo{}
+		}
+		if err := errorsp.WithStacks(f(info)); err != nil {
+			return err
+		}
+		_, err = r.client.UpdateHistory(ctx, &gpb.UpdateHistoryReq{
+			Person: person, Site: site, Id: id, Info: info, Revision: resp.Revision,
+		})
+		return err
+	})
+}
+
+func (r *Remote) UpdatePackageHistory(site, path string, f func(*gpb.HistoryInfo) error) error {
+	return r.updateHistory(false, site, path, f)
+}
+
+func (r *Remote) UpdatePersonHistory(site, id string, f func(*gpb.HistoryInfo) error) error {
+	return r.updateHistory(true, site, id, f)
+}
+
+func (r *Remote) DeletePackageHistory(site, path string) error {
+	_, err := r.client.DeleteHistory(context.Background(), &gpb.DeleteHistoryReq{Site: site, Id: path})
+	return err
+}
+
+func (r *Remote) DeletePersonHistory(site, id string) error {
+	_, err := r.client.DeleteHistory(context.Background(), &gpb.DeleteHistoryReq{Person: true, Site: site, Id: id})
+	return err
+}
+
+func (r *Remote) ReadPackageEvents(site, path, pageToken string, n int) ([]*gpb.HistoryEvent, string, error) {
+	if n <= 0 {
+		n = math.MaxInt32
+	}
+	resp, err := r.client.PackageCrawlHistory(context.Background(), &gpb.PackageCrawlHistoryReq{
+		Package: site + "/" + path, PageSize: int32(n), PageToken: pageToken,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Events, resp.NextPageToken, nil
+}
+
+// WatchChanges calls f with the changes after fromSeq in order. If follow is
+// true, it keeps waiting for new changes until ctx is done or f fails.
+// ErrChangesCompacted is returned if some of the changes have been compacted.
+func (r *Remote) WatchChanges(ctx context.Context, fromSeq int64, follow bool, f func(*gpb.Change) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := r.client.WatchChanges(ctx, &gpb.WatchChangesReq{FromSeq: fromSeq, Follow: follow})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if status.Code(err) == codes.OutOfRange {
+			return ErrChangesCompacted
+		}
+		if err != nil {
+			return err
+		}
+		if err := errorsp.WithStacks(f(resp.Change)); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Remote) ForEachChange(fromSeq int64, f func(*gpb.Change) error) error {
+	return r.WatchChanges(context.Background(), fromSeq, false, f)
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golangplus/testing/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// startRemote serves the local store with an in-process server and returns a
+// Remote connected to it, and a func stopping both.
+func startRemote(t *testing.T) (*Remote, func()) {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	gpb.RegisterStoreServiceServer(s, NewServer())
+	go s.Serve(lis)
+
+	r, err := DialRemote("bufconn", grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	assert.NoErrorOrDie(t, err)
+	return r, func() {
+		r.Close()
+		s.Stop()
+	}
+}
+
+// remoteTests are the tests of the public functions run through a Remote.
+var remoteTests = []struct {
+	name string
+	f    func(*testing.T)
+}{
+	{"ForEachPackageSite", TestForEachPackageSite},
+	{"ForEachPackageOfSite", TestForEachPackageOfSite},
+	{"UpdateReadDeletePackage", TestUpdateReadDeletePackage},
+	{"UpdateReadDeletePerson", TestUpdateReadDeletePerson},
+	{"AppendPackageEvent", TestAppendPackageEvent},
+	{"UpdateReadDeleteRepository", TestUpdateReadDeleteRepository},
+	{"ForEachRepositorySite", TestForEachRepositorySite},
+	{"ForEachRepositoryOfSite", TestForEachRepositoryOfSite},
+	{"UpdatePackage_Quarantine", TestUpdatePackage_Quarantine},
+	{"ForEachPackageWithPrefix", TestForEachPackageWithPrefix},
+	{"ForEachPackageWithPrefix_Update", TestForEachPackageWithPrefix_Update},
+	{"UpdateReadDeletePackageHistory", TestUpdateReadDeletePackageHistory},
+	{"UpdateReadDeletePersonHistory", TestUpdateReadDeletePersonHistory},
+	{"ForEachChange", TestForEachChange},
+	{"ReadPackageEvents", TestReadPackageEvents},
+	{"AppendPackageEvent_Rollup", TestAppendPackageEvent_Rollup},
+}
+
+func TestRemote(t *testing.T) {
+	r, stop := startRemote(t)
+	defer stop()
+	defer UseRemote(UseRemote(r))
+
+	for _, test := range remoteTests {
+		cleanDatabase(t)
+		t.Run(test.name, test.f)
+	}
+}
+
+func TestForEachPackageWithPrefix(t *testing.T) {
+	cleanDatabase(t)
+
+	const site = "TestForEachPackageWithPrefix.com"
+	for _, path := range []string{"a/b", "a/c", "ab", "b"} {
+		assert.NoError(t, UpdatePackage(site, path, func(info *gpb.PackageInfo) error {
+			info.Name = path
+			return nil
+		}))
+	}
+	var paths []string
+	assert.NoError(t, ForEachPackageWithPrefix(site, "a/", func(path string, info *gpb.PackageInfo) error {
+		assert.Equal(t, "info.Name", info.Name, path)
+		paths = append(paths, path)
+		return nil
+	}))
+	assert.Equal(t, "paths", paths, []string{"a/b", "a/c"})
+}
+
+func TestForEachPackageWithPrefix_Update(t *testing.T) {
+	cleanDatabase(t)
+	defer func(n int) { packagesPageSize = n }(packagesPageSize)
+	packagesPageSize = 2
+
+	const site = "TestForEachPackageWithPrefix_Update.com"
+	for _, path := range []string{"a/b", "a/c", "a/d", "a/e", "a/f", "b"} {
+		assert.NoError(t, UpdatePackage(site, path, func(info *gpb.PackageInfo) error {
+			return nil
+		}))
+	}
+	// The callback is free to update the packages being iterated.
+	var paths []string
+	assert.NoError(t, ForEachPackageWithPrefix(site, "a/", func(path string, info *gpb.PackageInfo) error {
+		paths = append(paths, path)
+		return UpdatePackage(site, path, func(info *gpb.PackageInfo) error {
+			info.Name = "updated"
+			return nil
+		})
+	}))
+	assert.Equal(t, "paths", paths, []string{"a/b", "a/c", "a/d", "a/e", "a/f"})
+	for _, path := range paths {
+		info, err := ReadPackage(site, path)
+		assert.NoError(t, err)
+		assert.Equal(t, "info.Name", info.Name, "updated")
+	}
+}
+
+func TestRemote_Revision(t *testing.T) {
+	cleanDatabase(t)
+	r, stop := startRemote(t)
+	defer stop()
+
+	const (
+		site = "TestRemote_Revision.com"
+		path = "gcse"
+	)
+	ctx := context.Background()
+	resp, err := r.client.ReadPackage(ctx, &gpb.ReadPackageReq{Site: site, Path: path})
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "resp.Revision", resp.Revision, int64(0))
+
+	updated, err := r.client.UpdatePackage(ctx, &gpb.UpdatePackageReq{
+		Site: site, Path: path, Info: &gpb.PackageInfo{Name: "first"}, Revision: resp.Revision,
+	})
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "updated.Revision", updated.Revision, int64(1))
+
+	// An update with a stale revision is aborted.
+	_, err = r.client.UpdatePackage(ctx, &gpb.UpdatePackageReq{
+		Site: site, Path: path, Info: &gpb.PackageInfo{Name: "second"}, Revision: resp.Revision,
+	})
+	assert.Equal(t, "code", status.Code(err), codes.Aborted)
+
+	info, err := r.ReadPackage(site, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "info", info, &gpb.PackageInfo{Name: "first"})
+}
+
+func TestRemote_UpdateRetry(t *testing.T) {
+	cleanDatabase(t)
+	r, stop := startRemote(t)
+	defer stop()
+
+	const (
+		site = "TestRemote_UpdateRetry.com"
+		path = "gcse"
+	)
+	calls := 0
+	assert.NoError(t, r.UpdatePackage(site, path, func(info *gpb.PackageInfo) error {
+		calls++
+		if calls == 1 {
+			// Another writer updates the package in between.
+			_, err := updatePackage(site, path, anyRevision, func(info *gpb.PackageInfo) error {
+				info.Author = "other"
+				return nil
+			})
+			assert.NoError(t, err)
+		}
+		info.Stars++
+		return nil
+	}))
+	assert.Equal(t, "calls", calls, 2)
+
+	info, err := r.ReadPackage(site, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "info", info, &gpb.PackageInfo{Author: "other", Stars: 1})
+}
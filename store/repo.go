@@ -4,67 +4,65 @@ import (
 	"log"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/golangplus/bytes"
 	"github.com/golangplus/errors"
 
-	"github.com/daviddengcn/bolthelper"
-
 	gpb "github.com/daviddengcn/gcse/shared/proto"
 )
 
+func repositoryKey(site, user, repo string) [][]byte {
+	return [][]byte{reposRoot, []byte(site), []byte(user), []byte(repo)}
+}
+
 // Returns an empty (non-nil) PackageInfo if not found.
 func ReadRepository(site, user, repo string) (*gpb.Repository, error) {
+	if r := currentRemote(); r != nil {
+		return r.ReadRepository(site, user, repo)
+	}
+	doc, _, err := readRepository(site, user, repo)
+	return doc, err
+}
+
+func readRepository(site, user, repo string) (*gpb.Repository, int64, error) {
 	doc := &gpb.Repository{}
-	if err := box.View(func(tx bh.Tx) error {
-		return tx.Value([][]byte{reposRoot, []byte(site), []byte(user), []byte(repo)}, func(bs bytesp.Slice) error {
-			if err := errorsp.WithStacksAndMessage(proto.Unmarshal(bs, doc), "Unmarshal %d bytes failed", len(bs)); err != nil {
-				log.Printf("Unmarshal failed: %v", err)
-				*doc = gpb.Repository{}
-			}
-			return nil
-		})
-	}); err != nil {
-		return nil, err
+	rev, err := readMessage(repositoryKey(site, user, repo), doc)
+	if err != nil {
+		return nil, 0, err
 	}
-	return doc, nil
+	return doc, rev, nil
 }
 
 func UpdateRepository(site, user, repo string, f func(doc *gpb.Repository) error) error {
-	return box.Update(func(tx bh.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([][]byte{reposRoot, []byte(site), []byte(user)})
-		if err != nil {
-			return err
-		}
-		doc := &gpb.Repository{}
-		if err := b.Value([][]byte{[]byte(repo)}, func(bs bytesp.Slice) error {
-			if err := errorsp.WithStacksAndMessage(proto.Unmarshal(bs, doc), "Unmarshal %d bytes", len(bs)); err != nil {
-				log.Printf("Unmarshaling failed: %v", err)
-				*doc = gpb.Repository{}
-			}
-			return nil
-		}); err != nil {
-			return err
-		}
-		if err := errorsp.WithStacks(f(doc)); err != nil {
-			return err
-		}
-		bs, err := proto.Marshal(doc)
-		if err != nil {
-			return errorsp.WithStacksAndMessage(err, "marshaling %v failed: %v", doc, err)
-		}
-		return b.Put([][]byte{[]byte(repo)}, bs)
+	if r := currentRemote(); r != nil {
+		return r.UpdateRepository(site, user, repo, f)
+	}
+	_, err := updateRepository(site, user, repo, anyRevision, f)
+	return err
+}
+
+func updateRepository(site, user, repo string, rev int64, f func(doc *gpb.Repository) error) (int64, error) {
+	doc := &gpb.Repository{}
+	return updateMessage(repositoryKey(site, user, repo), doc, rev, func() error {
+		return f(doc)
 	})
 }
 
 func DeleteRepository(site, user, repo string) error {
-	return box.Update(func(tx bh.Tx) error {
-		return tx.Delete([][]byte{reposRoot, []byte(site), []byte(user), []byte(repo)})
-	})
+	if r := currentRemote(); r != nil {
+		return r.DeleteRepository(site, user, repo)
+	}
+	return deleteMessage(repositoryKey(site, user, repo))
 }
 
 func ForEachRepositorySite(f func(site string) error) error {
-	return box.View(func(tx bh.Tx) error {
-		return tx.ForEach([][]byte{reposRoot}, func(_ bh.Bucket, k, v bytesp.Slice) error {
+	if r := currentRemote(); r != nil {
+		return r.ForEachRepositorySite(f)
+	}
+	return forEachRepositorySite(f)
+}
+
+func forEachRepositorySite(f func(site string) error) error {
+	return view(func(tx Tx) error {
+		return tx.ForEach([][]byte{reposRoot}, func(k, v []byte) error {
 			if v != nil {
 				log.Printf("Unexpected value %q for key %q, ignored", string(v), string(k))
 				return nil
@@ -75,13 +73,20 @@ func ForEachRepositorySite(f func(site string) error) error {
 }
 
 func ForEachRepositoryOfSite(site string, f func(user, name string, doc *gpb.Repository) error) error {
-	return box.View(func(tx bh.Tx) error {
-		return tx.ForEach([][]byte{reposRoot, []byte(site)}, func(b bh.Bucket, user, v bytesp.Slice) error {
+	if r := currentRemote(); r != nil {
+		return r.ForEachRepositoryOfSite(site, f)
+	}
+	return forEachRepositoryOfSite(site, f)
+}
+
+func forEachRepositoryOfSite(site string, f func(user, name string, doc *gpb.Repository) error) error {
+	return view(func(tx Tx) error {
+		return tx.ForEach([][]byte{reposRoot, []byte(site)}, func(user, v []byte) error {
 			if v != nil {
 				log.Printf("Unexpected value %q for key %q, ignored", string(v), string(user))
 				return nil
 			}
-			return b.ForEach([][]byte{user}, func(name, bs bytesp.Slice) error {
+			return tx.ForEach([][]byte{reposRoot, []byte(site), user}, func(name, bs []byte) error {
 				if bs == nil {
 					log.Printf("Unexpected nil value for key %q, ignored", string(name))
 					return nil
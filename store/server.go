@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golangplus/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/daviddengcn/gcse/utils"
+
+	gpb "github.com/daviddengcn/gcse/shared/proto"
+)
+
+// server serves the local store as a gpb.StoreServiceServer.
+type server struct{}
+
+var _ gpb.StoreServiceServer = server{}
+
+// NewServer returns a gpb.StoreServiceServer serving the local store, i.e.
+// never forwarding to a Remote.
+func NewServer() gpb.StoreServiceServer {
+	return server{}
+}
+
+// toStatus converts a revision mismatch into an ABORTED status, which tells
+// the client to read and update again.
+func toStatus(err error) error {
+	if errorsp.Cause(err) == ErrRevisionMismatch {
+		return status.Error(codes.Aborted, err.Error())
+	}
+	return err
+}
+
+func (server) PackageCrawlHistory(_ context.Context, req *gpb.PackageCrawlHistoryReq) (*gpb.PackageCrawlHistoryResp, error) {
+	site, path := utils.SplitPackage(req.Package)
+	info, err := readHistory(pkgsRoot, site, path)
+	if err != nil {
+		return nil, err
+	}
+	resp := &gpb.PackageCrawlHistoryResp{Info: info}
+	if req.PageSize > 0 {
+		if resp.Events, resp.NextPageToken, err = readPackageEvents(site, path, req.PageToken, int(req.PageSize)); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (server) ReadPackage(_ context.Context, req *gpb.ReadPackageReq) (*gpb.ReadPackageResp, error) {
+	info, rev, err := readPackage(req.Site, req.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &gpb.ReadPackageResp{Info: info, Revision: rev}, nil
+}
+
+func (server) UpdatePackage(_ context.Context, req *gpb.UpdatePackageReq) (*gpb.UpdatePackageResp, error) {
+	rev, err := updatePackage(req.Site, req.Path, req.Revision, func(info *gpb.PackageInfo) error {
+		// Replaces the record with the one in the request.
+		info.Reset()
+		if req.Info != nil {
+			proto.Merge(info, req.Info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &gpb.UpdatePackageResp{Revision: rev}, nil
+}
+
+func (server) DeletePackage(_ context.Context, req *gpb.DeletePackageReq) (*gpb.DeletePackageResp, error) {
+	if err := deleteMessage(packageKey(req.Site, req.Path)); err != nil {
+		return nil, err
+	}
+	return &gpb.DeletePackageResp{}, nil
+}
+
+func (server) ListPackageSites(_ context.Context, req *gpb.ListPackageSitesReq) (*gpb.ListPackageSitesResp, error) {
+	resp := &gpb.ListPackageSitesResp{}
+	if err := forEachPackageSite(func(site string) error {
+		resp.Sites = append(resp.Sites, site)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (server) ListPackages(req *gpb.ListPackagesReq, stream gpb.StoreService_ListPackagesServer) error {
+	return forEachPackageWithPrefix(req.Site, req.Prefix, func(path string, info *gpb.PackageInfo) error {
+		return stream.Send(&gpb.ListPackagesResp{Path: path, Info: info})
+	})
+}
+
+func (server) ReadRepository(_ context.Context, req *gpb.ReadRepositoryReq) (*gpb.ReadRepositoryResp, error) {
+	doc, rev, err := readRepository(req.Site, req.User, req.Repo)
+	if err != nil {
+		return nil, err
+	}
+	return &gpb.ReadRepositoryResp{Repository: doc, Revision: rev}, nil
+}
+
+func (server) UpdateRepository(_ context.Context, req *gpb.UpdateRepositoryReq) (*gpb.UpdateRepositoryResp, error) {
+	rev, err := updateRepository(req.Site, req.User, req.Repo, req.Revision, func(doc *gpb.Repository) error {
+		// Replaces the record with the one in the request.
+		doc.Reset()
+		if req.Repository != nil {
+			proto.Merge(doc, req.Repository)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &gpb.UpdateRepositoryResp{Revision: rev}, nil
+}
+
+func (server) DeleteRepository(_ context.Context, req *gpb.DeleteRepositoryReq) (*gpb.DeleteRepositoryResp, error) {
+	if err := deleteMessage(repositoryKey(req.Site, req.User, req.Repo)); err != nil {
+		return nil, err
+	}
+	return &gpb.DeleteRepositoryResp{}, nil
+}
+
+func (server) ListRepositorySites(_ context.Context, req *gpb.ListRepositorySitesReq) (*gpb.ListRepositorySitesResp, error) {
+	resp := &gpb.ListRepositorySitesResp{}
+	if err := forEachRepositorySite(func(site string) error {
+		resp.Sites = append(resp.Sites, site)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (server) ListRepositories(req *gpb.ListRepositoriesReq, stream gpb.StoreService_ListRepositoriesServer) error {
+	return forEachRepositoryOfSite(req.Site, func(user, name string, doc *gpb.Repository) error {
+		return stream.Send(&gpb.ListRepositoriesResp{User: user, Name: name, Repository: doc})
+	})
+}
+
+func (server) ReadPerson(_ context.Context, req *gpb.ReadPersonReq) (*gpb.ReadPersonResp, error) {
+	info, rev, err := readPerson(req.Site, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &gpb.ReadPersonResp{Info: info, Revision: rev}, nil
+}
+
+func (server) UpdatePerson(_ context.Context, req *gpb.UpdatePersonReq) (*gpb.UpdatePersonResp, error) {
+	rev, err := updatePerson(req.Site, req.Id, req.Revision, func(info *gpb.PersonInfo) error {
+		// Replaces the record with the one in the request.
+		info.Reset()
+		if req.Info != nil {
+			proto.Merge(info, req.Info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &gpb.UpdatePersonResp{Revision: rev}, nil
+}
+
+func (server) DeletePerson(_ context.Context, req *gpb.DeletePersonReq) (*gpb.DeletePersonResp, error) {
+	if err := deleteMessage(personKey(req.Site, req.Id)); err != nil {
+		return nil, err
+	}
+	return &gpb.DeletePersonResp{}, nil
+}
+
+func (server) AppendPackageEvent(_ context.Context, req *gpb.AppendPackageEventReq) (*gpb.AppendPackageEventResp, error) {
+	e := req.Event
+	if e == nil {
+		e = &gpb.HistoryEvent{Timestamp: req.Timestamp, Action: req.Action}
+	}
+	if _, err := ptypes.Timestamp(e.Timestamp); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid timestamp: %v", err)
+	}
+	if err := appendPackageEvent(req.Site, req.Path, req.FoundWay, e); err != nil {
+		return nil, err
+	}
+	return &gpb.AppendPackageEventResp{}, nil
+}
+
+func (server) ReadHistory(_ context.Context, req *gpb.ReadHistoryReq) (*gpb.ReadHistoryResp, error) {
+	info, rev, err := readHistoryRev(historyOwnerRoot(req.Person), req.Site, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &gpb.ReadHistoryResp{Info: info, Revision: rev}, nil
+}
+
+func (server) UpdateHistory(_ context.Context, req *gpb.UpdateHistoryReq) (*gpb.UpdateHistoryResp, error) {
+	rev, err := updateHistory(historyOwnerRoot(req.Person), req.Site, req.Id, req.Revision, func(info *gpb.HistoryInfo) error {
+		info.Reset()
+		if req.Info != nil {
+			proto.Merge(info, req.Info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &gpb.UpdateHistoryResp{Revision: rev}, nil
+}
+
+func (server) DeleteHistory(_ context.Context, req *gpb.DeleteHistoryReq) (*gpb.DeleteHistoryResp, error) {
+	if err := deleteHistory(historyOwnerRoot(req.Person), req.Site, req.Id); err != nil {
+		return nil, err
+	}
+	return &gpb.DeleteHistoryResp{}, nil
+}
+
+// changesPollInterval is how often a following watch checks the change log
+// besides being notified, e.g. for changes made by other processes.
+const changesPollInterval = 10 * time.Second
+
+func (server) WatchChanges(req *gpb.WatchChangesReq, stream gpb.StoreService_WatchChangesServer) error {
+	last := req.FromSeq
+	for {
+		// Gets the channel before reading so that no change is missed.
+		changed := changeNotifier.wait()
+		var err error
+		last, err = forEachChange(last, func(c *gpb.Change) error {
+			return stream.Send(&gpb.WatchChangesResp{Change: c})
+		})
+		if errorsp.Cause(err) == ErrChangesCompacted {
+			return status.Errorf(codes.OutOfRange, "changes after %d have been compacted", last)
+		}
+		if err != nil || !req.Follow {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-changed:
+		case <-time.After(changesPollInterval):
+		}
+	}
+}
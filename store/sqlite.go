@@ -0,0 +1,220 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/golangplus/errors"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteBackend is a Backend storing the values in a SQLite database. Every
+// value or namespace is a row of the entries table keyed by the encoded
+// namespace it is in and its name. Namespaces have NULL values.
+//
+// The database is in WAL mode. Updates are serialized on a single connection
+// of writer, while views run on the connections of reader and do not wait
+// for each other or for the update.
+type sqliteBackend struct {
+	writer *sql.DB
+	reader *sql.DB
+}
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS entries (
+	parent TEXT NOT NULL,
+	name BLOB NOT NULL,
+	value BLOB,
+	PRIMARY KEY (parent, name)
+)`
+
+// sqliteBusyTimeout is in milliseconds.
+const sqliteBusyTimeout = 10000
+
+func openSQLite(path string, txLock string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=%d&_txlock=%s", path, sqliteBusyTimeout, txLock))
+	return db, errorsp.WithStacksAndMessage(err, "open sqlite3 %v failed", path)
+}
+
+// OpenSQLiteBackend opens, or creates, the SQLite database at path as a
+// Backend.
+func OpenSQLiteBackend(path string) (Backend, error) {
+	writer, err := openSQLite(path, "immediate")
+	if err != nil {
+		return nil, err
+	}
+	// Write transactions of SQLite are serialized anyway, a single connection
+	// avoids busy errors.
+	writer.SetMaxOpenConns(1)
+	if _, err := writer.Exec(sqliteSchema); err != nil {
+		writer.Close()
+		return nil, errorsp.WithStacksAndMessage(err, "creating table in %v failed", path)
+	}
+	reader, err := openSQLite(path, "deferred")
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	return sqliteBackend{writer: writer, reader: reader}, nil
+}
+
+func (b sqliteBackend) View(f func(Tx) error) error {
+	tx, err := b.reader.Begin()
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	defer tx.Rollback()
+	return f(sqliteTx{tx: tx})
+}
+
+func (b sqliteBackend) Update(f func(Tx) error) error {
+	tx, err := b.writer.Begin()
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	if err := f(sqliteTx{tx: tx, writable: true}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return errorsp.WithStacks(tx.Commit())
+}
+
+func (b sqliteBackend) Close() error {
+	rerr := b.reader.Close()
+	if err := b.writer.Close(); err != nil {
+		return err
+	}
+	return rerr
+}
+
+type sqliteTx struct {
+	tx       *sql.Tx
+	writable bool
+}
+
+// encodeNamespace encodes the elements of a namespace into a string, whose
+// descendants' encodings all start with it followed by a "/".
+func encodeNamespace(ns [][]byte) string {
+	var enc strings.Builder
+	for _, p := range ns {
+		enc.WriteString("/")
+		enc.WriteString(hex.EncodeToString(p))
+	}
+	return enc.String()
+}
+
+// row returns the value of the key, and whether the row exists.
+func (t sqliteTx) row(k [][]byte) (value []byte, found bool, err error) {
+	err = t.tx.QueryRow("SELECT value FROM entries WHERE parent = ? AND name = ?",
+		encodeNamespace(k[:len(k)-1]), k[len(k)-1]).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errorsp.WithStacks(err)
+	}
+	return value, true, nil
+}
+
+func (t sqliteTx) Get(k [][]byte) ([]byte, error) {
+	v, _, err := t.row(k)
+	return v, err
+}
+
+func (t sqliteTx) Put(k [][]byte, v []byte) error {
+	if !t.writable {
+		return errTxReadOnly
+	}
+	for i := 1; i < len(k); i++ {
+		value, found, err := t.row(k[:i])
+		if err != nil {
+			return err
+		}
+		if found && value != nil {
+			return errNotNamespace
+		}
+		if !found {
+			if _, err := t.tx.Exec("INSERT INTO entries (parent, name, value) VALUES (?, ?, NULL)",
+				encodeNamespace(k[:i-1]), k[i-1]); err != nil {
+				return errorsp.WithStacks(err)
+			}
+		}
+	}
+	value, found, err := t.row(k)
+	if err != nil {
+		return err
+	}
+	if found && value == nil {
+		return errNotValue
+	}
+	if v == nil {
+		v = []byte{}
+	}
+	_, err = t.tx.Exec("INSERT OR REPLACE INTO entries (parent, name, value) VALUES (?, ?, ?)",
+		encodeNamespace(k[:len(k)-1]), k[len(k)-1], v)
+	return errorsp.WithStacks(err)
+}
+
+func (t sqliteTx) Delete(k [][]byte) error {
+	if !t.writable {
+		return errTxReadOnly
+	}
+	if _, err := t.tx.Exec("DELETE FROM entries WHERE parent = ? AND name = ?",
+		encodeNamespace(k[:len(k)-1]), k[len(k)-1]); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	// Deletes the descendants if k is a namespace. "0" is right after "/".
+	enc := encodeNamespace(k)
+	_, err := t.tx.Exec("DELETE FROM entries WHERE parent = ? OR (parent >= ? AND parent < ?)",
+		enc, enc+"/", enc+"0")
+	return errorsp.WithStacks(err)
+}
+
+func (t sqliteTx) ForEach(ns [][]byte, f func(k, v []byte) error) error {
+	return t.ForEachFrom(ns, nil, f)
+}
+
+func (t sqliteTx) ForEachFrom(ns [][]byte, from []byte, f func(k, v []byte) error) error {
+	if from == nil {
+		from = []byte{}
+	}
+	rows, err := t.tx.Query("SELECT name, value FROM entries WHERE parent = ? AND name >= ? ORDER BY name",
+		encodeNamespace(ns), from)
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return forEachRow(rows, f)
+}
+
+func (t sqliteTx) ForEachBefore(ns [][]byte, before []byte, f func(k, v []byte) error) error {
+	var rows *sql.Rows
+	var err error
+	if before == nil {
+		rows, err = t.tx.Query("SELECT name, value FROM entries WHERE parent = ? ORDER BY name DESC",
+			encodeNamespace(ns))
+	} else {
+		rows, err = t.tx.Query("SELECT name, value FROM entries WHERE parent = ? AND name < ? ORDER BY name DESC",
+			encodeNamespace(ns), before)
+	}
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return forEachRow(rows, f)
+}
+
+// forEachRow calls f with the names and values of rows, and closes it.
+func forEachRow(rows *sql.Rows, f func(k, v []byte) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		var k, v []byte
+		if err := rows.Scan(&k, &v); err != nil {
+			return errorsp.WithStacks(err)
+		}
+		if err := f(k, v); err != nil {
+			return err
+		}
+	}
+	return errorsp.WithStacks(rows.Err())
+}
@@ -2,14 +2,12 @@
 package store
 
 import (
+	"bytes"
 	"log"
 	"time"
 
-	"github.com/golangplus/bytes"
 	"github.com/golangplus/errors"
 
-	"github.com/daviddengcn/bolthelper"
-	"github.com/daviddengcn/gcse/configs"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 
@@ -32,15 +30,31 @@ var (
 	//  - <site>
 	//    - <user>
 	//     - <repo> -> Repository
-	pkgsRoot    = []byte("pkgs")
-	personsRoot = []byte("persons")
-	historyRoot = []byte("history")
-	reposRoot   = []byte("repos")
+	// meta
+	//  - schema_version -> decimal version
+	// quarantine
+	//  - <key of an unreadable value> -> raw value
+	// revisions
+	//  - <key of a record> -> decimal revision
+	// events
+	//  - pkgs
+	//    - <site>
+	//      - <path>
+	//        - <8-byte big-endian unix nanoseconds> -> HistoryEvent
+	pkgsRoot       = []byte("pkgs")
+	personsRoot    = []byte("persons")
+	historyRoot    = []byte("history")
+	reposRoot      = []byte("repos")
+	metaRoot       = []byte("meta")
+	quarantineRoot = []byte("quarantine")
+	revisionsRoot  = []byte("revisions")
+	eventsRoot     = []byte("events")
+
+	schemaVersionKey = [][]byte{metaRoot, []byte("schema_version")}
 )
 
-var box = &bh.RefCountBox{
-	DataPath: configs.StoreBoltPath,
-}
+// allRoots are all the top namespaces in the store.
+var allRoots = [][]byte{pkgsRoot, personsRoot, historyRoot, reposRoot, metaRoot, quarantineRoot, revisionsRoot, changesRoot, eventsRoot}
 
 func RepoInfoAge(r *gpb.RepoInfo) time.Duration {
 	t, _ := ptypes.Timestamp(r.CrawlingTime)
@@ -49,8 +63,15 @@ func RepoInfoAge(r *gpb.RepoInfo) time.Duration {
 
 // Returns all the sites one by one by calling the provided func.
 func ForEachPackageSite(f func(string) error) error {
-	return box.View(func(tx bh.Tx) error {
-		return tx.ForEach([][]byte{pkgsRoot}, func(_ bh.Bucket, k, v bytesp.Slice) error {
+	if r := currentRemote(); r != nil {
+		return r.ForEachPackageSite(f)
+	}
+	return forEachPackageSite(f)
+}
+
+func forEachPackageSite(f func(string) error) error {
+	return view(func(tx Tx) error {
+		return tx.ForEach([][]byte{pkgsRoot}, func(k, v []byte) error {
 			if v != nil {
 				log.Printf("Unexpected value %q for key %q, ignored", string(v), string(k))
 				return nil
@@ -61,118 +82,152 @@ func ForEachPackageSite(f func(string) error) error {
 }
 
 func ForEachPackageOfSite(site string, f func(string, *gpb.PackageInfo) error) error {
-	return box.View(func(tx bh.Tx) error {
-		return tx.ForEach([][]byte{pkgsRoot, []byte(site)}, func(_ bh.Bucket, k, v bytesp.Slice) error {
-			if v == nil {
-				log.Printf("Unexpected nil value for key %q, ignored", string(k))
-				return nil
-			}
-			info := &gpb.PackageInfo{}
-			if err := errorsp.WithStacksAndMessage(proto.Unmarshal(v, info), "Unmarshal %d bytes failed", len(v)); err != nil {
-				log.Printf("Unmarshal failed: %v, ignored", err)
+	return ForEachPackageWithPrefix(site, "", f)
+}
+
+// ForEachPackageWithPrefix calls f with the packages of the site whose paths
+// start with prefix.
+func ForEachPackageWithPrefix(site, prefix string, f func(string, *gpb.PackageInfo) error) error {
+	if r := currentRemote(); r != nil {
+		return r.ForEachPackageWithPrefix(site, prefix, f)
+	}
+	return forEachPackageWithPrefix(site, prefix, f)
+}
+
+// packagesPageSize is the number of packages forEachPackageWithPrefix reads
+// in a transaction.
+var packagesPageSize = 1000
+
+// forEachPackageWithPrefix reads the packages a page at a time and calls f
+// with them after the transaction is closed, so that f can update the store.
+func forEachPackageWithPrefix(site, prefix string, f func(string, *gpb.PackageInfo) error) error {
+	type pkgEntry struct {
+		path string
+		info *gpb.PackageInfo
+	}
+	from := []byte(prefix)
+	for {
+		var page []pkgEntry
+		more := false
+		if err := view(func(tx Tx) error {
+			return tx.ForEachFrom([][]byte{pkgsRoot, []byte(site)}, from, func(k, v []byte) error {
+				if !bytes.HasPrefix(k, []byte(prefix)) {
+					return errStopIter
+				}
+				if len(page) >= packagesPageSize {
+					from, more = append([]byte{}, k...), true
+					return errStopIter
+				}
+				if v == nil {
+					log.Printf("Unexpected nil value for key %q, ignored", string(k))
+					return nil
+				}
+				info := &gpb.PackageInfo{}
+				if err := errorsp.WithStacksAndMessage(proto.Unmarshal(v, info), "Unmarshal %d bytes failed", len(v)); err != nil {
+					log.Printf("Unmarshal failed: %v, ignored", err)
+					return nil
+				}
+				page = append(page, pkgEntry{path: string(k), info: info})
 				return nil
+			})
+		}); err != nil && err != errStopIter {
+			return err
+		}
+		for _, e := range page {
+			if err := f(e.path, e.info); err != nil {
+				return errorsp.WithStacks(err)
 			}
-			return errorsp.WithStacks(f(string(k), info))
-		})
-	})
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+func packageKey(site, path string) [][]byte {
+	return [][]byte{pkgsRoot, []byte(site), []byte(path)}
 }
 
 // Returns an empty (non-nil) PackageInfo if not found.
 func ReadPackage(site, path string) (*gpb.PackageInfo, error) {
+	if r := currentRemote(); r != nil {
+		return r.ReadPackage(site, path)
+	}
+	info, _, err := readPackage(site, path)
+	return info, err
+}
+
+// readPackage returns the package with its revision.
+func readPackage(site, path string) (*gpb.PackageInfo, int64, error) {
 	info := &gpb.PackageInfo{}
-	if err := box.View(func(tx bh.Tx) error {
-		return tx.Value([][]byte{pkgsRoot, []byte(site), []byte(path)}, func(bs bytesp.Slice) error {
-			if err := errorsp.WithStacksAndMessage(proto.Unmarshal(bs, info), "Unmarshal %d bytes failed", len(bs)); err != nil {
-				log.Printf("Unmarshal failed: %v", err)
-				*info = gpb.PackageInfo{}
-			}
-			return nil
-		})
-	}); err != nil {
-		return nil, err
+	rev, err := readMessage(packageKey(site, path), info)
+	if err != nil {
+		return nil, 0, err
 	}
-	return info, nil
+	return info, rev, nil
 }
 
 func UpdatePackage(site, path string, f func(*gpb.PackageInfo) error) error {
-	return box.Update(func(tx bh.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([][]byte{pkgsRoot, []byte(site)})
-		if err != nil {
-			return err
-		}
-		info := &gpb.PackageInfo{}
-		if err := b.Value([][]byte{[]byte(path)}, func(bs bytesp.Slice) error {
-			if err := errorsp.WithStacksAndMessage(proto.Unmarshal(bs, info), "Unmarshal %d bytes", len(bs)); err != nil {
-				log.Printf("Unmarshaling failed: %v", err)
-				*info = gpb.PackageInfo{}
-			}
-			return nil
-		}); err != nil {
-			return err
-		}
-		if err := errorsp.WithStacks(f(info)); err != nil {
-			return err
-		}
-		bs, err := proto.Marshal(info)
-		if err != nil {
-			return errorsp.WithStacksAndMessage(err, "marshaling %v failed: %v", info, err)
-		}
-		return b.Put([][]byte{[]byte(path)}, bs)
+	if r := currentRemote(); r != nil {
+		return r.UpdatePackage(site, path, f)
+	}
+	_, err := updatePackage(site, path, anyRevision, f)
+	return err
+}
+
+func updatePackage(site, path string, rev int64, f func(*gpb.PackageInfo) error) (int64, error) {
+	info := &gpb.PackageInfo{}
+	return updateMessage(packageKey(site, path), info, rev, func() error {
+		return f(info)
 	})
 }
 
 func DeletePackage(site, path string) error {
-	return box.Update(func(tx bh.Tx) error {
-		return tx.Delete([][]byte{pkgsRoot, []byte(site), []byte(path)})
-	})
+	if r := currentRemote(); r != nil {
+		return r.DeletePackage(site, path)
+	}
+	return deleteMessage(packageKey(site, path))
+}
+
+func personKey(site, id string) [][]byte {
+	return [][]byte{personsRoot, []byte(site), []byte(id)}
 }
 
 func ReadPerson(site, id string) (*gpb.PersonInfo, error) {
+	if r := currentRemote(); r != nil {
+		return r.ReadPerson(site, id)
+	}
+	info, _, err := readPerson(site, id)
+	return info, err
+}
+
+func readPerson(site, id string) (*gpb.PersonInfo, int64, error) {
 	info := &gpb.PersonInfo{}
-	if err := box.View(func(tx bh.Tx) error {
-		return tx.Value([][]byte{personsRoot, []byte(site), []byte(id)}, func(bs bytesp.Slice) error {
-			if err := errorsp.WithStacksAndMessage(proto.Unmarshal(bs, info), "Unmarshal %d bytes failed", len(bs)); err != nil {
-				log.Printf("Unmarshal failed: %v", err)
-				*info = gpb.PersonInfo{}
-			}
-			return nil
-		})
-	}); err != nil {
-		return nil, err
+	rev, err := readMessage(personKey(site, id), info)
+	if err != nil {
+		return nil, 0, err
 	}
-	return info, nil
+	return info, rev, nil
 }
 
 func UpdatePerson(site, id string, f func(*gpb.PersonInfo) error) error {
-	return box.Update(func(tx bh.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([][]byte{personsRoot, []byte(site)})
-		if err != nil {
-			return err
-		}
-		info := &gpb.PersonInfo{}
-		if err := b.Value([][]byte{[]byte(id)}, func(bs bytesp.Slice) error {
-			err := errorsp.WithStacksAndMessage(proto.Unmarshal(bs, info), "Unmarshal %d bytes", len(bs))
-			if err != nil {
-				log.Printf("Unmarshaling failed: %v", err)
-				*info = gpb.PersonInfo{}
-			}
-			return nil
-		}); err != nil {
-			return err
-		}
-		if err := errorsp.WithStacks(f(info)); err != nil {
-			return err
-		}
-		bs, err := proto.Marshal(info)
-		if err != nil {
-			return errorsp.WithStacksAndMessage(err, "marshaling %v failed: %v", info, err)
-		}
-		return b.Put([][]byte{[]byte(id)}, bs)
+	if r := currentRemote(); r != nil {
+		return r.UpdatePerson(site, id, f)
+	}
+	_, err := updatePerson(site, id, anyRevision, f)
+	return err
+}
+
+func updatePerson(site, id string, rev int64, f func(*gpb.PersonInfo) error) (int64, error) {
+	info := &gpb.PersonInfo{}
+	return updateMessage(personKey(site, id), info, rev, func() error {
+		return f(info)
 	})
 }
 
 func DeletePerson(site, id string) error {
-	return box.Update(func(tx bh.Tx) error {
-		return tx.Delete([][]byte{personsRoot, []byte(site), []byte(id)})
-	})
+	if r := currentRemote(); r != nil {
+		return r.DeletePerson(site, id)
+	}
+	return deleteMessage(personKey(site, id))
 }
@@ -19,8 +19,20 @@ func init() {
 	}
 }
 
+// testingBackend is the name of the backend cleanDatabase opens.
+var testingBackend = BoltBackendName
+
+// cleanDatabase replaces the backend in use with an empty one of
+// testingBackend.
 func cleanDatabase(t *testing.T) {
+	if old := UseBackend(nil); old != nil {
+		assert.NoErrorOrDie(t, old.Close())
+	}
 	assert.NoErrorOrDie(t, os.RemoveAll(configs.StoreBoltPath()))
+	assert.NoErrorOrDie(t, os.RemoveAll(configs.StoreSQLitePath()))
+	b, err := OpenBackend(testingBackend)
+	assert.NoErrorOrDie(t, err)
+	UseBackend(b)
 }
 
 func TestRepoInfoAge(t *testing.T) {
@@ -0,0 +1,347 @@
+package gcse
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/go-villa"
+)
+
+// typedDBShards is the number of shards of a TypedDB.
+const typedDBShards = 64
+
+type typedShard[V any] struct {
+	sync.RWMutex
+	m map[string]V
+}
+
+// TypedDB is an in-memory key-value DB of values of type V. Keys are sharded
+// by hash, each shard with its own lock, so that writers of different shards
+// do not block each other.
+//
+// It is persisted in the same format as MemDB, i.e. a gob snapshot of a
+// map[string]interface{} plus a WAL, so that the files of one can be loaded
+// by the other. V has to be registered to gob if it is not a builtin type.
+type TypedDB[V any] struct {
+	shards [typedDBShards]typedShard[V]
+	fn     villa.Path
+
+	// Locked after a shard. Guards the fields below.
+	walMu        sync.Mutex
+	wal          *walLog
+	lastModified time.Time
+	modified     bool
+	snapshotSize int64
+
+	// Serializes Sync and Checkpoint.
+	syncMu sync.Mutex
+}
+
+// NewTypedDB returns a TypedDB loaded from <root>/<kind>.gob. The DB is not
+// persisted if root is empty. A failed loading is logged and an empty DB is
+// returned.
+func NewTypedDB[V any](root villa.Path, kind string) *TypedDB[V] {
+	db, err := OpenTypedDB[V](root, kind)
+	if err != nil {
+		log.Printf("Load TypedDB %s failed: %v", kind, err)
+	}
+	return db
+}
+
+// OpenTypedDB is the same as NewTypedDB but also returns the error of the
+// loading, with the DB, which is empty in that case.
+func OpenTypedDB[V any](root villa.Path, kind string) (*TypedDB[V], error) {
+	db := &TypedDB[V]{}
+	for i := range db.shards {
+		db.shards[i].m = make(map[string]V)
+	}
+	if root == "" {
+		return db, nil
+	}
+	if err := root.MkdirAll(0755); err != nil {
+		log.Printf("MkdirAll failed: %v", err)
+	}
+	db.fn = root.Join(kind + ".gob")
+	return db, db.Load()
+}
+
+func (db *TypedDB[V]) shard(key string) *typedShard[V] {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &db.shards[h.Sum32()%typedDBShards]
+}
+
+// lockAll locks all the shards for reading, in order.
+func (db *TypedDB[V]) lockAll() {
+	for i := range db.shards {
+		db.shards[i].RLock()
+	}
+}
+
+func (db *TypedDB[V]) unlockAll() {
+	for i := range db.shards {
+		db.shards[i].RUnlock()
+	}
+}
+
+func (db *TypedDB[V]) Modified() bool {
+	db.walMu.Lock()
+	defer db.walMu.Unlock()
+
+	return db.modified
+}
+
+func (db *TypedDB[V]) LastModified() time.Time {
+	db.walMu.Lock()
+	defer db.walMu.Unlock()
+
+	return db.lastModified
+}
+
+// Load loads the snapshot and replays the WAL. Values which are not of type
+// V fail the loading.
+func (db *TypedDB[V]) Load() error {
+	if db.fn == "" {
+		return nil
+	}
+	for i := range db.shards {
+		db.shards[i].Lock()
+		defer db.shards[i].Unlock()
+	}
+	db.walMu.Lock()
+	defer db.walMu.Unlock()
+
+	if db.wal != nil {
+		db.wal.close()
+		db.wal = nil
+	}
+	for i := range db.shards {
+		db.shards[i].m = make(map[string]V)
+	}
+
+	var m map[string]interface{}
+	size, lastModified, err := loadSnapshot(db.fn, &m)
+	if err != nil {
+		return err
+	}
+	if lastModified.IsZero() {
+		lastModified = time.Now()
+	}
+	for k, v := range m {
+		tv, ok := v.(V)
+		if !ok {
+			return errorsp.NewWithStacks("value of %q in %v is a %T, not a %T", k, db.fn, v, tv)
+		}
+		db.shard(k).m[k] = tv
+	}
+	db.snapshotSize = size
+
+	var walErr error
+	wal, walModified, err := openWALLog(db.fn+".wal", func(r walRecord) {
+		if r.Delete {
+			delete(db.shard(r.Key).m, r.Key)
+			return
+		}
+		tv, ok := r.Value.(V)
+		if !ok {
+			if walErr == nil {
+				walErr = errorsp.NewWithStacks("value of %q in %v.wal is a %T, not a %T", r.Key, db.fn, r.Value, tv)
+			}
+			return
+		}
+		db.shard(r.Key).m[r.Key] = tv
+	})
+	if err != nil {
+		return err
+	}
+	if walErr != nil {
+		wal.close()
+		return walErr
+	}
+	db.wal = wal
+	if walModified.After(lastModified) {
+		lastModified = walModified
+	}
+
+	db.lastModified = lastModified
+	db.modified = false
+	return nil
+}
+
+// Get returns the value of key, and false if not found.
+func (db *TypedDB[V]) Get(key string) (V, bool) {
+	s := db.shard(key)
+	s.RLock()
+	defer s.RUnlock()
+
+	v, ok := s.m[key]
+	return v, ok
+}
+
+// logChange is called with the lock of the shard of r.Key held, so that the
+// records of a key are in the WAL in the same order as they are applied.
+func (db *TypedDB[V]) logChange(r walRecord) {
+	db.walMu.Lock()
+	defer db.walMu.Unlock()
+
+	if db.wal != nil {
+		db.wal.append(r)
+	}
+	db.lastModified = time.Now()
+	db.modified = true
+}
+
+func (db *TypedDB[V]) Put(key string, v V) {
+	s := db.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	s.m[key] = v
+	db.logChange(walRecord{Key: key, Value: v})
+}
+
+func (db *TypedDB[V]) Delete(key string) {
+	s := db.shard(key)
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.m, key)
+	db.logChange(walRecord{Key: key, Delete: true})
+}
+
+// Iterate calls output with all the entries. The entries of each shard are
+// copied under its lock, and output is called without holding any lock, so
+// it can modify the DB. Changes made during the iteration may or may not be
+// seen.
+func (db *TypedDB[V]) Iterate(output func(key string, v V) error) error {
+	type entry struct {
+		key string
+		v   V
+	}
+	var entries []entry
+	for i := range db.shards {
+		s := &db.shards[i]
+		s.RLock()
+		entries = entries[:0]
+		for k, v := range s.m {
+			entries = append(entries, entry{k, v})
+		}
+		s.RUnlock()
+
+		for _, e := range entries {
+			if err := output(e.key, e.v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Count returns the number of entries in the DB.
+func (db *TypedDB[V]) Count() int {
+	cnt := 0
+	for i := range db.shards {
+		s := &db.shards[i]
+		s.RLock()
+		cnt += len(s.m)
+		s.RUnlock()
+	}
+	return cnt
+}
+
+// Sync makes the modifications durable, usually by fsyncing the WAL only.
+// See MemDB.Sync.
+func (db *TypedDB[V]) Sync() error {
+	if db.fn == "" {
+		// this db is not for syncing
+		return nil
+	}
+	db.syncMu.Lock()
+	defer db.syncMu.Unlock()
+
+	db.walMu.Lock()
+	if !db.modified {
+		db.walMu.Unlock()
+		return nil
+	}
+	if db.wal != nil && !db.wal.shouldCheckpoint(db.snapshotSize) {
+		err := db.wal.sync()
+		if err == nil {
+			db.modified = false
+			db.walMu.Unlock()
+			return nil
+		}
+		log.Printf("Syncing %v failed, falling back to a checkpoint: %v", db.wal.fn, err)
+	}
+	db.walMu.Unlock()
+
+	return db.checkpoint()
+}
+
+// Checkpoint saves a snapshot of the DB and truncates the WAL.
+func (db *TypedDB[V]) Checkpoint() error {
+	if db.fn == "" {
+		return nil
+	}
+	db.syncMu.Lock()
+	defer db.syncMu.Unlock()
+
+	return db.checkpoint()
+}
+
+// Close closes the WAL. See MemDB.Close.
+func (db *TypedDB[V]) Close() error {
+	db.syncMu.Lock()
+	defer db.syncMu.Unlock()
+	db.walMu.Lock()
+	defer db.walMu.Unlock()
+
+	if db.wal == nil {
+		return nil
+	}
+	err := db.wal.close()
+	db.wal = nil
+	return err
+}
+
+// checkpoint is called with syncMu held. Writers are blocked while the
+// snapshot is saved; readers are not.
+func (db *TypedDB[V]) checkpoint() error {
+	db.lockAll()
+	defer db.unlockAll()
+
+	db.walMu.Lock()
+	if db.wal != nil {
+		// Fails before saving the snapshot if another process writes the WAL.
+		if err := db.wal.openWriter(); err != nil {
+			db.walMu.Unlock()
+			return err
+		}
+	}
+	db.walMu.Unlock()
+
+	m := make(map[string]interface{})
+	for i := range db.shards {
+		for k, v := range db.shards[i].m {
+			m[k] = v
+		}
+	}
+	size, err := saveSnapshot(db.fn, m)
+	if err != nil {
+		return err
+	}
+
+	db.walMu.Lock()
+	defer db.walMu.Unlock()
+
+	db.snapshotSize = size
+	db.modified = false
+	if db.wal == nil {
+		return nil
+	}
+	return db.wal.truncate()
+}
@@ -0,0 +1,134 @@
+package gcse
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func typedDBContents(db *TypedDB[CrawlingEntry]) map[string]CrawlingEntry {
+	m := make(map[string]CrawlingEntry)
+	db.Iterate(func(k string, v CrawlingEntry) error {
+		m[k] = v
+		return nil
+	})
+	return m
+}
+
+func TestTypedDB(t *testing.T) {
+	db := NewTypedDB[int]("", "")
+	_, ok := db.Get("a")
+	assert.False(t, "ok", ok)
+
+	for i := 0; i < 1000; i++ {
+		db.Put(fmt.Sprint(i), i)
+	}
+	db.Delete("10")
+	assert.Equal(t, "Count", db.Count(), 999)
+	v, ok := db.Get("20")
+	assert.True(t, "ok", ok)
+	assert.Equal(t, "v", v, 20)
+
+	// Iterate does not hold the locks, so the DB can be modified in it.
+	sum := 0
+	assert.NoError(t, db.Iterate(func(k string, v int) error {
+		sum += v
+		db.Delete(k)
+		return nil
+	}))
+	assert.Equal(t, "sum", sum, 999*1000/2-10)
+	assert.Equal(t, "Count", db.Count(), 0)
+}
+
+func TestTypedDB_Concurrent(t *testing.T) {
+	root := newTestMemDBRoot(t, "TestTypedDB_Concurrent")
+	defer root.RemoveAll()
+
+	db := NewTypedDB[int](root, "db")
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				db.Put(fmt.Sprintf("%d-%d", w, i), i)
+				if i%50 == 0 {
+					assert.NoError(t, db.Sync())
+				}
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			db.Iterate(func(string, int) error { return nil })
+		}
+	}()
+	wg.Wait()
+	assert.NoError(t, db.Sync())
+
+	loaded := NewTypedDB[int](root, "db")
+	assert.Equal(t, "Count", loaded.Count(), 8*200)
+	v, ok := loaded.Get("7-199")
+	assert.True(t, "ok", ok)
+	assert.Equal(t, "v", v, 199)
+}
+
+func TestTypedDB_Persistence(t *testing.T) {
+	root := newTestMemDBRoot(t, "TestTypedDB_Persistence")
+	defer root.RemoveAll()
+
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	db := NewTypedDB[CrawlingEntry](root, "db")
+	db.Put("a", CrawlingEntry{ScheduleTime: tm, Version: 1})
+	assert.True(t, "Modified", db.Modified())
+	assert.NoError(t, db.Sync())
+	assert.False(t, "Modified", db.Modified())
+	db.Put("b", CrawlingEntry{Etag: "x"})
+	db.Delete("a")
+	assert.NoError(t, db.Sync())
+
+	expected := map[string]CrawlingEntry{"b": {Etag: "x"}}
+	assert.Equal(t, "contents", typedDBContents(NewTypedDB[CrawlingEntry](root, "db")), expected)
+
+	assert.NoError(t, db.Checkpoint())
+	assert.Equal(t, "wal.size", db.wal.size, int64(0))
+	assert.Equal(t, "contents", typedDBContents(NewTypedDB[CrawlingEntry](root, "db")), expected)
+}
+
+func TestTypedDB_MemDBFormat(t *testing.T) {
+	root := newTestMemDBRoot(t, "TestTypedDB_MemDBFormat")
+	defer root.RemoveAll()
+
+	// Written by MemDB, in both the snapshot and the WAL.
+	mdb := NewMemDB(root, "db")
+	mdb.Put("a", CrawlingEntry{Etag: "a"})
+	assert.NoError(t, mdb.Sync())
+	mdb.Put("b", CrawlingEntry{Etag: "b"})
+	assert.NoError(t, mdb.Sync())
+	// Only one writer may have the WAL open.
+	assert.NoError(t, mdb.Close())
+
+	db := NewTypedDB[CrawlingEntry](root, "db")
+	assert.Equal(t, "contents", typedDBContents(db), map[string]CrawlingEntry{
+		"a": {Etag: "a"},
+		"b": {Etag: "b"},
+	})
+
+	// And read back by MemDB.
+	db.Put("c", CrawlingEntry{Etag: "c"})
+	assert.NoError(t, db.Sync())
+	var ent CrawlingEntry
+	assert.True(t, "Get", NewMemDB(root, "db").Get("c", &ent))
+	assert.Equal(t, "ent", ent, CrawlingEntry{Etag: "c"})
+
+	// Values of other types fail the loading.
+	mdb = NewMemDB(root, "wrong")
+	mdb.Put("a", 1)
+	assert.NoError(t, mdb.Sync())
+	assert.Error(t, NewTypedDB[CrawlingEntry](root, "wrong").Load())
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"sync"
+
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/mr"
+)
+
+// semaphore limits the number of the holders.
+type semaphore chan struct{}
+
+// acquire acquires the semaphore and returns a function releasing it once.
+func (s semaphore) acquire() func() {
+	s <- struct{}{}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-s })
+	}
+}
+
+// LimitInputs returns the inputs with at most workers partitions of them
+// iterated concurrently, i.e. the number of the concurrent mappers of an MR
+// job. ins is returned if workers is not positive.
+func LimitInputs(ins []mr.Input, workers int) []mr.Input {
+	if workers <= 0 {
+		return ins
+	}
+	sem := make(semaphore, workers)
+	res := make([]mr.Input, len(ins))
+	for i, in := range ins {
+		in := in
+		res[i] = &mr.InputStruct{
+			PartCountF: in.PartCount,
+			IteratorF: func(part int) (sophie.IterateCloser, error) {
+				release := sem.acquire()
+				it, err := in.Iterator(part)
+				if err != nil {
+					release()
+					return nil, err
+				}
+				return &sophie.IterateCloserStruct{
+					NextF: it.Next,
+					CloseF: func() error {
+						defer release()
+						return it.Close()
+					},
+				}, nil
+			},
+		}
+	}
+	return res
+}
+
+// limitedOutput is an mr.Output with the collectors of at most cap(sem)
+// partitions open concurrently.
+type limitedOutput struct {
+	mr.Output
+	sem semaphore
+}
+
+func (o limitedOutput) Collector(part int) (sophie.CollectCloser, error) {
+	release := o.sem.acquire()
+	c, err := o.Output.Collector(part)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return sophie.CollectCloserStruct{
+		CollectF: c.Collect,
+		CloseF: func() error {
+			defer release()
+			return c.Close()
+		},
+	}, nil
+}
+
+// LimitOutputs returns the outputs with at most workers partitions of each
+// collected concurrently, i.e. the number of the concurrent reducers of an MR
+// job. outs is returned if workers is not positive.
+func LimitOutputs(outs []mr.Output, workers int) []mr.Output {
+	if workers <= 0 {
+		return outs
+	}
+	res := make([]mr.Output, len(outs))
+	for i, out := range outs {
+		res[i] = limitedOutput{Output: out, sem: make(semaphore, workers)}
+	}
+	return res
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/mr"
+)
+
+func TestLimitInputs(t *testing.T) {
+	const parts, workers = 10, 3
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	in := &mr.InputStruct{
+		PartCountF: func() (int, error) {
+			return parts, nil
+		},
+		IteratorF: func(part int) (sophie.IterateCloser, error) {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			return &sophie.IterateCloserStruct{
+				NextF: func(key, val sophie.SophieReader) error {
+					return io.EOF
+				},
+				CloseF: func() error {
+					mu.Lock()
+					running--
+					mu.Unlock()
+					return nil
+				},
+			}, nil
+		},
+	}
+	ins := LimitInputs([]mr.Input{in}, workers)
+	cnt, err := ins[0].PartCount()
+	assert.NoError(t, err)
+	assert.Equal(t, "cnt", cnt, parts)
+
+	var wg sync.WaitGroup
+	for part := 0; part < parts; part++ {
+		wg.Add(1)
+		go func(part int) {
+			defer wg.Done()
+			it, err := ins[0].Iterator(part)
+			assert.NoError(t, err)
+			assert.Equal(t, "it.Next", it.Next(nil, nil), io.EOF)
+			assert.NoError(t, it.Close())
+		}(part)
+	}
+	wg.Wait()
+	assert.True(t, "maxRunning <= workers", maxRunning <= workers)
+	assert.Equal(t, "running", running, 0)
+
+	assert.Equal(t, "LimitInputs", LimitInputs([]mr.Input{in}, 0)[0], mr.Input(in))
+}
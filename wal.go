@@ -0,0 +1,341 @@
+package gcse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/go-villa"
+)
+
+// walRecord is a Put, or a Delete if Delete is true, in the write-ahead log of
+// a MemDB or a TypedDB.
+type walRecord struct {
+	Key    string
+	Value  interface{}
+	Delete bool
+}
+
+// WAL file format: a sequence of frames, each of which is
+//   - 4-byte big-endian length of the payload
+//   - 4-byte big-endian CRC-32 (IEEE) of the payload
+//   - payload: a walRecord encoded by a new gob.Encoder
+//
+// Every payload is a self-contained gob stream so that records appended by
+// different processes can be decoded one by one.
+const walHeaderSize = 8
+
+// walMaxRecordSize is the maximum size of a payload. A larger length is
+// treated as a corrupted frame instead of being allocated.
+const walMaxRecordSize = 1 << 28
+
+// encodeWALRecord returns the frame of a record.
+func encodeWALRecord(r walRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, walHeaderSize))
+	if err := gob.NewEncoder(&buf).Encode(r); err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "encoding WAL record of %q failed", r.Key)
+	}
+	bs := buf.Bytes()
+	payload := bs[walHeaderSize:]
+	binary.BigEndian.PutUint32(bs[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(bs[4:8], crc32.ChecksumIEEE(payload))
+	return bs, nil
+}
+
+// replayWAL calls f with the records in r in order, and returns the size of
+// the complete records. A torn or corrupted frame, e.g. written partially
+// before a crash, ends the log and is not an error.
+func replayWAL(r io.Reader, f func(walRecord)) (int64, error) {
+	br := bufio.NewReader(r)
+	var good int64
+	var header [walHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return good, nil
+			}
+			return good, errorsp.WithStacks(err)
+		}
+		size := binary.BigEndian.Uint32(header[0:4])
+		if size > walMaxRecordSize {
+			return good, nil
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return good, nil
+			}
+			return good, errorsp.WithStacks(err)
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			return good, nil
+		}
+		var rec walRecord
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+			return good, nil
+		}
+		f(rec)
+		good += int64(walHeaderSize + len(payload))
+	}
+}
+
+// walMinCheckpointSize is the WAL size below which Sync never checkpoints.
+// Above it, Sync checkpoints once the WAL is larger than the snapshot, so
+// that replaying costs at most as much as loading the snapshot.
+const walMinCheckpointSize = 4 << 20
+
+// errWALLocked is returned when opening a WAL for writing while another
+// writer has it open.
+var errWALLocked = errors.New("WAL is being written by another process")
+
+// walLog is an opened WAL file. It is read-only until the first write, when
+// the file is opened for appending under an exclusive lock, so that loading
+// a DB, e.g. in a tool, never changes the WAL of a running writer.
+type walLog struct {
+	fn villa.Path
+	// Nil until opened for writing.
+	f   *os.File
+	buf *bufio.Writer
+	// Size of the WAL including the buffered records.
+	size int64
+	// The error of the last failed append, which forces a checkpoint.
+	err error
+}
+
+// openWALLog replays the WAL at fn with apply. Torn records at the end are
+// ignored, and truncated only when the WAL is opened for writing. It also
+// returns the modification time of the WAL, zero if it is empty.
+func openWALLog(fn villa.Path, apply func(walRecord)) (*walLog, time.Time, error) {
+	f, err := os.Open(fn.S())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &walLog{fn: fn}, time.Time{}, nil
+		}
+		return nil, time.Time{}, errorsp.WithStacks(err)
+	}
+	defer f.Close()
+
+	cnt := 0
+	good, err := replayWAL(f, func(r walRecord) {
+		apply(r)
+		cnt++
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, errorsp.WithStacks(err)
+	}
+	if st.Size() > good {
+		log.Printf("Ignoring %d bytes of torn records at the end of %v", st.Size()-good, fn)
+	}
+	if cnt > 0 {
+		log.Printf("%d records replayed from %v", cnt, fn)
+	}
+	l := &walLog{fn: fn, size: good}
+	if good == 0 {
+		return l, time.Time{}, nil
+	}
+	return l, st.ModTime(), nil
+}
+
+// openWriter opens the WAL for appending if not yet, and truncates the torn
+// records at the end. errWALLocked is returned if another writer has it open.
+func (l *walLog) openWriter() error {
+	if l.f != nil {
+		return nil
+	}
+	f, err := os.OpenFile(l.fn.S(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	if err := lockWAL(f); err != nil {
+		f.Close()
+		return err
+	}
+	// Scans again under the lock since the WAL may have changed since it was
+	// replayed.
+	good, err := replayWAL(f, func(walRecord) {})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errorsp.WithStacks(err)
+	}
+	if st.Size() > good {
+		log.Printf("Truncating %d bytes of torn records at the end of %v", st.Size()-good, l.fn)
+		if err := f.Truncate(good); err != nil {
+			f.Close()
+			return errorsp.WithStacks(err)
+		}
+	}
+	if _, err := f.Seek(good, io.SeekStart); err != nil {
+		f.Close()
+		return errorsp.WithStacks(err)
+	}
+	l.f, l.buf, l.size = f, bufio.NewWriter(f), good
+	return nil
+}
+
+// append appends a record to the buffer. Failures are kept in l.err so that
+// the next sync saves a full snapshot instead.
+func (l *walLog) append(r walRecord) {
+	if l.err != nil {
+		return
+	}
+	err := l.openWriter()
+	var bs []byte
+	if err == nil {
+		bs, err = encodeWALRecord(r)
+	}
+	if err == nil {
+		_, err = l.buf.Write(bs)
+	}
+	if err != nil {
+		log.Printf("Appending to %v failed, falling back to a checkpoint: %v", l.fn, err)
+		l.err = err
+		return
+	}
+	l.size += int64(len(bs))
+}
+
+// shouldCheckpoint returns whether a snapshot should be saved instead of
+// syncing the WAL.
+func (l *walLog) shouldCheckpoint(snapshotSize int64) bool {
+	return l.err != nil || snapshotSize == 0 || l.size >= walMinCheckpointSize && l.size > snapshotSize
+}
+
+// sync flushes the buffer and fsyncs the WAL.
+func (l *walLog) sync() error {
+	if l.f == nil {
+		// Nothing has been appended.
+		return nil
+	}
+	if err := l.buf.Flush(); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return errorsp.WithStacks(l.f.Sync())
+}
+
+// truncate drops all the records, called after a snapshot is saved.
+func (l *walLog) truncate() error {
+	if err := l.openWriter(); err != nil {
+		return err
+	}
+	l.buf.Reset(l.f)
+	if err := l.f.Truncate(0); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	if _, err := l.f.Seek(0, io.SeekStart); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	l.size, l.err = 0, nil
+	return errorsp.WithStacks(l.f.Sync())
+}
+
+// close closes the WAL, releasing the lock if opened for writing, and drops
+// the records not flushed.
+func (l *walLog) close() error {
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f, l.buf = nil, nil
+	return errorsp.WithStacks(err)
+}
+
+// loadSnapshot decodes the gob snapshot at fn into m, and returns its size and
+// modification time, zeros if not found.
+func loadSnapshot(fn villa.Path, m *map[string]interface{}) (int64, time.Time, error) {
+	var size int64
+	var modTime time.Time
+	if st, err := fn.Stat(); err == nil {
+		size, modTime = st.Size(), st.ModTime()
+	}
+
+	f, err := fn.Open()
+	if os.IsNotExist(err) {
+		// try recover from fn.new, saved by an older version which removed fn
+		// before renaming.
+		f, err = (fn + ".new").Open()
+		if os.IsNotExist(err) {
+			// just an empty db
+			return 0, time.Time{}, nil
+		}
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer f.Close()
+
+	if err := gob.NewDecoder(f).Decode(m); err != nil {
+		return 0, time.Time{}, err
+	}
+	return size, modTime, nil
+}
+
+// safeSave saves to fn.new, fsyncs it and renames it to fn, so that fn is
+// always either the complete old file or the complete new one.
+func safeSave(fn villa.Path, doSave func(w io.Writer) error) error {
+	tmpFn := fn + ".new"
+	if err := func() error {
+		f, err := tmpFn.Create()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := doSave(f); err != nil {
+			return err
+		}
+		return f.Sync()
+	}(); err != nil {
+		return err
+	}
+
+	if err := tmpFn.Rename(fn); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(fn.S()))
+}
+
+// saveSnapshot saves m as a gob snapshot at fn and returns its size.
+func saveSnapshot(fn villa.Path, m map[string]interface{}) (int64, error) {
+	if err := safeSave(fn, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(m)
+	}); err != nil {
+		return 0, err
+	}
+	st, err := fn.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
+
+// syncDir fsyncs a directory so that renames in it are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// Some platforms do not support syncing directories.
+	d.Sync()
+	return nil
+}
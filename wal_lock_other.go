@@ -0,0 +1,11 @@
+//go:build windows || plan9
+
+package gcse
+
+import "os"
+
+// lockWAL does nothing since flock is not available. Only one writer may
+// have a WAL open at a time.
+func lockWAL(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !windows && !plan9
+
+package gcse
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/golangplus/errors"
+)
+
+// lockWAL takes an exclusive lock of the WAL opened as f, which is released
+// when f is closed. errWALLocked is returned if it is held by others.
+func lockWAL(f *os.File) error {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return errWALLocked
+		}
+		return errorsp.WithStacks(err)
+	}
+	return nil
+}